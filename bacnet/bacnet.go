@@ -0,0 +1,419 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package bacnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	// Port is the UDP port of BACnet/IP
+	Port = 47808
+
+	// maximum number of object identifiers collected from a service request
+	maxObjects = 100
+)
+
+var (
+	// ErrInvalidBVLC is returned if the datagram does not start with a BACnet/IP virtual link control header.
+	ErrInvalidBVLC = errors.New("bacnet: invalid BVLC header")
+
+	// ErrTruncated is returned if a header ends prematurely.
+	ErrTruncated = errors.New("bacnet: truncated data")
+)
+
+// BVLC functions.
+const (
+	BVLCResult                       = 0x00
+	BVLCForwardedNPDU                = 0x04
+	BVLCDistributeBroadcastToNetwork = 0x09
+	BVLCOriginalUnicastNPDU          = 0x0a
+	BVLCOriginalBroadcastNPDU        = 0x0b
+)
+
+// APDU types.
+const (
+	ConfirmedRequest   = 0
+	UnconfirmedRequest = 1
+	SimpleACK          = 2
+	ComplexACK         = 3
+	SegmentACK         = 4
+	Error              = 5
+	Reject             = 6
+	Abort              = 7
+)
+
+// Packet is a BACnet/IP datagram.
+type Packet struct {
+	BVLCFunction uint8
+
+	// address of the original sender of a forwarded NPDU
+	ForwardedAddress string
+
+	// NPDU is nil for BVLC functions without NPDU
+	NPDU *NPDU
+
+	// APDU is nil for network layer messages
+	APDU *APDU
+}
+
+// NPDU is the network layer header.
+type NPDU struct {
+	Control        uint8
+	Priority       uint8
+	ExpectingReply bool
+
+	// remote destination and source network and MAC address
+	DNET uint16
+	DADR []byte
+	SNET uint16
+	SADR []byte
+
+	HopCount uint8
+
+	// type of network layer messages
+	NetworkMessage     bool
+	NetworkMessageType uint8
+}
+
+// APDU is the application layer header.
+type APDU struct {
+	Type      uint8
+	Segmented bool
+	InvokeID  uint8
+
+	// service choice of requests and acknowledgements
+	ServiceChoice uint8
+
+	// reason of reject and abort PDUs
+	Reason uint8
+
+	// error class and code of error PDUs
+	ErrorClass uint32
+	ErrorCode  uint32
+
+	Objects []ObjectIdentifier
+}
+
+// ObjectIdentifier identifies an object of a BACnet device.
+type ObjectIdentifier struct {
+	Type     uint16
+	Instance uint32
+}
+
+// String returns the object type name and instance, e.g. analog-input:1.
+func (o ObjectIdentifier) String() string {
+	return ObjectTypeName(o.Type) + ":" + strconv.Itoa(int(o.Instance))
+}
+
+// Parse decodes a BACnet/IP datagram.
+func Parse(data []byte) (*Packet, error) {
+	if len(data) < 4 {
+		return nil, ErrTruncated
+	}
+	if data[0] != 0x81 {
+		return nil, ErrInvalidBVLC
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < 4 {
+		return nil, ErrInvalidBVLC
+	}
+	if length < len(data) {
+		data = data[:length]
+	}
+	p := &Packet{BVLCFunction: data[1]}
+	data = data[4:]
+
+	switch p.BVLCFunction {
+	case BVLCForwardedNPDU:
+		if len(data) < 6 {
+			return nil, ErrTruncated
+		}
+		p.ForwardedAddress = net.JoinHostPort(net.IP(data[:4]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(data[4:6]))))
+		data = data[6:]
+	case BVLCDistributeBroadcastToNetwork, BVLCOriginalUnicastNPDU, BVLCOriginalBroadcastNPDU:
+	default:
+		return p, nil
+	}
+
+	n, rest, err := parseNPDU(data)
+	if err != nil {
+		return p, err
+	}
+	p.NPDU = n
+	if n.NetworkMessage {
+		return p, nil
+	}
+	p.APDU, err = parseAPDU(rest)
+	return p, err
+}
+
+// parseNPDU decodes the network layer header and returns the remaining data.
+func parseNPDU(data []byte) (*NPDU, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, ErrTruncated
+	}
+	if data[0] != 0x01 {
+		return nil, nil, fmt.Errorf("bacnet: invalid NPDU version %d", data[0])
+	}
+	n := &NPDU{
+		Control:        data[1],
+		Priority:       data[1] & 0x03,
+		ExpectingReply: data[1]&0x04 != 0,
+		NetworkMessage: data[1]&0x80 != 0,
+	}
+	data = data[2:]
+
+	readAddress := func() (uint16, []byte, error) {
+		if len(data) < 3 || len(data) < 3+int(data[2]) {
+			return 0, nil, ErrTruncated
+		}
+		netw, size := binary.BigEndian.Uint16(data[:2]), int(data[2])
+		addr := data[3 : 3+size]
+		data = data[3+size:]
+		return netw, addr, nil
+	}
+
+	var err error
+	if n.Control&0x20 != 0 {
+		if n.DNET, n.DADR, err = readAddress(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if n.Control&0x08 != 0 {
+		if n.SNET, n.SADR, err = readAddress(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if n.Control&0x20 != 0 {
+		if len(data) < 1 {
+			return nil, nil, ErrTruncated
+		}
+		n.HopCount = data[0]
+		data = data[1:]
+	}
+	if n.NetworkMessage {
+		if len(data) < 1 {
+			return nil, nil, ErrTruncated
+		}
+		n.NetworkMessageType = data[0]
+		data = data[1:]
+	}
+	return n, data, nil
+}
+
+// parseAPDU decodes the application layer header and the object identifiers of the service.
+func parseAPDU(data []byte) (*APDU, error) {
+	if len(data) < 1 {
+		return nil, ErrTruncated
+	}
+	a := &APDU{
+		Type:      data[0] >> 4,
+		Segmented: data[0]&0x08 != 0,
+	}
+
+	var need int
+	switch a.Type {
+	case ConfirmedRequest:
+		need = 4
+		if a.Segmented {
+			need += 2
+		}
+	case UnconfirmedRequest:
+		need = 2
+	case SimpleACK, Error, Reject, Abort:
+		need = 3
+	case ComplexACK:
+		need = 3
+		if a.Segmented {
+			need += 2
+		}
+	case SegmentACK:
+		need = 4
+	default:
+		return a, fmt.Errorf("bacnet: invalid APDU type %d", a.Type)
+	}
+	if len(data) < need {
+		return a, ErrTruncated
+	}
+
+	switch a.Type {
+	case ConfirmedRequest:
+		a.InvokeID = data[2]
+		a.ServiceChoice = data[need-1]
+	case UnconfirmedRequest:
+		a.ServiceChoice = data[1]
+	case SimpleACK, ComplexACK, Error:
+		a.InvokeID = data[1]
+		a.ServiceChoice = data[need-1]
+	case SegmentACK, Reject, Abort:
+		a.InvokeID = data[1]
+		if a.Type != SegmentACK {
+			a.Reason = data[2]
+		}
+	}
+
+	// the service data of segmented messages can only be decoded after reassembly
+	if a.Segmented {
+		return a, nil
+	}
+	service := data[need:]
+
+	switch a.Type {
+	case ConfirmedRequest, ComplexACK:
+		a.Objects = objects(service, confirmedObjectTags[a.ServiceChoice])
+	case UnconfirmedRequest:
+		a.Objects = objects(service, unconfirmedObjectTags[a.ServiceChoice])
+	case Error:
+		// the error class and code are enumerated values, for some services enclosed in context tag 0
+		var values []uint32
+		for _, t := range tags(service) {
+			if !t.context && t.number == 9 {
+				values = append(values, t.uint())
+			}
+		}
+		if len(values) >= 2 {
+			a.ErrorClass, a.ErrorCode = values[0], values[1]
+		}
+	}
+	return a, nil
+}
+
+// context tags that contain object identifiers of confirmed services and their acknowledgements
+var confirmedObjectTags = map[uint8][]uint8{
+	1:  {1, 2}, // confirmedCOVNotification
+	2:  {1, 2}, // confirmedEventNotification
+	5:  {1},    // subscribeCOV
+	8:  {0},    // addListElement
+	9:  {0},    // removeListElement
+	10: {1},    // createObject
+	12: {0},    // readProperty
+	14: {0},    // readPropertyMultiple
+	15: {0},    // writeProperty
+	16: {0},    // writePropertyMultiple
+	26: {0},    // readRange
+	28: {1},    // subscribeCOVProperty
+}
+
+// context tags that contain object identifiers of unconfirmed services
+var unconfirmedObjectTags = map[uint8][]uint8{
+	2: {1, 2}, // unconfirmedCOVNotification
+	3: {1, 2}, // unconfirmedEventNotification
+	7: {2},    // who-Has
+}
+
+// objects collects the application tagged object identifiers
+// and the context tagged ones with the given tag numbers.
+func objects(data []byte, contextTags []uint8) (objs []ObjectIdentifier) {
+	for _, t := range tags(data) {
+		if len(objs) == maxObjects {
+			break
+		}
+		if len(t.value) != 4 {
+			continue
+		}
+		if !t.context {
+			if t.number == 12 {
+				objs = append(objs, objectIdentifier(t.value))
+			}
+			continue
+		}
+		for _, n := range contextTags {
+			if t.number == n {
+				objs = append(objs, objectIdentifier(t.value))
+				break
+			}
+		}
+	}
+	return
+}
+
+func objectIdentifier(v []byte) ObjectIdentifier {
+	id := binary.BigEndian.Uint32(v)
+	return ObjectIdentifier{
+		Type:     uint16(id >> 22),
+		Instance: id & 0x3fffff,
+	}
+}
+
+type tag struct {
+	number  uint8
+	context bool
+	value   []byte
+}
+
+func (t tag) uint() (v uint32) {
+	for _, b := range t.value {
+		v = v<<8 | uint32(b)
+	}
+	return
+}
+
+// tags decodes the encoded tags until the end of the data or the first invalid tag.
+// Opening and closing tags are skipped, so the contents of constructed values are returned as well.
+func tags(data []byte) (out []tag) {
+	for len(data) > 0 {
+		var (
+			t   = tag{number: data[0] >> 4, context: data[0]&0x08 != 0}
+			lvt = int(data[0] & 0x07)
+		)
+		data = data[1:]
+		if t.number == 15 {
+			if len(data) < 1 {
+				return
+			}
+			t.number = data[0]
+			data = data[1:]
+		}
+		if t.context && (lvt == 6 || lvt == 7) {
+			continue
+		}
+		// application tagged booleans carry the value in the tag
+		if !t.context && t.number == 1 {
+			out = append(out, t)
+			continue
+		}
+		if lvt == 5 {
+			if len(data) < 1 {
+				return
+			}
+			lvt = int(data[0])
+			data = data[1:]
+			switch lvt {
+			case 254:
+				if len(data) < 2 {
+					return
+				}
+				lvt = int(binary.BigEndian.Uint16(data))
+				data = data[2:]
+			case 255:
+				if len(data) < 4 {
+					return
+				}
+				lvt = int(binary.BigEndian.Uint32(data))
+				data = data[4:]
+			}
+		}
+		if lvt < 0 || len(data) < lvt {
+			return
+		}
+		t.value = data[:lvt]
+		data = data[lvt:]
+		out = append(out, t)
+	}
+	return
+}
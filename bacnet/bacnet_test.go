@@ -0,0 +1,125 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package bacnet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWhoIs(t *testing.T) {
+	p, err := Parse([]byte{0x81, 0x0b, 0x00, 0x08, 0x01, 0x00, 0x10, 0x08})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if BVLCFunctionName(p.BVLCFunction) != "Original-Broadcast-NPDU" || p.NPDU == nil || p.APDU == nil {
+		t.Fatalf("unexpected packet: %+v", p)
+	}
+	if APDUTypeName(p.APDU.Type) != "Unconfirmed-REQ" || p.APDU.ServiceName() != "who-Is" || p.APDU.IsControl() {
+		t.Fatalf("unexpected APDU: %+v", p.APDU)
+	}
+}
+
+func TestParseIAm(t *testing.T) {
+	p, err := Parse([]byte{
+		0x81, 0x0b, 0x00, 0x14, 0x01, 0x00, 0x10, 0x00,
+		0xc4, 0x02, 0x00, 0x00, 0x0a, 0x22, 0x05, 0xc4, 0x91, 0x00, 0x21, 0x0f,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.APDU.ServiceName() != "i-Am" || len(p.APDU.Objects) != 1 || p.APDU.Objects[0].String() != "device:10" {
+		t.Fatalf("unexpected APDU: %+v", p.APDU)
+	}
+}
+
+func TestParseReadProperty(t *testing.T) {
+	p, err := Parse([]byte{
+		0x81, 0x0a, 0x00, 0x11, 0x01, 0x04,
+		0x00, 0x05, 0x01, 0x0c, 0x0c, 0x00, 0x00, 0x00, 0x01, 0x19, 0x55,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := p.APDU
+	if !p.NPDU.ExpectingReply || APDUTypeName(a.Type) != "Confirmed-REQ" || a.InvokeID != 1 || a.ServiceName() != "readProperty" {
+		t.Fatalf("unexpected APDU: %+v", a)
+	}
+	if !reflect.DeepEqual(a.Objects, []ObjectIdentifier{{Type: 0, Instance: 1}}) {
+		t.Fatal("unexpected objects", a.Objects)
+	}
+
+	// acknowledgement with the present value as real
+	p, err = Parse([]byte{
+		0x81, 0x0a, 0x00, 0x16, 0x01, 0x00,
+		0x30, 0x01, 0x0c, 0x0c, 0x00, 0x00, 0x00, 0x01, 0x19, 0x55, 0x3e, 0x44, 0x42, 0x28, 0x00, 0x00, 0x3f,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if APDUTypeName(p.APDU.Type) != "ComplexACK" || p.APDU.ServiceName() != "readProperty" || len(p.APDU.Objects) != 1 || p.APDU.Objects[0].String() != "analog-input:1" {
+		t.Fatalf("unexpected APDU: %+v", p.APDU)
+	}
+}
+
+func TestParseWriteProperty(t *testing.T) {
+	p, err := Parse([]byte{
+		0x81, 0x0a, 0x00, 0x17, 0x01, 0x04,
+		0x00, 0x05, 0x02, 0x0f, 0x0c, 0x01, 0x00, 0x00, 0x05, 0x19, 0x55, 0x3e, 0x91, 0x01, 0x3f, 0x49, 0x08,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.APDU.ServiceName() != "writeProperty" || !p.APDU.IsControl() || len(p.APDU.Objects) != 1 || p.APDU.Objects[0].String() != "binary-output:5" {
+		t.Fatalf("unexpected APDU: %+v", p.APDU)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	p, err := Parse([]byte{0x81, 0x0a, 0x00, 0x0d, 0x01, 0x00, 0x50, 0x03, 0x0f, 0x91, 0x02, 0x91, 0x20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := p.APDU
+	if APDUTypeName(a.Type) != "Error" || a.InvokeID != 3 || a.ErrorClass != 2 || a.ErrorCode != 32 {
+		t.Fatalf("unexpected APDU: %+v", a)
+	}
+}
+
+func TestParseRouted(t *testing.T) {
+	// forwarded broadcast with a global destination network
+	p, err := Parse([]byte{
+		0x81, 0x04, 0x00, 0x12, 0xc0, 0xa8, 0x01, 0x0a, 0xba, 0xc0,
+		0x01, 0x20, 0xff, 0xff, 0x00, 0xff, 0x10, 0x08,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ForwardedAddress != "192.168.1.10:47808" || p.NPDU.DNET != 0xffff || p.NPDU.HopCount != 255 || p.APDU.ServiceName() != "who-Is" {
+		t.Fatalf("unexpected packet: %+v %+v", p, p.NPDU)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]byte{0x82, 0x0a, 0x00, 0x04}); err != ErrInvalidBVLC {
+		t.Fatal("expected invalid BVLC, got", err)
+	}
+	if _, err := Parse([]byte{0x81, 0x0a, 0x00, 0x05, 0x01}); err != ErrTruncated {
+		t.Fatal("expected truncated error, got", err)
+	}
+	// registration of a foreign device has no NPDU
+	p, err := Parse([]byte{0x81, 0x05, 0x00, 0x06, 0x00, 0x3c})
+	if err != nil || p.NPDU != nil || BVLCFunctionName(p.BVLCFunction) != "Register-Foreign-Device" {
+		t.Fatalf("unexpected packet: %+v %v", p, err)
+	}
+}
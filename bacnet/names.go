@@ -0,0 +1,221 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package bacnet
+
+import "strconv"
+
+var objectTypes = []string{
+	"analog-input",
+	"analog-output",
+	"analog-value",
+	"binary-input",
+	"binary-output",
+	"binary-value",
+	"calendar",
+	"command",
+	"device",
+	"event-enrollment",
+	"file",
+	"group",
+	"loop",
+	"multi-state-input",
+	"multi-state-output",
+	"notification-class",
+	"program",
+	"schedule",
+	"averaging",
+	"multi-state-value",
+	"trend-log",
+	"life-safety-point",
+	"life-safety-zone",
+	"accumulator",
+	"pulse-converter",
+	"event-log",
+	"global-group",
+	"trend-log-multiple",
+	"load-control",
+	"structured-view",
+	"access-door",
+	"timer",
+	"access-credential",
+	"access-point",
+	"access-rights",
+	"access-user",
+	"access-zone",
+	"credential-data-input",
+	"network-security",
+	"bitstring-value",
+	"characterstring-value",
+	"datepattern-value",
+	"date-value",
+	"datetimepattern-value",
+	"datetime-value",
+	"integer-value",
+	"large-analog-value",
+	"octetstring-value",
+	"positive-integer-value",
+	"timepattern-value",
+	"time-value",
+	"notification-forwarder",
+	"alert-enrollment",
+	"channel",
+	"lighting-output",
+	"binary-lighting-output",
+	"network-port",
+	"elevator-group",
+	"escalator",
+	"lift",
+}
+
+var confirmedServices = []string{
+	"acknowledgeAlarm",
+	"confirmedCOVNotification",
+	"confirmedEventNotification",
+	"getAlarmSummary",
+	"getEnrollmentSummary",
+	"subscribeCOV",
+	"atomicReadFile",
+	"atomicWriteFile",
+	"addListElement",
+	"removeListElement",
+	"createObject",
+	"deleteObject",
+	"readProperty",
+	"readPropertyConditional",
+	"readPropertyMultiple",
+	"writeProperty",
+	"writePropertyMultiple",
+	"deviceCommunicationControl",
+	"confirmedPrivateTransfer",
+	"confirmedTextMessage",
+	"reinitializeDevice",
+	"vtOpen",
+	"vtClose",
+	"vtData",
+	"authenticate",
+	"requestKey",
+	"readRange",
+	"lifeSafetyOperation",
+	"subscribeCOVProperty",
+	"getEventInformation",
+	"subscribeCOVPropertyMultiple",
+	"confirmedCOVNotificationMultiple",
+	"confirmedAuditNotification",
+	"auditLogQuery",
+}
+
+var unconfirmedServices = []string{
+	"i-Am",
+	"i-Have",
+	"unconfirmedCOVNotification",
+	"unconfirmedEventNotification",
+	"unconfirmedPrivateTransfer",
+	"unconfirmedTextMessage",
+	"timeSynchronization",
+	"who-Has",
+	"who-Is",
+	"utcTimeSynchronization",
+	"writeGroup",
+	"unconfirmedCOVNotificationMultiple",
+	"unconfirmedAuditNotification",
+	"who-Am-I",
+	"you-Are",
+}
+
+var apduTypes = []string{
+	"Confirmed-REQ",
+	"Unconfirmed-REQ",
+	"SimpleACK",
+	"ComplexACK",
+	"SegmentACK",
+	"Error",
+	"Reject",
+	"Abort",
+}
+
+var bvlcFunctions = []string{
+	"BVLC-Result",
+	"Write-Broadcast-Distribution-Table",
+	"Read-Broadcast-Distribution-Table",
+	"Read-Broadcast-Distribution-Table-Ack",
+	"Forwarded-NPDU",
+	"Register-Foreign-Device",
+	"Read-Foreign-Device-Table",
+	"Read-Foreign-Device-Table-Ack",
+	"Delete-Foreign-Device-Table-Entry",
+	"Distribute-Broadcast-To-Network",
+	"Original-Unicast-NPDU",
+	"Original-Broadcast-NPDU",
+	"Secure-BVLL",
+}
+
+func name(names []string, v int) string {
+	if v < len(names) {
+		return names[v]
+	}
+	return "unknown-" + strconv.Itoa(v)
+}
+
+// ObjectTypeName returns the name of an object type.
+func ObjectTypeName(t uint16) string {
+	if t >= 128 {
+		return "proprietary-" + strconv.Itoa(int(t))
+	}
+	return name(objectTypes, int(t))
+}
+
+// BVLCFunctionName returns the name of a BVLC function.
+func BVLCFunctionName(f uint8) string {
+	return name(bvlcFunctions, int(f))
+}
+
+// APDUTypeName returns the name of an APDU type.
+func APDUTypeName(t uint8) string {
+	return name(apduTypes, int(t))
+}
+
+// ServiceName returns the name of the service of the APDU,
+// or an empty string for APDU types without service choice.
+func (a *APDU) ServiceName() string {
+	switch a.Type {
+	case UnconfirmedRequest:
+		return name(unconfirmedServices, int(a.ServiceChoice))
+	case ConfirmedRequest, SimpleACK, ComplexACK, Error:
+		return name(confirmedServices, int(a.ServiceChoice))
+	}
+	return ""
+}
+
+// IsControl returns true for requests that write to objects or change the state of a device.
+func (a *APDU) IsControl() bool {
+	switch a.Type {
+	case ConfirmedRequest:
+		switch a.ServiceChoice {
+		case 7, // atomicWriteFile
+			8,  // addListElement
+			9,  // removeListElement
+			10, // createObject
+			11, // deleteObject
+			15, // writeProperty
+			16, // writePropertyMultiple
+			17, // deviceCommunicationControl
+			20, // reinitializeDevice
+			27: // lifeSafetyOperation
+			return true
+		}
+	case UnconfirmedRequest:
+		// writeGroup
+		return a.ServiceChoice == 10
+	}
+	return false
+}
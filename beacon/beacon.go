@@ -0,0 +1,280 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package beacon detects periodic connections between two endpoints,
+// by scoring the regularity of the intervals and sizes of repeated connections.
+//
+// Connections are grouped by source, destination, destination port and transport protocol.
+// For each group, the skewness and the median absolute deviation of the intervals and connection sizes are calculated.
+// Beacons have a small skew and dispersion, their timing and size scores are close to 1.
+// To bound the memory usage, only the most recent connections of each group are scored and the number of groups is limited.
+package beacon
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+type key struct {
+	src   string
+	dst   string
+	port  int32
+	proto string
+}
+
+// default limits of an Analyzer
+const (
+	DefaultMaxSamples = 1000
+	DefaultMaxGroups  = 100000
+)
+
+// group holds the last connections of a group in a ring buffer
+type group struct {
+	times []time.Time
+	sizes []float64
+
+	// first connection of the group, including the ones no longer in the buffer
+	first time.Time
+
+	// total number of connections and position of the oldest sample, once the buffer is full
+	total int
+	next  int
+}
+
+func (g *group) add(ts time.Time, size float64, max int) {
+	if g.total == 0 || ts.Before(g.first) {
+		g.first = ts
+	}
+	g.total++
+	if len(g.times) < max {
+		g.times = append(g.times, ts)
+		g.sizes = append(g.sizes, size)
+		return
+	}
+	g.times[g.next] = ts
+	g.sizes[g.next] = size
+	g.next = (g.next + 1) % len(g.times)
+}
+
+// Analyzer collects connections and scores them for beaconing.
+// It is safe for concurrent use.
+type Analyzer struct {
+	// MinConnections is the minimum number of connections for a group to be scored
+	MinConnections int
+
+	// MaxSamples is the number of most recent connections per group that are scored
+	MaxSamples int
+
+	// MaxGroups is the maximum number of groups,
+	// connections for new groups are dropped once it has been reached
+	MaxGroups int
+
+	// Dropped is the number of connections that have been dropped, because MaxGroups was reached
+	Dropped int64
+
+	groups map[key]*group
+	sync.Mutex
+}
+
+// NewAnalyzer returns a new Analyzer.
+func NewAnalyzer(minConnections int) *Analyzer {
+	return &Analyzer{
+		MinConnections: minConnections,
+		MaxSamples:     DefaultMaxSamples,
+		MaxGroups:      DefaultMaxGroups,
+		groups:         make(map[key]*group),
+	}
+}
+
+// Add adds a single connection.
+func (a *Analyzer) Add(ts time.Time, src, dst string, dstPort int32, proto string, size int64) {
+
+	k := key{src: src, dst: dst, port: dstPort, proto: proto}
+
+	a.Lock()
+	defer a.Unlock()
+
+	g, ok := a.groups[k]
+	if !ok {
+		if len(a.groups) >= a.MaxGroups {
+			a.Dropped++
+			return
+		}
+		g = &group{}
+		a.groups[k] = g
+	}
+	g.add(ts, float64(size), a.MaxSamples)
+}
+
+// AddRecord adds a Connection or Flow audit record.
+// other record types are ignored.
+func (a *Analyzer) AddRecord(record interface{}) {
+	switch r := record.(type) {
+	case *types.Connection:
+		port, _ := strconv.Atoi(r.DstPort)
+		a.Add(utils.StringToTime(r.TimestampFirst), r.SrcIP, r.DstIP, int32(port), r.TransportProto, int64(r.TotalSize))
+	case *types.Flow:
+		port, _ := strconv.Atoi(r.DstPort)
+		a.Add(utils.StringToTime(r.TimestampFirst), r.SrcIP, r.DstIP, int32(port), r.TransportProto, int64(r.TotalSize))
+	}
+}
+
+// Results scores all groups with enough connections
+// and returns the beacons with a score of at least minScore, ranked by their score.
+func (a *Analyzer) Results(minScore float64) []*types.Beacon {
+
+	a.Lock()
+	defer a.Unlock()
+
+	var out []*types.Beacon
+	for k, g := range a.groups {
+		if g.total < a.MinConnections || len(g.times) < 3 {
+			continue
+		}
+		if b := score(k, g); b.Score >= minScore {
+			out = append(out, b)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].NumConnections > out[j].NumConnections
+	})
+	for i, b := range out {
+		b.Rank = int32(i + 1)
+	}
+
+	return out
+}
+
+func score(k key, g *group) *types.Beacon {
+
+	// connections might be added out of order
+	idx := make([]int, len(g.times))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		return g.times[idx[i]].Before(g.times[idx[j]])
+	})
+
+	var (
+		last      = g.times[idx[len(idx)-1]]
+		intervals = make([]float64, 0, len(idx)-1)
+	)
+	for i := 1; i < len(idx); i++ {
+		intervals = append(intervals, float64(g.times[idx[i]].Sub(g.times[idx[i-1]])))
+	}
+
+	var (
+		iMean, iStd    = meanStd(intervals)
+		iMedian, iSkew = medianSkew(intervals)
+		iMAD           = mad(intervals, iMedian)
+		sMean, sStd    = meanStd(g.sizes)
+		sMedian, sSkew = medianSkew(g.sizes)
+		sMAD           = mad(g.sizes, sMedian)
+		timingScore    = (1 - math.Abs(iSkew) + dispersionScore(iMAD, iMedian)) / 2
+		sizeScore      = (1 - math.Abs(sSkew) + dispersionScore(sMAD, sMedian)) / 2
+	)
+
+	return &types.Beacon{
+		Timestamp:      utils.TimeToString(g.first),
+		TimestampLast:  utils.TimeToString(last),
+		SrcIP:          k.src,
+		DstIP:          k.dst,
+		DstPort:        k.port,
+		Proto:          k.proto,
+		NumConnections: int32(g.total),
+		MeanInterval:   int64(iMean),
+		MedianInterval: int64(iMedian),
+		Jitter:         int64(iStd),
+		IntervalMAD:    int64(iMAD),
+		IntervalSkew:   iSkew,
+		MeanSize:       sMean,
+		SizeVariance:   sStd * sStd,
+		SizeSkew:       sSkew,
+		TimingScore:    timingScore,
+		SizeScore:      sizeScore,
+		Score:          (timingScore + sizeScore) / 2,
+	}
+}
+
+// dispersionScore is 1 for no dispersion and decreases to 0,
+// when the median absolute deviation reaches the median.
+func dispersionScore(mad, median float64) float64 {
+	if median == 0 {
+		if mad == 0 {
+			return 1
+		}
+		return 0
+	}
+	return math.Max(0, 1-mad/median)
+}
+
+func meanStd(v []float64) (mean, std float64) {
+	for _, x := range v {
+		mean += x
+	}
+	mean /= float64(len(v))
+	for _, x := range v {
+		std += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(v)))
+}
+
+// medianSkew returns the median and the bowley skewness,
+// which ranges from -1 to 1 and is 0 for a symmetric distribution.
+func medianSkew(v []float64) (median, skew float64) {
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	var (
+		q1 = quantile(s, 0.25)
+		q2 = quantile(s, 0.5)
+		q3 = quantile(s, 0.75)
+	)
+	if q3 == q1 {
+		return q2, 0
+	}
+	return q2, (q1 + q3 - 2*q2) / (q3 - q1)
+}
+
+// mad returns the median absolute deviation.
+func mad(v []float64, median float64) float64 {
+	d := make([]float64, len(v))
+	for i, x := range v {
+		d[i] = math.Abs(x - median)
+	}
+	sort.Float64s(d)
+	return quantile(d, 0.5)
+}
+
+// quantile uses linear interpolation on the sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[i]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
@@ -0,0 +1,150 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package beacon
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+func TestResults(t *testing.T) {
+
+	var (
+		a     = NewAnalyzer(10)
+		start = time.Unix(1500000000, 0)
+		rnd   = rand.New(rand.NewSource(1))
+	)
+
+	// check-in every 60 seconds with up to one second of jitter and constant size, added in reverse order
+	for i := 99; i >= 0; i-- {
+		ts := start.Add(time.Duration(i)*time.Minute + time.Duration(rnd.Intn(1000))*time.Millisecond)
+		a.Add(ts, "10.0.0.1", "1.2.3.4", 443, "TCP", 512)
+	}
+
+	// browsing with random intervals and sizes
+	ts := start
+	for i := 0; i < 100; i++ {
+		ts = ts.Add(time.Duration(rnd.ExpFloat64()*30) * time.Second)
+		a.Add(ts, "10.0.0.1", "5.6.7.8", 443, "TCP", int64(rnd.Intn(100000)))
+	}
+
+	// not enough connections
+	for i := 0; i < 5; i++ {
+		a.Add(start.Add(time.Duration(i)*time.Minute), "10.0.0.2", "1.2.3.4", 80, "TCP", 100)
+	}
+
+	all := a.Results(0)
+	if len(all) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(all))
+	}
+
+	b := all[0]
+	if b.DstIP != "1.2.3.4" || b.Rank != 1 || b.NumConnections != 100 {
+		t.Fatalf("unexpected first result: %+v", b)
+	}
+	if b.Score < 0.9 || b.SizeScore != 1 || b.SizeVariance != 0 {
+		t.Errorf("expected high score for beacon, got %+v", b)
+	}
+	if d := time.Duration(b.MedianInterval); d < 59*time.Second || d > 61*time.Second {
+		t.Errorf("unexpected median interval %s", d)
+	}
+	if first := utils.StringToTime(b.Timestamp); first.Before(start) || first.After(start.Add(time.Second)) {
+		t.Errorf("unexpected first timestamp %s", b.Timestamp)
+	}
+
+	if all[1].Score >= 0.7 || all[1].Rank != 2 {
+		t.Errorf("expected low score for random connections, got %+v", all[1])
+	}
+
+	if res := a.Results(0.9); len(res) != 1 {
+		t.Errorf("expected 1 result with min score, got %d", len(res))
+	}
+}
+
+func TestAddRecord(t *testing.T) {
+	a := NewAnalyzer(3)
+	for i := 0; i < 3; i++ {
+		a.AddRecord(&types.Connection{
+			TimestampFirst: utils.TimeToString(time.Unix(int64(1500000000+i*10), 0)),
+			SrcIP:          "10.0.0.1",
+			DstIP:          "10.0.0.2",
+			DstPort:        "53",
+			TransportProto: "UDP",
+			TotalSize:      100,
+		})
+	}
+	a.AddRecord(&types.HTTP{})
+
+	res := a.Results(0)
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(res))
+	}
+	if res[0].DstPort != 53 || res[0].Proto != "UDP" || res[0].MeanInterval != int64(10*time.Second) || res[0].Score != 1 {
+		t.Errorf("unexpected result: %+v", res[0])
+	}
+}
+
+func TestLimits(t *testing.T) {
+
+	var (
+		a     = NewAnalyzer(3)
+		start = time.Unix(1500000000, 0)
+	)
+	a.MaxSamples = 10
+	a.MaxGroups = 2
+
+	// irregular connections followed by regular ones, only the last 10 are scored
+	for i := 0; i < 10; i++ {
+		a.Add(start.Add(time.Duration(i*i)*time.Second), "10.0.0.1", "10.0.0.2", 443, "TCP", int64(i*100))
+	}
+	for i := 0; i < 10; i++ {
+		a.Add(start.Add(time.Duration(100+i*10)*time.Second), "10.0.0.1", "10.0.0.2", 443, "TCP", 100)
+	}
+	a.Add(start, "10.0.0.1", "10.0.0.3", 443, "TCP", 100)
+	a.Add(start, "10.0.0.1", "10.0.0.4", 443, "TCP", 100)
+
+	if a.Dropped != 1 || len(a.groups) != 2 {
+		t.Fatalf("expected 2 groups and 1 dropped connection, got %d and %d", len(a.groups), a.Dropped)
+	}
+	g := a.groups[key{src: "10.0.0.1", dst: "10.0.0.2", port: 443, proto: "TCP"}]
+	if len(g.times) != 10 || g.total != 20 {
+		t.Fatalf("expected 10 of 20 samples, got %d of %d", len(g.times), g.total)
+	}
+
+	res := a.Results(0)
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(res))
+	}
+	b := res[0]
+	if b.NumConnections != 20 || b.Score != 1 || b.Timestamp != utils.TimeToString(start) {
+		t.Errorf("unexpected result: %+v", b)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	s := []float64{1, 2, 3, 4}
+	if q := quantile(s, 0.5); q != 2.5 {
+		t.Errorf("expected 2.5, got %f", q)
+	}
+	if q := quantile(s, 1); q != 4 {
+		t.Errorf("expected 4, got %f", q)
+	}
+	if _, skew := medianSkew([]float64{1, 2, 9, 10, 11}); skew >= 0 {
+		t.Errorf("expected negative skew, got %f", skew)
+	}
+}
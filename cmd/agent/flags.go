@@ -13,7 +13,11 @@
 
 package main
 
-import "flag"
+import (
+	"flag"
+
+	"github.com/dreadl0ck/netcap/encoder"
+)
 
 var (
 	flagInterface = flag.String("iface", "en0", "interface")
@@ -33,7 +37,7 @@ var (
 	flagAddr          = flag.String("addr", "127.0.0.1:1335", "specify the address and port of the collection server")
 	flagBaseLayer     = flag.String("base", "ethernet", "select base layer")
 	flagDecodeOptions = flag.String("opts", "lazy", "select decoding options")
-	flagPayload       = flag.Bool("payload", false, "capture payload for supported layers")
+	flagPayload       = encoder.PayloadFlag("payload capture policy for supported encoders, e.g. TCP:256,UDP:0,Flow:hash or * for all")
 	flagVersion       = flag.Bool("version", false, "print netcap package version and exit")
 	flagContext       = flag.Bool("context", true, "add packet flow context to selected audit records")
 
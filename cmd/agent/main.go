@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	gzip "github.com/klauspost/pgzip"
 
@@ -38,6 +39,7 @@ func main() {
 	// parse commandline flags
 	flag.Usage = printUsage
 	flag.Parse()
+	encoder.ParsePayloadFlag(flagPayload)
 
 	// print version and exit
 	if *flagVersion {
@@ -102,10 +104,10 @@ func main() {
 			Source:          *flagInterface,
 
 			// set channel writer
-			WriteChan:       true,
-			IncludePayloads: *flagPayload,
-			AddContext:      *flagContext,
-			MemBufferSize:   *flagMemBufferSize,
+			WriteChan:     true,
+			PayloadPolicy: *flagPayload,
+			AddContext:    *flagContext,
+			MemBufferSize: *flagMemBufferSize,
 		},
 		BaseLayer:     utils.GetBaseLayer(*flagBaseLayer),
 		DecodeOptions: utils.GetDecodeOptions(*flagDecodeOptions),
@@ -151,6 +153,7 @@ func main() {
 				// set clientID and messageType
 				b.ClientID = userName
 				b.MessageType = info.Type
+				b.ContainsPayloads = encoder.ContainsPayloads(strings.TrimPrefix(info.Type.String(), "NC_"))
 
 				// if there is buffered data left over
 				if len(leftOverBuf) > 0 {
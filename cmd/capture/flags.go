@@ -15,6 +15,8 @@ package main
 
 import (
 	"flag"
+
+	"github.com/dreadl0ck/netcap/encoder"
 )
 
 var (
@@ -30,6 +32,8 @@ var (
 	flagPrintProtocolOverview = flag.Bool("overview", false, "print a list of all available encoders and fields")
 
 	flagInterface    = flag.String("iface", "", "attach to network interface and capture in live mode")
+	flagFlowsListen  = flag.String("flows-listen", "", "listen for NetFlow v5/v9, IPFIX and sFlow datagrams on the UDP address, e.g. :2055")
+	flagFlowsRead    = flag.String("flows-read", "", "read NetFlow v5/v9, IPFIX and sFlow datagrams from a pcap with the export traffic or a file with the raw messages")
 	flagCompress     = flag.Bool("comp", true, "compress output with gzip")
 	flagBuffer       = flag.Bool("buf", true, "buffer data in memory before writing to disk")
 	flagWorkers      = flag.Int("workers", 1000, "number of workers")
@@ -45,7 +49,7 @@ var (
 
 	flagBaseLayer     = flag.String("base", "ethernet", "select base layer")
 	flagDecodeOptions = flag.String("opts", "lazy", "select decoding options")
-	flagPayload       = flag.Bool("payload", false, "capture payload for supported layers")
+	flagPayload       = encoder.PayloadFlag("payload capture policy for supported encoders, e.g. TCP:256,UDP:0,Flow:hash or * for all")
 
 	flagCSV     = flag.Bool("csv", false, "output data as CSV instead of audit records")
 	flagContext = flag.Bool("context", true, "add packet flow context to selected audit records")
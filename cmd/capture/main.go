@@ -35,6 +35,7 @@ func main() {
 	// parse commandline flags
 	flag.Usage = printUsage
 	flag.Parse()
+	encoder.ParsePayloadFlag(flagPayload)
 
 	// print version and exit
 	if *flagVersion {
@@ -84,12 +85,12 @@ func main() {
 
 	// live mode?
 	var live bool
-	if *flagInterface != "" {
+	if *flagInterface != "" || *flagFlowsListen != "" {
 		live = true
 	}
 
 	// abort if there is no input or no live capture
-	if *flagInput == "" && !live {
+	if *flagInput == "" && *flagFlowsRead == "" && !live {
 		printHeader()
 		fmt.Println(ansi.Red + "> nothing to do. need a pcap file with the read flag (-r) or live mode and an interface (-iface)" + ansi.Reset)
 		os.Exit(1)
@@ -108,6 +109,10 @@ func main() {
 		source = *flagInput
 	} else if *flagInterface != "" {
 		source = *flagInterface
+	} else if *flagFlowsListen != "" {
+		source = *flagFlowsListen
+	} else if *flagFlowsRead != "" {
+		source = *flagFlowsRead
 	} else {
 		source = "unknown"
 	}
@@ -129,7 +134,7 @@ func main() {
 			Out:             *flagOutDir,
 			Source:          source,
 			Version:         netcap.Version,
-			IncludePayloads: *flagPayload,
+			PayloadPolicy:   *flagPayload,
 			Export:          false,
 			AddContext:      *flagContext,
 			MemBufferSize:   *flagMemBufferSize,
@@ -141,6 +146,21 @@ func main() {
 	netcap.PrintBuildInfo()
 	c.PrintConfiguration()
 
+	// collect flows from NetFlow, IPFIX and sFlow exporters
+	if *flagFlowsListen != "" {
+		err := c.CollectFlows(*flagFlowsListen)
+		if err != nil {
+			log.Fatal("failed to collect flows: ", err)
+		}
+		return
+	}
+	if *flagFlowsRead != "" {
+		if err := c.CollectFlowFile(*flagFlowsRead); err != nil {
+			log.Fatal("failed to collect audit records from flow file: ", err)
+		}
+		return
+	}
+
 	// collect traffic live from named interface
 	if live {
 		err := c.CollectLive(*flagInterface, *flagBPF)
@@ -48,10 +48,9 @@ func NewAuditRecordHandle(b *types.Batch, path string) *AuditRecordHandle {
 	fmt.Println("new audit record handle", path)
 
 	conf := encoder.Config{
-		Source:          b.ClientID,
-		Version:         netcap.Version,
-		IncludePayloads: b.ContainsPayloads,
-		MemBufferSize:   *flagMemBufferSize,
+		Source:        b.ClientID,
+		Version:       netcap.Version,
+		MemBufferSize: *flagMemBufferSize,
 	}
 
 	var (
@@ -62,7 +61,7 @@ func NewAuditRecordHandle(b *types.Batch, path string) *AuditRecordHandle {
 	)
 
 	// add file header
-	err = delimited.NewWriter(gWriter).PutProto(netcap.NewHeader(b.MessageType, conf.Source, conf.Version, conf.IncludePayloads))
+	err = delimited.NewWriter(gWriter).PutProto(netcap.NewHeader(b.MessageType, conf.Source, conf.Version, b.ContainsPayloads))
 	if err != nil {
 		fmt.Println("failed to write header")
 		panic(err)
@@ -13,7 +13,11 @@
 
 package main
 
-import "flag"
+import (
+	"flag"
+
+	"github.com/dreadl0ck/netcap/encoder"
+)
 
 var (
 	flagMetricsAddress = flag.String("address", "127.0.0.1:7777", "set address for exposing metrics")
@@ -30,7 +34,7 @@ var (
 	flagVersion        = flag.Bool("version", false, "print netcap package version and exit")
 	flagBaseLayer      = flag.String("base", "ethernet", "select base layer")
 	flagDecodeOptions  = flag.String("opts", "lazy", "select decoding options")
-	flagPayload        = flag.Bool("payload", false, "capture payload for supported layers")
+	flagPayload        = encoder.PayloadFlag("payload capture policy for supported encoders, e.g. TCP:256,UDP:0,Flow:hash or * for all")
 	flagCompress       = flag.Bool("comp", true, "compress output with gzip")
 	flagBuffer         = flag.Bool("buf", true, "buffer data in memory before writing to disk")
 	flagOutDir         = flag.String("out", "", "specify output directory, will be created if it does not exist")
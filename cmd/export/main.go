@@ -37,6 +37,7 @@ func main() {
 	// parse commandline flags
 	flag.Usage = printUsage
 	flag.Parse()
+	encoder.ParsePayloadFlag(flagPayload)
 
 	// print version and exit
 	if *flagVersion {
@@ -105,7 +106,7 @@ func main() {
 				Out:             *flagOutDir,
 				Source:          source,
 				Version:         netcap.Version,
-				IncludePayloads: *flagPayload,
+				PayloadPolicy:   *flagPayload,
 				Export:          true,
 				AddContext:      *flagContext,
 				MemBufferSize:   *flagMemBufferSize,
@@ -31,6 +31,7 @@ var (
 
 	flagVersion = flag.Bool("version", false, "print netcap package version and exit")
 	flagCustom  = flag.String("custom", "", "use custom mappings at path")
+	flagYARA    = flag.String("yara", "", "label with matches of the YARA rules from the given file or directory instead of suricata alerts")
 
 	// this wont work currently, because the Select() func will stop if there are fields that are not present on an audit record
 	// as labeling iterates over all available records, there will always be a record that does not have all selected fields
@@ -55,6 +55,11 @@ func main() {
 	var err error
 	if *flagCustom != "" {
 		err = label.CustomLabels(*flagCustom, *flagOutDir, *flagDescription, *flagSeparator, "")
+	} else if *flagYARA != "" {
+		if *flagInput == "" {
+			log.Fatal("no input file specified. YARA labeling requires a pcap file.")
+		}
+		err = label.YARA(*flagInput, *flagYARA, *flagOutDir, *flagDescription, *flagSeparator, "")
 	} else {
 		err = label.Suricata(*flagInput, *flagOutDir, *flagDescription, *flagSeparator, "")
 	}
@@ -15,6 +15,7 @@ func printHeader() {
 	fmt.Println("	$ net.label -r traffic.pcap -out output_dir")
 	fmt.Println("	$ net.label -r taffic.pcap -progress")
 	fmt.Println("	$ net.label -r taffic.pcap -collect")
+	fmt.Println("	$ net.label -r traffic.pcap -yara yara/rules")
 	fmt.Println()
 }
 
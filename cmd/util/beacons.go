@@ -0,0 +1,119 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/beacon"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// findBeacons scores the connections from the input file or directory for beaconing
+// and prints the ranked results. If an output directory is set, the results are also written to disk.
+func findBeacons() {
+
+	path, err := connectionFile(*flagInput)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := netcap.Open(path, *flagMemBufferSize)
+	if err != nil {
+		log.Fatal("failed to open audit record file: ", err)
+	}
+
+	var (
+		header = r.ReadHeader()
+		record = netcap.InitRecord(header.Type)
+		a      = beacon.NewAnalyzer(*flagBeaconMin)
+	)
+	if header.Type != types.Type_NC_Connection && header.Type != types.Type_NC_Flow {
+		log.Fatal("beacons can only be determined from Connection or Flow audit records, got ", header.Type)
+	}
+
+	for {
+		err := r.Next(record)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		a.AddRecord(record)
+	}
+	if err := r.Close(); err != nil {
+		log.Fatal("failed to close file: ", err)
+	}
+
+	var w *netcap.Writer
+	if *flagOut != "" {
+		if err := os.MkdirAll(*flagOut, 0755); err != nil {
+			log.Fatal(err)
+		}
+		w = netcap.NewWriter("Beacon", true, true, false, *flagOut, false, *flagMemBufferSize)
+		if err := w.WriteHeader(types.Type_NC_Beacon, path, netcap.Version, false); err != nil {
+			log.Fatal("failed to write header: ", err)
+		}
+	}
+
+	beacons := a.Results(*flagBeaconScore)
+	fmt.Println(strings.Join(types.Beacon{}.CSVHeader(), *flagSeparator))
+	for _, b := range beacons {
+		fmt.Println(strings.Join(b.CSVRecord(), *flagSeparator))
+		if w != nil {
+			if err := w.Write(b); err != nil {
+				log.Fatal("failed to write beacon: ", err)
+			}
+		}
+	}
+
+	if w != nil {
+		name, _ := w.Close()
+		fmt.Println("wrote", len(beacons), "beacons to", name)
+	}
+}
+
+// connectionFile returns path if it is a file,
+// otherwise the Connection audit records in the directory are used, or the Flow audit records if there are none.
+func connectionFile(path string) (string, error) {
+	return findRecordFile(path, "Connection", "Flow")
+}
+
+// findRecordFile returns path if it is a file,
+// otherwise the audit records of the first type that exists in the directory are used.
+func findRecordFile(path string, typeNames ...string) (string, error) {
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !stat.IsDir() {
+		return path, nil
+	}
+
+	for _, typ := range typeNames {
+		for _, name := range []string{typ + ".ncap.gz", typ + ".ncap"} {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				return filepath.Join(path, name), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s audit records found in %s", strings.Join(typeNames, " or "), path)
+}
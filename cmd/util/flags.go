@@ -23,4 +23,19 @@ var (
 	flagSeparator     = flag.String("sep", ",", "set separator string for csv output")
 	flagVersion       = flag.Bool("version", false, "print netcap package version and exit")
 	flagMemBufferSize = flag.Int("membuf-size", 1024*1024*10, "set size for membuf")
+	flagRules         = flag.String("rules", "", "evaluate the detection rules from the given YAML file or directory on the audit records from -r (file or directory)")
+	flagOut           = flag.String("out", "", "output directory for the audit records generated with -rules or -beacons, records are only printed if empty")
+	flagBeacons       = flag.Bool("beacons", false, "rank the Connection or Flow audit records from -r (file or directory) by their beaconing score")
+	flagBeaconMin     = flag.Int("beacon-min-conns", 10, "minimum number of connections between two endpoints to check for beaconing")
+	flagBeaconScore   = flag.Float64("beacon-score", 0.8, "minimum score from 0 to 1 for beacons to be reported")
+	flagPDNSName      = flag.String("pdns-name", "", "print the passive DNS history of the name and its subdomains, from the PassiveDNS or DNS audit records from -r (file or directory)")
+	flagPDNSAnswer    = flag.String("pdns-answer", "", "print the names that resolved to the address or name, from the PassiveDNS or DNS audit records from -r (file or directory)")
+
+	// netflow
+	flagNetFlowExport  = flag.Bool("netflow-export", false, "export the Flow or Connection audit records from -r (file or directory) as NetFlow v9 or IPFIX messages to -netflow-addr or -netflow-file")
+	flagNetFlowAddr    = flag.String("netflow-addr", "", "UDP address of the collector for -netflow-export, e.g. 127.0.0.1:4739")
+	flagNetFlowFile    = flag.String("netflow-file", "", "write the messages for -netflow-export to the file at path")
+	flagNetFlowVersion = flag.Int("netflow-version", 10, "version of the exported messages, 9 for NetFlow v9 or 10 for IPFIX")
+	flagNetFlowCollect = flag.String("netflow-collect", "", "listen for NetFlow v9 and IPFIX messages on the UDP address and print the flows")
+	flagNetFlowRead    = flag.String("netflow-read", "", "print the flows from a file with NetFlow v9 or IPFIX messages, as written with -netflow-file")
 )
@@ -45,4 +45,38 @@ func main() {
 		checkFields()
 		return
 	}
+
+	// util to evaluate detection rules on audit records
+	if *flagRules != "" {
+		evaluateRules()
+		return
+	}
+
+	// util to find beacons in connections
+	if *flagBeacons {
+		findBeacons()
+		return
+	}
+
+	// util to query the passive DNS history
+	if *flagPDNSName != "" || *flagPDNSAnswer != "" {
+		queryPassiveDNS()
+		return
+	}
+
+	// util to export flows as NetFlow v9 or IPFIX
+	if *flagNetFlowExport {
+		exportNetFlow()
+		return
+	}
+
+	// collector for NetFlow v9 and IPFIX messages
+	if *flagNetFlowCollect != "" {
+		collectNetFlow()
+		return
+	}
+	if *flagNetFlowRead != "" {
+		readNetFlow()
+		return
+	}
 }
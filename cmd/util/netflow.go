@@ -0,0 +1,129 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/netflow"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// exportNetFlow exports the flows from the input file or directory as NetFlow v9 or IPFIX messages.
+func exportNetFlow() {
+
+	if *flagNetFlowAddr == "" && *flagNetFlowFile == "" {
+		log.Fatal("-netflow-export requires -netflow-addr or -netflow-file")
+	}
+
+	path, err := findRecordFile(*flagInput, "Flow", "Connection")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := netcap.Open(path, *flagMemBufferSize)
+	if err != nil {
+		log.Fatal("failed to open audit record file: ", err)
+	}
+	defer r.Close()
+
+	var (
+		header = r.ReadHeader()
+		record = netcap.InitRecord(header.Type)
+		num    int
+	)
+	if header.Type != types.Type_NC_Flow && header.Type != types.Type_NC_Connection {
+		log.Fatal("only Flow or Connection audit records can be exported, got ", header.Type)
+	}
+
+	e, err := netflow.Open(*flagNetFlowAddr, *flagNetFlowFile, *flagNetFlowVersion)
+	if err != nil {
+		log.Fatal("failed to create exporter: ", err)
+	}
+
+	for {
+		err := r.Next(record)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		if f, ok := netflow.FromAuditRecord(record); ok {
+			if err := e.Add(f); err != nil {
+				log.Fatal("failed to export flow: ", err)
+			}
+			num++
+		}
+	}
+	if err := e.Close(); err != nil {
+		log.Fatal("failed to export flows: ", err)
+	}
+
+	fmt.Println("exported", num, "flows from", path)
+}
+
+// collectNetFlow prints the flows from the NetFlow v9 or IPFIX messages received on the UDP address.
+func collectNetFlow() {
+
+	conn, err := net.ListenPacket("udp", *flagNetFlowCollect)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Println("listening for NetFlow v9 and IPFIX messages on", conn.LocalAddr())
+
+	var (
+		d   = netflow.NewDecoder()
+		buf = make([]byte, 65535)
+	)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		records, _, err := d.Decode(buf[:n])
+		if err != nil {
+			log.Println("invalid message from", addr, err)
+		}
+		for _, r := range records {
+			fmt.Println(r)
+		}
+	}
+}
+
+// readNetFlow prints the flows from a file with NetFlow v9 or IPFIX messages, as written with -netflow-file.
+func readNetFlow() {
+
+	data, err := ioutil.ReadFile(*flagNetFlowRead)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d := netflow.NewDecoder()
+	for len(data) > 0 {
+		records, n, err := d.Decode(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range records {
+			fmt.Println(r)
+		}
+		data = data[n:]
+	}
+}
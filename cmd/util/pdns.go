@@ -0,0 +1,78 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/pdns"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// queryPassiveDNS loads the passive DNS store from the input file or directory
+// and prints the history of a name, or the names that resolved to an answer.
+func queryPassiveDNS() {
+
+	path, err := findRecordFile(*flagInput, "PassiveDNS", "DNS")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := netcap.Open(path, *flagMemBufferSize)
+	if err != nil {
+		log.Fatal("failed to open audit record file: ", err)
+	}
+
+	var (
+		header = r.ReadHeader()
+		record = netcap.InitRecord(header.Type)
+		store  = pdns.NewStore(int(^uint(0) >> 1))
+	)
+	if header.Type != types.Type_NC_PassiveDNS && header.Type != types.Type_NC_DNS {
+		log.Fatal("passive DNS can only be queried from PassiveDNS or DNS audit records, got ", header.Type)
+	}
+
+	for {
+		err := r.Next(record)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		switch rec := record.(type) {
+		case *types.PassiveDNS:
+			store.AddEntry(rec)
+		case *types.DNS:
+			store.AddRecord(rec)
+		}
+	}
+	if err := r.Close(); err != nil {
+		log.Fatal("failed to close file: ", err)
+	}
+
+	var entries []*types.PassiveDNS
+	if *flagPDNSName != "" {
+		entries = store.Name(*flagPDNSName)
+	} else {
+		entries = store.Resolved(*flagPDNSAnswer)
+	}
+
+	fmt.Println(strings.Join(types.PassiveDNS{}.CSVHeader(), *flagSeparator))
+	for _, e := range entries {
+		fmt.Println(strings.Join(e.CSVRecord(), *flagSeparator))
+	}
+}
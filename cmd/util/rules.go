@@ -0,0 +1,110 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/detection"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// evaluateRules runs the detection rules on the audit record file or directory
+// and prints the generated alerts. If an output directory is set, the alerts are also written to disk.
+func evaluateRules() {
+
+	engine, err := detection.NewEngine(*flagRules)
+	if err != nil {
+		log.Fatal("failed to load rules: ", err)
+	}
+	fmt.Println("loaded", engine.NumRules(), "detection rules from", *flagRules)
+
+	files, err := auditRecordFiles(*flagInput)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		w         *netcap.Writer
+		numAlerts int
+	)
+	if *flagOut != "" {
+		if err := os.MkdirAll(*flagOut, 0755); err != nil {
+			log.Fatal(err)
+		}
+		w = netcap.NewWriter("Alert", true, true, false, *flagOut, false, *flagMemBufferSize)
+		if err := w.WriteHeader(types.Type_NC_Alert, *flagInput, netcap.Version, false); err != nil {
+			log.Fatal("failed to write header: ", err)
+		}
+	}
+
+	for _, f := range files {
+		err := engine.ProcessFile(f, func(a *types.Alert) {
+			numAlerts++
+			fmt.Println(strings.Join(a.CSVRecord(), *flagSeparator))
+			if w != nil {
+				if err := w.Write(a); err != nil {
+					log.Fatal("failed to write alert: ", err)
+				}
+			}
+		})
+		if err != nil {
+			log.Fatal("failed to process ", f, ": ", err)
+		}
+	}
+
+	if w != nil {
+		name, _ := w.Close()
+		fmt.Println("wrote", numAlerts, "alerts to", name)
+		return
+	}
+	fmt.Println("generated", numAlerts, "alerts")
+}
+
+// auditRecordFiles returns path if it is a file,
+// or all audit record files in the directory, excluding previously generated alerts.
+func auditRecordFiles(path string) ([]string, error) {
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !(strings.HasSuffix(name, ".ncap") || strings.HasSuffix(name, ".ncap.gz")) {
+			continue
+		}
+		if strings.HasPrefix(name, "Alert.ncap") {
+			continue
+		}
+		out = append(out, filepath.Join(path, name))
+	}
+	return out, nil
+}
@@ -33,6 +33,9 @@ func printHeader() {
 	fmt.Println("	$ net.util -r TCP.ncap.gz -check")
 	fmt.Println("	$ net.util -r TCP.ncap.gz -check -sep '/'")
 	fmt.Println("	$ net.util -ts2utc 1505839354.197231")
+	fmt.Println("	$ net.util -r HTTP.ncap.gz -rules detection/rules")
+	fmt.Println("	$ net.util -r out -rules detection/rules -out alerts")
+	fmt.Println("	$ net.util -r Connection.ncap.gz -beacons -beacon-score 0.9")
 	fmt.Println()
 }
 
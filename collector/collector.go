@@ -44,7 +44,7 @@ var flagFreeOSMemory = flag.Int("free-os-mem", 0, "free OS memory every X minute
 type Collector struct {
 
 	// input channels for the worker pool
-	workers []chan gopacket.Packet
+	workers []chan packetInfo
 
 	// synchronization
 	statMutex sync.Mutex
@@ -78,6 +78,7 @@ type Collector struct {
 	totalBytesWritten int64
 	files             map[string]string
 	inputSize         int64
+	numFlowRecords    int64
 
 	// configuration
 	config *Config
@@ -100,7 +101,7 @@ func New(config Config) *Collector {
 func (c *Collector) stopWorkers() {
 	// wait until all packets have been decoded
 	for _, w := range c.workers {
-		w <- nil
+		w <- packetInfo{}
 		// TODO closing here produces a data race
 		// close(w)
 	}
@@ -187,7 +188,11 @@ func (c *Collector) handlePacket(p gopacket.Packet) {
 	// }
 
 	// send the packetInfo to the encoder routine
-	c.workers[c.next] <- p
+	// the packet counter has been incremented when printing the progress for this packet
+	c.workers[c.next] <- packetInfo{
+		p:   p,
+		num: atomic.LoadInt64(&c.current),
+	}
 
 	// increment or reset next
 	if c.config.Workers >= c.next+1 {
@@ -261,6 +266,9 @@ func (c *Collector) Stats() {
 	}
 
 	res := "\n-> total bytes of data written to disk: " + humanize.Bytes(uint64(c.totalBytesWritten)) + "\n"
+	if c.numFlowRecords > 0 {
+		res += "-> " + strconv.FormatInt(c.numFlowRecords, 10) + " flow records received from flow exporters\n"
+	}
 	if c.unkownPcapWriterAtomic.count > 0 {
 		res += "-> " + share(c.unkownPcapWriterAtomic.count, c.numPackets) + " of packets (" + strconv.FormatInt(c.unkownPcapWriterAtomic.count, 10) + ") written to unknown.pcap\n"
 	}
@@ -323,9 +331,6 @@ func (c *Collector) Init() (err error) {
 	encoder.InitLayerEncoders(c.config.EncoderConfig)
 	encoder.InitCustomEncoders(c.config.EncoderConfig)
 
-	// set payload capture
-	encoder.CapturePayload = c.config.EncoderConfig.IncludePayloads
-
 	// set pointer of collectors atomic counter map in encoder pkg
 	encoder.SetErrorMap(c.errorMap)
 
@@ -370,6 +375,11 @@ func (c *Collector) FreeOSMemory() {
 // PrintConfiguration dumps the current collector config to stdout
 func (c *Collector) PrintConfiguration() {
 
+	payloads := c.config.EncoderConfig.PayloadPolicy
+	if payloads == "" {
+		payloads = "none"
+	}
+
 	// print configuration as table
 	tui.Table(os.Stdout, []string{"Setting", "Value"}, [][]string{
 		{"Workers", strconv.Itoa(c.config.Workers)},
@@ -378,7 +388,7 @@ func (c *Collector) PrintConfiguration() {
 		{"Compression", strconv.FormatBool(c.config.EncoderConfig.Compression)},
 		{"PacketBuffer", strconv.Itoa(c.config.PacketBufferSize) + " packets"},
 		{"PacketContext", strconv.FormatBool(c.config.EncoderConfig.AddContext)},
-		{"Payloads", strconv.FormatBool(c.config.EncoderConfig.IncludePayloads)},
+		{"Payloads", payloads},
 	})
 	fmt.Println() // add a newline
 }
@@ -0,0 +1,201 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package collector
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/encoder"
+	"github.com/dreadl0ck/netcap/netflow"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+// base layers for the packet headers sampled by sFlow agents
+var sflowLayers = map[uint32]gopacket.LayerType{
+	netflow.SFlowProtoEthernet: layers.LayerTypeEthernet,
+	netflow.SFlowProtoIPv4:     layers.LayerTypeIPv4,
+	netflow.SFlowProtoIPv6:     layers.LayerTypeIPv6,
+}
+
+// flowDecoders holds a decoder per exporter, since the templates are only valid for a single exporter
+type flowDecoders map[string]*netflow.Decoder
+
+func (f flowDecoders) get(exporter string) *netflow.Decoder {
+	d, ok := f[exporter]
+	if !ok {
+		d = netflow.NewDecoder()
+		f[exporter] = d
+	}
+	return d
+}
+
+// CollectFlows listens for NetFlow v5, NetFlow v9, IPFIX and sFlow datagrams on the UDP address.
+// Flow records are written as Flow and Connection audit records,
+// packet headers sampled by sFlow agents are decoded like captured packets.
+func (c *Collector) CollectFlows(addr string) error {
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// initialize collector
+	if err := c.Init(); err != nil {
+		return err
+	}
+
+	encoder.LiveMode = true
+	fmt.Println("listening for NetFlow, IPFIX and sFlow datagrams on", conn.LocalAddr())
+
+	var (
+		decoders = make(flowDecoders)
+		buf      = make([]byte, 65535)
+	)
+	for {
+		n, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			return errors.Wrap(err, "Error reading datagram")
+		}
+
+		host, _, _ := net.SplitHostPort(src.String())
+		if _, err := c.handleFlowDatagram(decoders.get(host), buf[:n], time.Now()); err != nil {
+			c.errorMap.Inc(err.Error())
+		}
+	}
+}
+
+// CollectFlowFile reads NetFlow v5, NetFlow v9, IPFIX and sFlow datagrams from a file.
+// The file can either be a pcap or pcapng capture of the export traffic,
+// or contain the messages back to back, as written by the netflow exporter.
+func (c *Collector) CollectFlowFile(path string) error {
+
+	// stat input file
+	stat, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	clearLine()
+	println("opening", path+" | size:", humanize.Bytes(uint64(stat.Size())))
+	c.inputSize = stat.Size()
+
+	// initialize collector
+	if err := c.Init(); err != nil {
+		return err
+	}
+
+	decoders := make(flowDecoders)
+	if r, f, err := openPcap(path); err == nil {
+		defer f.Close()
+		err = c.collectFlowCapture(r, r.LinkType(), decoders)
+		if err != nil {
+			return err
+		}
+	} else if r, f, err := openPcapNG(path); err == nil {
+		defer f.Close()
+		err = c.collectFlowCapture(r, r.LinkType(), decoders)
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		// there are no timestamps for the messages, use the current time for sampled packets
+		var (
+			d  = decoders.get("")
+			ts = time.Now()
+		)
+		for len(data) > 0 {
+			n, err := c.handleFlowDatagram(d, data, ts)
+			if err != nil {
+				return errors.Wrap(err, "failed to decode message")
+			}
+			data = data[n:]
+		}
+	}
+
+	// only sampled packets are counted
+	c.numPackets = atomic.LoadInt64(&c.current)
+
+	c.cleanup()
+	return nil
+}
+
+// collectFlowCapture processes the UDP payloads of a capture with export traffic
+func (c *Collector) collectFlowCapture(r gopacket.PacketDataSource, linkType layers.LinkType, decoders flowDecoders) error {
+
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return errors.Wrap(err, "Error reading packet data")
+		}
+
+		p := gopacket.NewPacket(data, linkType, gopacket.Default)
+		udp, ok := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if !ok || p.NetworkLayer() == nil {
+			continue
+		}
+
+		exporter := p.NetworkLayer().NetworkFlow().Src().String()
+		if _, err := c.handleFlowDatagram(decoders.get(exporter), udp.Payload, ci.Timestamp); err != nil {
+			c.errorMap.Inc(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// handleFlowDatagram processes a NetFlow, IPFIX or sFlow datagram and returns its length
+func (c *Collector) handleFlowDatagram(d *netflow.Decoder, data []byte, ts time.Time) (int, error) {
+
+	if netflow.IsSFlow(data) {
+		samples, n, err := netflow.DecodeSFlow(data)
+		for _, s := range samples {
+			baseLayer, ok := sflowLayers[s.Protocol]
+			if !ok {
+				continue
+			}
+			c.printProgressLive()
+			c.decodePacket(s.Data, gopacket.CaptureInfo{
+				Timestamp:     ts,
+				CaptureLength: len(s.Data),
+				Length:        int(s.FrameLength),
+			}, baseLayer)
+		}
+		return n, err
+	}
+
+	records, n, err := d.Decode(data)
+	for _, r := range records {
+		encoder.AddFlowRecord(r)
+	}
+	atomic.AddInt64(&c.numFlowRecords, int64(len(records)))
+
+	return n, err
+}
@@ -32,9 +32,15 @@ func (c *Collector) handleRawPacketData(data []byte, ci gopacket.CaptureInfo) {
 	// show progress
 	c.printProgress()
 
-	// create a new gopacket with lazy decoding
 	// base layer is by default Ethernet
-	p := gopacket.NewPacket(data, c.config.BaseLayer, c.config.DecodeOptions)
+	c.decodePacket(data, ci, c.config.BaseLayer)
+}
+
+// decodePacket decodes the packet starting with the given layer and passes it to a worker
+func (c *Collector) decodePacket(data []byte, ci gopacket.CaptureInfo, baseLayer gopacket.Decoder) {
+
+	// create a new gopacket with lazy decoding
+	p := gopacket.NewPacket(data, baseLayer, c.config.DecodeOptions)
 	p.Metadata().Timestamp = ci.Timestamp
 	p.Metadata().CaptureInfo = ci
 
@@ -21,23 +21,33 @@ import (
 	"github.com/dreadl0ck/gopacket"
 )
 
+// packetInfo is a packet along with its capture-wide sequence number.
+type packetInfo struct {
+	p   gopacket.Packet
+	num int64
+}
+
 // worker spawns a new worker goroutine
 // and returns a channel for receiving input packets.
-func (c *Collector) worker() chan gopacket.Packet {
+func (c *Collector) worker() chan packetInfo {
 
 	// init channel to receive input packets
-	chanInput := make(chan gopacket.Packet, c.config.PacketBufferSize)
+	chanInput := make(chan packetInfo, c.config.PacketBufferSize)
 
 	// start worker
 	go func() {
 		for {
 			select {
-			case p := <-chanInput:
+			case info := <-chanInput:
 
 				// nil packet is used to exit goroutine
-				if p == nil {
+				if info.p == nil {
 					return
 				}
+				var (
+					p   = info.p
+					ctx *types.PacketContext
+				)
 
 				// iterate over all layers
 				for _, layer := range p.Layers() {
@@ -74,22 +84,9 @@ func (c *Collector) worker() chan gopacket.Packet {
 					// pick encoders from the encoderMap by looking up the layer type
 					if encoders, ok := encoder.LayerEncoders[layer.LayerType()]; ok {
 
-						var ctx = &types.PacketContext{}
-
-						if encoder.AddContext {
-
-							var (
-								netLayer       = p.NetworkLayer()
-								transportLayer = p.TransportLayer()
-							)
-							if netLayer != nil {
-								ctx.SrcIP = netLayer.NetworkFlow().Src().String()
-								ctx.DstIP = netLayer.NetworkFlow().Dst().String()
-							}
-							if transportLayer != nil {
-								ctx.SrcPort = transportLayer.TransportFlow().Src().String()
-								ctx.DstPort = transportLayer.TransportFlow().Dst().String()
-							}
+						// the context is created once and shared by all layers of the packet
+						if ctx == nil {
+							ctx = newPacketContext(p, info.num)
 						}
 
 						for _, e := range encoders {
@@ -161,9 +158,38 @@ func (c *Collector) worker() chan gopacket.Packet {
 	return chanInput
 }
 
+// newPacketContext creates the flow context for the layers of a packet,
+// it remains empty if adding the context has not been requested.
+func newPacketContext(p gopacket.Packet, num int64) *types.PacketContext {
+
+	ctx := &types.PacketContext{}
+	if !encoder.AddContext {
+		return ctx
+	}
+
+	var (
+		netLayer       = p.NetworkLayer()
+		transportLayer = p.TransportLayer()
+	)
+	if netLayer != nil {
+		ctx.SrcIP = netLayer.NetworkFlow().Src().String()
+		ctx.DstIP = netLayer.NetworkFlow().Dst().String()
+	}
+	if transportLayer != nil {
+		ctx.SrcPort = transportLayer.TransportFlow().Src().String()
+		ctx.DstPort = transportLayer.TransportFlow().Dst().String()
+	}
+	ctx.CommunityID = encoder.CommunityID(p)
+	ctx.ConnUID = encoder.ConnectionUID(p)
+	ctx.FlowUID = encoder.FlowUID(p)
+	ctx.PacketNumber = num
+
+	return ctx
+}
+
 // spawn the configured number of workers
-func (c *Collector) initWorkers() []chan gopacket.Packet {
-	workers := make([]chan gopacket.Packet, c.config.Workers)
+func (c *Collector) initWorkers() []chan packetInfo {
+	workers := make([]chan packetInfo, c.config.Workers)
 	for i := range workers {
 		workers[i] = c.worker()
 	}
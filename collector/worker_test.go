@@ -0,0 +1,71 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package collector
+
+import (
+	"net"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/encoder"
+)
+
+func TestNewPacketContext(t *testing.T) {
+
+	var (
+		ip = &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolUDP,
+			SrcIP:    net.IP{10, 0, 0, 1},
+			DstIP:    net.IP{10, 0, 0, 2},
+		}
+		udp = &layers.UDP{SrcPort: 40000, DstPort: 53}
+		buf = gopacket.NewSerializeBuffer()
+	)
+	if err := udp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatal(err)
+	}
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, udp, gopacket.Payload("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+
+	defer func(v bool) {
+		encoder.AddContext = v
+	}(encoder.AddContext)
+
+	// the context remains empty if it has not been requested
+	encoder.AddContext = false
+	if ctx := newPacketContext(p, 7); ctx.PacketNumber != 0 || ctx.ConnUID != "" || ctx.FlowUID != "" {
+		t.Fatal("expected an empty context, got", ctx)
+	}
+
+	encoder.AddContext = true
+	ctx := newPacketContext(p, 7)
+	if ctx.PacketNumber != 7 {
+		t.Fatal("expected packet number 7, got", ctx.PacketNumber)
+	}
+	if ctx.SrcIP != "10.0.0.1" || ctx.DstIP != "10.0.0.2" || ctx.SrcPort != "40000" || ctx.DstPort != "53" {
+		t.Fatal("unexpected endpoints", ctx)
+	}
+	if ctx.ConnUID != encoder.ConnectionUID(p) || ctx.FlowUID != encoder.FlowUID(p) || ctx.CommunityID != encoder.CommunityID(p) {
+		t.Fatal("unexpected identifiers", ctx)
+	}
+	if next := newPacketContext(p, 8); next.PacketNumber != 8 || next.ConnUID != ctx.ConnUID || next.FlowUID != ctx.FlowUID {
+		t.Fatal("unexpected context for the next packet of the flow", next)
+	}
+}
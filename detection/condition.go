@@ -0,0 +1,212 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package detection
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/pkg/errors"
+)
+
+// condition is a compiled rule condition.
+type condition func(r types.AuditRecord) bool
+
+// aggregation is the part of the condition following the pipe,
+// e.g. count(DstIP) by SrcIP > 10
+type aggregation struct {
+	field     string // count distinct values of this field, empty to count records
+	by        string // group by this field, empty for a single group
+	op        string
+	threshold float64
+}
+
+var aggregationExpr = regexp.MustCompile(`^count\(\s*([\w.]*)\s*\)\s*(?:by\s+([\w.]+)\s*)?(>=|<=|==|!=|>|<)\s*([0-9.]+)$`)
+
+// compileCondition compiles a condition with an optional aggregation.
+func compileCondition(src string, selections map[string]*selection) (condition, *aggregation, error) {
+
+	var agg *aggregation
+	if parts := strings.SplitN(src, "|", 2); len(parts) == 2 {
+		m := aggregationExpr.FindStringSubmatch(strings.TrimSpace(parts[1]))
+		if m == nil {
+			return nil, nil, errors.Errorf("invalid aggregation %q", strings.TrimSpace(parts[1]))
+		}
+		threshold, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		agg = &aggregation{
+			field:     m[1],
+			by:        m[2],
+			op:        m[3],
+			threshold: threshold,
+		}
+		src = parts[0]
+	}
+
+	c := &condParser{
+		toks:       strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(src)),
+		selections: selections,
+	}
+	if len(c.toks) == 0 {
+		return nil, nil, errors.New("empty condition")
+	}
+	cond, err := c.or()
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.pos != len(c.toks) {
+		return nil, nil, errors.Errorf("unexpected %q", c.toks[c.pos])
+	}
+	return cond, agg, nil
+}
+
+// condParser is a recursive descent parser for conditions.
+type condParser struct {
+	toks       []string
+	pos        int
+	selections map[string]*selection
+}
+
+func (c *condParser) peek() string {
+	if c.pos < len(c.toks) {
+		return c.toks[c.pos]
+	}
+	return ""
+}
+
+func (c *condParser) next() string {
+	t := c.peek()
+	c.pos++
+	return t
+}
+
+func (c *condParser) or() (condition, error) {
+	left, err := c.and()
+	if err != nil {
+		return nil, err
+	}
+	for c.peek() == "or" {
+		c.pos++
+		right, err := c.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r types.AuditRecord) bool {
+			return l(r) || right(r)
+		}
+	}
+	return left, nil
+}
+
+func (c *condParser) and() (condition, error) {
+	left, err := c.not()
+	if err != nil {
+		return nil, err
+	}
+	for c.peek() == "and" {
+		c.pos++
+		right, err := c.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r types.AuditRecord) bool {
+			return l(r) && right(r)
+		}
+	}
+	return left, nil
+}
+
+func (c *condParser) not() (condition, error) {
+	if c.peek() == "not" {
+		c.pos++
+		n, err := c.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(r types.AuditRecord) bool {
+			return !n(r)
+		}, nil
+	}
+	return c.primary()
+}
+
+func (c *condParser) primary() (condition, error) {
+	tok := c.next()
+	switch tok {
+	case "":
+		return nil, errors.New("unexpected end of condition")
+	case "(":
+		cond, err := c.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := c.next(); t != ")" {
+			return nil, errors.Errorf("expected ), got %q", t)
+		}
+		return cond, nil
+	case "1", "any", "all":
+		if c.next() != "of" {
+			return nil, errors.Errorf("expected of after %s", tok)
+		}
+		return c.of(tok == "all", c.next())
+	}
+
+	sel, ok := c.selections[tok]
+	if !ok {
+		return nil, errors.Errorf("unknown selection %q", tok)
+	}
+	return sel.match, nil
+}
+
+// of compiles '1 of pattern' and 'all of pattern' expressions.
+func (c *condParser) of(all bool, pattern string) (condition, error) {
+
+	if pattern == "them" {
+		pattern = "*"
+	}
+
+	var names []string
+	for name := range c.selections {
+		if ok, _ := path.Match(pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.Errorf("no selection matches %q", pattern)
+	}
+
+	// sort for a deterministic evaluation order
+	sort.Strings(names)
+	var sels []*selection
+	for _, n := range names {
+		sels = append(sels, c.selections[n])
+	}
+
+	return func(r types.AuditRecord) bool {
+		for _, s := range sels {
+			if s.match(r) != all {
+				return !all
+			}
+		}
+		return all
+	}, nil
+}
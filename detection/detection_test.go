@@ -0,0 +1,178 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package detection
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func mustParse(t *testing.T, src string) *Rule {
+	r, err := ParseRule([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSelection(t *testing.T) {
+
+	r := mustParse(t, `
+title: Scripted HTTP client
+type: HTTP
+detection:
+  selection:
+    UserAgent|re: '^(curl|python)'
+    DstIP|cidr:
+      - 10.0.0.0/8
+      - 192.168.0.0/16
+  post:
+    Method: POST
+  admin:
+    URL|contains: admin
+  status:
+    StatusCode|gte: 500
+  condition: selection and not (post or 1 of a*) or status
+`)
+
+	tests := []struct {
+		rec   *types.HTTP
+		match bool
+	}{
+		{&types.HTTP{UserAgent: "curl/7.58.0", DstIP: "10.1.2.3", Method: "GET"}, true},
+		{&types.HTTP{UserAgent: "Python-urllib/3.7", DstIP: "192.168.1.1", Method: "GET"}, true},
+		{&types.HTTP{UserAgent: "curl/7.58.0", DstIP: "8.8.8.8", Method: "GET"}, false},
+		{&types.HTTP{UserAgent: "Mozilla/5.0", DstIP: "10.1.2.3", Method: "GET"}, false},
+		{&types.HTTP{UserAgent: "curl/7.58.0", DstIP: "10.1.2.3", Method: "post"}, false},
+		{&types.HTTP{UserAgent: "curl/7.58.0", DstIP: "10.1.2.3", URL: "/ADMIN/login"}, false},
+		{&types.HTTP{UserAgent: "Mozilla/5.0", StatusCode: 503}, true},
+	}
+	for i, test := range tests {
+		if got := r.cond(test.rec); got != test.match {
+			t.Errorf("%d: expected %v, got %v", i, test.match, got)
+		}
+	}
+}
+
+func TestNestedFields(t *testing.T) {
+
+	r := mustParse(t, `
+title: Long DNS query
+type: DNS
+detection:
+  selection:
+    QR: false
+    Questions.Name|len|gt: 20
+  wildcard:
+    Questions.Name: '*.example.com'
+  condition: all of them
+`)
+
+	q := func(names ...string) *types.DNS {
+		d := &types.DNS{}
+		for _, n := range names {
+			d.Questions = append(d.Questions, &types.DNSQuestion{Name: []byte(n)})
+		}
+		return d
+	}
+
+	if !r.cond(q("short.com", "averyveryverylong.example.com")) {
+		t.Error("expected match for any element")
+	}
+	if r.cond(q("a.example.com")) {
+		t.Error("expected no match for short name")
+	}
+	if r.cond(q()) {
+		t.Error("expected no match without questions")
+	}
+	resp := q("averyveryverylong.example.com")
+	resp.QR = true
+	if r.cond(resp) {
+		t.Error("expected no match for response")
+	}
+}
+
+func TestAggregation(t *testing.T) {
+
+	r := mustParse(t, `
+title: Horizontal scan
+type: HTTP
+detection:
+  selection:
+    Method: GET
+  timeframe: 10s
+  condition: selection | count(DstIP) by SrcIP >= 3
+`)
+	e := newEngine([]*Rule{r})
+
+	req := func(sec int, src, dst string) *types.HTTP {
+		return &types.HTTP{
+			Timestamp: fmt.Sprintf("%d.000000", 1500000000+sec),
+			Method:    "GET",
+			SrcIP:     src,
+			DstIP:     dst,
+		}
+	}
+
+	var alerts []*types.Alert
+	for _, h := range []*types.HTTP{
+		req(0, "10.0.0.1", "10.0.0.2"),
+		req(1, "10.0.0.1", "10.0.0.2"), // duplicate destination
+		req(2, "10.0.0.9", "10.0.0.3"), // other group
+		req(3, "10.0.0.1", "10.0.0.3"),
+		req(20, "10.0.0.1", "10.0.0.4"), // previous events are outside the timeframe
+		req(21, "10.0.0.1", "10.0.0.5"),
+		req(22, "10.0.0.1", "10.0.0.6"),
+	} {
+		alerts = append(alerts, e.Process(h)...)
+	}
+
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	a := alerts[0]
+	if a.Group != "10.0.0.1" || a.Count != 3 || a.Timestamp != "1500000022.000000" {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, src := range []string{
+		"title: a\ntype: Unknown\ndetection:\n  s:\n    A: 1\n  condition: s",
+		"title: a\ntype: HTTP\ndetection:\n  s:\n    Unknown: 1\n  condition: s",
+		"title: a\ntype: HTTP\ndetection:\n  s:\n    Method|foo: GET\n  condition: s",
+		"title: a\ntype: HTTP\ndetection:\n  s:\n    Method: GET\n  condition: s and other",
+		"title: a\ntype: HTTP\ndetection:\n  s:\n    Method: GET\n  condition: s | sum() > 1",
+		"title: a\ntype: HTTP\ndetection:\n  s:\n    Method: GET\n  condition: s | count() by Unknown > 1",
+		"title: a\ntype: HTTP\nseverity: urgent\ndetection:\n  s:\n    Method: GET\n  condition: s",
+		"title: a\ntype: HTTP\ndetection:\n  s:\n    StatusCode|gt: abc\n  condition: s",
+	} {
+		if _, err := ParseRule([]byte(src)); err == nil {
+			t.Errorf("expected error for rule:\n%s", strings.TrimSpace(src))
+		}
+	}
+}
+
+func TestExampleRules(t *testing.T) {
+	e, err := NewEngine("rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.NumRules() == 0 {
+		t.Fatal("no rules loaded")
+	}
+}
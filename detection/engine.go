@@ -0,0 +1,256 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package detection
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/pkg/errors"
+)
+
+// sweepInterval is the number of processed records for a rule,
+// after which groups without events in the current timeframe are removed.
+const sweepInterval = 10000
+
+// Engine evaluates detection rules on audit records.
+// It is safe for concurrent use.
+type Engine struct {
+	// rules by audit record type name
+	rules map[string][]*Rule
+	num   int
+}
+
+// NewEngine loads the rules from a file or directory.
+func NewEngine(path string) (*Engine, error) {
+	rules, err := loadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return newEngine(rules), nil
+}
+
+func newEngine(rules []*Rule) *Engine {
+	e := &Engine{
+		rules: make(map[string][]*Rule),
+		num:   len(rules),
+	}
+	for _, r := range rules {
+		e.rules[r.Type] = append(e.rules[r.Type], r)
+	}
+	return e
+}
+
+// NumRules returns the number of loaded rules.
+func (e *Engine) NumRules() int {
+	return e.num
+}
+
+// HasRules checks if there are rules for the named audit record type.
+func (e *Engine) HasRules(typ string) bool {
+	return len(e.rules[typ]) > 0
+}
+
+// Process evaluates all rules for the type of the record and returns the generated alerts.
+func (e *Engine) Process(record types.AuditRecord) (alerts []*types.Alert) {
+
+	t := reflect.TypeOf(record)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for _, r := range e.rules[t.Name()] {
+		if !r.cond(record) {
+			continue
+		}
+		if r.agg == nil {
+			alerts = append(alerts, r.newAlert(record, "", 0))
+			continue
+		}
+		if a := r.aggregate(record); a != nil {
+			alerts = append(alerts, a)
+		}
+	}
+
+	return alerts
+}
+
+// ProcessFile evaluates the rules on all records of the audit record file at path
+// and calls handle for every generated alert.
+func (e *Engine) ProcessFile(path string, handle func(*types.Alert)) error {
+
+	r, err := netcap.Open(path, netcap.DefaultBufferSize)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var (
+		header = r.ReadHeader()
+		record = netcap.InitRecord(header.Type)
+	)
+	p, ok := record.(types.AuditRecord)
+	if !ok {
+		return errors.Errorf("type %s does not implement the types.AuditRecord interface", header.Type)
+	}
+
+	// skip files without rules for the type
+	if !e.HasRules(strings.TrimPrefix(header.Type.String(), "NC_")) {
+		return nil
+	}
+
+	for {
+		err := r.Next(record)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		for _, a := range e.Process(p) {
+			handle(a)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) newAlert(record types.AuditRecord, group string, count int64) *types.Alert {
+	return &types.Alert{
+		Timestamp:   record.Time(),
+		RuleID:      r.ID,
+		Title:       r.Title,
+		Description: r.Description,
+		Severity:    r.Severity,
+		Tags:        r.Tags,
+		RecordType:  r.Type,
+		SrcIP:       record.Src(),
+		DstIP:       record.Dst(),
+		Group:       group,
+		Count:       count,
+	}
+}
+
+// window holds the events of an aggregation group within the timeframe
+type window struct {
+	times  []time.Time
+	values []string
+}
+
+// aggregate adds the record to its group and returns an alert if the threshold has been exceeded.
+// the group is reset after an alert, so the threshold must be exceeded again for the next alert.
+func (r *Rule) aggregate(record types.AuditRecord) *types.Alert {
+
+	var (
+		ts    = utils.StringToTime(record.Time())
+		group string
+		val   string
+	)
+	if r.agg.by != "" {
+		group = firstString(record, r.agg.by)
+	}
+	if r.agg.field != "" {
+		val = firstString(record, r.agg.field)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events++
+	if r.events%sweepInterval == 0 {
+		r.sweep(ts)
+	}
+
+	w, ok := r.groups[group]
+	if !ok {
+		w = &window{}
+		r.groups[group] = w
+	}
+	w.times = append(w.times, ts)
+	w.values = append(w.values, val)
+
+	// drop events outside of the timeframe
+	if r.timeframe > 0 {
+		var (
+			start = ts.Add(-r.timeframe)
+			i     = 0
+		)
+		for i < len(w.times) && w.times[i].Before(start) {
+			i++
+		}
+		w.times, w.values = w.times[i:], w.values[i:]
+	}
+
+	// count records or distinct values
+	count := len(w.times)
+	if r.agg.field != "" {
+		distinct := make(map[string]struct{})
+		for _, v := range w.values {
+			distinct[v] = struct{}{}
+		}
+		count = len(distinct)
+	}
+
+	if !compare(float64(count), r.agg.op, r.agg.threshold) {
+		return nil
+	}
+
+	delete(r.groups, group)
+
+	return r.newAlert(record, group, int64(count))
+}
+
+// sweep removes groups without events in the timeframe before ts
+// must be called with the lock held
+func (r *Rule) sweep(ts time.Time) {
+	if r.timeframe == 0 {
+		return
+	}
+	start := ts.Add(-r.timeframe)
+	for g, w := range r.groups {
+		if len(w.times) == 0 || w.times[len(w.times)-1].Before(start) {
+			delete(r.groups, g)
+		}
+	}
+}
+
+func compare(a float64, op string, b float64) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case "==":
+		return a == b
+	default:
+		return a != b
+	}
+}
+
+// firstString returns the first value of the field as string.
+func firstString(record types.AuditRecord, field string) string {
+	vals := resolve(record, strings.Split(field, "."))
+	if len(vals) == 0 {
+		return ""
+	}
+	return toString(vals[0])
+}
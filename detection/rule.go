@@ -0,0 +1,244 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package detection implements a rule engine for sigma style detection rules,
+// that are evaluated on the fields of netcap audit records.
+//
+// A rule is written in YAML and applies to a single audit record type:
+//
+//	title: Scripted HTTP client to internal host
+//	id: http-scripted-client-internal
+//	severity: medium
+//	type: HTTP
+//	detection:
+//	  selection:
+//	    UserAgent|re: '^(curl|python)'
+//	    DstIP|cidr:
+//	      - 10.0.0.0/8
+//	      - 192.168.0.0/16
+//	  condition: selection
+//
+// Selections map field names to values. All fields of a selection must match,
+// a list of values matches if any of the values matches, unless the 'all' modifier is used.
+// Nested fields are separated with a dot, e.g. Questions.Name, for repeated fields any element may match.
+// SrcIP and DstIP are available for all record types.
+//
+// Supported modifiers: contains, startswith, endswith, re, cidr, gt, gte, lt, lte, len and all.
+// String comparisons are case insensitive, plain values may contain * wildcards.
+//
+// The condition combines selections with and, or, not, parentheses and
+// '1 of' / 'all of' expressions over selection name patterns or 'them'.
+// It may be followed by an aggregation that is evaluated over the timeframe of the detection,
+// e.g. 'selection | count() by SrcIP > 100' or 'selection | count(DstIP) by SrcIP > 20'.
+// Timestamps of the audit records are used for the time windows, so live and offline evaluation behave the same.
+package detection
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Severities in ascending order.
+var Severities = []string{"informational", "low", "medium", "high", "critical"}
+
+// Rule is a detection rule for a single audit record type.
+type Rule struct {
+	Title       string                 `yaml:"title"`
+	ID          string                 `yaml:"id"`
+	Description string                 `yaml:"description"`
+	Severity    string                 `yaml:"severity"`
+	Tags        []string               `yaml:"tags"`
+	Type        string                 `yaml:"type"`
+	Detection   map[string]interface{} `yaml:"detection"`
+
+	cond      condition
+	agg       *aggregation
+	timeframe time.Duration
+
+	// aggregation state
+	groups map[string]*window
+	events int
+	mu     sync.Mutex
+}
+
+// LoadRule parses a single rule from the file at path.
+func LoadRule(path string) (*Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseRule(data)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return r, nil
+}
+
+// ParseRule parses and compiles a rule from its YAML representation.
+func ParseRule(data []byte) (*Rule, error) {
+
+	r := &Rule{}
+	if err := yaml.UnmarshalStrict(data, r); err != nil {
+		return nil, err
+	}
+
+	if r.Title == "" {
+		return nil, errors.New("missing title")
+	}
+	if r.ID == "" {
+		r.ID = strings.ToLower(strings.Replace(r.Title, " ", "-", -1))
+	}
+
+	// make sure the record type exists
+	typ, ok := types.Type_value["NC_"+r.Type]
+	if !ok {
+		return nil, errors.Errorf("unknown audit record type %q", r.Type)
+	}
+
+	if r.Severity == "" {
+		r.Severity = "medium"
+	}
+	if !validSeverity(r.Severity) {
+		return nil, errors.Errorf("invalid severity %q, must be one of %v", r.Severity, Severities)
+	}
+
+	var (
+		selections = make(map[string]*selection)
+		cond       string
+	)
+	for key, val := range r.Detection {
+		switch key {
+		case "condition":
+			s, ok := val.(string)
+			if !ok {
+				return nil, errors.New("condition must be a string")
+			}
+			cond = s
+		case "timeframe":
+			s, ok := val.(string)
+			if !ok {
+				return nil, errors.New("timeframe must be a string, e.g. 1m")
+			}
+			d, err := parseTimeframe(s)
+			if err != nil {
+				return nil, err
+			}
+			r.timeframe = d
+		default:
+			sel, err := newSelection(val, netcap.InitRecord(types.Type(typ)))
+			if err != nil {
+				return nil, errors.Wrap(err, "selection "+key)
+			}
+			selections[key] = sel
+		}
+	}
+	if cond == "" {
+		return nil, errors.New("missing condition")
+	}
+
+	var err error
+	r.cond, r.agg, err = compileCondition(cond, selections)
+	if err != nil {
+		return nil, errors.Wrap(err, "condition")
+	}
+	if r.agg != nil {
+		if err := checkField(netcap.InitRecord(types.Type(typ)), r.agg.field); err != nil {
+			return nil, errors.Wrap(err, "aggregation")
+		}
+		if err := checkField(netcap.InitRecord(types.Type(typ)), r.agg.by); err != nil {
+			return nil, errors.Wrap(err, "aggregation")
+		}
+		r.groups = make(map[string]*window)
+	}
+
+	return r, nil
+}
+
+func validSeverity(s string) bool {
+	for _, sev := range Severities {
+		if s == sev {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeframe parses a duration, with support for days, e.g. 1d.
+func parseTimeframe(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, errors.Errorf("invalid timeframe %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid timeframe %q", s)
+	}
+	return d, nil
+}
+
+// loadRules parses all files with a .yml or .yaml extension in the directory at path.
+// If path points to a file, only this file will be loaded.
+func loadRules(path string) ([]*Rule, error) {
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		r, err := LoadRule(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*Rule{r}, nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rules []*Rule
+		ids   = make(map[string]string)
+	)
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		file := filepath.Join(path, f.Name())
+		r, err := LoadRule(file)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := ids[r.ID]; ok {
+			return nil, errors.Errorf("duplicate rule id %s in %s, first defined in %s", r.ID, file, prev)
+		}
+		ids[r.ID] = file
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
@@ -0,0 +1,420 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package detection
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/pkg/errors"
+)
+
+// selection matches if any of its field groups matches.
+// all field matchers inside a group must match.
+type selection struct {
+	groups [][]*fieldMatcher
+}
+
+func (s *selection) match(r types.AuditRecord) bool {
+	for _, group := range s.groups {
+		matched := true
+		for _, f := range group {
+			if !f.match(r) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// newSelection compiles a selection, which is either a map of fields or a list of maps.
+// the field names are checked against the given record.
+func newSelection(val interface{}, record interface{}) (*selection, error) {
+	s := &selection{}
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		group, err := newGroup(v, record)
+		if err != nil {
+			return nil, err
+		}
+		s.groups = append(s.groups, group)
+	case []interface{}:
+		for _, item := range v {
+			m, ok := item.(map[interface{}]interface{})
+			if !ok {
+				return nil, errors.New("list items must be maps of fields")
+			}
+			group, err := newGroup(m, record)
+			if err != nil {
+				return nil, err
+			}
+			s.groups = append(s.groups, group)
+		}
+	default:
+		return nil, errors.New("must be a map of fields or a list of maps")
+	}
+	return s, nil
+}
+
+func newGroup(m map[interface{}]interface{}, record interface{}) (group []*fieldMatcher, err error) {
+	for k, v := range m {
+		key, ok := k.(string)
+		if !ok {
+			return nil, errors.Errorf("invalid field %v", k)
+		}
+		f, err := newFieldMatcher(key, v)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkField(record, f.field); err != nil {
+			return nil, err
+		}
+		group = append(group, f)
+	}
+	return group, nil
+}
+
+// fieldMatcher compares the values of a field using an operator.
+type fieldMatcher struct {
+	field  string
+	path   []string
+	op     string
+	length bool
+	all    bool
+	values []*value
+}
+
+// value is a compiled value from a rule.
+type value struct {
+	str   string
+	num   float64
+	isNum bool
+	re    *regexp.Regexp
+	ipnet *net.IPNet
+}
+
+// newFieldMatcher compiles a field definition like 'Questions.Name|len|gt' and its values.
+func newFieldMatcher(key string, val interface{}) (*fieldMatcher, error) {
+	var (
+		parts = strings.Split(key, "|")
+		f     = &fieldMatcher{
+			field: parts[0],
+			path:  strings.Split(parts[0], "."),
+			op:    "eq",
+		}
+	)
+	for _, m := range parts[1:] {
+		switch m {
+		case "len":
+			f.length = true
+		case "all":
+			f.all = true
+		case "contains", "startswith", "endswith", "re", "cidr", "gt", "gte", "lt", "lte":
+			if f.op != "eq" {
+				return nil, errors.Errorf("field %s: only one operator allowed", f.field)
+			}
+			f.op = m
+		default:
+			return nil, errors.Errorf("field %s: unknown modifier %s", f.field, m)
+		}
+	}
+
+	var raw []interface{}
+	if list, ok := val.([]interface{}); ok {
+		raw = list
+	} else {
+		raw = []interface{}{val}
+	}
+	if len(raw) == 0 {
+		return nil, errors.Errorf("field %s: no values", f.field)
+	}
+
+	for _, r := range raw {
+		v, err := f.newValue(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "field "+f.field)
+		}
+		f.values = append(f.values, v)
+	}
+	return f, nil
+}
+
+func (f *fieldMatcher) newValue(raw interface{}) (*value, error) {
+	v := &value{}
+	if raw != nil {
+		v.str = fmt.Sprint(raw)
+	}
+	switch f.op {
+	case "gt", "gte", "lt", "lte":
+		n, err := strconv.ParseFloat(v.str, 64)
+		if err != nil {
+			return nil, errors.Errorf("%q is not a number", v.str)
+		}
+		v.num = n
+		v.isNum = true
+	case "re":
+		re, err := regexp.Compile("(?i)" + v.str)
+		if err != nil {
+			return nil, err
+		}
+		v.re = re
+	case "cidr":
+		_, ipnet, err := net.ParseCIDR(v.str)
+		if err != nil {
+			return nil, err
+		}
+		v.ipnet = ipnet
+	case "eq":
+		if n, err := strconv.ParseFloat(v.str, 64); err == nil {
+			v.num = n
+			v.isNum = true
+		}
+		// plain values with wildcards are converted to regular expressions
+		if strings.Contains(v.str, "*") {
+			parts := strings.Split(v.str, "*")
+			for i := range parts {
+				parts[i] = regexp.QuoteMeta(parts[i])
+			}
+			v.re = regexp.MustCompile("(?i)^" + strings.Join(parts, ".*") + "$")
+		}
+	}
+	v.str = strings.ToLower(v.str)
+	return v, nil
+}
+
+func (f *fieldMatcher) match(r types.AuditRecord) bool {
+	fields := resolve(r, f.path)
+
+	for _, v := range f.values {
+		matched := false
+		for _, field := range fields {
+			if f.matchValue(field, v) {
+				matched = true
+				break
+			}
+		}
+		if matched && !f.all {
+			return true
+		}
+		if !matched && f.all {
+			return false
+		}
+	}
+	return f.all
+}
+
+func (f *fieldMatcher) matchValue(field reflect.Value, v *value) bool {
+
+	if f.length {
+		n := float64(lengthOf(field))
+		switch f.op {
+		case "gt":
+			return n > v.num
+		case "gte":
+			return n >= v.num
+		case "lt":
+			return n < v.num
+		case "lte":
+			return n <= v.num
+		default:
+			return v.isNum && n == v.num
+		}
+	}
+
+	switch f.op {
+	case "gt", "gte", "lt", "lte":
+		n, ok := toNumber(field)
+		if !ok {
+			return false
+		}
+		switch f.op {
+		case "gt":
+			return n > v.num
+		case "gte":
+			return n >= v.num
+		case "lt":
+			return n < v.num
+		default:
+			return n <= v.num
+		}
+	case "cidr":
+		ip := net.ParseIP(toString(field))
+		return ip != nil && v.ipnet.Contains(ip)
+	case "re":
+		return v.re.MatchString(toString(field))
+	}
+
+	s := strings.ToLower(toString(field))
+	switch f.op {
+	case "contains":
+		return strings.Contains(s, v.str)
+	case "startswith":
+		return strings.HasPrefix(s, v.str)
+	case "endswith":
+		return strings.HasSuffix(s, v.str)
+	}
+
+	// eq
+	if v.re != nil {
+		return v.re.MatchString(s)
+	}
+	if v.isNum {
+		if n, ok := toNumber(field); ok {
+			return n == v.num
+		}
+	}
+	return s == v.str
+}
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// resolve returns the values for the field path.
+// repeated fields are expanded, so multiple values may be returned.
+// SrcIP and DstIP fall back to the Src() and Dst() methods of the record.
+func resolve(r types.AuditRecord, path []string) []reflect.Value {
+
+	vals := []reflect.Value{reflect.ValueOf(r)}
+	for i, name := range path {
+		var next []reflect.Value
+		for _, v := range vals {
+			for _, elem := range expand(v) {
+				if elem.Kind() != reflect.Struct {
+					continue
+				}
+				field := elem.FieldByName(name)
+				if !field.IsValid() {
+					if i == 0 && name == "SrcIP" {
+						next = append(next, reflect.ValueOf(r.Src()))
+					} else if i == 0 && name == "DstIP" {
+						next = append(next, reflect.ValueOf(r.Dst()))
+					}
+					continue
+				}
+				next = append(next, field)
+			}
+		}
+		vals = next
+	}
+
+	var out []reflect.Value
+	for _, v := range vals {
+		out = append(out, expand(v)...)
+	}
+	return out
+}
+
+// expand dereferences pointers and returns the elements of slices, except for byte slices.
+func expand(v reflect.Value) []reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice && v.Type() != bytesType {
+		var out []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, expand(v.Index(i))...)
+		}
+		return out
+	}
+	return []reflect.Value{v}
+}
+
+// checkField verifies that the field path exists on the record type.
+func checkField(record interface{}, field string) error {
+	if field == "" {
+		return nil
+	}
+	path := strings.Split(field, ".")
+	if len(path) == 1 && (field == "SrcIP" || field == "DstIP") {
+		return nil
+	}
+	t := reflect.TypeOf(record)
+	for _, name := range path {
+		for t.Kind() == reflect.Ptr || (t.Kind() == reflect.Slice && t != bytesType) {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return errors.Errorf("invalid field %s", field)
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return errors.Errorf("unknown field %s", field)
+		}
+		t = f.Type
+	}
+	return nil
+}
+
+func toString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Slice:
+		if v.Type() == bytesType {
+			return string(v.Bytes())
+		}
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// enums implement the Stringer interface
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func toNumber(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case reflect.String:
+		n, err := strconv.ParseFloat(v.String(), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func lengthOf(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len()
+	}
+	return len(toString(v))
+}
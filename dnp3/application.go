@@ -0,0 +1,455 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package dnp3
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// maximum number of object indexes collected per object header
+const maxIndexes = 100
+
+// Function codes of the application layer.
+const (
+	FuncConfirm             = 0
+	FuncRead                = 1
+	FuncWrite               = 2
+	FuncSelect              = 3
+	FuncOperate             = 4
+	FuncDirectOperate       = 5
+	FuncDirectOperateNR     = 6
+	FuncColdRestart         = 13
+	FuncWarmRestart         = 14
+	FuncStopApplication     = 18
+	FuncResponse            = 129
+	FuncUnsolicitedResponse = 130
+)
+
+// Application is the application layer header with the object headers of a fragment.
+type Application struct {
+	Control  uint8
+	Function uint8
+
+	// internal indications of responses, IIN1 in the upper byte
+	IIN uint16
+
+	Objects []ObjectHeader
+}
+
+// Sequence returns the application sequence number.
+func (a *Application) Sequence() uint8 {
+	return a.Control & 0x0f
+}
+
+// Confirm returns true if the receiver has to confirm the fragment.
+func (a *Application) Confirm() bool {
+	return a.Control&0x20 != 0
+}
+
+// Unsolicited returns true for unsolicited responses and their confirmations.
+func (a *Application) Unsolicited() bool {
+	return a.Control&0x10 != 0
+}
+
+// ObjectHeader describes the objects of a group and variation in the fragment.
+type ObjectHeader struct {
+	Group     uint8
+	Variation uint8
+	Qualifier uint8
+
+	// range of the object indexes, for range qualifiers
+	Start uint32
+	Stop  uint32
+
+	// number of objects
+	Count uint32
+
+	// indexes from the object prefixes, e.g. of the points of a control command
+	Indexes []uint32
+}
+
+// ParseApplication parses the application header and the object headers of a fragment.
+// parsing stops without an error at the first object with an unknown size,
+// the object headers parsed until then are returned.
+func ParseApplication(data []byte) (*Application, error) {
+	if len(data) < 2 {
+		return nil, ErrTruncated
+	}
+	a := &Application{
+		Control:  data[0],
+		Function: data[1],
+	}
+	data = data[2:]
+	if IsResponse(a.Function) {
+		if len(data) < 2 {
+			return a, ErrTruncated
+		}
+		a.IIN = binary.BigEndian.Uint16(data[:2])
+		data = data[2:]
+	}
+
+	for len(data) > 0 {
+		h, n, err := parseObjectHeader(data, a.Function)
+		if h != nil {
+			a.Objects = append(a.Objects, *h)
+		}
+		if err != nil {
+			return a, err
+		}
+		if n == 0 {
+			break
+		}
+		data = data[n:]
+	}
+	return a, nil
+}
+
+// parseObjectHeader parses an object header and skips the objects that follow it.
+// n is zero if the objects cannot be skipped.
+func parseObjectHeader(data []byte, function uint8) (h *ObjectHeader, n int, err error) {
+	if len(data) < 3 {
+		return nil, 0, ErrTruncated
+	}
+	h = &ObjectHeader{
+		Group:     data[0],
+		Variation: data[1],
+		Qualifier: data[2],
+	}
+	n = 3
+
+	var (
+		prefix = prefixSize(h.Qualifier >> 4 & 0x07)
+		rng    = h.Qualifier & 0x0f
+	)
+	switch rng {
+	case 0x00, 0x01, 0x02, 0x03, 0x04, 0x05:
+		// start and stop index or virtual address
+		size := 1 << (rng % 3)
+		if len(data) < n+2*size {
+			return h, 0, ErrTruncated
+		}
+		h.Start = readUint(data[n:], size)
+		h.Stop = readUint(data[n+size:], size)
+		if h.Stop >= h.Start {
+			h.Count = h.Stop - h.Start + 1
+		}
+		n += 2 * size
+	case 0x06:
+		// all objects of the group, no range field
+		return h, n, nil
+	case 0x07, 0x08, 0x09, 0x0b:
+		size := 1
+		switch rng {
+		case 0x08:
+			size = 2
+		case 0x09:
+			size = 4
+		}
+		if len(data) < n+size {
+			return h, 0, ErrTruncated
+		}
+		h.Count = readUint(data[n:], size)
+		n += size
+	default:
+		return h, 0, nil
+	}
+
+	if !hasObjects(function) {
+		return h, n, nil
+	}
+
+	size, bits, ok := ObjectSize(h.Group, h.Variation)
+	if bits && prefix == 0 {
+		// packed objects
+		length := (int(h.Count)*size + 7) / 8
+		if len(data) < n+length {
+			return h, 0, ErrTruncated
+		}
+		return h, n + length, nil
+	}
+
+	sizePrefix := h.Qualifier>>4&0x07 >= 4
+	if !ok && !sizePrefix {
+		return h, 0, nil
+	}
+	if size == 0 && prefix == 0 {
+		// objects without data, e.g. the class data of group 60
+		return h, n, nil
+	}
+
+	// every object occupies at least one byte
+	if uint64(h.Count) > uint64(len(data)-n) {
+		return h, 0, ErrTruncated
+	}
+
+	for i := uint32(0); i < h.Count; i++ {
+		if len(data) < n+prefix {
+			return h, 0, ErrTruncated
+		}
+		p := readUint(data[n:], prefix)
+		n += prefix
+		if sizePrefix {
+			size = int(p)
+		} else if prefix > 0 && len(h.Indexes) < maxIndexes {
+			h.Indexes = append(h.Indexes, p)
+		}
+		if len(data) < n+size {
+			return h, 0, ErrTruncated
+		}
+		n += size
+	}
+	return h, n, nil
+}
+
+// hasObjects returns false for the requests that only contain object headers without data
+func hasObjects(function uint8) bool {
+	switch function {
+	case FuncRead, 7, 8, 9, 10, 20, 21, 22:
+		return false
+	}
+	return true
+}
+
+// prefixSize returns the size of the object prefix for the prefix code of a qualifier
+func prefixSize(code uint8) int {
+	switch code {
+	case 1, 4:
+		return 1
+	case 2, 5:
+		return 2
+	case 3, 6:
+		return 4
+	}
+	return 0
+}
+
+func readUint(data []byte, size int) uint32 {
+	switch size {
+	case 1:
+		return uint32(data[0])
+	case 2:
+		return uint32(binary.LittleEndian.Uint16(data))
+	case 4:
+		return binary.LittleEndian.Uint32(data)
+	}
+	return 0
+}
+
+// objectSizes contains the size in bytes of the objects with a fixed size, by group and variation
+var objectSizes = map[[2]uint8]int{
+	{1, 2}: 1, {2, 1}: 1, {2, 2}: 7, {2, 3}: 3, {3, 2}: 1, {4, 1}: 1, {4, 2}: 7, {4, 3}: 3,
+	{10, 2}: 1, {11, 1}: 1, {11, 2}: 7, {12, 1}: 11, {12, 2}: 11, {13, 1}: 1, {13, 2}: 7,
+	{20, 1}: 5, {20, 2}: 3, {20, 5}: 4, {20, 6}: 2,
+	{21, 1}: 5, {21, 2}: 3, {21, 5}: 11, {21, 6}: 9, {21, 9}: 4, {21, 10}: 2,
+	{22, 1}: 5, {22, 2}: 3, {22, 5}: 11, {22, 6}: 9,
+	{23, 1}: 5, {23, 2}: 3, {23, 5}: 11, {23, 6}: 9,
+	{30, 1}: 5, {30, 2}: 3, {30, 3}: 4, {30, 4}: 2, {30, 5}: 5, {30, 6}: 9,
+	{31, 1}: 5, {31, 2}: 3, {31, 3}: 11, {31, 4}: 9, {31, 5}: 4, {31, 6}: 2, {31, 7}: 5, {31, 8}: 9,
+	{32, 1}: 5, {32, 2}: 3, {32, 3}: 11, {32, 4}: 9, {32, 5}: 5, {32, 6}: 9, {32, 7}: 11, {32, 8}: 15,
+	{33, 1}: 5, {33, 2}: 3, {33, 3}: 11, {33, 4}: 9, {33, 5}: 5, {33, 6}: 9, {33, 7}: 11, {33, 8}: 15,
+	{34, 1}: 2, {34, 2}: 4, {34, 3}: 4,
+	{40, 1}: 5, {40, 2}: 3, {40, 3}: 5, {40, 4}: 9,
+	{41, 1}: 5, {41, 2}: 3, {41, 3}: 5, {41, 4}: 9,
+	{42, 1}: 5, {42, 2}: 3, {42, 3}: 11, {42, 4}: 9, {42, 5}: 5, {42, 6}: 9, {42, 7}: 11, {42, 8}: 15,
+	{43, 1}: 5, {43, 2}: 3, {43, 3}: 11, {43, 4}: 9, {43, 5}: 5, {43, 6}: 9, {43, 7}: 11, {43, 8}: 15,
+	{50, 1}: 6, {50, 2}: 10, {50, 3}: 6, {50, 4}: 11, {51, 1}: 6, {51, 2}: 6, {52, 1}: 2, {52, 2}: 2,
+	{60, 1}: 0, {60, 2}: 0, {60, 3}: 0, {60, 4}: 0,
+}
+
+// ObjectSize returns the size of an object of the group and variation.
+// bits is true for packed objects, the size is given in bits then.
+// ok is false if the size is unknown or variable.
+func ObjectSize(group, variation uint8) (size int, bits bool, ok bool) {
+	switch [2]uint8{group, variation} {
+	case [2]uint8{1, 1}, [2]uint8{10, 1}, [2]uint8{12, 3}, [2]uint8{80, 1}:
+		return 1, true, true
+	case [2]uint8{3, 1}:
+		return 2, true, true
+	}
+	if group == 110 || group == 111 {
+		// octet strings, the variation is the length
+		return int(variation), false, true
+	}
+	size, ok = objectSizes[[2]uint8{group, variation}]
+	return size, false, ok
+}
+
+// IsResponse returns true for the function codes of responses.
+func IsResponse(function uint8) bool {
+	return function >= FuncResponse
+}
+
+// IsControl returns true for the function codes that change the state of an outstation,
+// like writes, control operations, restarts, application control and file operations.
+func IsControl(function uint8) bool {
+	switch {
+	case function >= FuncWrite && function <= 22:
+		return true
+	case function == 24, function == 25, function == 27, function == 31:
+		return true
+	}
+	return false
+}
+
+var functionNames = map[uint8]string{
+	0:   "CONFIRM",
+	1:   "READ",
+	2:   "WRITE",
+	3:   "SELECT",
+	4:   "OPERATE",
+	5:   "DIRECT_OPERATE",
+	6:   "DIRECT_OPERATE_NR",
+	7:   "IMMED_FREEZE",
+	8:   "IMMED_FREEZE_NR",
+	9:   "FREEZE_CLEAR",
+	10:  "FREEZE_CLEAR_NR",
+	11:  "FREEZE_AT_TIME",
+	12:  "FREEZE_AT_TIME_NR",
+	13:  "COLD_RESTART",
+	14:  "WARM_RESTART",
+	15:  "INITIALIZE_DATA",
+	16:  "INITIALIZE_APPL",
+	17:  "START_APPL",
+	18:  "STOP_APPL",
+	19:  "SAVE_CONFIG",
+	20:  "ENABLE_UNSOLICITED",
+	21:  "DISABLE_UNSOLICITED",
+	22:  "ASSIGN_CLASS",
+	23:  "DELAY_MEASURE",
+	24:  "RECORD_CURRENT_TIME",
+	25:  "OPEN_FILE",
+	26:  "CLOSE_FILE",
+	27:  "DELETE_FILE",
+	28:  "GET_FILE_INFO",
+	29:  "AUTHENTICATE_FILE",
+	30:  "ABORT_FILE",
+	31:  "ACTIVATE_CONFIG",
+	32:  "AUTHENTICATE_REQ",
+	33:  "AUTH_REQ_NO_ACK",
+	129: "RESPONSE",
+	130: "UNSOLICITED_RESPONSE",
+	131: "AUTHENTICATE_RESP",
+}
+
+// FunctionName returns the name of an application layer function code.
+func FunctionName(function uint8) string {
+	if name, ok := functionNames[function]; ok {
+		return name
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(function))
+}
+
+var (
+	primaryLinkFunctions = map[uint8]string{
+		0: "RESET_LINK_STATES",
+		1: "RESET_USER_PROCESS",
+		2: "TEST_LINK_STATES",
+		3: "CONFIRMED_USER_DATA",
+		4: "UNCONFIRMED_USER_DATA",
+		9: "REQUEST_LINK_STATUS",
+	}
+	secondaryLinkFunctions = map[uint8]string{
+		0:  "ACK",
+		1:  "NACK",
+		11: "LINK_STATUS",
+		15: "NOT_SUPPORTED",
+	}
+)
+
+// LinkFunctionName returns the name of the link layer function code of the header.
+func LinkFunctionName(h LinkHeader) string {
+	names := secondaryLinkFunctions
+	if h.Primary() {
+		names = primaryLinkFunctions
+	}
+	if name, ok := names[h.Function()]; ok {
+		return name
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(h.Function()))
+}
+
+var groupNames = map[uint8]string{
+	0:   "Device Attributes",
+	1:   "Binary Input",
+	2:   "Binary Input Event",
+	3:   "Double-bit Binary Input",
+	4:   "Double-bit Binary Input Event",
+	10:  "Binary Output",
+	11:  "Binary Output Event",
+	12:  "Binary Command",
+	13:  "Binary Command Event",
+	20:  "Counter",
+	21:  "Frozen Counter",
+	22:  "Counter Event",
+	23:  "Frozen Counter Event",
+	30:  "Analog Input",
+	31:  "Frozen Analog Input",
+	32:  "Analog Input Event",
+	33:  "Frozen Analog Input Event",
+	34:  "Analog Input Deadband",
+	40:  "Analog Output Status",
+	41:  "Analog Output",
+	42:  "Analog Output Event",
+	43:  "Analog Output Command Event",
+	50:  "Time and Date",
+	51:  "Common Time of Occurrence",
+	52:  "Time Delay",
+	60:  "Class Data",
+	70:  "File Control",
+	80:  "Internal Indications",
+	110: "Octet String",
+	111: "Octet String Event",
+	112: "Virtual Terminal Output",
+	113: "Virtual Terminal Event",
+	120: "Authentication",
+}
+
+// GroupName returns the name of an object group.
+func GroupName(group uint8) string {
+	if name, ok := groupNames[group]; ok {
+		return name
+	}
+	return "Group " + strconv.Itoa(int(group))
+}
+
+// iinFlags contains the names of the internal indication bits, starting with the most significant bit of IIN1
+var iinFlags = [16]string{
+	"DEVICE_RESTART",
+	"DEVICE_TROUBLE",
+	"LOCAL_CONTROL",
+	"NEED_TIME",
+	"CLASS_3_EVENTS",
+	"CLASS_2_EVENTS",
+	"CLASS_1_EVENTS",
+	"BROADCAST",
+	"RESERVED_1",
+	"RESERVED_2",
+	"CONFIG_CORRUPT",
+	"ALREADY_EXECUTING",
+	"EVENT_BUFFER_OVERFLOW",
+	"PARAMETER_ERROR",
+	"OBJECT_UNKNOWN",
+	"NO_FUNC_CODE_SUPPORT",
+}
+
+// IINFlags returns the names of the internal indications that are set.
+func IINFlags(iin uint16) []string {
+	var flags []string
+	for i, name := range iinFlags {
+		if iin&(0x8000>>uint(i)) != 0 {
+			flags = append(flags, name)
+		}
+	}
+	return flags
+}
@@ -0,0 +1,220 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package dnp3
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// frame builds a link layer frame with the CRCs for the user data
+func frame(control uint8, dst, src uint16, user []byte) []byte {
+	header := []byte{0x05, 0x64, byte(5 + len(user)), control, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(header[4:], dst)
+	binary.LittleEndian.PutUint16(header[6:], src)
+	data := appendCRC(header)
+	for len(user) > 0 {
+		n := len(user)
+		if n > blockSize {
+			n = blockSize
+		}
+		data = append(data, appendCRC(user[:n])...)
+		user = user[n:]
+	}
+	return data
+}
+
+func appendCRC(data []byte) []byte {
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, CRC(data))
+	return append(append([]byte{}, data...), crc...)
+}
+
+func TestParseFrame(t *testing.T) {
+	// reset link states from master 1024 to outstation 1
+	data := []byte{0x05, 0x64, 0x05, 0xc0, 0x01, 0x00, 0x00, 0x04, 0xe9, 0x21}
+	f, n, err := ParseFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || !f.FromMaster() || !f.Primary() || f.Function() != 0 || f.Destination != 1 || f.Source != 1024 {
+		t.Fatalf("unexpected frame: %+v", f)
+	}
+	if LinkFunctionName(f.LinkHeader) != "RESET_LINK_STATES" {
+		t.Fatal("unexpected link function", LinkFunctionName(f.LinkHeader))
+	}
+
+	data[9] = 0
+	if _, _, err = ParseFrame(data); err != ErrInvalidCRC {
+		t.Fatal("expected CRC error, got", err)
+	}
+	if _, _, err = ParseFrame(data[:5]); err != ErrTruncated {
+		t.Fatal("expected truncated error, got", err)
+	}
+	if _, _, err = ParseFrame([]byte{0x68, 0x04, 0x07}); err != ErrNoStart {
+		t.Fatal("expected start error, got", err)
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	user := []byte{0xc0, 0xc3, FuncRead, 60, 2, 0x06, 60, 3, 0x06, 60, 4, 0x06, 60, 1, 0x06}
+	data := frame(0xc4, 10, 1, user)
+	f, n, err := ParseFrame(data)
+	if err != nil || n != len(data) {
+		t.Fatal(err, n, len(data))
+	}
+
+	var r Reassembler
+	frag := r.Add(f)
+	if frag == nil || frag.Frames != 1 || !frag.Transport.First || !frag.Transport.Final {
+		t.Fatalf("unexpected fragment: %+v", frag)
+	}
+
+	a, err := ParseApplication(frag.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Function != FuncRead || a.Sequence() != 3 || IsControl(a.Function) || len(a.Objects) != 4 {
+		t.Fatalf("unexpected application: %+v", a)
+	}
+	if a.Objects[3].Group != 60 || a.Objects[3].Variation != 1 || a.Objects[3].Qualifier != 0x06 {
+		t.Fatalf("unexpected object: %+v", a.Objects[3])
+	}
+}
+
+func TestDirectOperate(t *testing.T) {
+	// CROB latch on for point 3, followed by an analog output for point 1
+	data := []byte{0xc1, FuncDirectOperate,
+		12, 1, 0x28, 0x01, 0x00, 0x03, 0x00, 0x03, 0x01, 0x64, 0, 0, 0, 0, 0, 0, 0, 0,
+		41, 2, 0x17, 0x01, 0x01, 0x10, 0x00, 0x00,
+	}
+	a, err := ParseApplication(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsControl(a.Function) || FunctionName(a.Function) != "DIRECT_OPERATE" {
+		t.Fatal("unexpected function", a.Function)
+	}
+	expected := []ObjectHeader{
+		{Group: 12, Variation: 1, Qualifier: 0x28, Count: 1, Indexes: []uint32{3}},
+		{Group: 41, Variation: 2, Qualifier: 0x17, Count: 1, Indexes: []uint32{1}},
+	}
+	if !reflect.DeepEqual(a.Objects, expected) {
+		t.Fatalf("unexpected objects: %+v", a.Objects)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	data := []byte{0xe2, FuncResponse, 0x90, 0x02,
+		30, 2, 0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x10, 0x00, 0x01, 0x20, 0x00,
+		1, 1, 0x00, 0x00, 0x09, 0xff, 0x03,
+		20, 1, 0x06,
+	}
+	a, err := ParseApplication(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Confirm() || a.Unsolicited() || a.Sequence() != 2 {
+		t.Fatal("unexpected control", a.Control)
+	}
+	if flags := IINFlags(a.IIN); !reflect.DeepEqual(flags, []string{"DEVICE_RESTART", "NEED_TIME", "OBJECT_UNKNOWN"}) {
+		t.Fatal("unexpected IIN flags", flags)
+	}
+	if len(a.Objects) != 3 {
+		t.Fatalf("unexpected objects: %+v", a.Objects)
+	}
+	if o := a.Objects[0]; o.Group != 30 || o.Start != 0 || o.Stop != 1 || o.Count != 2 {
+		t.Fatalf("unexpected object: %+v", o)
+	}
+	if o := a.Objects[1]; o.Group != 1 || o.Count != 10 {
+		t.Fatalf("unexpected object: %+v", o)
+	}
+	if GroupName(a.Objects[0].Group) != "Analog Input" {
+		t.Fatal("unexpected group name")
+	}
+}
+
+func TestUnknownObjectSize(t *testing.T) {
+	// the objects of group 0 have a variable size, parsing stops after the header
+	data := []byte{0xc0, FuncResponse, 0x00, 0x00, 0, 252, 0x00, 0x00, 0x00, 0x01, 0x02, 30, 2, 0x06}
+	a, err := ParseApplication(data)
+	if err != nil || len(a.Objects) != 1 || a.Objects[0].Variation != 252 {
+		t.Fatalf("unexpected result: %+v %v", a, err)
+	}
+	if _, err = ParseApplication([]byte{0xc0, FuncResponse, 0x00}); err != ErrTruncated {
+		t.Fatal("expected truncated error, got", err)
+	}
+}
+
+func TestObjectCount(t *testing.T) {
+	// class 0 data with a 4 byte count, the objects have no data
+	data := []byte{0xc0, FuncResponse, 0x00, 0x00, 60, 1, 0x09, 0xff, 0xff, 0xff, 0xff, 60, 2, 0x09, 0xff, 0xff, 0xff, 0xff}
+	start := time.Now()
+	a, err := ParseApplication(data)
+	if err != nil || len(a.Objects) != 2 || a.Objects[1].Count != 0xffffffff {
+		t.Fatalf("unexpected result: %+v %v", a, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatal("parsing took", d)
+	}
+
+	// the count exceeds the remaining data
+	data = []byte{0xc0, FuncResponse, 0x00, 0x00, 30, 2, 0x09, 0xff, 0xff, 0xff, 0xff, 0x01, 0x10, 0x00}
+	if _, err = ParseApplication(data); err != ErrTruncated {
+		t.Fatal("expected truncated error, got", err)
+	}
+}
+
+func TestReassembly(t *testing.T) {
+	app := []byte{0xc0, FuncResponse, 0x00, 0x00}
+	for i := 0; i < 100; i++ {
+		app = append(app, 30, 2, 0x00, byte(i), byte(i), 0x01, 0x10, 0x00)
+	}
+
+	var (
+		r      Reassembler
+		frag   *Fragment
+		frames int
+	)
+	for i, seq := 0, uint8(5); i < len(app); i, seq = i+200, seq+1 {
+		end := i + 200
+		th := seq & 0x3f
+		if i == 0 {
+			th |= 0x40
+		}
+		if end >= len(app) {
+			end = len(app)
+			th |= 0x80
+		}
+		f, _, err := ParseFrame(frame(0x44, 1, 10, append([]byte{th}, app[i:end]...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames++
+		if frag = r.Add(f); frag != nil && end != len(app) {
+			t.Fatal("fragment complete before the final segment")
+		}
+	}
+	if frag == nil || frag.Frames != frames || frag.Transport.Sequence != 5 || len(frag.Data) != len(app) {
+		t.Fatalf("unexpected fragment: %+v", frag)
+	}
+	a, err := ParseApplication(frag.Data)
+	if err != nil || len(a.Objects) != 100 {
+		t.Fatal("unexpected objects", err)
+	}
+	if r.Flush() != nil {
+		t.Fatal("unexpected incomplete fragment")
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package dnp3
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// the link layer header including its CRC
+	headerSize = 10
+
+	// the user data is split into blocks that are followed by a CRC each
+	blockSize = 16
+
+	// Port is the TCP and UDP port registered for DNP3
+	Port = 20000
+)
+
+var (
+	// ErrNoStart is returned if the data does not start with the start bytes of a frame.
+	ErrNoStart = errors.New("dnp3: missing start bytes")
+
+	// ErrInvalidLength is returned for frames with a length below the minimum.
+	ErrInvalidLength = errors.New("dnp3: invalid frame length")
+
+	// ErrInvalidCRC is returned if the CRC of the header or a data block does not match, the frame is decoded anyway.
+	ErrInvalidCRC = errors.New("dnp3: invalid CRC")
+
+	// ErrTruncated is returned if the data ends within a frame or an application fragment.
+	ErrTruncated = errors.New("dnp3: truncated data")
+)
+
+// LinkHeader is the header of a link layer frame.
+type LinkHeader struct {
+	Length      uint8
+	Control     uint8
+	Destination uint16
+	Source      uint16
+}
+
+// FromMaster returns true if the DIR bit is set.
+func (h LinkHeader) FromMaster() bool {
+	return h.Control&0x80 != 0
+}
+
+// Primary returns true if the PRM bit is set, the frame initiates a transaction.
+func (h LinkHeader) Primary() bool {
+	return h.Control&0x40 != 0
+}
+
+// Function returns the link layer function code.
+func (h LinkHeader) Function() uint8 {
+	return h.Control & 0x0f
+}
+
+// Frame is a link layer frame with the user data, the CRCs have been removed.
+type Frame struct {
+	LinkHeader
+	Data []byte
+}
+
+// FrameLength returns the size of the frame at the start of the data including all CRCs.
+// ok is false if the header is incomplete.
+func FrameLength(data []byte) (n int, ok bool, err error) {
+	if len(data) < 3 {
+		return 0, false, nil
+	}
+	if data[0] != 0x05 || data[1] != 0x64 {
+		return 0, false, ErrNoStart
+	}
+	if data[2] < 5 {
+		return 0, false, ErrInvalidLength
+	}
+	user := int(data[2]) - 5
+	return headerSize + user + 2*((user+blockSize-1)/blockSize), true, nil
+}
+
+// ParseFrame parses the frame at the start of the data and returns its size.
+// ErrInvalidCRC is returned together with the frame.
+func ParseFrame(data []byte) (*Frame, int, error) {
+	n, ok, err := FrameLength(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok || len(data) < n {
+		return nil, 0, ErrTruncated
+	}
+
+	f := &Frame{
+		LinkHeader: LinkHeader{
+			Length:      data[2],
+			Control:     data[3],
+			Destination: binary.LittleEndian.Uint16(data[4:6]),
+			Source:      binary.LittleEndian.Uint16(data[6:8]),
+		},
+	}
+	if !checkCRC(data[:8], data[8:10]) {
+		err = ErrInvalidCRC
+	}
+
+	remaining := int(f.Length) - 5
+	for off := headerSize; remaining > 0; {
+		size := remaining
+		if size > blockSize {
+			size = blockSize
+		}
+		if !checkCRC(data[off:off+size], data[off+size:off+size+2]) {
+			err = ErrInvalidCRC
+		}
+		f.Data = append(f.Data, data[off:off+size]...)
+		off += size + 2
+		remaining -= size
+	}
+	return f, n, err
+}
+
+// Transport is the transport function header, the first byte of the user data.
+type Transport struct {
+	Final    bool
+	First    bool
+	Sequence uint8
+}
+
+// ParseTransport parses the transport header.
+func ParseTransport(b byte) Transport {
+	return Transport{
+		Final:    b&0x80 != 0,
+		First:    b&0x40 != 0,
+		Sequence: b & 0x3f,
+	}
+}
+
+// Fragment is an application layer fragment that has been joined from the transport segments of several frames.
+type Fragment struct {
+	// header of the frame that contained the first segment
+	Link      LinkHeader
+	Transport Transport
+	Frames    int
+
+	// application data, empty for frames without user data
+	Data []byte
+}
+
+// Reassembler joins the transport segments of the frames sent in one direction.
+type Reassembler struct {
+	current *Fragment
+}
+
+// Add adds a frame and returns the fragment once it is complete.
+// frames without user data are returned as a fragment without data.
+func (r *Reassembler) Add(f *Frame) *Fragment {
+	if len(f.Data) == 0 {
+		return &Fragment{Link: f.LinkHeader, Frames: 1}
+	}
+
+	t := ParseTransport(f.Data[0])
+	if t.First || r.current == nil {
+		// a new first segment discards an incomplete fragment
+		r.current = &Fragment{Link: f.LinkHeader, Transport: t}
+	}
+	r.current.Frames++
+	r.current.Data = append(r.current.Data, f.Data[1:]...)
+
+	if !t.Final {
+		return nil
+	}
+	frag := r.current
+	r.current = nil
+	return frag
+}
+
+// Flush returns the incomplete fragment, if any.
+func (r *Reassembler) Flush() *Fragment {
+	frag := r.current
+	r.current = nil
+	return frag
+}
+
+// crcTable contains the CRC-16 of the DNP3 polynomial 0x3D65 for all bytes, in reflected form
+var crcTable = func() (t [256]uint16) {
+	for i := range t {
+		crc := uint16(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa6bc
+			} else {
+				crc >>= 1
+			}
+		}
+		t[i] = crc
+	}
+	return
+}()
+
+// CRC returns the DNP3 CRC of the data.
+func CRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc>>8 ^ crcTable[byte(crc)^b]
+	}
+	return ^crc
+}
+
+func checkCRC(data, crc []byte) bool {
+	return CRC(data) == binary.LittleEndian.Uint16(crc)
+}
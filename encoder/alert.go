@@ -0,0 +1,108 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/detection"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/mgutz/ansi"
+)
+
+var (
+	flagRules = flag.String("rules", "", "evaluate the detection rules from the given YAML file or directory on all generated audit records")
+
+	// detectionEngine is nil if no rules were configured
+	detectionEngine *detection.Engine
+
+	// set in postinit, to avoid an initialization loop when writing from other encoders
+	alertEncoderInstance *CustomEncoder
+)
+
+var alertEncoder = CreateCustomEncoder(types.Type_NC_Alert, "Alert", func(e *CustomEncoder) error {
+
+	// postinit:
+	// load the detection rules
+
+	if *flagRules == "" {
+		return nil
+	}
+
+	engine, err := detection.NewEngine(*flagRules)
+	if err != nil {
+		return err
+	}
+	fmt.Println("loaded", engine.NumRules(), "detection rules from", *flagRules)
+
+	detectionEngine = engine
+	alertEncoderInstance = e
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// alerts are generated when other encoders write their audit records
+	return nil
+}, nil)
+
+// checkRulesEncoder exits if detection rules were configured,
+// but the Alert encoder that evaluates them is not part of the selected encoders
+func checkRulesEncoder() {
+
+	if *flagRules == "" {
+		return
+	}
+
+	for _, e := range customEncoderSlice {
+		if e == alertEncoder {
+			return
+		}
+	}
+
+	fmt.Println("the detection rules from -rules are evaluated by the " + ansi.Red + "Alert" + ansi.Reset + " encoder, add it to -include or remove it from -exclude")
+	os.Exit(1)
+}
+
+// evaluateRules runs the detection rules on a generated audit record
+// and writes an alert for each match
+func evaluateRules(record proto.Message) {
+
+	if detectionEngine == nil {
+		return
+	}
+
+	r, ok := record.(types.AuditRecord)
+	if !ok {
+		return
+	}
+
+	for _, a := range detectionEngine.Process(r) {
+
+		// export metrics if configured
+		if alertEncoderInstance.export {
+			a.Inc()
+		}
+
+		// write record to disk
+		atomic.AddInt64(&alertEncoderInstance.numRecords, 1)
+		err := alertEncoderInstance.writer.Write(a)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+	}
+}
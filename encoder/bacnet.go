@@ -0,0 +1,88 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/hex"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/bacnet"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+var bacnetEncoder = CreateCustomEncoder(types.Type_NC_BACnet, "BACnet", nil, func(p gopacket.Packet) proto.Message {
+
+	l := p.Layer(layers.LayerTypeUDP)
+	if l == nil {
+		return nil
+	}
+	udp := l.(*layers.UDP)
+	if udp.SrcPort != bacnet.Port && udp.DstPort != bacnet.Port {
+		return nil
+	}
+
+	pkt, err := bacnet.Parse(udp.Payload)
+	if err != nil {
+		// packets with an invalid NPDU or APDU are written anyway
+		errorMap.Inc(err.Error())
+		if pkt == nil {
+			return nil
+		}
+	}
+
+	var (
+		net = p.NetworkLayer().NetworkFlow()
+		b   = &types.BACnet{
+			Timestamp:        utils.TimeToString(p.Metadata().Timestamp),
+			BVLCFunction:     bacnet.BVLCFunctionName(pkt.BVLCFunction),
+			ForwardedAddress: pkt.ForwardedAddress,
+			SrcIP:            net.Src().String(),
+			DstIP:            net.Dst().String(),
+			SrcPort:          int32(udp.SrcPort),
+			DstPort:          int32(udp.DstPort),
+			ConnUID:          calcMd5(newConnectionID(p).String()),
+		}
+	)
+
+	if n := pkt.NPDU; n != nil {
+		b.NetworkMessage = n.NetworkMessage
+		b.NetworkMessageType = int32(n.NetworkMessageType)
+		b.Priority = int32(n.Priority)
+		b.ExpectingReply = n.ExpectingReply
+		b.DNET = int32(n.DNET)
+		b.DADR = hex.EncodeToString(n.DADR)
+		b.SNET = int32(n.SNET)
+		b.SADR = hex.EncodeToString(n.SADR)
+		b.HopCount = int32(n.HopCount)
+	}
+
+	if a := pkt.APDU; a != nil {
+		b.APDUType = bacnet.APDUTypeName(a.Type)
+		b.Segmented = a.Segmented
+		b.InvokeID = int32(a.InvokeID)
+		b.ServiceChoice = int32(a.ServiceChoice)
+		b.ServiceName = a.ServiceName()
+		b.ErrorClass = int32(a.ErrorClass)
+		b.ErrorCode = int32(a.ErrorCode)
+		b.Reason = int32(a.Reason)
+		b.Control = a.IsControl()
+		for _, o := range a.Objects {
+			b.Objects = append(b.Objects, o.String())
+		}
+	}
+
+	return b
+}, nil)
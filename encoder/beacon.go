@@ -0,0 +1,79 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/beacon"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagBeaconMinConns   = flag.Int("beacon-min-conns", 10, "minimum number of connections between two endpoints to check for beaconing")
+	flagBeaconScore      = flag.Float64("beacon-score", 0.8, "minimum score from 0 to 1 for beacons to be reported")
+	flagBeaconMaxSamples = flag.Int("beacon-max-samples", beacon.DefaultMaxSamples, "number of most recent connections per group that are scored for beaconing")
+	flagBeaconMaxGroups  = flag.Int("beacon-max-groups", beacon.DefaultMaxGroups, "maximum number of endpoint groups tracked for beaconing")
+
+	// beaconAnalyzer collects all written connections, nil if the Beacon encoder is not active
+	beaconAnalyzer *beacon.Analyzer
+
+	// beaconFlows is set if the Connection encoder is not active,
+	// the written flows are collected instead of the connections
+	beaconFlows bool
+)
+
+var beaconEncoder = CreateCustomEncoder(types.Type_NC_Beacon, "Beacon", func(e *CustomEncoder) error {
+	beaconAnalyzer = beacon.NewAnalyzer(*flagBeaconMinConns)
+	beaconAnalyzer.MaxSamples = *flagBeaconMaxSamples
+	beaconAnalyzer.MaxGroups = *flagBeaconMaxGroups
+
+	// the Connection encoder is initialized before
+	beaconFlows = connEncoderInstance == nil
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// beacons are determined after all connections have been written
+	return nil
+}, func(e *CustomEncoder) error {
+
+	// deinit:
+	// score the connections of the whole capture and write the ranked beacons
+	// the Connection and Flow encoders are destroyed before, so all connections and flows have been flushed
+
+	if beaconAnalyzer.Dropped > 0 {
+		errorMap.Inc("Beacon: too many endpoint groups, connections dropped")
+	}
+
+	for _, b := range beaconAnalyzer.Results(*flagBeaconScore) {
+
+		// export metrics if configured
+		if e.export {
+			b.Inc()
+		}
+
+		// write record to disk
+		atomic.AddInt64(&e.numRecords, 1)
+		err := e.writer.Write(b)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+
+		evaluateRules(b)
+	}
+
+	return nil
+})
@@ -22,10 +22,7 @@ import (
 
 var cipEncoder = CreateLayerEncoder(types.Type_NC_CIP, layers.LayerTypeCIP, func(layer gopacket.Layer, timestamp string) proto.Message {
 	if cip, ok := layer.(*layers.CIP); ok {
-		var payload []byte
-		if CapturePayload {
-			payload = cip.Data
-		}
+		payload, hash := capturePayload("CIP", cip.Data)
 		var additional []uint32
 		if cip.Response {
 			for _, v := range cip.AdditionalStatus {
@@ -41,6 +38,7 @@ var cipEncoder = CreateLayerEncoder(types.Type_NC_CIP, layers.LayerTypeCIP, func
 			Status:           int32(cip.Status),
 			AdditionalStatus: additional,
 			Data:             payload,
+			DataHash:         hash,
 		}
 	}
 	return nil
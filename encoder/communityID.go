@@ -0,0 +1,34 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+var flagCommunityIDSeed = flag.Int("community-id-seed", 0, "seed for the community id flow hash, must match the seed configured for zeek or suricata")
+
+// CommunityID returns the community id flow hash for the packet
+func CommunityID(p gopacket.Packet) string {
+	return utils.CommunityIDFromPacket(uint16(*flagCommunityIDSeed), p)
+}
+
+// tcpCommunityID returns the community id flow hash for a TCP stream
+func tcpCommunityID(net, transport gopacket.Flow) string {
+	return utils.CommunityIDFromFlows(uint16(*flagCommunityIDSeed), uint8(layers.IPProtocolTCP), net, transport)
+}
@@ -25,7 +25,7 @@ type Config struct {
 	WriteChan       bool
 	Source          string
 	Version         string
-	IncludePayloads bool
+	PayloadPolicy   string
 	Export          bool
 	AddContext      bool
 	MemBufferSize   int
@@ -15,9 +15,9 @@ package encoder
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"strconv"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,34 +27,43 @@ import (
 	"github.com/dreadl0ck/gopacket"
 )
 
-// AtomicConnMap contains all connections and provides synchronized access
+// AtomicConnMap holds the active connections in a FlowTable.
+// Items contains the same connections by their ID, it is maintained by the table
+// and must only be read with the lock held.
 type AtomicConnMap struct {
+	*FlowTable
 	Items map[string]*types.Connection
-	sync.Mutex
 }
 
-// Size returns the number of elements in the Items map
-func (a *AtomicConnMap) Size() int {
-	a.Lock()
-	defer a.Unlock()
+func newAtomicConnMap(t *FlowTable) *AtomicConnMap {
+	a := &AtomicConnMap{
+		FlowTable: t,
+		Items:     make(map[string]*types.Connection),
+	}
+	t.onRemove = func(key, value interface{}) {
+		delete(a.Items, key.(string))
+	}
+	return a
+}
 
-	return len(a.Items)
+// Put adds a new connection, see FlowTable.Put.
+func (a *AtomicConnMap) Put(id string, c *types.Connection, ts time.Time) (evicted interface{}) {
+	evicted = a.FlowTable.Put(id, c, ts)
+	a.Items[id] = c
+	return evicted
 }
 
 var (
-	// Connections hold all connections
-	Connections = &AtomicConnMap{
-		Items: make(map[string]*types.Connection),
-	}
+	// Connections hold all active connections
+	Connections         = newAtomicConnMap(NewFlowTable("Connection", 0, 0, 0))
 	connEncoderInstance *CustomEncoder
-	conns               int64
-
-	// flags for flushing intervals
-	flagConnFlushInterval = flag.Int("conn-flush-interval", 10000, "flush connections every X flows")
-	flagConnTimeOut       = flag.Int("conn-timeout", 60, "close connections older than X seconds")
+	connPayloads        = newPayloadAccumulator("Connection")
 
-	connFlushInterval int64
-	connTimeOut       time.Duration
+	// flags for the connection table
+	flagConnFlushInterval = flag.Int("conn-flush-interval", 10000, "deprecated: connections are checked for timeouts once per second of packet time")
+	flagConnTimeOut       = flag.Int("conn-timeout", 60, "close connections that have been idle for X seconds")
+	flagConnActiveTimeout = flag.Int("conn-active-timeout", 1800, "close connections that have been active for X seconds, the connection continues in a new record")
+	flagConnTableSize     = flag.Int("conn-table-size", 1000000, "maximum number of connections kept in memory, the least recently used connection is closed if the limit is reached")
 )
 
 // ConnectionID is a bidirectional connection
@@ -70,14 +79,8 @@ func (c ConnectionID) String() string {
 	return strconv.FormatUint(c.LinkFlowID, 10) + strconv.FormatUint(c.NetworkFlowID, 10) + strconv.FormatUint(c.TransportFlowID, 10)
 }
 
-var connectionEncoder = CreateCustomEncoder(types.Type_NC_Connection, "Connection", func(d *CustomEncoder) error {
-	connEncoderInstance = d
-	connFlushInterval = int64(*flagConnFlushInterval)
-	connTimeOut = time.Second * time.Duration(*flagConnTimeOut)
-	return nil
-}, func(p gopacket.Packet) proto.Message {
-
-	// assemble connectionID
+// newConnectionID assembles the ConnectionID for the packet
+func newConnectionID(p gopacket.Packet) ConnectionID {
 	c := ConnectionID{}
 	if ll := p.LinkLayer(); ll != nil {
 		c.LinkFlowID = ll.LinkFlow().FastHash()
@@ -88,10 +91,43 @@ var connectionEncoder = CreateCustomEncoder(types.Type_NC_Connection, "Connectio
 	if tl := p.TransportLayer(); tl != nil {
 		c.TransportFlowID = tl.TransportFlow().FastHash()
 	}
+	return c
+}
+
+// ConnectionUID returns the UID of the Connection the packet belongs to
+func ConnectionUID(p gopacket.Packet) string {
+	return calcMd5(newConnectionID(p).String())
+}
+
+var connectionEncoder = CreateCustomEncoder(types.Type_NC_Connection, "Connection", func(d *CustomEncoder) error {
+	connEncoderInstance = d
+	Connections = newAtomicConnMap(NewFlowTable(
+		"Connection",
+		*flagConnTableSize,
+		time.Second*time.Duration(*flagConnTimeOut),
+		time.Second*time.Duration(*flagConnActiveTimeout),
+	))
+
+	if flagIsSet("conn-flush-interval") {
+		fmt.Println("warning: -conn-flush-interval", *flagConnFlushInterval, "is deprecated and has no effect, connections are checked for timeouts once per second of packet time")
+	}
+
+	var err error
+	connExporter, err = newNetFlowExporter("Connection")
+	return err
+}, func(p gopacket.Packet) proto.Message {
+
+	// assemble connectionID
+	var (
+		id      = newConnectionID(p).String()
+		ts      = p.Metadata().Timestamp
+		expired []interface{}
+	)
 
 	// lookup flow
 	Connections.Lock()
-	if conn, ok := Connections.Items[c.String()]; ok {
+	if c, ok := Connections.Get(id, ts); ok {
+		conn := c.(*types.Connection)
 
 		// conn exists. update fields
 		calcDuration := false
@@ -132,6 +168,10 @@ var connectionEncoder = CreateCustomEncoder(types.Type_NC_Connection, "Connectio
 		conn.NumPackets++
 		conn.TotalSize += int32(len(p.Data()))
 
+		if al := p.ApplicationLayer(); al != nil {
+			connPayloads.add(conn, &conn.Payload, al.Payload())
+		}
+
 		// only calculate duration when timetamps have changed
 		if calcDuration {
 			conn.Duration = utils.StringToTime(conn.TimestampLast).Sub(utils.StringToTime(conn.TimestampFirst)).Nanoseconds()
@@ -139,7 +179,8 @@ var connectionEncoder = CreateCustomEncoder(types.Type_NC_Connection, "Connectio
 	} else {
 		// create a new Connection
 		conn := &types.Connection{}
-		conn.UID = calcMd5(c.String())
+		conn.UID = calcMd5(id)
+		conn.CommunityID = CommunityID(p)
 		conn.TimestampFirst = utils.TimeToString(p.Metadata().Timestamp)
 
 		if ll := p.LinkLayer(); ll != nil {
@@ -160,46 +201,43 @@ var connectionEncoder = CreateCustomEncoder(types.Type_NC_Connection, "Connectio
 		if al := p.ApplicationLayer(); al != nil {
 			conn.ApplicationProto = al.LayerType().String()
 			conn.AppPayloadSize = int32(len(al.Payload()))
+			connPayloads.add(conn, &conn.Payload, al.Payload())
 		}
-		Connections.Items[c.String()] = conn
-
-		conns++
-
-		// flush
-		if conns%connFlushInterval == 0 {
-
-			var selectConns []*types.Connection
-			for id, c := range Connections.Items {
-				// flush entries whose last timestamp is connTimeOut older than current packet
-				if p.Metadata().Timestamp.Sub(utils.StringToTime(c.TimestampLast)) > connTimeOut {
-					selectConns = append(selectConns, c)
-					// cleanup
-					delete(Connections.Items, id)
-				}
-			}
-
-			// flush selection in background
-			go func() {
-				for _, c := range selectConns {
-					writeConn(c)
-				}
-			}()
+		if evicted := Connections.Put(id, conn, ts); evicted != nil {
+			expired = append(expired, evicted)
 		}
 	}
+	expired = append(expired, Connections.Expire(ts)...)
 	Connections.Unlock()
+
+	// write timed out and evicted connections
+	for _, c := range expired {
+		writeConn(c.(*types.Connection))
+	}
 	return nil
 }, func(e *CustomEncoder) error {
 	if !e.writer.IsChanWriter {
-		for _, c := range Connections.Items {
-			writeConn(c)
+		Connections.Lock()
+		conns := Connections.Flush()
+		Connections.Unlock()
+		for _, c := range conns {
+			writeConn(c.(*types.Connection))
 		}
 	}
+	closeNetFlowExporter(connExporter)
 	return nil
 })
 
 // writeConn writes the connection
 func writeConn(c *types.Connection) {
 
+	c.DstHostname = dstHostname(c.DstIP)
+	labelFTPDataConnection(c)
+
+	if hash := connPayloads.finish(c); hash != "" {
+		c.PayloadHash = hash
+	}
+
 	if connEncoderInstance.export {
 		c.Inc()
 	}
@@ -209,4 +247,11 @@ func writeConn(c *types.Connection) {
 	if err != nil {
 		log.Fatal("failed to write proto: ", err)
 	}
+
+	evaluateRules(c)
+	exportNetFlow(connExporter, c)
+
+	if beaconAnalyzer != nil && !beaconFlows {
+		beaconAnalyzer.AddRecord(c)
+	}
 }
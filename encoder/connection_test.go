@@ -0,0 +1,91 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"net"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+// testTCPPacket serializes a TCP packet between the given endpoints
+func testTCPPacket(t *testing.T, srcIP, dstIP string, srcPort, dstPort int) gopacket.Packet {
+
+	var (
+		eth = &layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+			DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		ip = &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolTCP,
+			SrcIP:    net.ParseIP(srcIP),
+			DstIP:    net.ParseIP(dstIP),
+		}
+		tcp = &layers.TCP{
+			SrcPort: layers.TCPPort(srcPort),
+			DstPort: layers.TCPPort(dstPort),
+			ACK:     true,
+			Window:  1024,
+		}
+		buf = gopacket.NewSerializeBuffer()
+	)
+	if srcIP > dstIP {
+		// the link layer is swapped along with the addresses, as in a reply
+		eth.SrcMAC, eth.DstMAC = eth.DstMAC, eth.SrcMAC
+	}
+	if err := tcp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatal(err)
+	}
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, eth, ip, tcp, gopacket.Payload("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestPacketContextUIDs(t *testing.T) {
+
+	var (
+		request = testTCPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 80)
+		reply   = testTCPPacket(t, "10.0.0.2", "10.0.0.1", 80, 40000)
+		other   = testTCPPacket(t, "10.0.0.1", "10.0.0.2", 40001, 80)
+	)
+
+	// both directions belong to the same connection
+	if ConnectionUID(request) != ConnectionUID(reply) {
+		t.Fatal("expected the same connection UID for both directions")
+	}
+	if ConnectionUID(request) == ConnectionUID(other) {
+		t.Fatal("expected different connection UIDs for different ports")
+	}
+	if ConnectionUID(request) != calcMd5(newConnectionID(request).String()) {
+		t.Fatal("connection UID does not match the UID of the Connection record")
+	}
+
+	// flows are unidirectional
+	if FlowUID(request) == FlowUID(reply) {
+		t.Fatal("expected different flow UIDs for both directions")
+	}
+	if FlowUID(request) != calcMd5(newFlowID(request)) {
+		t.Fatal("flow UID does not match the UID of the Flow record")
+	}
+	if FlowUID(request) != FlowUID(testTCPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 80)) {
+		t.Fatal("expected the same flow UID for packets of the same flow")
+	}
+}
@@ -33,12 +33,32 @@ var (
 	// contains all available custom encoders
 	customEncoderSlice = []*CustomEncoder{
 		tlsEncoder,
+		quicEncoder,
 		linkFlowEncoder,
 		networkFlowEncoder,
 		transportFlowEncoder,
 		httpEncoder,
 		flowEncoder,
 		connectionEncoder,
+		yaraEncoder,
+		fileEncoder,
+		smbEncoder,
+		kerberosEncoder,
+		ntlmEncoder,
+		mailEncoder,
+		mailSessionEncoder,
+		ftpEncoder,
+		dnp3Encoder,
+		iec104Encoder,
+		s7CommEncoder,
+		bacnetEncoder,
+		modbusDeviceEncoder,
+		scanEncoder,
+		beaconEncoder,
+		dnsAnomalyEncoder,
+		passiveDNSEncoder,
+		flowFeaturesEncoder,
+		alertEncoder,
 	}
 )
 
@@ -61,14 +81,12 @@ type (
 		numRecords int64
 
 		// HTTP specific stats
-		numRequests             int64
-		numResponses            int64
-		numUnmatchedResp        int64
-		numNilRequests          int64
-		numFoundRequests        int64
-		numRemovedRequests      int64
-		numUnansweredRequests   int64
-		numClientStreamNotFound int64
+		numRequests           int64
+		numResponses          int64
+		numUnmatchedResp      int64
+		numFoundRequests      int64
+		numUnansweredRequests int64
+		numInformational      int64
 
 		writer *netcap.Writer
 		export bool
@@ -102,6 +120,8 @@ func InitCustomEncoders(c Config) {
 		selection []*CustomEncoder
 	)
 
+	setPayloadPolicy(c.PayloadPolicy)
+
 	// if there are includes and the first item is not an empty string
 	if len(in) > 0 && in[0] != "" {
 
@@ -150,6 +170,8 @@ func InitCustomEncoders(c Config) {
 		}
 	}
 
+	checkRulesEncoder()
+
 	// initialize encoders
 	for _, e := range customEncoderSlice {
 
@@ -168,7 +190,7 @@ func InitCustomEncoders(c Config) {
 		e.export = c.Export
 
 		// write header
-		err := e.writer.WriteHeader(e.Type, c.Source, c.Version, c.IncludePayloads)
+		err := e.writer.WriteHeader(e.Type, c.Source, c.Version, ContainsPayloads(e.Name))
 		if err != nil {
 			log.Fatal("failed to write header for audit record: ", e.Name)
 		}
@@ -226,6 +248,9 @@ func (e *CustomEncoder) Encode(p gopacket.Packet) error {
 				log.Fatal("type does not implement the types.AuditRecord interface")
 			}
 		}
+
+		// run detection rules if configured
+		evaluateRules(record)
 	}
 	return nil
 }
@@ -0,0 +1,245 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/dnp3"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+// set in postinit, nil if the DNP3 encoder is not active
+var dnp3EncoderInstance *CustomEncoder
+
+var dnp3Encoder = CreateCustomEncoder(types.Type_NC_DNP3, "DNP3", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for DNP3 over TCP and ensure TCP stream reassembly is enabled
+
+	dnp3EncoderInstance = e
+	streamDecoders[dnp3.Port] = newDNP3Decoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+
+	// DNP3 over TCP is decoded after stream reassembly
+	l := p.Layer(layers.LayerTypeUDP)
+	if l == nil {
+		return nil
+	}
+	udp := l.(*layers.UDP)
+	if udp.SrcPort != dnp3.Port && udp.DstPort != dnp3.Port {
+		return nil
+	}
+
+	var (
+		r       dnp3.Reassembler
+		data    = udp.Payload
+		ts      = p.Metadata().Timestamp
+		net     = p.NetworkLayer().NetworkFlow()
+		connUID = calcMd5(newConnectionID(p).String())
+	)
+
+	// a datagram can contain several frames
+	for len(data) > 0 {
+		f, n, err := dnp3.ParseFrame(data)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			if err != dnp3.ErrInvalidCRC {
+				break
+			}
+		}
+		if frag := r.Add(f); frag != nil {
+			writeDNP3(newDNP3(frag, ts, "UDP", net, udp.TransportFlow(), connUID))
+		}
+		data = data[n:]
+	}
+	if frag := r.Flush(); frag != nil {
+		writeDNP3(newDNP3(frag, ts, "UDP", net, udp.TransportFlow(), connUID))
+	}
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// newDNP3 creates the audit record for an application fragment sent from the source to the destination of the flows
+func newDNP3(frag *dnp3.Fragment, ts time.Time, transport string, net, tr gopacket.Flow, connUID string) *types.DNP3 {
+
+	d := &types.DNP3{
+		Timestamp:        utils.TimeToString(ts),
+		Transport:        transport,
+		FromMaster:       frag.Link.FromMaster(),
+		Primary:          frag.Link.Primary(),
+		LinkFunction:     int32(frag.Link.Function()),
+		LinkFunctionName: dnp3.LinkFunctionName(frag.Link),
+		Source:           int32(frag.Link.Source),
+		Destination:      int32(frag.Link.Destination),
+		NumFrames:        int32(frag.Frames),
+		SrcIP:            net.Src().String(),
+		DstIP:            net.Dst().String(),
+		SrcPort:          int32(binary.BigEndian.Uint16(tr.Src().Raw())),
+		DstPort:          int32(binary.BigEndian.Uint16(tr.Dst().Raw())),
+		ConnUID:          connUID,
+	}
+
+	// link layer frames without user data
+	if frag.Data == nil {
+		return d
+	}
+	d.TransportSequence = int32(frag.Transport.Sequence)
+
+	app, err := dnp3.ParseApplication(frag.Data)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+	if app == nil {
+		return d
+	}
+
+	d.AppSequence = int32(app.Sequence())
+	d.Confirm = app.Confirm()
+	d.Unsolicited = app.Unsolicited()
+	d.FunctionCode = int32(app.Function)
+	d.FunctionName = dnp3.FunctionName(app.Function)
+	d.Control = dnp3.IsControl(app.Function)
+	d.IIN = int32(app.IIN)
+	d.IINFlags = dnp3.IINFlags(app.IIN)
+
+	for _, o := range app.Objects {
+		d.Objects = append(d.Objects, &types.DNP3Object{
+			Group:     int32(o.Group),
+			Variation: int32(o.Variation),
+			Qualifier: int32(o.Qualifier),
+			Name:      dnp3.GroupName(o.Group),
+			Start:     o.Start,
+			Stop:      o.Stop,
+			Count:     o.Count,
+			Indexes:   o.Indexes,
+		})
+	}
+	return d
+}
+
+// writeDNP3 writes the audit record
+func writeDNP3(d *types.DNP3) {
+
+	if dnp3EncoderInstance == nil {
+		return
+	}
+
+	// export metrics if configured
+	if dnp3EncoderInstance.export {
+		d.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&dnp3EncoderInstance.numRecords, 1)
+	err := dnp3EncoderInstance.writer.Write(d)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(d)
+}
+
+// dnp3Decoder splits a TCP stream into link layer frames and joins their transport segments
+type dnp3Decoder struct {
+	parent *tcpStream
+
+	// incomplete frames
+	client []byte
+	server []byte
+
+	// incomplete application fragments
+	clientFragment dnp3.Reassembler
+	serverFragment dnp3.Reassembler
+
+	// time of the latest data
+	last time.Time
+
+	// set if the stream does not contain DNP3 frames
+	invalid bool
+}
+
+func newDNP3Decoder(t *tcpStream) streamDecoder {
+	return &dnp3Decoder{parent: t}
+}
+
+func (d *dnp3Decoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.invalid {
+		return
+	}
+	d.last = ts
+
+	buf, r := &d.server, &d.serverFragment
+	if client {
+		buf, r = &d.client, &d.clientFragment
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		length, ok, err := dnp3.FrameLength(*buf)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			d.invalid = true
+			d.client, d.server = nil, nil
+			return
+		}
+		if !ok || len(*buf) < length {
+			break
+		}
+
+		f, n, err := dnp3.ParseFrame(*buf)
+		if err != nil {
+			// frames with an invalid CRC are decoded anyway
+			errorMap.Inc(err.Error())
+		}
+		if frag := r.Add(f); frag != nil {
+			d.write(client, frag, ts)
+		}
+		*buf = (*buf)[n:]
+	}
+
+	// release the memory once all frames have been processed
+	if len(*buf) == 0 {
+		*buf = nil
+	}
+}
+
+// close writes the incomplete application fragments
+func (d *dnp3Decoder) close() {
+	if frag := d.clientFragment.Flush(); frag != nil {
+		d.write(true, frag, d.last)
+	}
+	if frag := d.serverFragment.Flush(); frag != nil {
+		d.write(false, frag, d.last)
+	}
+}
+
+func (d *dnp3Decoder) write(client bool, frag *dnp3.Fragment, ts time.Time) {
+	net, transport := d.parent.clientFlows()
+	if !client {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+	writeDNP3(newDNP3(frag, ts, "TCP", net, transport, d.parent.connUID))
+}
@@ -0,0 +1,352 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagDNSTunnelScore = flag.Float64("dns-tunnel-score", 0.6, "minimum score from 0 to 1 to report a DNS query as tunneling")
+	flagDNSDGAScore    = flag.Float64("dns-dga-score", 0.6, "minimum score from 0 to 1 to report a queried domain as generated by a DGA")
+)
+
+const (
+	// maximum number of distinct subdomains tracked per parent domain
+	maxDNSSubdomains = 10000
+
+	// maximum number of parent domains, the least recently queried one is evicted if the limit is reached
+	maxDNSParents = 100000
+
+	// parent domains are removed after they have not been queried for this duration
+	dnsParentTimeout = time.Hour
+
+	// the set of reported names is reset after reaching this size
+	maxDNSReported = 100000
+
+	// registered labels shorter than this are not scored for DGA
+	minDGALabelLength = 8
+
+	// characters of the bigram model: a-z, 0-9 and hyphen
+	bigramAlphabet = 37
+)
+
+var (
+	dnsAnomalyEncoderInstance *CustomEncoder
+
+	// state per parent domain, the lock of the table guards dnsReported as well
+	dnsParents = NewFlowTable("DNSAnomaly", maxDNSParents, dnsParentTimeout, 0)
+
+	// names that have been reported already
+	dnsReported = make(map[string]struct{})
+
+	// second level labels used for country code top level domains, e.g. co.uk
+	secondLevelLabels = map[string]bool{
+		"co": true, "com": true, "net": true, "org": true, "gov": true,
+		"edu": true, "ac": true, "or": true, "ne": true, "go": true,
+	}
+
+	// bigram log10 probabilities, trained on bigramCorpus
+	bigramModel [bigramAlphabet][bigramAlphabet]float64
+)
+
+type dnsParent struct {
+	subdomains map[string]struct{}
+	txtNull    int32
+}
+
+// bigramCorpus contains common words and tokens of legitimate domain names
+// it is used to train the bigram model for detecting randomly generated labels
+const bigramCorpus = `
+google facebook youtube amazon microsoft apple twitter instagram linkedin wikipedia netflix yahoo
+office live outlook windows update cloud cloudflare akamai azure amazonaws github gitlab bitbucket
+mail email smtp imap server service services secure security login account accounts support help
+static images image media video videos news weather sports music games game store shop shopping
+online web website network networks internet connect connection content delivery cdn edge cache
+download downloads upload api apps app application mobile phone phones data database analytics
+search engine portal market marketing ads advertising tracking track metrics stats status monitor
+bank banking finance financial insurance health medical hospital school university college
+education learning student students research science technology tech digital software systems
+hosting host domain domains register registry resolver time clock sync ntp printer local home
+business company corporate enterprise group global international national world city state country
+travel hotel hotels flight flights booking tickets events event community forum forums blog blogs
+social chat message messages messenger talk voice meeting meetings conference team teams work
+people person friend friends family photo photos picture pictures gallery design studio creative
+open source project projects development developer developers docs documentation wiki library
+public private internal external gateway proxy firewall router switch access remote desktop
+central north south east west new york london paris berlin tokyo china india america europe
+first second third one two three four five best top free fast quick easy simple smart power
+light dark blue green red black white gold silver star sun moon sky sea river mountain land
+house garden kitchen food fresh coffee tea water fire earth wind life love happy good great
+information management manager control center centre office solutions solution consulting
+partner partners client clients customer customers user users member members profile profiles
+exchange trade trading capital invest investment credit card cards payment payments pay wallet
+check verify validation certificate certificates trust authority ocsp crl pki signing
+`
+
+func init() {
+	var counts [bigramAlphabet][bigramAlphabet]float64
+	for _, word := range strings.Fields(bigramCorpus) {
+		for i := 1; i < len(word); i++ {
+			a, b := bigramIndex(word[i-1]), bigramIndex(word[i])
+			if a >= 0 && b >= 0 {
+				counts[a][b]++
+			}
+		}
+	}
+	// laplace smoothing
+	for a := range counts {
+		var total float64
+		for b := range counts[a] {
+			total += counts[a][b]
+		}
+		for b := range counts[a] {
+			bigramModel[a][b] = math.Log10((counts[a][b] + 1) / (total + bigramAlphabet))
+		}
+	}
+}
+
+func bigramIndex(c byte) int {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26
+	case c == '-':
+		return 36
+	}
+	return -1
+}
+
+var dnsAnomalyEncoder = CreateCustomEncoder(types.Type_NC_DNSAnomaly, "DNSAnomaly", func(e *CustomEncoder) error {
+	dnsAnomalyEncoderInstance = e
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+
+	l := p.Layer(layers.LayerTypeDNS)
+	if l == nil {
+		return nil
+	}
+	dns := l.(*layers.DNS)
+	if dns.QR {
+		return nil
+	}
+
+	var src, dst string
+	if nl := p.NetworkLayer(); nl != nil {
+		src, dst = nl.NetworkFlow().Src().String(), nl.NetworkFlow().Dst().String()
+	}
+
+	for _, q := range dns.Questions {
+		if a := analyzeDNSQuery(strings.ToLower(string(q.Name)), q.Type, p.Metadata().Timestamp); a != nil {
+			a.Timestamp = utils.TimeToString(p.Metadata().Timestamp)
+			a.SrcIP = src
+			a.DstIP = dst
+			writeDNSAnomaly(a)
+		}
+	}
+
+	// records have been written already, since a single query can contain multiple questions
+	return nil
+}, nil)
+
+// analyzeDNSQuery updates the state of the parent domain
+// and returns a DNSAnomaly if the query is suspicious
+func analyzeDNSQuery(name string, typ layers.DNSType, ts time.Time) *types.DNSAnomaly {
+
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || strings.HasSuffix(name, ".arpa") || strings.HasSuffix(name, ".local") {
+		return nil
+	}
+
+	var (
+		labels    = strings.Split(name, ".")
+		numParent = 2
+	)
+	if n := len(labels); n >= 3 && len(labels[n-1]) == 2 && secondLevelLabels[labels[n-2]] {
+		numParent = 3
+	}
+	if numParent > len(labels) {
+		numParent = len(labels)
+	}
+
+	var (
+		parent     = strings.Join(labels[len(labels)-numParent:], ".")
+		registered = labels[len(labels)-numParent]
+		subdomain  = strings.Join(labels[:len(labels)-numParent], "")
+		longest    int
+	)
+	for _, label := range labels {
+		if len(label) > longest {
+			longest = len(label)
+		}
+	}
+
+	dnsParents.Lock()
+	defer dnsParents.Unlock()
+
+	dnsParents.Expire(ts)
+
+	var state *dnsParent
+	if v, ok := dnsParents.Get(parent, ts); ok {
+		state = v.(*dnsParent)
+	} else {
+		state = &dnsParent{subdomains: make(map[string]struct{})}
+		dnsParents.Put(parent, state, ts)
+	}
+	if subdomain != "" && len(state.subdomains) < maxDNSSubdomains {
+		state.subdomains[subdomain] = struct{}{}
+	}
+	if typ == layers.DNSTypeTXT || typ == layers.DNSTypeNULL {
+		state.txtNull++
+	}
+
+	var (
+		chars                = subdomain + registered
+		digits, vowels, spec int
+	)
+	for i := 0; i < len(chars); i++ {
+		switch c := chars[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case strings.IndexByte("aeiou", c) >= 0:
+			vowels++
+		case c == '-' || c == '_':
+			spec++
+		}
+	}
+
+	a := &types.DNSAnomaly{
+		Name:             name,
+		ParentDomain:     parent,
+		QueryType:        typ.String(),
+		Length:           int32(len(name)),
+		NumLabels:        int32(len(labels)),
+		LongestLabel:     int32(longest),
+		SubdomainEntropy: Entropy([]byte(subdomain)),
+		DomainEntropy:    Entropy([]byte(registered)),
+		DigitRatio:       ratio(digits, len(chars)),
+		VowelRatio:       ratio(vowels, len(chars)),
+		SpecialRatio:     ratio(spec, len(chars)),
+		BigramScore:      bigramLikelihood(registered),
+		UniqueSubdomains: int32(len(state.subdomains)),
+		TXTNullQueries:   state.txtNull,
+	}
+
+	// tunneling: long, high entropy subdomains, many distinct subdomains and TXT / NULL records for the parent domain
+	a.TunnelScore = 0.3*clamp(float64(len(subdomain)-20)/40) +
+		0.3*clamp((a.SubdomainEntropy-3)/1.5) +
+		0.25*clamp(float64(a.UniqueSubdomains)/200) +
+		0.15*clamp(float64(a.TXTNullQueries)/100)
+
+	// DGA: registered label with unlikely bigrams, few vowels, digits and high entropy
+	if len(registered) >= minDGALabelLength {
+		var (
+			labelDigits, labelVowels int
+		)
+		for i := 0; i < len(registered); i++ {
+			if c := registered[i]; c >= '0' && c <= '9' {
+				labelDigits++
+			} else if strings.IndexByte("aeiou", c) >= 0 {
+				labelVowels++
+			}
+		}
+		a.DGAScore = 0.5*clamp((-a.BigramScore-1.3)/0.4) +
+			0.2*clamp((0.35-ratio(labelVowels, len(registered)))/0.25) +
+			0.15*clamp(ratio(labelDigits, len(registered))/0.3) +
+			0.15*clamp(a.DomainEntropy-2.5)
+	}
+
+	var reasons []string
+	if a.TunnelScore >= *flagDNSTunnelScore {
+		reasons = append(reasons, "Tunneling")
+	}
+	if a.DGAScore >= *flagDNSDGAScore {
+		reasons = append(reasons, "DGA")
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	a.Reason = strings.Join(reasons, "/")
+
+	// report every name only once
+	if _, ok := dnsReported[name]; ok {
+		return nil
+	}
+	if len(dnsReported) >= maxDNSReported {
+		dnsReported = make(map[string]struct{})
+	}
+	dnsReported[name] = struct{}{}
+
+	return a
+}
+
+// bigramLikelihood returns the average log10 likelihood of the bigrams in the label
+func bigramLikelihood(label string) float64 {
+	var (
+		sum float64
+		n   int
+	)
+	for i := 1; i < len(label); i++ {
+		a, b := bigramIndex(label[i-1]), bigramIndex(label[i])
+		if a < 0 || b < 0 {
+			continue
+		}
+		sum += bigramModel[a][b]
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// clamp limits the value to the range from 0 to 1
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func writeDNSAnomaly(a *types.DNSAnomaly) {
+
+	// export metrics if configured
+	if dnsAnomalyEncoderInstance.export {
+		a.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&dnsAnomalyEncoderInstance.numRecords, 1)
+	err := dnsAnomalyEncoderInstance.writer.Write(a)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(a)
+}
@@ -0,0 +1,138 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+func resetDNSAnomaly() {
+	dnsParents = NewFlowTable("DNSAnomaly", maxDNSParents, dnsParentTimeout, 0)
+	dnsReported = make(map[string]struct{})
+}
+
+func TestEntropy(t *testing.T) {
+	for in, expected := range map[string]float64{
+		"":         0,
+		"aaaa":     0,
+		"abab":     1,
+		"abcd":     2,
+		"abcdefgh": 3,
+	} {
+		if e := Entropy([]byte(in)); e != expected {
+			t.Errorf("expected entropy %f for %q, got %f", expected, in, e)
+		}
+	}
+}
+
+func TestBigramLikelihood(t *testing.T) {
+	var (
+		word   = bigramLikelihood("facebook")
+		random = bigramLikelihood("xqzjkvwp")
+	)
+	if word <= random {
+		t.Fatalf("expected a higher likelihood for a word: %f <= %f", word, random)
+	}
+	if random > -1.5 {
+		t.Fatalf("expected a low likelihood for random characters, got %f", random)
+	}
+	if l := bigramLikelihood("a"); l != 0 {
+		t.Fatalf("expected 0 for a single character, got %f", l)
+	}
+}
+
+func TestDNSTunnelScore(t *testing.T) {
+
+	resetDNSAnomaly()
+
+	var (
+		rnd   = rand.New(rand.NewSource(1))
+		start = time.Unix(1500000000, 0)
+		buf   = make([]byte, 24)
+		a     = analyzeDNSQuery("www.google.com", layers.DNSTypeA, start)
+	)
+	if a != nil {
+		t.Fatalf("unexpected anomaly for a regular query: %+v", a)
+	}
+
+	// hex encoded data in the subdomains, queried as TXT records
+	var first, last float64
+	for i := 0; i < 300; i++ {
+		rnd.Read(buf)
+		name := hex.EncodeToString(buf) + ".t.example.com"
+		if a = analyzeDNSQuery(name, layers.DNSTypeTXT, start.Add(time.Duration(i)*time.Second)); a == nil {
+			continue
+		}
+		if first == 0 {
+			first = a.TunnelScore
+		}
+		last = a.TunnelScore
+		if a.Reason != "Tunneling" || a.ParentDomain != "example.com" {
+			t.Fatalf("unexpected anomaly: %+v", a)
+		}
+	}
+	// the score increases with the number of distinct subdomains and TXT queries
+	if last < 0.75 || last-first < 0.1 {
+		t.Fatalf("unexpected tunnel scores, first %f, last %f", first, last)
+	}
+
+	v, _ := dnsParents.Get("example.com", start)
+	if p := v.(*dnsParent); len(p.subdomains) != 300 || p.txtNull != 300 {
+		t.Fatalf("unexpected parent state: %d subdomains, %d TXT queries", len(p.subdomains), p.txtNull)
+	}
+}
+
+func TestDNSDGAScore(t *testing.T) {
+
+	resetDNSAnomaly()
+
+	start := time.Unix(1500000000, 0)
+	for _, name := range []string{"microsoft.com", "cloudflare.net", "google.co.uk", "short.io"} {
+		if a := analyzeDNSQuery(name, layers.DNSTypeA, start); a != nil {
+			t.Errorf("unexpected anomaly for %s: %+v", name, a)
+		}
+	}
+
+	a := analyzeDNSQuery("xjwqkzvbtr7q.com", layers.DNSTypeA, start)
+	if a == nil || a.Reason != "DGA" || a.DGAScore < 0.8 {
+		t.Fatalf("expected a DGA anomaly, got %+v", a)
+	}
+
+	// every name is reported once
+	if a := analyzeDNSQuery("xjwqkzvbtr7q.com.", layers.DNSTypeA, start); a != nil {
+		t.Fatal("name reported twice")
+	}
+}
+
+func TestDNSParentsExpiry(t *testing.T) {
+
+	resetDNSAnomaly()
+
+	start := time.Unix(1500000000, 0)
+	analyzeDNSQuery("a.example.com", layers.DNSTypeA, start)
+	analyzeDNSQuery("b.example.org", layers.DNSTypeA, start.Add(dnsParentTimeout))
+	if n := dnsParents.Size(); n != 2 {
+		t.Fatalf("expected 2 parents, got %d", n)
+	}
+
+	analyzeDNSQuery("c.example.net", layers.DNSTypeA, start.Add(dnsParentTimeout+time.Minute))
+	if _, ok := dnsParents.Get("example.com", start); ok || dnsParents.Size() != 2 {
+		t.Fatal("idle parent domain not expired")
+	}
+}
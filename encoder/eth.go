@@ -22,6 +22,7 @@ import (
 
 var ethernetEncoder = CreateLayerEncoder(types.Type_NC_Ethernet, layers.LayerTypeEthernet, func(layer gopacket.Layer, timestamp string) proto.Message {
 	if eth, ok := layer.(*layers.Ethernet); ok {
+		payload, hash := capturePayload("Ethernet", eth.Payload)
 		return &types.Ethernet{
 			Timestamp:      timestamp,
 			SrcMAC:         eth.SrcMAC.String(),
@@ -29,6 +30,8 @@ var ethernetEncoder = CreateLayerEncoder(types.Type_NC_Ethernet, layers.LayerTyp
 			EthernetType:   int32(eth.EthernetType),
 			PayloadEntropy: Entropy(eth.Payload),
 			PayloadSize:    int32(len(eth.Payload)),
+			Payload:        payload,
+			PayloadHash:    hash,
 		}
 	}
 	return nil
@@ -0,0 +1,110 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagFileStorage = flag.String("file-storage", "", "directory to store extracted files, named by their SHA-256 hash")
+
+	// set in postinit, nil if the File encoder is not active
+	fileEncoderInstance *CustomEncoder
+)
+
+var fileEncoder = CreateCustomEncoder(types.Type_NC_File, "File", func(e *CustomEncoder) error {
+	fileEncoderInstance = e
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// files are handed to the encoder by the protocol decoders after stream reassembly
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// extractFile completes the File audit record with the hashes, MIME type, size and entropy of the data,
+// stores the data in the file storage if configured and writes the record
+// protocol decoders set the remaining fields, like the source, addresses and filename hints
+func extractFile(f *types.File, data []byte) {
+
+	if fileEncoderInstance == nil || len(data) == 0 {
+		return
+	}
+
+	var (
+		md5Sum    = md5.Sum(data)
+		sha1Sum   = sha1.Sum(data)
+		sha256Sum = sha256.Sum256(data)
+	)
+	f.Length = int64(len(data))
+	f.MD5 = hex.EncodeToString(md5Sum[:])
+	f.SHA1 = hex.EncodeToString(sha1Sum[:])
+	f.SHA256 = hex.EncodeToString(sha256Sum[:])
+	f.MIME = strings.Split(http.DetectContentType(data), ";")[0]
+	f.Entropy = Entropy(data)
+
+	f.Name = csvField(f.Name)
+	f.ContentType = csvField(f.ContentType)
+
+	if *flagFileStorage != "" {
+		path, err := storeContent(*flagFileStorage, f.SHA256, data)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		} else {
+			f.Location = path
+		}
+	}
+
+	// export metrics if configured
+	if fileEncoderInstance.export {
+		f.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&fileEncoderInstance.numRecords, 1)
+	err := fileEncoderInstance.writer.Write(f)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(f)
+}
+
+// storeContent writes the data into the directory, using the given hash as filename
+// existing files are not written again, since their contents are identical
+func storeContent(dir, hash string, data []byte) (string, error) {
+	path := filepath.Join(dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return path, ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,107 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func TestExtractFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "netcap-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(e *CustomEncoder, storage string) {
+		fileEncoderInstance = e
+		*flagFileStorage = storage
+	}(fileEncoderInstance, *flagFileStorage)
+
+	w := netcap.NewWriter("File", false, false, true, dir, false, 0)
+	defer w.Close()
+	fileEncoderInstance = &CustomEncoder{writer: w}
+	*flagFileStorage = filepath.Join(dir, "files")
+
+	var (
+		data = []byte("%PDF-1.4 test")
+		f    = &types.File{Name: "report, final.pdf", ContentType: "application/pdf; a=1, b=2"}
+	)
+	extractFile(f, data)
+
+	if f.Length != int64(len(data)) ||
+		f.MD5 != "662d150c1c021efdffc61004e797114b" ||
+		f.SHA1 != "aad40d63bba893303d27183eb955d53a0284007b" ||
+		f.SHA256 != "d663640088750cf16276d623c2588d7233f2b84b45f4b2e20832f47b16aa5618" {
+		t.Fatalf("unexpected length or hashes: %+v", f)
+	}
+	if f.MIME != "application/pdf" {
+		t.Fatal("unexpected MIME type", f.MIME)
+	}
+	if f.Entropy != Entropy(data) || f.Entropy <= 0 {
+		t.Fatal("unexpected entropy", f.Entropy)
+	}
+	if f.Name != "report(comma) final.pdf" || f.ContentType != "application/pdf; a=1(comma) b=2" {
+		t.Fatalf("commas not replaced: %q %q", f.Name, f.ContentType)
+	}
+	if f.Location != filepath.Join(*flagFileStorage, f.SHA256) {
+		t.Fatal("unexpected location", f.Location)
+	}
+	if stored, err := ioutil.ReadFile(f.Location); err != nil || string(stored) != string(data) {
+		t.Fatalf("unexpected stored content %q, %v", stored, err)
+	}
+	if n := fileEncoderInstance.NumRecords(); n != 1 {
+		t.Fatal("expected one record, got", n)
+	}
+
+	// empty data is not extracted
+	extractFile(&types.File{}, nil)
+	if n := fileEncoderInstance.NumRecords(); n != 1 {
+		t.Fatal("expected no record for empty data, got", n)
+	}
+}
+
+func TestStoreContent(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "netcap-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storage := filepath.Join(dir, "files")
+	path, err := storeContent(storage, "hash", []byte("first"))
+	if err != nil || path != filepath.Join(storage, "hash") {
+		t.Fatal(path, err)
+	}
+
+	// the content is addressed by its hash, an existing file is not written again
+	path, err = storeContent(storage, "hash", []byte("second"))
+	if err != nil || path != filepath.Join(storage, "hash") {
+		t.Fatal(path, err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "first" {
+		t.Fatalf("existing file overwritten: %q, %v", data, err)
+	}
+	if files, _ := ioutil.ReadDir(storage); len(files) != 1 {
+		t.Fatal("expected one stored file, got", len(files))
+	}
+}
@@ -17,7 +17,6 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,55 +26,78 @@ import (
 	"github.com/dreadl0ck/gopacket"
 )
 
+// AtomicFlowMap holds the active flows in a FlowTable.
+// Items contains the same flows by their ID, it is maintained by the table
+// and must only be read with the lock held.
 type AtomicFlowMap struct {
+	*FlowTable
 	Items map[string]*types.Flow
-	sync.Mutex
 }
 
-func (a *AtomicFlowMap) Size() int {
-	a.Lock()
-	defer a.Unlock()
+func newAtomicFlowMap(t *FlowTable) *AtomicFlowMap {
+	a := &AtomicFlowMap{
+		FlowTable: t,
+		Items:     make(map[string]*types.Flow),
+	}
+	t.onRemove = func(key, value interface{}) {
+		delete(a.Items, key.(string))
+	}
+	return a
+}
 
-	return len(a.Items)
+// Put adds a new flow, see FlowTable.Put.
+func (a *AtomicFlowMap) Put(id string, f *types.Flow, ts time.Time) (evicted interface{}) {
+	evicted = a.FlowTable.Put(id, f, ts)
+	a.Items[id] = f
+	return evicted
 }
 
 var (
-	Flows = &AtomicFlowMap{
-		Items: make(map[string]*types.Flow),
-	}
+	// Flows holds all active flows
+	Flows               = newAtomicFlowMap(NewFlowTable("Flow", 0, 0, 0))
 	flowEncoderInstance *CustomEncoder
-	flows               int64
-
-	flagFlowFlushInterval = flag.Int("flow-flush-interval", 2000, "flush flows every X flows")
-	flagFlowTimeOut       = flag.Int("flow-timeout", 30, "close flows older than X seconds")
+	flowPayloads        = newPayloadAccumulator("Flow")
 
-	flowFlushInterval int64
-	flowTimeOut       time.Duration
+	flagFlowFlushInterval = flag.Int("flow-flush-interval", 2000, "deprecated: flows are checked for timeouts once per second of packet time")
+	flagFlowTimeOut       = flag.Int("flow-timeout", 30, "close flows that have been idle for X seconds")
+	flagFlowActiveTimeout = flag.Int("flow-active-timeout", 1800, "close flows that have been active for X seconds, the flow continues in a new record")
+	flagFlowTableSize     = flag.Int("flow-table-size", 1000000, "maximum number of flows kept in memory per flow type, the least recently used flow is closed if the limit is reached")
 )
 
+// newFlowTable creates a flow table configured with the flow flags
+func newFlowTable(name string) *FlowTable {
+	return NewFlowTable(
+		name,
+		*flagFlowTableSize,
+		time.Second*time.Duration(*flagFlowTimeOut),
+		time.Second*time.Duration(*flagFlowActiveTimeout),
+	)
+}
+
 var flowEncoder = CreateCustomEncoder(types.Type_NC_Flow, "Flow", func(d *CustomEncoder) error {
 	flowEncoderInstance = d
-	flowFlushInterval = int64(*flagFlowFlushInterval)
-	flowTimeOut = time.Second * time.Duration(*flagFlowTimeOut)
-	return nil
+	Flows = newAtomicFlowMap(newFlowTable("Flow"))
+
+	if flagIsSet("flow-flush-interval") {
+		fmt.Println("warning: -flow-flush-interval", *flagFlowFlushInterval, "is deprecated and has no effect, flows are checked for timeouts once per second of packet time")
+	}
+
+	var err error
+	flowExporter, err = newNetFlowExporter("Flow")
+	return err
 }, func(p gopacket.Packet) proto.Message {
 
 	// get identifier
 	var (
-		net       gopacket.Flow
-		transport gopacket.Flow
+		flowID  = newFlowID(p)
+		ts      = p.Metadata().Timestamp
+		expired []interface{}
 	)
-	if nl := p.NetworkLayer(); nl != nil {
-		net = nl.NetworkFlow()
-	}
-	if tl := p.TransportLayer(); tl != nil {
-		transport = tl.TransportFlow()
-	}
-	flowID := fmt.Sprintf("%s:%s", net, transport)
 
 	// lookup flow
 	Flows.Lock()
-	if flow, ok := Flows.Items[flowID]; ok {
+	if f, ok := Flows.Get(flowID, ts); ok {
+		flow := f.(*types.Flow)
 
 		// flow exists. update fields
 		calcDuration := false
@@ -124,6 +146,10 @@ var flowEncoder = CreateCustomEncoder(types.Type_NC_Flow, "Flow", func(d *Custom
 		flow.NumPackets++
 		flow.TotalSize += int32(len(p.Data()))
 
+		if al := p.ApplicationLayer(); al != nil {
+			flowPayloads.add(flow, &flow.Payload, al.Payload())
+		}
+
 		// only calculate duration when timetamps have changed
 		if calcDuration {
 			flow.Duration = utils.StringToTime(flow.TimestampLast).Sub(utils.StringToTime(flow.TimestampFirst)).Nanoseconds()
@@ -132,6 +158,7 @@ var flowEncoder = CreateCustomEncoder(types.Type_NC_Flow, "Flow", func(d *Custom
 		// create a new flow
 		f := &types.Flow{}
 		f.UID = calcMd5(flowID)
+		f.CommunityID = CommunityID(p)
 		f.TimestampFirst = utils.TimeToString(p.Metadata().Timestamp)
 
 		if ll := p.LinkLayer(); ll != nil {
@@ -152,44 +179,62 @@ var flowEncoder = CreateCustomEncoder(types.Type_NC_Flow, "Flow", func(d *Custom
 		if al := p.ApplicationLayer(); al != nil {
 			f.ApplicationProto = al.LayerType().String()
 			f.AppPayloadSize = int32(len(al.Payload()))
+			flowPayloads.add(f, &f.Payload, al.Payload())
 		}
-		Flows.Items[flowID] = f
-
-		// continuously flush flows
-		flows++
-		if flows%flowFlushInterval == 0 {
-
-			var selectFlows []*types.Flow
-			for id, f := range Flows.Items {
-				// flush entries whose last timestamp is flowTimeOut older than current packet
-				if p.Metadata().Timestamp.Sub(utils.StringToTime(f.TimestampLast)) > flowTimeOut {
-					selectFlows = append(selectFlows, f)
-					// cleanup
-					delete(Flows.Items, id)
-				}
-			}
-
-			// do this in background
-			go func() {
-				for _, f := range selectFlows {
-					writeFlow(f)
-				}
-			}()
+		if evicted := Flows.Put(flowID, f, ts); evicted != nil {
+			expired = append(expired, evicted)
 		}
 	}
+	expired = append(expired, Flows.Expire(ts)...)
 	Flows.Unlock()
+
+	// write timed out and evicted flows
+	for _, f := range expired {
+		writeFlow(f.(*types.Flow))
+	}
 	return nil
 }, func(e *CustomEncoder) error {
 	if !e.writer.IsChanWriter {
-		for _, f := range Flows.Items {
-			writeFlow(f)
+		Flows.Lock()
+		flows := Flows.Flush()
+		Flows.Unlock()
+		for _, f := range flows {
+			writeFlow(f.(*types.Flow))
 		}
 	}
+	closeNetFlowExporter(flowExporter)
 	return nil
 })
 
+// newFlowID returns the identifier of the flow the packet belongs to
+// format: <networkFlow>:<tranportFlow>
+func newFlowID(p gopacket.Packet) string {
+	var (
+		net       gopacket.Flow
+		transport gopacket.Flow
+	)
+	if nl := p.NetworkLayer(); nl != nil {
+		net = nl.NetworkFlow()
+	}
+	if tl := p.TransportLayer(); tl != nil {
+		transport = tl.TransportFlow()
+	}
+	return fmt.Sprintf("%s:%s", net, transport)
+}
+
+// FlowUID returns the UID of the Flow the packet belongs to
+func FlowUID(p gopacket.Packet) string {
+	return calcMd5(newFlowID(p))
+}
+
 func writeFlow(f *types.Flow) {
 
+	f.DstHostname = dstHostname(f.DstIP)
+
+	if hash := flowPayloads.finish(f); hash != "" {
+		f.PayloadHash = hash
+	}
+
 	if flowEncoderInstance.export {
 		f.Inc()
 	}
@@ -199,4 +244,11 @@ func writeFlow(f *types.Flow) {
 	if err != nil {
 		log.Fatal("failed to write proto: ", err)
 	}
+
+	evaluateRules(f)
+	exportNetFlow(flowExporter, f)
+
+	if beaconAnalyzer != nil && beaconFlows {
+		beaconAnalyzer.AddRecord(f)
+	}
 }
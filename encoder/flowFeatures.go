@@ -0,0 +1,539 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagFeaturesTimeout         = flag.Int("features-timeout", 120, "export flow features for flows that have been idle or active for more than X seconds")
+	flagFeaturesActivityTimeout = flag.Int("features-activity-timeout", 5, "start a new active period of a flow after X seconds without packets")
+)
+
+const (
+	// packets with a larger gap start a new bulk or subflow
+	bulkTimeout    = time.Second
+	subflowTimeout = time.Second
+
+	// number of consecutive payload packets in one direction that form a bulk
+	bulkMinPackets = 4
+)
+
+var (
+	flowFeaturesEncoderInstance *CustomEncoder
+
+	featureFlows = NewFlowTable("FlowFeatures", 0, 0, 0)
+)
+
+// featureKey identifies a bidirectional flow
+// FastHash is symmetric, so both directions map to the same key
+type featureKey struct {
+	protocol  int32
+	net       uint64
+	transport uint64
+}
+
+// featureStats keeps running statistics over a series of values
+type featureStats struct {
+	n          int64
+	sum, sumSq float64
+	min, max   float64
+}
+
+func (s *featureStats) add(v float64) {
+	if s.n == 0 || v < s.min {
+		s.min = v
+	}
+	if s.n == 0 || v > s.max {
+		s.max = v
+	}
+	s.n++
+	s.sum += v
+	s.sumSq += v * v
+}
+
+func (s *featureStats) mean() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.sum / float64(s.n)
+}
+
+// variance returns the sample variance
+func (s *featureStats) variance() float64 {
+	if s.n < 2 {
+		return 0
+	}
+	v := (s.sumSq - s.sum*s.sum/float64(s.n)) / float64(s.n-1)
+	// avoid negative values due to rounding errors
+	return math.Max(0, v)
+}
+
+func (s *featureStats) std() float64 {
+	return math.Sqrt(s.variance())
+}
+
+// bulkState tracks bulk transfers in one direction
+type bulkState struct {
+	start       time.Time
+	last        time.Time
+	packetCount int64
+	size        int64
+
+	numBulks   int64
+	numPackets int64
+	totalSize  int64
+	duration   time.Duration
+}
+
+// update adds a packet to the current bulk
+// other is the time of the last bulk packet in the opposite direction
+func (b *bulkState) update(ts, other time.Time, size int64) {
+
+	// a packet in the other direction ends the bulk
+	if other.After(b.start) {
+		b.start = time.Time{}
+	}
+	if size <= 0 {
+		return
+	}
+	if b.start.IsZero() || ts.Sub(b.last) > bulkTimeout {
+		b.start, b.last = ts, ts
+		b.packetCount, b.size = 1, size
+		return
+	}
+
+	b.packetCount++
+	b.size += size
+	if b.packetCount == bulkMinPackets {
+		b.numBulks++
+		b.numPackets += b.packetCount
+		b.totalSize += b.size
+		b.duration += ts.Sub(b.start)
+	} else if b.packetCount > bulkMinPackets {
+		b.numPackets++
+		b.totalSize += size
+		b.duration += ts.Sub(b.last)
+	}
+	b.last = ts
+}
+
+// averages returns the average bytes and packets per bulk and the bulk rate in bytes per second
+func (b *bulkState) averages() (bytes, packets, rate float64) {
+	if b.numBulks == 0 {
+		return 0, 0, 0
+	}
+	bytes = float64(b.totalSize) / float64(b.numBulks)
+	packets = float64(b.numPackets) / float64(b.numBulks)
+	if b.duration > 0 {
+		rate = float64(b.totalSize) / b.duration.Seconds()
+	}
+	return
+}
+
+// featureFlow collects the features of a bidirectional flow
+type featureFlow struct {
+	first, last time.Time
+
+	srcIP, dstIP     string
+	srcPort, dstPort int32
+	protocol         int32
+
+	fwdLen, bwdLen, allLen  featureStats
+	flowIAT, fwdIAT, bwdIAT featureStats
+	lastFwd, lastBwd        time.Time
+
+	fwdPSH, bwdPSH, fwdURG, bwdURG         int32
+	fwdHeader, bwdHeader                   int64
+	fin, syn, rst, psh, ack, urg, cwe, ece int32
+	finFwd, finBwd                         bool
+
+	fwdBulk, bwdBulk bulkState
+
+	subflowLast  time.Time
+	numSubflows  int64
+	startActive  time.Time
+	endActive    time.Time
+	active, idle featureStats
+
+	initWinFwd, initWinBwd int32
+	actDataFwd             int64
+	minSegFwd              int64
+}
+
+var flowFeaturesEncoder = CreateCustomEncoder(types.Type_NC_FlowFeatures, "FlowFeatures", func(e *CustomEncoder) error {
+	flowFeaturesEncoderInstance = e
+	timeout := time.Duration(*flagFeaturesTimeout) * time.Second
+	featureFlows = NewFlowTable("FlowFeatures", *flagFlowTableSize, timeout, timeout)
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+
+	var (
+		nl = p.NetworkLayer()
+		tl = p.TransportLayer()
+	)
+	if nl == nil || tl == nil {
+		return nil
+	}
+
+	var (
+		tcp      *layers.TCP
+		protocol int32
+	)
+	switch t := tl.(type) {
+	case *layers.TCP:
+		tcp = t
+		protocol = int32(layers.IPProtocolTCP)
+	case *layers.UDP:
+		protocol = int32(layers.IPProtocolUDP)
+	default:
+		return nil
+	}
+
+	var (
+		ts      = p.Metadata().Timestamp
+		k       = featureKey{protocol: protocol, net: nl.NetworkFlow().FastHash(), transport: tl.TransportFlow().FastHash()}
+		timeout = time.Duration(*flagFeaturesTimeout) * time.Second
+		src     = nl.NetworkFlow().Src().String()
+		srcPort = tl.TransportFlow().Src().String()
+		done    []interface{}
+		f       *featureFlow
+	)
+
+	featureFlows.Lock()
+
+	if v, ok := featureFlows.Get(k, ts); ok {
+		f = v.(*featureFlow)
+		if ts.Sub(f.last) > timeout || ts.Sub(f.first) > timeout {
+			// idle or active timeout, export and start a new flow
+			done = append(done, f)
+			featureFlows.Delete(k)
+			f = nil
+		}
+	}
+	if f == nil {
+		sp, _ := strconv.Atoi(srcPort)
+		dp, _ := strconv.Atoi(tl.TransportFlow().Dst().String())
+		f = &featureFlow{
+			first:       ts,
+			last:        ts,
+			srcIP:       src,
+			dstIP:       nl.NetworkFlow().Dst().String(),
+			srcPort:     int32(sp),
+			dstPort:     int32(dp),
+			protocol:    protocol,
+			subflowLast: ts,
+			numSubflows: 1,
+			startActive: ts,
+			endActive:   ts,
+			initWinFwd:  -1,
+			initWinBwd:  -1,
+			minSegFwd:   -1,
+		}
+		if evicted := featureFlows.Put(k, f, ts); evicted != nil {
+			done = append(done, evicted)
+		}
+	}
+
+	fwd := src == f.srcIP && srcPort == strconv.Itoa(int(f.srcPort))
+	f.update(ts, fwd, int64(len(tl.LayerPayload())), int64(len(tl.LayerContents())), tcp)
+
+	// TCP flows end with a reset or after both sides sent a FIN
+	if tcp != nil && (tcp.RST || (f.finFwd && f.finBwd)) {
+		done = append(done, f)
+		featureFlows.Delete(k)
+	}
+
+	done = append(done, featureFlows.Expire(ts)...)
+	featureFlows.Unlock()
+
+	// write ended, timed out and evicted flows
+	for _, f := range done {
+		writeFlowFeatures(f.(*featureFlow))
+	}
+
+	// records are written once the flow has ended
+	return nil
+}, func(e *CustomEncoder) error {
+
+	// deinit:
+	// export the remaining flows
+
+	featureFlows.Lock()
+	flows := featureFlows.Flush()
+	featureFlows.Unlock()
+
+	for _, f := range flows {
+		writeFlowFeatures(f.(*featureFlow))
+	}
+
+	return nil
+})
+
+// micros converts a duration to microseconds
+// packets may be processed out of order, negative durations are treated as zero
+func micros(d time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	return float64(d) / float64(time.Microsecond)
+}
+
+// update adds a packet to the flow
+func (f *featureFlow) update(ts time.Time, fwd bool, size, header int64, tcp *layers.TCP) {
+
+	if fwd {
+		if f.fwdLen.n > 0 {
+			f.fwdIAT.add(micros(ts.Sub(f.lastFwd)))
+		}
+		f.fwdLen.add(float64(size))
+		f.fwdHeader += header
+		if size > 0 {
+			f.actDataFwd++
+		}
+		if f.minSegFwd < 0 || header < f.minSegFwd {
+			f.minSegFwd = header
+		}
+		if ts.After(f.lastFwd) {
+			f.lastFwd = ts
+		}
+		f.fwdBulk.update(ts, f.bwdBulk.last, size)
+	} else {
+		if f.bwdLen.n > 0 {
+			f.bwdIAT.add(micros(ts.Sub(f.lastBwd)))
+		}
+		f.bwdLen.add(float64(size))
+		f.bwdHeader += header
+		if ts.After(f.lastBwd) {
+			f.lastBwd = ts
+		}
+		f.bwdBulk.update(ts, f.fwdBulk.last, size)
+	}
+
+	if f.allLen.n > 0 {
+		f.flowIAT.add(micros(ts.Sub(f.last)))
+	}
+	f.allLen.add(float64(size))
+
+	if tcp != nil {
+		f.updateFlags(fwd, tcp)
+	}
+
+	// subflows are separated by gaps of more than a second
+	if ts.Sub(f.subflowLast) > subflowTimeout {
+		f.numSubflows++
+	}
+	if ts.After(f.subflowLast) {
+		f.subflowLast = ts
+	}
+
+	// active and idle periods
+	if ts.Sub(f.endActive) > time.Duration(*flagFeaturesActivityTimeout)*time.Second {
+		if d := f.endActive.Sub(f.startActive); d > 0 {
+			f.active.add(micros(d))
+		}
+		f.idle.add(micros(ts.Sub(f.endActive)))
+		f.startActive, f.endActive = ts, ts
+	} else if ts.After(f.endActive) {
+		f.endActive = ts
+	}
+
+	if ts.After(f.last) {
+		f.last = ts
+	}
+}
+
+func (f *featureFlow) updateFlags(fwd bool, tcp *layers.TCP) {
+	if fwd {
+		if f.initWinFwd < 0 {
+			f.initWinFwd = int32(tcp.Window)
+		}
+		if tcp.PSH {
+			f.fwdPSH++
+		}
+		if tcp.URG {
+			f.fwdURG++
+		}
+		if tcp.FIN {
+			f.finFwd = true
+		}
+	} else {
+		if f.initWinBwd < 0 {
+			f.initWinBwd = int32(tcp.Window)
+		}
+		if tcp.PSH {
+			f.bwdPSH++
+		}
+		if tcp.URG {
+			f.bwdURG++
+		}
+		if tcp.FIN {
+			f.finBwd = true
+		}
+	}
+	for _, flag := range []struct {
+		set   bool
+		count *int32
+	}{
+		{tcp.FIN, &f.fin},
+		{tcp.SYN, &f.syn},
+		{tcp.RST, &f.rst},
+		{tcp.PSH, &f.psh},
+		{tcp.ACK, &f.ack},
+		{tcp.URG, &f.urg},
+		{tcp.CWR, &f.cwe},
+		{tcp.ECE, &f.ece},
+	} {
+		if flag.set {
+			*flag.count++
+		}
+	}
+}
+
+func writeFlowFeatures(f *featureFlow) {
+
+	// add the last active period
+	if d := f.endActive.Sub(f.startActive); d > 0 {
+		f.active.add(micros(d))
+		f.startActive = f.endActive
+	}
+
+	var (
+		duration  = f.last.Sub(f.first)
+		seconds   = duration.Seconds()
+		perSecond = func(v float64) float64 {
+			if seconds <= 0 {
+				return 0
+			}
+			return v / seconds
+		}
+		fwdBytes, fwdPackets, fwdRate = f.fwdBulk.averages()
+		bwdBytes, bwdPackets, bwdRate = f.bwdBulk.averages()
+		downUp                        float64
+	)
+	if f.fwdLen.n > 0 {
+		downUp = float64(f.bwdLen.n) / float64(f.fwdLen.n)
+	}
+
+	r := &types.FlowFeatures{
+		Timestamp:             utils.TimeToString(f.first),
+		FlowID:                f.srcIP + "-" + f.dstIP + "-" + strconv.Itoa(int(f.srcPort)) + "-" + strconv.Itoa(int(f.dstPort)) + "-" + strconv.Itoa(int(f.protocol)),
+		SrcIP:                 f.srcIP,
+		SrcPort:               f.srcPort,
+		DstIP:                 f.dstIP,
+		DstPort:               f.dstPort,
+		Protocol:              f.protocol,
+		Duration:              int64(micros(duration)),
+		TotalFwdPackets:       f.fwdLen.n,
+		TotalBwdPackets:       f.bwdLen.n,
+		TotalLengthFwdPackets: int64(f.fwdLen.sum),
+		TotalLengthBwdPackets: int64(f.bwdLen.sum),
+		FwdPacketLengthMax:    int64(f.fwdLen.max),
+		FwdPacketLengthMin:    int64(f.fwdLen.min),
+		FwdPacketLengthMean:   f.fwdLen.mean(),
+		FwdPacketLengthStd:    f.fwdLen.std(),
+		BwdPacketLengthMax:    int64(f.bwdLen.max),
+		BwdPacketLengthMin:    int64(f.bwdLen.min),
+		BwdPacketLengthMean:   f.bwdLen.mean(),
+		BwdPacketLengthStd:    f.bwdLen.std(),
+		FlowBytesPerSec:       perSecond(f.allLen.sum),
+		FlowPacketsPerSec:     perSecond(float64(f.allLen.n)),
+		FlowIATMean:           f.flowIAT.mean(),
+		FlowIATStd:            f.flowIAT.std(),
+		FlowIATMax:            f.flowIAT.max,
+		FlowIATMin:            f.flowIAT.min,
+		FwdIATTotal:           f.fwdIAT.sum,
+		FwdIATMean:            f.fwdIAT.mean(),
+		FwdIATStd:             f.fwdIAT.std(),
+		FwdIATMax:             f.fwdIAT.max,
+		FwdIATMin:             f.fwdIAT.min,
+		BwdIATTotal:           f.bwdIAT.sum,
+		BwdIATMean:            f.bwdIAT.mean(),
+		BwdIATStd:             f.bwdIAT.std(),
+		BwdIATMax:             f.bwdIAT.max,
+		BwdIATMin:             f.bwdIAT.min,
+		FwdPSHFlags:           f.fwdPSH,
+		BwdPSHFlags:           f.bwdPSH,
+		FwdURGFlags:           f.fwdURG,
+		BwdURGFlags:           f.bwdURG,
+		FwdHeaderLength:       f.fwdHeader,
+		BwdHeaderLength:       f.bwdHeader,
+		FwdPacketsPerSec:      perSecond(float64(f.fwdLen.n)),
+		BwdPacketsPerSec:      perSecond(float64(f.bwdLen.n)),
+		PacketLengthMin:       int64(f.allLen.min),
+		PacketLengthMax:       int64(f.allLen.max),
+		PacketLengthMean:      f.allLen.mean(),
+		PacketLengthStd:       f.allLen.std(),
+		PacketLengthVariance:  f.allLen.variance(),
+		FINFlagCount:          f.fin,
+		SYNFlagCount:          f.syn,
+		RSTFlagCount:          f.rst,
+		PSHFlagCount:          f.psh,
+		ACKFlagCount:          f.ack,
+		URGFlagCount:          f.urg,
+		CWEFlagCount:          f.cwe,
+		ECEFlagCount:          f.ece,
+		DownUpRatio:           downUp,
+		AveragePacketSize:     f.allLen.mean(),
+		AvgFwdSegmentSize:     f.fwdLen.mean(),
+		AvgBwdSegmentSize:     f.bwdLen.mean(),
+		FwdAvgBytesBulk:       fwdBytes,
+		FwdAvgPacketsBulk:     fwdPackets,
+		FwdAvgBulkRate:        fwdRate,
+		BwdAvgBytesBulk:       bwdBytes,
+		BwdAvgPacketsBulk:     bwdPackets,
+		BwdAvgBulkRate:        bwdRate,
+		SubflowFwdPackets:     f.fwdLen.n / f.numSubflows,
+		SubflowFwdBytes:       int64(f.fwdLen.sum) / f.numSubflows,
+		SubflowBwdPackets:     f.bwdLen.n / f.numSubflows,
+		SubflowBwdBytes:       int64(f.bwdLen.sum) / f.numSubflows,
+		InitWinBytesFwd:       f.initWinFwd,
+		InitWinBytesBwd:       f.initWinBwd,
+		ActDataPktFwd:         f.actDataFwd,
+		MinSegSizeFwd:         f.minSegFwd,
+		ActiveMean:            f.active.mean(),
+		ActiveStd:             f.active.std(),
+		ActiveMax:             f.active.max,
+		ActiveMin:             f.active.min,
+		IdleMean:              f.idle.mean(),
+		IdleStd:               f.idle.std(),
+		IdleMax:               f.idle.max,
+		IdleMin:               f.idle.min,
+	}
+
+	// export metrics if configured
+	if flowFeaturesEncoderInstance.export {
+		r.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&flowFeaturesEncoderInstance.numRecords, 1)
+	err := flowFeaturesEncoderInstance.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(r)
+}
@@ -0,0 +1,127 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+func TestFeatureStats(t *testing.T) {
+	var s featureStats
+	if s.mean() != 0 || s.variance() != 0 {
+		t.Fatal("expected zero values without samples")
+	}
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.add(v)
+	}
+	if s.n != 8 || s.min != 2 || s.max != 9 || s.mean() != 5 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	// sample variance: 32 / 7
+	if v := s.variance(); v < 4.571 || v > 4.572 {
+		t.Fatalf("unexpected variance %f", v)
+	}
+}
+
+func TestFeatureFlowDirections(t *testing.T) {
+
+	var (
+		start = time.Unix(1500000000, 0)
+		f     = &featureFlow{
+			first:       start,
+			last:        start,
+			subflowLast: start,
+			numSubflows: 1,
+			startActive: start,
+			endActive:   start,
+			initWinFwd:  -1,
+			initWinBwd:  -1,
+			minSegFwd:   -1,
+		}
+		ms = func(n int) time.Time {
+			return start.Add(time.Duration(n) * time.Millisecond)
+		}
+	)
+
+	// handshake
+	f.update(ms(0), true, 0, 40, &layers.TCP{SYN: true, Window: 1000})
+	f.update(ms(10), false, 0, 40, &layers.TCP{SYN: true, ACK: true, Window: 2000})
+	f.update(ms(20), true, 0, 32, &layers.TCP{ACK: true, Window: 1001})
+
+	// request and a bulk of 5 response packets
+	f.update(ms(30), true, 100, 32, &layers.TCP{ACK: true, PSH: true})
+	for i := 0; i < 5; i++ {
+		f.update(ms(40+i*10), false, 1000, 32, &layers.TCP{ACK: true})
+	}
+	f.update(ms(100), false, 500, 32, &layers.TCP{ACK: true, PSH: true, URG: true})
+
+	if f.fwdLen.n != 3 || f.bwdLen.n != 7 || f.fwdLen.sum != 100 || f.bwdLen.sum != 5500 {
+		t.Fatalf("unexpected packet counts: fwd %d/%f, bwd %d/%f", f.fwdLen.n, f.fwdLen.sum, f.bwdLen.n, f.bwdLen.sum)
+	}
+	if f.fwdLen.max != 100 || f.fwdLen.min != 0 || f.bwdLen.max != 1000 || f.bwdLen.min != 0 {
+		t.Fatal("unexpected packet lengths")
+	}
+
+	// inter arrival times in microseconds
+	if f.fwdIAT.n != 2 || f.fwdIAT.sum != 30000 || f.fwdIAT.min != 10000 || f.fwdIAT.max != 20000 {
+		t.Fatalf("unexpected forward IAT: %+v", f.fwdIAT)
+	}
+	if f.bwdIAT.n != 6 || f.bwdIAT.sum != 90000 || f.bwdIAT.max != 30000 {
+		t.Fatalf("unexpected backward IAT: %+v", f.bwdIAT)
+	}
+	if f.flowIAT.n != 9 || f.flowIAT.sum != 100000 {
+		t.Fatalf("unexpected flow IAT: %+v", f.flowIAT)
+	}
+
+	if f.fwdHeader != 104 || f.bwdHeader != 232 || f.minSegFwd != 32 || f.actDataFwd != 1 {
+		t.Fatalf("unexpected header stats: fwd %d, bwd %d, min %d, data %d", f.fwdHeader, f.bwdHeader, f.minSegFwd, f.actDataFwd)
+	}
+	if f.initWinFwd != 1000 || f.initWinBwd != 2000 {
+		t.Fatal("unexpected initial windows", f.initWinFwd, f.initWinBwd)
+	}
+	if f.fwdPSH != 1 || f.bwdPSH != 1 || f.fwdURG != 0 || f.bwdURG != 1 || f.syn != 2 || f.ack != 9 {
+		t.Fatal("unexpected flag counts")
+	}
+
+	// only the response forms a bulk
+	if f.fwdBulk.numBulks != 0 || f.bwdBulk.numBulks != 1 || f.bwdBulk.numPackets != 6 || f.bwdBulk.totalSize != 5500 {
+		t.Fatalf("unexpected bulks: fwd %+v, bwd %+v", f.fwdBulk, f.bwdBulk)
+	}
+	if bytes, packets, rate := f.bwdBulk.averages(); bytes != 5500 || packets != 6 || rate != 5500/0.06 {
+		t.Fatalf("unexpected bulk averages: %f, %f, %f", bytes, packets, rate)
+	}
+
+	if !f.last.Equal(ms(100)) || f.numSubflows != 1 {
+		t.Fatal("unexpected flow state")
+	}
+}
+
+// a packet in the other direction ends a bulk
+func TestBulkInterrupted(t *testing.T) {
+
+	var (
+		b     bulkState
+		start = time.Unix(1500000000, 0)
+	)
+	for i := 0; i < 3; i++ {
+		b.update(start.Add(time.Duration(i)*time.Millisecond), time.Time{}, 100)
+	}
+	b.update(start.Add(4*time.Millisecond), start.Add(3*time.Millisecond), 100)
+	if b.numBulks != 0 || b.packetCount != 1 {
+		t.Fatalf("unexpected bulk: %+v", b)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/netflow"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// AddFlowRecord writes a flow received from a NetFlow, IPFIX or sFlow exporter as Flow audit record,
+// and merges it into the Connection of both directions.
+// The identifiers are calculated like for captured packets without a link layer.
+// Nothing is written for the Flow or Connection encoder if it is not active.
+func AddFlowRecord(r *netflow.Record) {
+
+	var (
+		e           = newFlowRecordEndpoints(r)
+		communityID = utils.CommunityID(uint16(*flagCommunityIDSeed), r.Proto, r.SrcIP, r.DstIP, r.SrcPort, r.DstPort)
+	)
+
+	if flowEncoderInstance != nil {
+		writeFlow(&types.Flow{
+			TimestampFirst: utils.TimeToString(r.Start),
+			TimestampLast:  utils.TimeToString(r.End),
+			LinkProto:      e.linkProto,
+			NetworkProto:   e.networkProto,
+			TransportProto: e.transportProto,
+			SrcMAC:         e.srcMAC,
+			DstMAC:         e.dstMAC,
+			SrcIP:          r.SrcIP.String(),
+			DstIP:          r.DstIP.String(),
+			SrcPort:        e.srcPort,
+			DstPort:        e.dstPort,
+			TotalSize:      addInt32(0, r.Bytes),
+			NumPackets:     addInt32(0, r.Packets),
+			UID:            calcMd5(fmt.Sprintf("%s:%s", e.net, e.transport)),
+			Duration:       r.End.Sub(r.Start).Nanoseconds(),
+			CommunityID:    communityID,
+		})
+	}
+
+	if connEncoderInstance != nil {
+		addConnectionRecord(r, e, communityID)
+	}
+}
+
+// addConnectionRecord merges the flow into its connection, the earliest flow decides about the direction
+func addConnectionRecord(r *netflow.Record, e *flowRecordEndpoints, communityID string) {
+
+	var (
+		id      = ConnectionID{LinkFlowID: e.link.FastHash(), NetworkFlowID: e.net.FastHash(), TransportFlowID: e.transport.FastHash()}.String()
+		ts      = r.End
+		expired []interface{}
+	)
+
+	Connections.Lock()
+	if c, ok := Connections.Get(id, ts); ok {
+		conn := c.(*types.Connection)
+
+		if r.Start.Before(utils.StringToTime(conn.TimestampFirst)) {
+			conn.TimestampFirst = utils.TimeToString(r.Start)
+			conn.SrcMAC, conn.DstMAC = e.srcMAC, e.dstMAC
+			conn.SrcIP, conn.DstIP = r.SrcIP.String(), r.DstIP.String()
+			conn.SrcPort, conn.DstPort = e.srcPort, e.dstPort
+		}
+		if r.End.After(utils.StringToTime(conn.TimestampLast)) {
+			conn.TimestampLast = utils.TimeToString(r.End)
+		}
+		conn.NumPackets = addInt32(conn.NumPackets, r.Packets)
+		conn.TotalSize = addInt32(conn.TotalSize, r.Bytes)
+		conn.Duration = utils.StringToTime(conn.TimestampLast).Sub(utils.StringToTime(conn.TimestampFirst)).Nanoseconds()
+	} else {
+		conn := &types.Connection{
+			TimestampFirst: utils.TimeToString(r.Start),
+			TimestampLast:  utils.TimeToString(r.End),
+			LinkProto:      e.linkProto,
+			NetworkProto:   e.networkProto,
+			TransportProto: e.transportProto,
+			SrcMAC:         e.srcMAC,
+			DstMAC:         e.dstMAC,
+			SrcIP:          r.SrcIP.String(),
+			DstIP:          r.DstIP.String(),
+			SrcPort:        e.srcPort,
+			DstPort:        e.dstPort,
+			TotalSize:      addInt32(0, r.Bytes),
+			NumPackets:     addInt32(0, r.Packets),
+			UID:            calcMd5(id),
+			Duration:       r.End.Sub(r.Start).Nanoseconds(),
+			CommunityID:    communityID,
+		}
+		if evicted := Connections.Put(id, conn, ts); evicted != nil {
+			expired = append(expired, evicted)
+		}
+	}
+	expired = append(expired, Connections.Expire(ts)...)
+	Connections.Unlock()
+
+	// write timed out and evicted connections
+	for _, c := range expired {
+		writeConn(c.(*types.Connection))
+	}
+}
+
+// flowRecordEndpoints holds the flows and their string representations for a flow record,
+// as they would have been created by the packet decoding
+type flowRecordEndpoints struct {
+	link, net, transport gopacket.Flow
+
+	linkProto, networkProto, transportProto string
+	srcMAC, dstMAC, srcPort, dstPort        string
+}
+
+func newFlowRecordEndpoints(r *netflow.Record) *flowRecordEndpoints {
+
+	e := &flowRecordEndpoints{}
+
+	if len(r.SrcMAC) == 6 && len(r.DstMAC) == 6 {
+		e.link = gopacket.NewFlow(layers.EndpointMAC, r.SrcMAC, r.DstMAC)
+		e.linkProto = layers.LayerTypeEthernet.String()
+		e.srcMAC, e.dstMAC = r.SrcMAC.String(), r.DstMAC.String()
+	}
+
+	if src, dst := r.SrcIP.To4(), r.DstIP.To4(); src != nil && dst != nil {
+		e.net = gopacket.NewFlow(layers.EndpointIPv4, src, dst)
+		e.networkProto = layers.LayerTypeIPv4.String()
+	} else {
+		e.net = gopacket.NewFlow(layers.EndpointIPv6, r.SrcIP.To16(), r.DstIP.To16())
+		e.networkProto = layers.LayerTypeIPv6.String()
+	}
+
+	var (
+		endpointType gopacket.EndpointType
+		layerType    gopacket.LayerType
+	)
+	switch layers.IPProtocol(r.Proto) {
+	case layers.IPProtocolTCP:
+		endpointType, layerType = layers.EndpointTCPPort, layers.LayerTypeTCP
+	case layers.IPProtocolUDP:
+		endpointType, layerType = layers.EndpointUDPPort, layers.LayerTypeUDP
+	case layers.IPProtocolSCTP:
+		endpointType, layerType = layers.EndpointSCTPPort, layers.LayerTypeSCTP
+	default:
+		// no transport layer, e.g. for ICMP
+		return e
+	}
+	e.transport = gopacket.NewFlow(endpointType, portBytes(r.SrcPort), portBytes(r.DstPort))
+	e.transportProto = layerType.String()
+	e.srcPort = strconv.Itoa(int(r.SrcPort))
+	e.dstPort = strconv.Itoa(int(r.DstPort))
+
+	return e
+}
+
+func portBytes(p uint16) []byte {
+	return []byte{byte(p >> 8), byte(p)}
+}
+
+// addInt32 adds the counter from a flow record to the 32 bit value of an audit record,
+// the result is limited to the maximum value, since exported counters use 64 bits
+func addInt32(v int32, n uint64) int32 {
+	if n > uint64(math.MaxInt32-int64(v)) {
+		return math.MaxInt32
+	}
+	return v + int32(n)
+}
@@ -0,0 +1,38 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAddInt32(t *testing.T) {
+	for _, c := range []struct {
+		v        int32
+		n        uint64
+		expected int32
+	}{
+		{0, 1500, 1500},
+		{100, 1 << 31, math.MaxInt32},
+		{0, 1 << 40, math.MaxInt32},
+		{math.MaxInt32 - 10, 10, math.MaxInt32},
+		{math.MaxInt32 - 10, 11, math.MaxInt32},
+		{math.MaxInt32, 0, math.MaxInt32},
+	} {
+		if r := addInt32(c.v, c.n); r != c.expected {
+			t.Errorf("addInt32(%d, %d): expected %d, got %d", c.v, c.n, c.expected, r)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// interval in which the flow tables are checked for timed out flows, measured in packet time
+const flowSweepInterval = time.Second
+
+// reasons for removing entries from a flow table
+const (
+	evictIdle     = "idle"
+	evictActive   = "active"
+	evictCapacity = "capacity"
+)
+
+var (
+	flowTableEntries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nc_flowtable_entries",
+			Help: "Number of entries in the flow tables",
+		},
+		[]string{"Table"},
+	)
+	flowTableEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nc_flowtable_evictions_total",
+			Help: "Counter for entries removed from the flow tables because of a timeout or the table size limit",
+		},
+		[]string{"Table", "Reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(flowTableEntries)
+	prometheus.MustRegister(flowTableEvictions)
+}
+
+// FlowTable holds the state of flows with a bounded number of entries.
+// If the table is full, the least recently used entry is evicted.
+// Entries are removed once they have been idle for the idle timeout,
+// long lived flows are removed after the active timeout, so they are exported periodically.
+// The next packet of such a flow creates a new entry.
+// Except for Size, the methods must be called with the lock held.
+type FlowTable struct {
+	sync.Mutex
+
+	name    string
+	maxSize int
+	idle    time.Duration
+	active  time.Duration
+
+	items map[interface{}]*flowEntry
+
+	// entries ordered by their last use, most recently used first
+	lru *list.List
+	// entries ordered by their creation, oldest first
+	created *list.List
+
+	// latest packet timestamp
+	now       time.Time
+	nextSweep time.Time
+
+	size prometheus.Gauge
+
+	// called for each removed entry, if set
+	onRemove func(key, value interface{})
+}
+
+type flowEntry struct {
+	key   interface{}
+	value interface{}
+
+	first time.Time
+	last  time.Time
+
+	lruElem     *list.Element
+	createdElem *list.Element
+}
+
+// NewFlowTable creates a flow table with the given limits.
+// A maxSize or timeout of zero disables the limit.
+func NewFlowTable(name string, maxSize int, idle, active time.Duration) *FlowTable {
+	return &FlowTable{
+		name:    name,
+		maxSize: maxSize,
+		idle:    idle,
+		active:  active,
+		items:   make(map[interface{}]*flowEntry),
+		lru:     list.New(),
+		created: list.New(),
+		size:    flowTableEntries.WithLabelValues(name),
+	}
+}
+
+// Size returns the number of entries.
+func (t *FlowTable) Size() int {
+	t.Lock()
+	defer t.Unlock()
+
+	return len(t.items)
+}
+
+// Get returns the value for key and marks the entry as used at ts.
+func (t *FlowTable) Get(key interface{}, ts time.Time) (interface{}, bool) {
+	e, ok := t.items[key]
+	if !ok {
+		return nil, false
+	}
+	if ts.After(e.last) {
+		e.last = ts
+	}
+	t.lru.MoveToFront(e.lruElem)
+	return e.value, true
+}
+
+// Put adds a new entry, first seen at ts.
+// If the table is full, the least recently used value is evicted and returned.
+func (t *FlowTable) Put(key, value interface{}, ts time.Time) (evicted interface{}) {
+
+	if t.maxSize > 0 && len(t.items) >= t.maxSize {
+		if back := t.lru.Back(); back != nil {
+			e := back.Value.(*flowEntry)
+			t.remove(e, evictCapacity)
+			evicted = e.value
+		}
+	}
+
+	e := &flowEntry{
+		key:   key,
+		value: value,
+		first: ts,
+		last:  ts,
+	}
+	e.lruElem = t.lru.PushFront(e)
+	e.createdElem = t.created.PushBack(e)
+	t.items[key] = e
+	t.size.Set(float64(len(t.items)))
+
+	return evicted
+}
+
+// Expire advances the table time to ts and removes the entries,
+// that exceeded the idle or active timeout.
+// Tables are checked once per flowSweepInterval of packet time.
+func (t *FlowTable) Expire(ts time.Time) (expired []interface{}) {
+
+	if ts.After(t.now) {
+		t.now = ts
+	}
+	if t.now.Before(t.nextSweep) {
+		return nil
+	}
+	t.nextSweep = t.now.Add(flowSweepInterval)
+
+	// packets are processed concurrently and may arrive slightly out of order,
+	// so the lists are only approximately sorted by time.
+	if t.idle > 0 {
+		for elem := t.lru.Back(); elem != nil; elem = t.lru.Back() {
+			e := elem.Value.(*flowEntry)
+			if t.now.Sub(e.last) <= t.idle {
+				break
+			}
+			t.remove(e, evictIdle)
+			expired = append(expired, e.value)
+		}
+	}
+	if t.active > 0 {
+		for elem := t.created.Front(); elem != nil; elem = t.created.Front() {
+			e := elem.Value.(*flowEntry)
+			if t.now.Sub(e.first) <= t.active {
+				break
+			}
+			t.remove(e, evictActive)
+			expired = append(expired, e.value)
+		}
+	}
+
+	return expired
+}
+
+// Flush removes and returns all values.
+func (t *FlowTable) Flush() (values []interface{}) {
+	for elem := t.created.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*flowEntry)
+		values = append(values, e.value)
+		if t.onRemove != nil {
+			t.onRemove(e.key, e.value)
+		}
+	}
+	t.items = make(map[interface{}]*flowEntry)
+	t.lru.Init()
+	t.created.Init()
+	t.size.Set(0)
+	return values
+}
+
+// Each calls fn for all values.
+func (t *FlowTable) Each(fn func(value interface{})) {
+	for _, e := range t.items {
+		fn(e.value)
+	}
+}
+
+// Delete removes the entry for key, e.g. after the flow has been closed.
+// It is not counted as eviction.
+func (t *FlowTable) Delete(key interface{}) {
+	if e, ok := t.items[key]; ok {
+		t.unlink(e)
+	}
+}
+
+func (t *FlowTable) remove(e *flowEntry, reason string) {
+	t.unlink(e)
+	flowTableEvictions.WithLabelValues(t.name, reason).Inc()
+}
+
+func (t *FlowTable) unlink(e *flowEntry) {
+	delete(t.items, e.key)
+	t.lru.Remove(e.lruElem)
+	t.created.Remove(e.createdElem)
+	t.size.Set(float64(len(t.items)))
+	if t.onRemove != nil {
+		t.onRemove(e.key, e.value)
+	}
+}
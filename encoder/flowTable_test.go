@@ -0,0 +1,206 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var tableStart = time.Unix(1500000000, 0)
+
+func evictions(table, reason string) float64 {
+	return testutil.ToFloat64(flowTableEvictions.WithLabelValues(table, reason))
+}
+
+func sortedStrings(values []interface{}) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = v.(string)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestFlowTableEvictionOrder(t *testing.T) {
+
+	var (
+		tbl    = NewFlowTable("TestCapacity", 3, 0, 0)
+		before = evictions("TestCapacity", evictCapacity)
+	)
+	tbl.Put("a", "a", tableStart)
+	tbl.Put("b", "b", tableStart.Add(time.Second))
+	tbl.Put("c", "c", tableStart.Add(2*time.Second))
+
+	// a is used again, so b is the least recently used entry
+	if v, ok := tbl.Get("a", tableStart.Add(3*time.Second)); !ok || v != "a" {
+		t.Fatal("entry not found")
+	}
+	if evicted := tbl.Put("d", "d", tableStart.Add(4*time.Second)); evicted != "b" {
+		t.Fatalf("expected b to be evicted, got %v", evicted)
+	}
+	if evicted := tbl.Put("e", "e", tableStart.Add(5*time.Second)); evicted != "c" {
+		t.Fatalf("expected c to be evicted, got %v", evicted)
+	}
+	if _, ok := tbl.Get("b", tableStart); ok {
+		t.Fatal("evicted entry still present")
+	}
+	if n := tbl.Size(); n != 3 {
+		t.Fatalf("expected 3 entries, got %d", n)
+	}
+	if n := evictions("TestCapacity", evictCapacity) - before; n != 2 {
+		t.Fatalf("expected 2 capacity evictions, got %f", n)
+	}
+
+	// flush returns the remaining values in creation order
+	if values := tbl.Flush(); !reflect.DeepEqual(values, []interface{}{"a", "d", "e"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if tbl.Size() != 0 {
+		t.Fatal("table not empty after flush")
+	}
+}
+
+func TestFlowTableIdleTimeout(t *testing.T) {
+
+	var (
+		tbl    = NewFlowTable("TestIdle", 0, 10*time.Second, 0)
+		before = evictions("TestIdle", evictIdle)
+	)
+	tbl.Put("a", "a", tableStart)
+	tbl.Put("b", "b", tableStart)
+	tbl.Put("c", "c", tableStart.Add(5*time.Second))
+	tbl.Get("a", tableStart.Add(8*time.Second))
+
+	if expired := tbl.Expire(tableStart.Add(10 * time.Second)); len(expired) != 0 {
+		t.Fatalf("expected no expired entries, got %v", expired)
+	}
+
+	// the tables are only checked once per sweep interval
+	if expired := tbl.Expire(tableStart.Add(10*time.Second + flowSweepInterval/2)); len(expired) != 0 {
+		t.Fatalf("expected no check before the sweep interval, got %v", expired)
+	}
+
+	if expired := tbl.Expire(tableStart.Add(16 * time.Second)); !reflect.DeepEqual(sortedStrings(expired), []string{"b", "c"}) {
+		t.Fatalf("expected b and c to expire, got %v", expired)
+	}
+	if n := evictions("TestIdle", evictIdle) - before; n != 2 {
+		t.Fatalf("expected 2 idle evictions, got %f", n)
+	}
+
+	// an older timestamp does not move the table time backwards
+	if expired := tbl.Expire(tableStart); len(expired) != 0 {
+		t.Fatalf("unexpected expired entries %v", expired)
+	}
+	if expired := tbl.Expire(tableStart.Add(19 * time.Second)); !reflect.DeepEqual(sortedStrings(expired), []string{"a"}) {
+		t.Fatalf("expected a to expire, got %v", expired)
+	}
+}
+
+// long lived flows are split after the active timeout, even if they are still in use
+func TestFlowTableActiveTimeout(t *testing.T) {
+
+	var (
+		tbl    = NewFlowTable("TestActive", 0, 10*time.Second, 30*time.Second)
+		before = evictions("TestActive", evictActive)
+	)
+	tbl.Put("a", "a", tableStart)
+	tbl.Put("b", "b", tableStart.Add(20*time.Second))
+
+	for i := 1; i <= 30; i++ {
+		ts := tableStart.Add(time.Duration(i) * time.Second)
+		tbl.Get("a", ts)
+		tbl.Get("b", ts)
+		if expired := tbl.Expire(ts); len(expired) != 0 {
+			t.Fatalf("unexpected expired entries %v at %d", expired, i)
+		}
+	}
+
+	ts := tableStart.Add(31 * time.Second)
+	tbl.Get("a", ts)
+	if expired := tbl.Expire(ts); !reflect.DeepEqual(expired, []interface{}{"a"}) {
+		t.Fatalf("expected a to expire, got %v", expired)
+	}
+	if n := evictions("TestActive", evictActive) - before; n != 1 {
+		t.Fatalf("expected 1 active eviction, got %f", n)
+	}
+
+	// the next packet of the flow creates a new entry
+	if _, ok := tbl.Get("a", ts); ok {
+		t.Fatal("split entry still present")
+	}
+	tbl.Put("a", "a2", ts)
+	if v, _ := tbl.Get("a", ts); v != "a2" {
+		t.Fatal("new entry not found")
+	}
+}
+
+func TestFlowTableDelete(t *testing.T) {
+
+	var (
+		tbl    = NewFlowTable("TestDelete", 0, time.Second, 0)
+		before = evictions("TestDelete", evictIdle)
+	)
+	tbl.Put("a", "a", tableStart)
+	tbl.Delete("a")
+	tbl.Delete("b")
+
+	if tbl.Size() != 0 {
+		t.Fatal("entry not deleted")
+	}
+	if expired := tbl.Expire(tableStart.Add(time.Minute)); len(expired) != 0 {
+		t.Fatalf("deleted entry expired: %v", expired)
+	}
+	if n := evictions("TestDelete", evictIdle) - before; n != 0 {
+		t.Fatalf("deleted entry counted as eviction")
+	}
+}
+
+func TestAtomicFlowMapItems(t *testing.T) {
+
+	var (
+		flows = newAtomicFlowMap(NewFlowTable("TestItems", 2, 10*time.Second, 0))
+		a     = &types.Flow{UID: "a"}
+		b     = &types.Flow{UID: "b"}
+		c     = &types.Flow{UID: "c"}
+	)
+	flows.Put("a", a, tableStart)
+	flows.Put("b", b, tableStart.Add(time.Second))
+	if len(flows.Items) != 2 || flows.Items["a"] != a || flows.Items["b"] != b {
+		t.Fatal("unexpected items", flows.Items)
+	}
+
+	// a is evicted
+	flows.Put("c", c, tableStart.Add(2*time.Second))
+	if _, ok := flows.Items["a"]; ok || len(flows.Items) != 2 {
+		t.Fatal("evicted flow not removed from items", flows.Items)
+	}
+
+	// b expires
+	flows.Get("c", tableStart.Add(11*time.Second))
+	flows.Expire(tableStart.Add(12 * time.Second))
+	if len(flows.Items) != 1 || flows.Items["c"] != c {
+		t.Fatal("expired flow not removed from items", flows.Items)
+	}
+
+	flows.Flush()
+	if len(flows.Items) != 0 {
+		t.Fatal("flushed flows not removed from items", flows.Items)
+	}
+}
@@ -0,0 +1,433 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/ftp"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// maximum number of commands added to a FTP audit record
+	maxFTPCommands = 1000
+
+	// streams with longer lines are not decoded any further
+	maxFTPLine = 64 * 1024
+
+	// transferred files are truncated after this size
+	maxFTPFileSize = 64 * 1024 * 1024
+)
+
+var (
+	// set in postinit, nil if the FTP encoder is not active
+	ftpEncoderInstance *CustomEncoder
+
+	// ftpMu guards the data channels, and the commands and audit records they refer to,
+	// which are shared between the streams of the control and the data connections
+	ftpMu sync.Mutex
+
+	// opened data channels by the UID of their connection, to label the Connection audit records
+	ftpDataConns = make(map[string]*ftpDataChannel)
+)
+
+var ftpEncoder = CreateCustomEncoder(types.Type_NC_FTP, "FTP", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for the control connection and ensure TCP stream reassembly is enabled
+	// data connections are announced by the control connection
+
+	ftpEncoderInstance = e
+	streamDecoders[21] = newFTPDecoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// FTP is decoded after stream reassembly
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// ftpDataChannel is a data connection negotiated on a control connection
+type ftpDataChannel struct {
+	addr       string
+	controlUID string
+	session    *types.FTP
+
+	// transfer command that used the channel, nil until the client sent it
+	command *types.FTPCommand
+
+	// UID of the data connection, empty until it has been opened
+	connUID string
+}
+
+// ftpDecoder decodes a FTP control connection
+type ftpDecoder struct {
+	parent  *tcpStream
+	session *types.FTP
+
+	// incomplete lines
+	client []byte
+	server []byte
+
+	// commands waiting for their reply
+	pending []*types.FTPCommand
+
+	// code and message of a multiline reply
+	multiline    int
+	multilineMsg string
+
+	// channel negotiated by the latest PORT, EPRT, PASV or EPSV command,
+	// until it is used by a transfer command
+	channel *ftpDataChannel
+
+	// negotiated channels that have not been opened yet, to cancel them when the connection is closed
+	channels []*ftpDataChannel
+
+	// set after AUTH TLS, or if a line exceeds the maximum length
+	done bool
+}
+
+func newFTPDecoder(t *tcpStream) streamDecoder {
+	net, transport := t.clientFlows()
+	return &ftpDecoder{
+		parent: t,
+		session: &types.FTP{
+			SrcIP:   net.Src().String(),
+			DstIP:   net.Dst().String(),
+			SrcPort: int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+			DstPort: int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+			ConnUID: t.connUID,
+		},
+	}
+}
+
+func (d *ftpDecoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.done {
+		return
+	}
+
+	if d.session.Timestamp == "" {
+		d.session.Timestamp = utils.TimeToString(ts)
+	}
+	d.session.TimestampLast = utils.TimeToString(ts)
+
+	buf := &d.server
+	if client {
+		buf = &d.client
+	}
+	*buf = append(*buf, data...)
+
+	for len(*buf) > 0 && !d.done {
+		i := bytes.IndexByte(*buf, '\n')
+		if i < 0 {
+			if len(*buf) > maxFTPLine {
+				errorMap.Inc("FTP: line too long")
+				d.done = true
+			}
+			break
+		}
+		if client {
+			d.clientLine(string((*buf)[:i+1]), ts)
+		} else {
+			d.serverLine(string((*buf)[:i+1]))
+		}
+		*buf = (*buf)[i+1:]
+	}
+
+	// release the memory once all lines have been processed
+	if len(*buf) == 0 || d.done {
+		*buf = nil
+	}
+}
+
+func (d *ftpDecoder) clientLine(l string, ts time.Time) {
+
+	verb, arg := ftp.ParseCommand(l)
+	if verb == "" {
+		return
+	}
+
+	c := &types.FTPCommand{
+		Timestamp: utils.TimeToString(ts),
+		Command:   csvField(verb),
+		Argument:  csvField(arg),
+	}
+
+	switch {
+	case verb == "USER":
+		d.session.User = csvField(arg)
+	case verb == "PASS" || verb == "ACCT":
+		c.Argument = ""
+	case verb == "PORT":
+		if ip, port, err := ftp.ParsePORT(arg); err == nil {
+			d.announce(c, ip.String(), port)
+		}
+	case verb == "EPRT":
+		if ip, port, err := ftp.ParseEPRT(arg); err == nil {
+			d.announce(c, ip.String(), port)
+		}
+	case ftp.IsTransfer(verb) && d.channel != nil:
+		ftpMu.Lock()
+		d.channel.command = c
+		c.DataConnUID = d.channel.connUID
+		ftpMu.Unlock()
+		d.channel = nil
+	}
+
+	if len(d.pending) < maxFTPCommands {
+		d.pending = append(d.pending, c)
+	}
+
+	ftpMu.Lock()
+	if len(d.session.Commands) < maxFTPCommands {
+		d.session.Commands = append(d.session.Commands, c)
+	}
+	ftpMu.Unlock()
+}
+
+func (d *ftpDecoder) serverLine(l string) {
+
+	if d.multiline != 0 {
+		if ftp.IsLastLine(l, d.multiline) {
+			code := d.multiline
+			d.multiline = 0
+			d.reply(code, d.multilineMsg)
+		}
+		return
+	}
+
+	code, text, multiline, ok := ftp.ParseReply(l)
+	if !ok {
+		return
+	}
+	if multiline {
+		d.multiline = code
+		d.multilineMsg = text
+		return
+	}
+	d.reply(code, text)
+}
+
+// reply assigns a complete reply to the oldest command waiting for a reply
+func (d *ftpDecoder) reply(code int, text string) {
+
+	if len(d.pending) == 0 {
+		if d.session.Banner == "" && code == 220 {
+			d.session.Banner = csvField(text)
+		}
+		return
+	}
+
+	c := d.pending[0]
+	c.ReplyCode = int32(code)
+	c.ReplyMessage = csvField(text)
+
+	// preliminary replies are followed by the final reply for the same command
+	if code < 200 {
+		return
+	}
+	d.pending = d.pending[1:]
+
+	switch {
+	case c.Command == "PASV" && code == 227:
+		if ip, port, err := ftp.ParsePASV(text); err == nil {
+			addr := ip.String()
+			if ip.IsUnspecified() {
+				addr = d.session.DstIP
+			}
+			d.announce(c, addr, port)
+		}
+	case c.Command == "EPSV" && code == 229:
+		if port, err := ftp.ParseEPSV(text); err == nil {
+			d.announce(c, d.session.DstIP, port)
+		}
+	case c.Command == "AUTH" && code == 234:
+		// the remaining data is encrypted
+		d.done = true
+	}
+}
+
+// announce registers the data connection negotiated by the command
+func (d *ftpDecoder) announce(c *types.FTPCommand, ip string, port int) {
+
+	ch := &ftpDataChannel{
+		addr:       streamAddr(ip, port),
+		controlUID: d.parent.connUID,
+		session:    d.session,
+	}
+	c.DataAddress = ch.addr
+
+	ftpMu.Lock()
+	defer ftpMu.Unlock()
+
+	// the previous channel has been replaced before a transfer used it, it will not be opened
+	if prev := d.channel; prev != nil && prev.connUID == "" {
+		cancelExpectedStream(prev.addr)
+	}
+
+	// keep the channels that have not been opened, to cancel them when the control connection is closed.
+	// if there are too many, the oldest one is canceled
+	channels := d.channels[:0]
+	for _, o := range d.channels {
+		if o.connUID == "" && o != d.channel {
+			channels = append(channels, o)
+		}
+	}
+	if len(channels) >= maxFTPCommands {
+		cancelExpectedStream(channels[0].addr)
+		channels = channels[1:]
+	}
+	d.channels = append(channels, ch)
+	d.channel = ch
+
+	expectStream(ch.addr, func(t *tcpStream) streamDecoder {
+		return newFTPDataDecoder(t, ch)
+	})
+}
+
+func (d *ftpDecoder) close() {
+
+	ftpMu.Lock()
+	defer ftpMu.Unlock()
+
+	for _, ch := range d.channels {
+		if ch.connUID == "" {
+			cancelExpectedStream(ch.addr)
+		}
+	}
+
+	if ftpEncoderInstance == nil || d.session.Timestamp == "" {
+		return
+	}
+
+	// export metrics if configured
+	if ftpEncoderInstance.export {
+		d.session.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&ftpEncoderInstance.numRecords, 1)
+	err := ftpEncoderInstance.writer.Write(d.session)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(d.session)
+}
+
+// ftpDataDecoder collects the data of a data connection for file extraction
+type ftpDataDecoder struct {
+	parent  *tcpStream
+	channel *ftpDataChannel
+
+	data       []byte
+	fromClient bool
+	ts         time.Time
+}
+
+func newFTPDataDecoder(t *tcpStream, ch *ftpDataChannel) streamDecoder {
+
+	ftpMu.Lock()
+	ch.connUID = t.connUID
+	if ch.command != nil {
+		ch.command.DataConnUID = t.connUID
+	}
+	ch.session.NumTransfers++
+	if connEncoderInstance != nil {
+		ftpDataConns[t.connUID] = ch
+	}
+	ftpMu.Unlock()
+
+	return &ftpDataDecoder{
+		parent:  t,
+		channel: ch,
+	}
+}
+
+func (d *ftpDataDecoder) decode(client bool, data []byte, ts time.Time) {
+	if d.ts.IsZero() {
+		d.ts = ts
+		d.fromClient = client
+	}
+	if fileEncoderInstance != nil && client == d.fromClient && len(d.data)+len(data) <= maxFTPFileSize {
+		d.data = append(d.data, data...)
+	}
+}
+
+// close extracts the transferred file, directory listings are ignored
+func (d *ftpDataDecoder) close() {
+
+	if fileEncoderInstance == nil || len(d.data) == 0 {
+		return
+	}
+
+	var verb, arg string
+	ftpMu.Lock()
+	if d.channel.command != nil {
+		verb, arg = d.channel.command.Command, d.channel.command.Argument
+	}
+	ftpMu.Unlock()
+
+	switch verb {
+	case "LIST", "NLST", "MLSD":
+		return
+	}
+
+	source := "FTPDownload"
+	if ftp.IsUpload(verb) || (verb == "" && d.fromClient) {
+		source = "FTPUpload"
+	}
+
+	var name string
+	if arg != "" {
+		name = path.Base(arg)
+	}
+
+	f := d.parent.newFile(utils.TimeToString(d.ts), source, "", name, "", d.fromClient)
+	f.Protocol = "FTP"
+	extractFile(f, d.data)
+}
+
+// labelFTPDataConnection marks the Connection audit record of a data connection
+// and refers to the control connection and the command that used it
+func labelFTPDataConnection(c *types.Connection) {
+
+	ftpMu.Lock()
+	defer ftpMu.Unlock()
+
+	ch, ok := ftpDataConns[c.UID]
+	if !ok {
+		return
+	}
+	delete(ftpDataConns, c.UID)
+
+	c.ApplicationProto = "FTP-DATA"
+	c.ControlUID = ch.controlUID
+	if ch.command != nil {
+		c.ControlCommand = strings.TrimSpace(ch.command.Command + " " + ch.command.Argument)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dreadl0ck/gopacket"
@@ -29,13 +30,14 @@ import (
 	"github.com/dreadl0ck/gopacket/layers"
 	"github.com/dreadl0ck/gopacket/reassembly"
 	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
 	"github.com/evilsocket/islazy/tui"
 	"github.com/golang/protobuf/proto"
 )
 
 var (
 	defragger     = ip4defrag.NewIPv4Defragmenter()
-	streamFactory = &tcpStreamFactory{doHTTP: !*nohttp}
+	streamFactory = &tcpStreamFactory{}
 	streamPool    = reassembly.NewStreamPool(streamFactory)
 	assembler     = reassembly.NewAssembler(streamPool)
 
@@ -48,8 +50,22 @@ var (
 
 	// HTTPActive must be set to true to decode HTTP traffic
 	HTTPActive bool
+
+	flushStreamsOnce sync.Once
 )
 
+// flushStreams closes the remaining streams and waits until they have been decoded,
+// it is called by the deinit of every encoder that writes records from reassembled streams,
+// so that no records are lost when its writer is closed. Only the first call flushes,
+// it returns the number of streams that have been closed by it.
+func flushStreams() (closed int) {
+	flushStreamsOnce.Do(func() {
+		closed = assembler.FlushAll()
+		streamFactory.WaitGoRoutines()
+	})
+	return
+}
+
 // Stream contains both unidirectional flows for a connection
 type Stream struct {
 	a gopacket.Flow
@@ -126,6 +142,9 @@ func DecodeHTTP(packet gopacket.Packet) {
 		c := Context{
 			CaptureInfo: packet.Metadata().CaptureInfo,
 		}
+		if ll := packet.LinkLayer(); ll != nil {
+			c.linkFlowID = ll.LinkFlow().FastHash()
+		}
 		reassemblyStats.totalsz += len(tcp.Payload)
 		assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, &c)
 	}
@@ -155,6 +174,10 @@ var httpEncoder = CreateCustomEncoder(types.Type_NC_HTTP, "HTTP", func(d *Custom
 
 	HTTPActive = true
 
+	// other encoders can enable stream reassembly as well,
+	// HTTP is only decoded if this encoder is active
+	streamFactory.doHTTP = !*nohttp
+
 	return nil
 }, func(packet gopacket.Packet) proto.Message {
 	// actual decoder is nil, because the processing happens after TCP stream reassembly
@@ -190,7 +213,7 @@ var httpEncoder = CreateCustomEncoder(types.Type_NC_HTTP, "HTTP", func(d *Custom
 	})
 	fmt.Println() // add a newline
 
-	closed := assembler.FlushAll()
+	closed := flushStreams()
 	fmt.Printf("Final flush: %d closed\n", closed)
 	if outputLevel >= 2 {
 		streamPool.Dump()
@@ -209,7 +232,6 @@ var httpEncoder = CreateCustomEncoder(types.Type_NC_HTTP, "HTTP", func(d *Custom
 		}
 	}
 
-	streamFactory.WaitGoRoutines()
 	logDebug("%s\n", assembler.Dump())
 
 	printProgress(1, 1)
@@ -248,9 +270,9 @@ var httpEncoder = CreateCustomEncoder(types.Type_NC_HTTP, "HTTP", func(d *Custom
 	fmt.Println("httpEncoder.numRequests", e.numRequests)
 	fmt.Println("httpEncoder.numResponses", e.numResponses)
 	fmt.Println("httpEncoder.numUnmatchedResp", e.numUnmatchedResp)
-	fmt.Println("httpEncoder.numNilRequests", e.numNilRequests)
 	fmt.Println("httpEncoder.numFoundRequests", e.numFoundRequests)
 	fmt.Println("httpEncoder.numUnansweredRequests", e.numUnansweredRequests)
+	fmt.Println("httpEncoder.numInformational", e.numInformational)
 
 	return nil
 })
@@ -279,18 +301,71 @@ func setRequest(h *types.HTTP, req *http.Request) {
 	// retrieve ip adresses set on the request while processing
 	h.SrcIP = req.Header.Get("netcap-clientip")
 	h.DstIP = req.Header.Get("netcap-serverip")
+	h.CommunityID = req.Header.Get("netcap-communityid")
+	h.RequestHeader = headerMap(req.Header)
+
+	// the net/http package removes the Host header from the map
+	if req.Host != "" {
+		h.RequestHeader["Host"] = req.Host
+	}
+
+	setHTTPRequestBody(h, req)
 }
 
 func newHTTPFromResponse(res *http.Response) *types.HTTP {
-	return &types.HTTP{
+	h := &types.HTTP{
 		ResContentLength:   int32(res.ContentLength),
 		ContentType:        res.Header.Get("Content-Type"),
 		StatusCode:         int32(res.StatusCode),
 		ServerName:         res.Header.Get("Server"),
 		ResContentEncoding: res.Header.Get("Content-Encoding"),
+		ResponseHeader:     headerMap(res.Header),
+		ResTimestamp:       res.Header.Get("netcap-ts"),
+	}
+	setHTTPResponseBody(h, res)
+	return h
+}
+
+// setServerLatency calculates the time between the request and the response
+func setServerLatency(h *types.HTTP) {
+	if h.Timestamp != "" && h.ResTimestamp != "" {
+		h.ServerLatency = utils.StringToTime(h.ResTimestamp).Sub(utils.StringToTime(h.Timestamp)).Nanoseconds()
 	}
 }
 
+// headerMap converts the HTTP header into a map
+// multiple values are joined with a comma and the headers set by netcap for internal use are omitted
+func headerMap(header http.Header) map[string]string {
+	m := make(map[string]string, len(header))
+	for k, v := range header {
+		if strings.HasPrefix(k, "Netcap-") {
+			continue
+		}
+		m[k] = strings.Join(v, ", ")
+	}
+	return m
+}
+
+// writeHTTP writes the HTTP audit record
+func writeHTTP(h *types.HTTP) {
+
+	h.DstHostname = dstHostname(h.DstIP)
+
+	// export metrics if configured
+	if httpEncoder.export {
+		h.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&httpEncoder.numRecords, 1)
+	err := httpEncoder.writer.Write(h)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(h)
+}
+
 func logError(t string, s string, a ...interface{}) {
 	errorsMapMutex.Lock()
 	numErrors++
@@ -0,0 +1,289 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+
+	gzip "github.com/klauspost/pgzip"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+var (
+	flagHTTPBodies  = flag.String("http-bodies", "", "directory to store decoded HTTP request and response bodies and uploaded files, named by their SHA-256 hash")
+	flagHTTPMaxBody = flag.Int("http-max-body", 64*1024*1024, "maximum size in bytes of a decoded HTTP body or form part, larger bodies are truncated")
+)
+
+// httpBody replaces the body of a request or response after it has been read from the stream
+// and carries the decoded body data until the audit record is assembled
+type httpBody struct {
+	*bytes.Reader
+
+	data      []byte
+	truncated bool
+	hash      string
+	mime      string
+	parts     []*types.HTTPFormPart
+
+	// contents of the uploaded files for file extraction, in the same order as the parts
+	uploads [][]byte
+}
+
+// Close implements the io.ReadCloser interface
+func (b *httpBody) Close() error {
+	return nil
+}
+
+// captureHTTPBodies returns true if HTTP bodies should be added to the audit records
+// or are needed for file extraction
+func captureHTTPBodies() bool {
+	return payloadRuleFor("HTTP").capture() || *flagHTTPBodies != "" || fileEncoderInstance != nil
+}
+
+// newHTTPBody decodes the raw body according to the content encoding in the header
+// and parses multipart forms, the raw body is still available for reading
+func newHTTPBody(ident string, raw []byte, header http.Header) *httpBody {
+
+	data, truncated, err := decodeHTTPBody(raw, header.Get("Content-Encoding"))
+	if err != nil {
+		logError("HTTP-body-decode", "HTTP/%s: failed to decode body: %s\n", ident, err)
+	}
+
+	b := &httpBody{
+		Reader:    bytes.NewReader(raw),
+		data:      data,
+		truncated: truncated,
+		hash:      storeHTTPBody(ident, data),
+		mime:      http.DetectContentType(data),
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		b.parts, b.uploads = parseMultipartForm(ident, data, params["boundary"])
+	}
+
+	return b
+}
+
+// decodeHTTPBody removes the content encoding from the body
+// the chunked transfer encoding has already been removed by the net/http package
+// the decoded body is truncated to the maximum body size, to protect against compression bombs
+// if decoding fails, the raw body is returned along with the error
+func decodeHTTPBody(body []byte, encoding string) (data []byte, truncated bool, err error) {
+
+	var r io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// deflate is supposed to be zlib wrapped, but some servers send raw deflate data
+		r, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		if len(body) > *flagHTTPMaxBody {
+			return body[:*flagHTTPMaxBody], true, nil
+		}
+		return body, false, nil
+	}
+	if err != nil {
+		data, truncated, _ = readHTTPBody(bytes.NewReader(body))
+		return data, truncated, err
+	}
+	defer r.Close()
+
+	data, truncated, err = readHTTPBody(r)
+	if err != nil {
+		data, truncated, _ = readHTTPBody(bytes.NewReader(body))
+		return data, truncated, err
+	}
+	return data, truncated, nil
+}
+
+// readHTTPBody reads up to the maximum body size from r
+// and reports whether there was more data
+func readHTTPBody(r io.Reader) ([]byte, bool, error) {
+	max := *flagHTTPMaxBody
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if len(data) > max {
+		return data[:max], true, err
+	}
+	return data, false, err
+}
+
+// parseMultipartForm collects information about the parts of a multipart form
+// uploaded files are stored in the HTTP body directory and returned for file extraction
+func parseMultipartForm(ident string, data []byte, boundary string) (parts []*types.HTTPFormPart, uploads [][]byte) {
+
+	r := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			logError("HTTP-multipart", "HTTP/%s: failed to read multipart form: %s\n", ident, err)
+			break
+		}
+
+		content, truncated, err := readHTTPBody(p)
+		if err != nil {
+			logError("HTTP-multipart", "HTTP/%s: failed to read form part %s: %s\n", ident, p.FormName(), err)
+		}
+
+		part := &types.HTTPFormPart{
+			Name:        p.FormName(),
+			FileName:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Length:      int32(len(content)),
+			MIME:        http.DetectContentType(content),
+			Truncated:   truncated,
+		}
+		if part.FileName != "" {
+			part.Hash = storeHTTPBody(ident, content)
+			uploads = append(uploads, content)
+		} else {
+			part.Hash = hashHTTPBody(content)
+			uploads = append(uploads, nil)
+		}
+		parts = append(parts, part)
+		p.Close()
+	}
+	return
+}
+
+// hashHTTPBody returns the hex encoded SHA-256 of the data
+func hashHTTPBody(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// storeHTTPBody writes the data into the HTTP body directory, if configured
+// files are named by the hex encoded SHA-256 of their contents,
+// so identical bodies are only stored once
+func storeHTTPBody(ident string, data []byte) string {
+
+	hash := hashHTTPBody(data)
+	if *flagHTTPBodies == "" || len(data) == 0 {
+		return hash
+	}
+
+	if _, err := storeContent(*flagHTTPBodies, hash, data); err != nil {
+		logError("HTTP-body-store", "HTTP/%s: failed to store body: %s\n", ident, err)
+	}
+	return hash
+}
+
+// setHTTPRequestBody sets the request body information on the audit record
+func setHTTPRequestBody(h *types.HTTP, req *http.Request) {
+	if b, ok := req.Body.(*httpBody); ok {
+		h.ReqBody, _ = capturePayload("HTTP", b.data)
+		h.ReqBodySize = int32(len(b.data))
+		h.ReqBodyHash = b.hash
+		h.ReqBodyMIME = b.mime
+		h.ReqBodyTruncated = b.truncated
+		h.FormParts = b.parts
+	}
+}
+
+// setHTTPResponseBody sets the response body information on the audit record
+func setHTTPResponseBody(h *types.HTTP, res *http.Response) {
+	if b, ok := res.Body.(*httpBody); ok {
+		h.ResBody, _ = capturePayload("HTTP", b.data)
+		h.ResBodySize = int32(len(b.data))
+		h.ResBodyHash = b.hash
+		h.ResBodyMIME = b.mime
+		h.ResBodyTruncated = b.truncated
+	}
+}
+
+// extractHTTPFiles hands the request and response bodies of the stream to the file extraction
+// for multipart forms, the uploaded files are extracted instead of the request body
+// response bodies are only extracted for successful responses
+// either req or res can be nil
+func (t *tcpStream) extractHTTPFiles(req *http.Request, res *http.Response) {
+
+	if fileEncoderInstance == nil {
+		return
+	}
+
+	var (
+		host string
+		name string
+	)
+	if req != nil {
+		ts := req.Header.Get("netcap-ts")
+		host = req.Host
+		if base := path.Base(req.URL.Path); base != "/" && base != "." {
+			name = base
+		}
+
+		if b, ok := req.Body.(*httpBody); ok {
+			if len(b.parts) > 0 {
+				for i, p := range b.parts {
+					if b.uploads[i] != nil {
+						extractFile(t.newFile(ts, "HTTPUpload", host, p.FileName, p.ContentType, true), b.uploads[i])
+					}
+				}
+			} else {
+				extractFile(t.newFile(ts, "HTTPRequestBody", host, name, req.Header.Get("Content-Type"), true), b.data)
+			}
+		}
+	}
+	if res != nil && res.StatusCode == http.StatusOK {
+		ts := res.Header.Get("netcap-ts")
+		if b, ok := res.Body.(*httpBody); ok {
+			// prefer the filename from the Content-Disposition header
+			if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+				name = params["filename"]
+			}
+			extractFile(t.newFile(ts, "HTTPResponseBody", host, name, res.Header.Get("Content-Type"), false), b.data)
+		}
+	}
+}
+
+// newFile prepares a File audit record for data transferred on the stream
+// fromClient indicates the direction of the transfer
+func (t *tcpStream) newFile(ts, source, host, name, contentType string, fromClient bool) *types.File {
+	net, transport := t.clientFlows()
+	if !fromClient {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+	return &types.File{
+		Timestamp:   ts,
+		Name:        name,
+		ContentType: contentType,
+		Source:      source,
+		Protocol:    "HTTP",
+		Host:        host,
+		SrcIP:       net.Src().String(),
+		DstIP:       net.Dst().String(),
+		SrcPort:     int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+		DstPort:     int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+		ConnUID:     t.connUID,
+	}
+}
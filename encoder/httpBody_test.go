@@ -0,0 +1,56 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHTTPBodyLimit(t *testing.T) {
+
+	defer func(max int) {
+		*flagHTTPMaxBody = max
+	}(*flagHTTPMaxBody)
+	*flagHTTPMaxBody = 1024
+
+	// 1MB of zeros compresses to about 1KB
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(make([]byte, 1024*1024))
+	w.Close()
+
+	data, truncated, err := decodeHTTPBody(buf.Bytes(), "gzip")
+	if err != nil || !truncated || len(data) != 1024 {
+		t.Fatalf("expected truncated body of 1024 bytes, got %d, %v, %v", len(data), truncated, err)
+	}
+
+	data, truncated, err = decodeHTTPBody([]byte("hello"), "gzip")
+	if err == nil || truncated || string(data) != "hello" {
+		t.Fatalf("expected raw body and error for invalid data, got %q, %v, %v", data, truncated, err)
+	}
+
+	data, truncated, _ = decodeHTTPBody(make([]byte, 2048), "")
+	if !truncated || len(data) != 1024 {
+		t.Fatalf("expected truncated identity body, got %d, %v", len(data), truncated)
+	}
+
+	form := "--b\r\nContent-Disposition: form-data; name=\"f\"; filename=\"a.bin\"\r\n\r\n" + strings.Repeat("A", 2000) + "\r\n--b--\r\n"
+	parts, uploads := parseMultipartForm("test", []byte(form), "b")
+	if len(parts) != 1 || !parts[0].Truncated || parts[0].Length != 1024 || len(uploads[0]) != 1024 {
+		t.Fatalf("expected truncated form part, got %+v", parts)
+	}
+}
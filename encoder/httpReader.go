@@ -46,7 +46,6 @@ package encoder
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -57,8 +56,7 @@ import (
 	"path"
 	"sync"
 	"sync/atomic"
-
-	gzip "github.com/klauspost/pgzip"
+	"time"
 
 	"github.com/dreadl0ck/netcap/types"
 	"github.com/dreadl0ck/netcap/utils"
@@ -68,19 +66,51 @@ import (
  * HTTP part
  */
 
+// httpData is a chunk of reassembled stream data
+// along with the capture time of the packet that completed it
+type httpData struct {
+	raw       []byte
+	timestamp time.Time
+}
+
 type httpReader struct {
 	ident    string
 	isClient bool
-	bytes    chan []byte
+	bytes    chan *httpData
 	data     []byte
 	hexdump  bool
 	parent   *tcpStream
+
+	// timestamp of the chunk that is currently being read
+	current time.Time
+	// timestamp of the chunk that contains the start of the current message
+	msgStart time.Time
+
+	// number of chunks handed to and received by the reader, and whether the reader
+	// waits for the next chunk. guarded by the lock of the parent stream
+	sent     int
+	received int
+	idle     bool
 }
 
 func (h *httpReader) Read(p []byte) (int, error) {
 	ok := true
 	for ok && len(h.data) == 0 {
-		h.data, ok = <-h.bytes
+		var d *httpData
+		if h.isClient {
+			h.setIdle(true)
+		}
+		d, ok = <-h.bytes
+		if ok {
+			if h.isClient {
+				h.setIdle(false)
+			}
+			h.data = d.raw
+			h.current = d.timestamp
+			if h.msgStart.IsZero() {
+				h.msgStart = d.timestamp
+			}
+		}
 	}
 	if !ok || len(h.data) == 0 {
 		return 0, io.EOF
@@ -91,7 +121,60 @@ func (h *httpReader) Read(p []byte) (int, error) {
 	return l, nil
 }
 
-func (h *httpReader) cleanup(wg *sync.WaitGroup, s2c Stream, c2s Stream) {
+// setIdle records whether the client reader waits for the next chunk,
+// and wakes up the response reader waiting for a request
+func (h *httpReader) setIdle(idle bool) {
+	h.parent.Lock()
+	h.idle = idle
+	if !idle {
+		h.received++
+	}
+	h.parent.requestParsed.Broadcast()
+	h.parent.Unlock()
+}
+
+// waitRequest returns the request for the next response.
+// pipelined requests are answered in order, so the n-th response belongs to the n-th request.
+// the stream data is fed in capture order, so if the request has not been parsed yet,
+// the reader waits until the client reader parsed it or needs more data to complete it.
+// nil is returned if the request was not captured
+func (h *httpReader) waitRequest() *http.Request {
+	h.parent.Lock()
+	defer h.parent.Unlock()
+
+	c := &h.parent.client
+	for {
+		if n := len(h.parent.responses); n < len(h.parent.requests) {
+			return h.parent.requests[n]
+		}
+		if c.idle && c.received == c.sent {
+			return nil
+		}
+		h.parent.requestParsed.Wait()
+	}
+}
+
+// startMessage must be called before reading the next message from the buffered reader,
+// to determine the timestamp of the chunk that contains the start of the message
+func (h *httpReader) startMessage(b *bufio.Reader) {
+	if b.Buffered() > 0 {
+		// message starts in data that has been read already
+		h.msgStart = h.current
+	} else {
+		// message starts with the next chunk
+		h.msgStart = time.Time{}
+	}
+}
+
+// messageTime returns the timestamp of the last message that has been read
+func (h *httpReader) messageTime() time.Time {
+	if h.msgStart.IsZero() {
+		return h.parent.firstPacket
+	}
+	return h.msgStart
+}
+
+func (h *httpReader) cleanup(wg *sync.WaitGroup) {
 
 	// determine if one side of the stream has already been closed
 	h.parent.Lock()
@@ -115,61 +198,54 @@ func (h *httpReader) cleanup(wg *sync.WaitGroup, s2c Stream, c2s Stream) {
 	// to ensure all necessary requests and responses are present
 	if h.parent.last {
 
-		for _, res := range h.parent.responses {
+		// responses are paired with the requests in the order they have been sent,
+		// since HTTP/1.x requires a server to answer pipelined requests in order
+		for i, res := range h.parent.responses {
 
-			// populate types.HTTP with all infos from response
-			ht := newHTTPFromResponse(res)
+			var req *http.Request
+			if i < len(h.parent.requests) {
+				req = h.parent.requests[i]
+			}
+			res.Request = req
 
-			_ = h.findRequest(res, s2c)
+			// hand the bodies to the file extraction
+			h.parent.extractHTTPFiles(req, res)
+			h.parent.extractHTTPNTLM(req, res)
 
 			atomic.AddInt64(&httpEncoder.numResponses, 1)
 
-			// now add request information
-			if res.Request != nil {
-				atomic.AddInt64(&httpEncoder.numRequests, 1)
-				setRequest(ht, res.Request)
-			} else {
+			if req == nil {
 				// response without matching request
 				// dont add to output for now
 				atomic.AddInt64(&httpEncoder.numUnmatchedResp, 1)
 				continue
 			}
 
-			// export metrics if configured
-			if httpEncoder.export {
-				ht.Inc()
-			}
+			// populate types.HTTP with all infos from response and request
+			ht := newHTTPFromResponse(res)
+			setRequest(ht, req)
+			setServerLatency(ht)
 
-			// write record to disk
-			atomic.AddInt64(&httpEncoder.numRecords, 1)
-			err := httpEncoder.writer.Write(ht)
-			if err != nil {
-				errorMap.Inc(err.Error())
-			}
+			atomic.AddInt64(&httpEncoder.numRequests, 1)
+			atomic.AddInt64(&httpEncoder.numFoundRequests, 1)
+
+			writeHTTP(ht)
 		}
 
-		for _, req := range h.parent.requests {
-			if req != nil {
-				h := &types.HTTP{}
-				setRequest(h, req)
-
-				atomic.AddInt64(&httpEncoder.numRequests, 1)
-				atomic.AddInt64(&httpEncoder.numUnansweredRequests, 1)
-
-				// export metrics if configured
-				if httpEncoder.export {
-					h.Inc()
-				}
-
-				// write record to disk
-				atomic.AddInt64(&httpEncoder.numRecords, 1)
-				err := httpEncoder.writer.Write(h)
-				if err != nil {
-					errorMap.Inc(err.Error())
-				}
-			} else {
-				atomic.AddInt64(&httpEncoder.numNilRequests, 1)
-			}
+		// write the requests that have not been answered
+		for i := len(h.parent.responses); i < len(h.parent.requests); i++ {
+
+			req := h.parent.requests[i]
+			h.parent.extractHTTPFiles(req, nil)
+			h.parent.extractHTTPNTLM(req, nil)
+
+			ht := &types.HTTP{}
+			setRequest(ht, req)
+
+			atomic.AddInt64(&httpEncoder.numRequests, 1)
+			atomic.AddInt64(&httpEncoder.numUnansweredRequests, 1)
+
+			writeHTTP(ht)
 		}
 	}
 
@@ -189,13 +265,15 @@ func (h *httpReader) run(wg *sync.WaitGroup) {
 	)
 
 	// defer a cleanup func to flush the requests and responses once the stream encounters an EOF
-	defer h.cleanup(wg, s2c, c2s)
+	defer h.cleanup(wg)
 
 	var (
 		err error
 		b   = bufio.NewReader(h)
 	)
 	for {
+		h.startMessage(b)
+
 		// handle parsing HTTP requests
 		if h.isClient {
 			err = h.readRequest(b, c2s)
@@ -217,8 +295,16 @@ func (h *httpReader) run(wg *sync.WaitGroup) {
 
 func (h *httpReader) readResponse(b *bufio.Reader, s2c Stream) error {
 
+	// wait for the start of the response, the client data captured before it has been fed by then
+	if _, err := b.Peek(1); err != nil {
+		return err
+	}
+
+	// the request is needed to parse the response correctly, e.g. responses to HEAD requests have no body
+	req := h.waitRequest()
+
 	// try to read HTTP response from the buffered reader
-	res, err := http.ReadResponse(b, nil)
+	res, err := http.ReadResponse(b, req)
 	if err == io.EOF || err == io.ErrUnexpectedEOF {
 		return err
 	} else if err != nil {
@@ -237,6 +323,24 @@ func (h *httpReader) readResponse(b *bufio.Reader, s2c Stream) error {
 	}
 	res.Body.Close()
 
+	// informational responses precede the final response for a request
+	if res.StatusCode >= 100 && res.StatusCode < 200 && res.StatusCode != http.StatusSwitchingProtocols {
+		logInfo("HTTP/%s Informational response: %s\n", h.ident, res.Status)
+		atomic.AddInt64(&httpEncoder.numInformational, 1)
+		return nil
+	}
+
+	res.Header.Set("netcap-ts", utils.TimeToString(h.messageTime()))
+
+	// keep the body for the audit record
+	if captureHTTPBodies() && len(body) > 0 {
+		res.Body = newHTTPBody(h.ident, body, res.Header)
+	}
+
+	if yaraRules != nil && len(body) > 0 {
+		writeYARAMatches(yaraRules.Scan(body), utils.TimeToString(h.messageTime()), "HTTPResponseBody", "HTTP", s2c.a, s2c.b, h.parent.connUID, len(body))
+	}
+
 	sym := ","
 	if res.ContentLength > 0 && res.ContentLength != int64(s) {
 		sym = "!="
@@ -268,31 +372,6 @@ func (h *httpReader) readResponse(b *bufio.Reader, s2c Stream) error {
 	return nil
 }
 
-func (h *httpReader) findRequest(res *http.Response, s2c Stream) string {
-
-	// try to find the matching HTTP request for the response
-	var (
-		req    *http.Request
-		reqURL string
-	)
-
-	h.parent.Lock()
-	if len(h.parent.requests) != 0 {
-		// take the request from the parent stream and delete it from there
-		req, h.parent.requests = h.parent.requests[0], h.parent.requests[1:]
-		reqURL = req.URL.String()
-	}
-	h.parent.Unlock()
-
-	// set request instance on response
-	if req != nil {
-		res.Request = req
-		atomic.AddInt64(&httpEncoder.numFoundRequests, 1)
-	}
-
-	return reqURL
-}
-
 func (h *httpReader) saveResponse(err error, body []byte, encoding []string, reqURL string) error {
 	var (
 		ctype = http.DetectContentType(body)
@@ -331,29 +410,24 @@ func (h *httpReader) saveResponse(err error, body []byte, encoding []string, req
 		return err
 	}
 
-	// explicitely declare io.Reader interface
-	var r io.Reader
-
-	// now assign a new buffer
-	r = bytes.NewBuffer(body)
-	if len(encoding) > 0 && (encoding[0] == "gzip" || encoding[0] == "deflate") {
-		r, err = gzip.NewReader(r)
+	if len(encoding) > 0 {
+		var truncated bool
+		body, truncated, err = decodeHTTPBody(body, encoding[0])
 		if err != nil {
-			logError("HTTP-gunzip", "Failed to gzip decode: %s", err)
+			logError("HTTP-gunzip", "Failed to decode %s: %s", encoding[0], err)
+		} else if truncated {
+			logInfo("%s: decoded body exceeds %d bytes and has been truncated\n", h.ident, *flagHTTPMaxBody)
 		}
 	}
 	if err == nil {
-		w, err := io.Copy(f, r)
-		if _, ok := r.(*gzip.Reader); ok {
-			r.(*gzip.Reader).Close()
-		}
-		f.Close()
+		w, err := f.Write(body)
 		if err != nil {
 			logError("HTTP-save", "%s: failed to save %s (l:%d): %s\n", h.ident, target, w, err)
 		} else {
 			logInfo("%s: Saved %s (l:%d)\n", h.ident, target, w)
 		}
 	}
+	f.Close()
 
 	return nil
 }
@@ -379,12 +453,22 @@ func (h *httpReader) readRequest(b *bufio.Reader, c2s Stream) error {
 	}
 	req.Body.Close()
 
+	// keep the body for the audit record
+	if captureHTTPBodies() && len(body) > 0 {
+		req.Body = newHTTPBody(h.ident, body, req.Header)
+	}
+
+	if yaraRules != nil && len(body) > 0 {
+		writeYARAMatches(yaraRules.Scan(body), utils.TimeToString(h.messageTime()), "HTTPRequestBody", "HTTP", c2s.a, c2s.b, h.parent.connUID, len(body))
+	}
+
 	logInfo("HTTP/%s Request: %s %s (body:%d)\n", h.ident, req.Method, req.URL, s)
 
 	// set some infos for netcap on the HTTP request header
-	req.Header.Set("netcap-ts", utils.TimeToString(h.parent.firstPacket))
+	req.Header.Set("netcap-ts", utils.TimeToString(h.messageTime()))
 	req.Header.Set("netcap-clientip", h.parent.net.Src().String())
 	req.Header.Set("netcap-serverip", h.parent.net.Dst().String())
+	req.Header.Set("netcap-communityid", tcpCommunityID(h.parent.net, h.parent.transport))
 
 	// increase counter
 	mu.Lock()
@@ -393,6 +477,7 @@ func (h *httpReader) readRequest(b *bufio.Reader, c2s Stream) error {
 
 	h.parent.Lock()
 	h.parent.requests = append(h.parent.requests, req)
+	h.parent.requestParsed.Broadcast()
 	h.parent.Unlock()
 
 	return nil
@@ -0,0 +1,176 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// newTestHTTPStream creates an HTTP stream and starts its readers, like the stream factory
+func newTestHTTPStream(wg *sync.WaitGroup) *tcpStream {
+	s := &tcpStream{
+		net:         gopacket.NewFlow(layers.EndpointIPv4, net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}),
+		transport:   gopacket.NewFlow(layers.EndpointTCPPort, []byte{0x9c, 0x40}, []byte{0, 80}),
+		isHTTP:      true,
+		firstPacket: time.Unix(1500000000, 0),
+	}
+	s.requestParsed = sync.NewCond(&s.Mutex)
+	s.client = httpReader{bytes: make(chan *httpData), parent: s, isClient: true}
+	s.server = httpReader{bytes: make(chan *httpData), parent: s}
+
+	wg.Add(2)
+	go s.client.run(wg)
+	go s.server.run(wg)
+	return s
+}
+
+// feed hands a chunk of reassembled data to the reader for the direction, like ReassembledSG
+func (t *tcpStream) feed(client bool, data string) {
+	d := &httpData{raw: []byte(data), timestamp: t.firstPacket}
+	if client {
+		t.Lock()
+		t.client.sent++
+		t.Unlock()
+		t.client.bytes <- d
+	} else {
+		t.server.bytes <- d
+	}
+}
+
+func TestHTTPPipelining(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "netcap-http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(w *netcap.Writer) {
+		httpEncoder.writer = w
+	}(httpEncoder.writer)
+	httpEncoder.writer = netcap.NewWriter("HTTP", false, false, true, dir, false, 0)
+
+	var (
+		wg sync.WaitGroup
+		s  = newTestHTTPStream(&wg)
+	)
+
+	// three pipelined requests, the last one is not answered.
+	// the response to HEAD announces a body that is not sent,
+	// it can only be parsed after it has been paired with its request
+	s.feed(true, "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\nHEAD /b HTTP/1.1\r\nHost: example.com\r\n\r\nGET /c HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	s.feed(false, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhelloHTTP/1.1 200 OK\r\nContent-Length: 1000\r\n\r\n")
+	close(s.client.bytes)
+	close(s.server.bytes)
+	wg.Wait()
+
+	if len(s.requests) != 3 || len(s.responses) != 2 {
+		t.Fatalf("expected 3 requests and 2 responses, got %d and %d", len(s.requests), len(s.responses))
+	}
+	for i, method := range []string{"GET", "HEAD"} {
+		if req := s.responses[i].Request; req == nil || req.Method != method || req != s.requests[i] {
+			t.Fatalf("response %d not paired with the %s request", i, method)
+		}
+	}
+
+	name, _ := httpEncoder.writer.Close()
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		header = types.HTTP{}.CSVHeader()
+		index  = make(map[string]int)
+		got    []string
+	)
+	for i, f := range header {
+		index[f] = i
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		cols := strings.Split(line, ",")
+		got = append(got, cols[index["Method"]]+" "+cols[index["URL"]]+" "+cols[index["StatusCode"]])
+	}
+	if strings.Join(got, "|") != "GET /a 200|HEAD /b 200|GET /c 0" {
+		t.Fatal("unexpected records", got)
+	}
+}
+
+func TestWaitRequest(t *testing.T) {
+
+	s := &tcpStream{}
+	s.requestParsed = sync.NewCond(&s.Mutex)
+	s.server.parent = s
+
+	// the client waits for data and has parsed everything, the request was not captured
+	s.client.idle = true
+	if req := s.server.waitRequest(); req != nil {
+		t.Fatal("expected no request, got", req)
+	}
+
+	// the next unanswered request is returned
+	var (
+		first  = &http.Request{Method: "GET"}
+		second = &http.Request{Method: "POST"}
+	)
+	s.requests = []*http.Request{first, second}
+	s.responses = []*http.Response{{Request: first}}
+	if req := s.server.waitRequest(); req != second {
+		t.Fatal("expected the second request, got", req)
+	}
+
+	// all requests are answered, but the client has data left to parse
+	s.responses = append(s.responses, &http.Response{Request: second})
+	s.client.idle = false
+	s.client.sent = 1
+
+	res := make(chan *http.Request)
+	go func() {
+		res <- s.server.waitRequest()
+	}()
+	select {
+	case req := <-res:
+		t.Fatal("returned before the request was parsed", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	third := &http.Request{Method: "PUT"}
+	s.Lock()
+	s.client.received = 1
+	s.requests = append(s.requests, third)
+	s.requestParsed.Broadcast()
+	s.Unlock()
+
+	select {
+	case req := <-res:
+		if req != third {
+			t.Fatal("expected the third request, got", req)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitRequest did not return after the request was parsed")
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/iec104"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+// set in postinit, nil if the IEC104 encoder is not active
+var iec104EncoderInstance *CustomEncoder
+
+var iec104Encoder = CreateCustomEncoder(types.Type_NC_IEC104, "IEC104", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for IEC 60870-5-104 and ensure TCP stream reassembly is enabled
+
+	iec104EncoderInstance = e
+	streamDecoders[iec104.Port] = newIEC104Decoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// IEC 60870-5-104 is decoded after stream reassembly
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// iec104Decoder splits a TCP stream into APDUs using their length field
+type iec104Decoder struct {
+	parent *tcpStream
+
+	// incomplete APDUs
+	client []byte
+	server []byte
+
+	// set if the stream does not contain APDUs
+	invalid bool
+}
+
+func newIEC104Decoder(t *tcpStream) streamDecoder {
+	return &iec104Decoder{parent: t}
+}
+
+func (d *iec104Decoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.invalid {
+		return
+	}
+
+	buf := &d.server
+	if client {
+		buf = &d.client
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		length, ok, err := iec104.FrameLength(*buf)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			d.invalid = true
+			d.client, d.server = nil, nil
+			return
+		}
+		if !ok || len(*buf) < length {
+			break
+		}
+		d.write(client, (*buf)[:length], ts)
+		*buf = (*buf)[length:]
+	}
+
+	// release the memory once all APDUs have been processed
+	if len(*buf) == 0 {
+		*buf = nil
+	}
+}
+
+func (d *iec104Decoder) close() {}
+
+func (d *iec104Decoder) write(client bool, data []byte, ts time.Time) {
+
+	if iec104EncoderInstance == nil {
+		return
+	}
+
+	apdu, err := iec104.Parse(data)
+	if err != nil {
+		// APDUs with a truncated ASDU are written anyway
+		errorMap.Inc(err.Error())
+		if apdu == nil {
+			return
+		}
+	}
+
+	net, transport := d.parent.clientFlows()
+	if !client {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+
+	i := &types.IEC104{
+		Timestamp: utils.TimeToString(ts),
+		Format:    apdu.Format,
+		SendSeq:   int32(apdu.SendSeq),
+		RecvSeq:   int32(apdu.RecvSeq),
+		SrcIP:     net.Src().String(),
+		DstIP:     net.Dst().String(),
+		SrcPort:   int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+		DstPort:   int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+		ConnUID:   d.parent.connUID,
+	}
+	if apdu.Format == iec104.FormatU {
+		i.UFunction = iec104.UFunctionName(apdu.UFunction)
+	}
+	if s := apdu.ASDU; s != nil {
+		i.TypeID = int32(s.TypeID)
+		i.TypeName = iec104.TypeName(s.TypeID)
+		i.Sequence = s.Sequence
+		i.NumObjects = int32(s.NumObjects)
+		i.Cause = int32(s.Cause)
+		i.CauseName = iec104.CauseName(s.Cause)
+		i.Negative = s.Negative
+		i.Test = s.Test
+		i.Originator = int32(s.Originator)
+		i.CommonAddress = int32(s.CommonAddress)
+		i.IOAs = s.IOAs
+		i.Command = iec104.IsCommand(s.TypeID)
+	}
+
+	// export metrics if configured
+	if iec104EncoderInstance.export {
+		i.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&iec104EncoderInstance.numRecords, 1)
+	err = iec104EncoderInstance.writer.Write(i)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(i)
+}
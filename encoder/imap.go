@@ -0,0 +1,201 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dreadl0ck/netcap/mail"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// imapDecoder decodes the commands and responses of an IMAP session,
+// the messages retrieved with FETCH and the messages uploaded with APPEND
+type imapDecoder struct {
+	mailSession
+
+	// commands waiting for a response, by tag
+	tagged map[string]*types.MailCommand
+
+	// tag of the AUTHENTICATE command waiting for continuation requests
+	authTag string
+
+	// set after the greeting of the server
+	greeted bool
+
+	client imapLiteral
+	server imapLiteral
+}
+
+// imapLiteral is the state of the literals sent in one direction
+type imapLiteral struct {
+	// remaining bytes of the current literal
+	remaining int
+
+	// set if the next line continues the line with the literal
+	continued bool
+
+	// set if the literal contains a message
+	message   bool
+	data      []byte
+	timestamp time.Time
+}
+
+func newIMAPDecoder(t *tcpStream) streamDecoder {
+	return &imapDecoder{
+		mailSession: newMailSession(t, "IMAP"),
+		tagged:      make(map[string]*types.MailCommand),
+	}
+}
+
+func (d *imapDecoder) decode(client bool, data []byte, ts time.Time) {
+	d.feed(client, data, ts, d.next)
+}
+
+func (d *imapDecoder) close() {
+	d.writeSession()
+}
+
+func (d *imapDecoder) next(client bool, buf []byte, ts time.Time) int {
+
+	lit := &d.server
+	if client {
+		lit = &d.client
+	}
+
+	if lit.remaining > 0 {
+		n := lit.remaining
+		if n > len(buf) {
+			n = len(buf)
+		}
+		if lit.message && d.collectMessages() {
+			lit.data = appendMessage(lit.data, buf[:n])
+		}
+		lit.remaining -= n
+		if lit.remaining == 0 && lit.message {
+			lit.message = false
+			if client {
+				d.writeMail(lit.data, "APPEND", true, lit.timestamp, "", nil)
+			} else {
+				d.writeMail(lit.data, "FETCH", false, lit.timestamp, "", nil)
+			}
+			lit.data = nil
+		}
+		return n
+	}
+
+	l := mailLine(buf)
+	if l == nil {
+		return 0
+	}
+
+	switch {
+	case lit.continued:
+		// the line continues a command or response after a literal
+		lit.continued = false
+	case client:
+		d.clientLine(l, ts)
+	default:
+		d.serverLine(l)
+	}
+
+	// a literal follows the line
+	if n, ok := mail.Literal(string(l)); ok {
+		lit.remaining = n
+		lit.continued = true
+		if client {
+			_, verb, _ := mail.ParseIMAPLine(string(l))
+			lit.message = verb == "APPEND"
+		} else {
+			lit.message = mail.IsMessageLiteral(string(l))
+		}
+		if lit.message {
+			lit.data = []byte{}
+			lit.timestamp = ts
+		}
+	}
+	return len(l)
+}
+
+func (d *imapDecoder) clientLine(l []byte, ts time.Time) {
+
+	if d.continuation {
+		d.continuation = false
+		d.authResponse(string(l))
+		return
+	}
+
+	tag, verb, arg := mail.ParseIMAPLine(string(l))
+	switch verb {
+	case "UID":
+		verb += " " + strings.ToUpper(mail.Atom(arg))
+	case "LOGIN":
+		// the password is omitted from the argument
+		arg = mail.Atom(arg)
+		d.session.AuthUser = csvField(arg)
+	case "AUTHENTICATE":
+		mechanism, response := splitAuth(arg)
+		arg = mechanism
+		d.auth = mechanism
+		d.authTag = tag
+		d.session.AuthMechanism = mechanism
+		if response != "" && response != "=" {
+			d.authResponse(response)
+		}
+	}
+
+	c := d.command(ts, verb, arg)
+	if len(d.tagged) < maxMailCommands {
+		d.tagged[tag] = c
+	}
+}
+
+func (d *imapDecoder) serverLine(l []byte) {
+
+	tag, status, text := mail.ParseIMAPLine(string(l))
+	switch tag {
+	case "*":
+		if !d.greeted {
+			d.greeted = true
+			d.session.Banner = csvField(strings.TrimSpace(status + " " + text))
+		}
+		if status == "CAPABILITY" || strings.Contains(strings.ToUpper(text), "[CAPABILITY ") {
+			if m := mail.Mechanisms(strings.NewReplacer("[", " ", "]", " ").Replace(text)); len(m) > 0 {
+				d.session.AuthMechanisms = m
+			}
+		}
+	case "+":
+		// continuation request for an authentication response or a literal
+		if d.authTag != "" {
+			d.continuation = true
+		}
+	default:
+		c, ok := d.tagged[tag]
+		if !ok {
+			return
+		}
+		delete(d.tagged, tag)
+		c.Status = status
+
+		if tag == d.authTag {
+			d.authTag = ""
+			d.auth = ""
+			d.continuation = false
+		}
+		if c.Command == "STARTTLS" && status == "OK" {
+			d.session.StartTLS = true
+			d.done = true
+		}
+	}
+}
@@ -26,7 +26,10 @@ var ipv4Encoder = CreateLayerEncoder(
 	func(layer gopacket.Layer, timestamp string) proto.Message {
 		if ip4, ok := layer.(*layers.IPv4); ok {
 
-			var opts []*types.IPv4Option
+			var (
+				opts          []*types.IPv4Option
+				payload, hash = capturePayload("IPv4", ip4.Payload)
+			)
 			for _, o := range ip4.Options {
 				opts = append(opts, &types.IPv4Option{
 					OptionData:   o.OptionData,
@@ -52,6 +55,8 @@ var ipv4Encoder = CreateLayerEncoder(
 				Options:        opts,
 				PayloadEntropy: Entropy(ip4.Payload),
 				PayloadSize:    int32(len(ip4.Payload)),
+				Payload:        payload,
+				PayloadHash:    hash,
 			}
 		}
 		return nil
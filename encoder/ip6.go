@@ -25,6 +25,7 @@ var ipv6Encoder = CreateLayerEncoder(
 	layers.LayerTypeIPv6,
 	func(layer gopacket.Layer, timestamp string) proto.Message {
 		if ip6, ok := layer.(*layers.IPv6); ok {
+			payload, hash := capturePayload("IPv6", ip6.Payload)
 			return &types.IPv6{
 				Timestamp:      timestamp,
 				Version:        int32(ip6.Version),
@@ -37,6 +38,8 @@ var ipv6Encoder = CreateLayerEncoder(
 				DstIP:          ip6.DstIP.String(),
 				PayloadSize:    int32(len(ip6.Payload)),
 				PayloadEntropy: Entropy(ip6.Payload),
+				Payload:        payload,
+				PayloadHash:    hash,
 			}
 		}
 		return nil
@@ -0,0 +1,192 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/kerberos"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+// set in postinit, nil if the Kerberos encoder is not active
+var kerberosEncoderInstance *CustomEncoder
+
+var kerberosEncoder = CreateCustomEncoder(types.Type_NC_Kerberos, "Kerberos", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for Kerberos over TCP and ensure TCP stream reassembly is enabled
+
+	kerberosEncoderInstance = e
+	streamDecoders[88] = newKerberosDecoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+
+	// Kerberos over TCP is decoded after stream reassembly
+	l := p.Layer(layers.LayerTypeUDP)
+	if l == nil {
+		return nil
+	}
+	udp := l.(*layers.UDP)
+	if udp.SrcPort != 88 && udp.DstPort != 88 {
+		return nil
+	}
+
+	msg, err := kerberos.Parse(udp.Payload)
+	if err != nil {
+		errorMap.Inc(err.Error())
+		return nil
+	}
+
+	return newKerberos(msg, p.Metadata().Timestamp, "UDP", p.NetworkLayer().NetworkFlow(), udp.TransportFlow(), calcMd5(newConnectionID(p).String()))
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// newKerberos creates the audit record for a message sent from the source to the destination of the flows
+func newKerberos(msg *kerberos.Message, ts time.Time, transport string, net, tr gopacket.Flow, connUID string) *types.Kerberos {
+
+	k := &types.Kerberos{
+		Timestamp:       utils.TimeToString(ts),
+		MsgType:         kerberos.MsgTypeName(msg.Type),
+		Transport:       transport,
+		ClientRealm:     msg.ClientRealm,
+		ClientName:      msg.ClientName.String(),
+		Realm:           msg.Realm,
+		ServiceName:     msg.ServiceName.String(),
+		KDCOptions:      msg.KDCOptions,
+		EncryptionTypes: msg.Etypes,
+		PADataTypes:     msg.PADataTypes,
+		PreAuthEtype:    msg.PreAuthEtype,
+		TicketEtype:     msg.TicketEtype,
+		EncPartEtype:    msg.EncPartEtype,
+		ErrorText:       msg.ErrorText,
+		SrcIP:           net.Src().String(),
+		DstIP:           net.Dst().String(),
+		SrcPort:         int32(binary.BigEndian.Uint16(tr.Src().Raw())),
+		DstPort:         int32(binary.BigEndian.Uint16(tr.Dst().Raw())),
+		ConnUID:         connUID,
+	}
+	if msg.Type == kerberos.MsgError {
+		k.ErrorCode = msg.ErrorCode
+		k.ErrorName = kerberos.ErrorName(msg.ErrorCode)
+	}
+
+	// a client that only offers weak encryption types might be trying to obtain a crackable ticket
+	onlyWeak := len(msg.Etypes) > 0
+	for _, e := range msg.Etypes {
+		if !kerberos.WeakEtype(e) {
+			onlyWeak = false
+			break
+		}
+	}
+	k.WeakEtype = onlyWeak || kerberos.WeakEtype(msg.PreAuthEtype) || kerberos.WeakEtype(msg.TicketEtype) || kerberos.WeakEtype(msg.EncPartEtype)
+
+	return k
+}
+
+// kerberosDecoder splits a TCP stream into messages using their length prefix
+type kerberosDecoder struct {
+	parent *tcpStream
+
+	// incomplete messages
+	client []byte
+	server []byte
+
+	// set if the stream contains invalid length values
+	invalid bool
+}
+
+func newKerberosDecoder(t *tcpStream) streamDecoder {
+	return &kerberosDecoder{parent: t}
+}
+
+func (d *kerberosDecoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.invalid {
+		return
+	}
+
+	buf := &d.server
+	if client {
+		buf = &d.client
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		length, ok := kerberos.FrameLength(*buf)
+		if !ok {
+			break
+		}
+		if length > kerberos.MaxMessageSize {
+			errorMap.Inc("kerberos: invalid message length")
+			d.invalid = true
+			d.client, d.server = nil, nil
+			return
+		}
+		if len(*buf) < 4+length {
+			break
+		}
+		d.write(client, (*buf)[4:4+length], ts)
+		*buf = (*buf)[4+length:]
+	}
+
+	// release the memory once all messages have been processed
+	if len(*buf) == 0 {
+		*buf = nil
+	}
+}
+
+func (d *kerberosDecoder) close() {}
+
+func (d *kerberosDecoder) write(client bool, data []byte, ts time.Time) {
+
+	if kerberosEncoderInstance == nil {
+		return
+	}
+
+	msg, err := kerberos.Parse(data)
+	if err != nil {
+		errorMap.Inc(err.Error())
+		return
+	}
+
+	net, transport := d.parent.clientFlows()
+	if !client {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+	k := newKerberos(msg, ts, "TCP", net, transport, d.parent.connUID)
+
+	// export metrics if configured
+	if kerberosEncoderInstance.export {
+		k.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&kerberosEncoderInstance.numRecords, 1)
+	err = kerberosEncoderInstance.writer.Write(k)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(k)
+}
@@ -20,7 +20,9 @@ import (
 	"strings"
 
 	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
 	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/modbus"
 	"github.com/dreadl0ck/netcap/types"
 	"github.com/dreadl0ck/netcap/utils"
 	"github.com/golang/protobuf/proto"
@@ -107,6 +109,9 @@ type (
 		Handler LayerEncoderHandler
 		writer  *netcap.Writer
 		export  bool
+
+		// set if the layer is decoded after TCP stream reassembly
+		streamDecoded bool
 	}
 )
 
@@ -126,6 +131,7 @@ func InitLayerEncoders(c Config) {
 	)
 
 	AddContext = c.AddContext
+	setPayloadPolicy(c.PayloadPolicy)
 
 	// if there are includes and the first item is not an empty string
 	if len(in) > 0 && in[0] != "" {
@@ -192,7 +198,7 @@ func InitLayerEncoders(c Config) {
 		}
 		e.writer = netcap.NewWriter(filename, c.Buffer, c.Compression, c.CSV, c.Out, c.WriteChan, c.MemBufferSize)
 
-		err := e.writer.WriteHeader(e.Type, c.Source, c.Version, c.IncludePayloads)
+		err := e.writer.WriteHeader(e.Type, c.Source, c.Version, ContainsPayloads(e.Layer.String()))
 		if err != nil {
 			log.Fatal("failed to write header for audit record: ", e.Type.String())
 		}
@@ -203,6 +209,17 @@ func InitLayerEncoders(c Config) {
 		// add to layer encoders map
 		LayerEncoders[e.Layer] = append(LayerEncoders[e.Layer], e)
 	}
+
+	// DNS over TCP requires stream reassembly
+	if _, ok := LayerEncoders[layers.LayerTypeDNS]; ok {
+		HTTPActive = true
+	}
+
+	// Modbus TCP is decoded after stream reassembly, to pair requests and responses
+	if _, ok := LayerEncoders[layers.LayerTypeModbus]; ok {
+		streamDecoders[modbus.Port] = newModbusDecoder
+		HTTPActive = true
+	}
 	fmt.Println("initialized", len(LayerEncoders), "layer encoders")
 }
 
@@ -212,6 +229,10 @@ func CreateLayerEncoder(nt types.Type, lt gopacket.LayerType, handler LayerEncod
 		Layer:   lt,
 		Handler: handler,
 		Type:    nt,
+
+		// DNS messages over TCP are prefixed with their length,
+		// Modbus TCP requests and responses are paired per connection
+		streamDecoded: lt == layers.LayerTypeDNS || lt == layers.LayerTypeModbus,
 	}
 }
 
@@ -220,6 +241,11 @@ func CreateLayerEncoder(nt types.Type, lt gopacket.LayerType, handler LayerEncod
 // and writes the serialized protobuf into the data pipe
 func (e *LayerEncoder) Encode(ctx *types.PacketContext, p gopacket.Packet, l gopacket.Layer) error {
 
+	// layers over TCP that are decoded after stream reassembly instead
+	if e.streamDecoded && p.Layer(layers.LayerTypeTCP) != nil {
+		return nil
+	}
+
 	record := e.Handler(l, utils.TimeToString(p.Metadata().Timestamp))
 	if record != nil {
 
@@ -233,31 +259,42 @@ func (e *LayerEncoder) Encode(ctx *types.PacketContext, p gopacket.Packet, l gop
 			}
 		}
 
-		if e.writer.IsCSV() {
-			_, err := e.writer.WriteCSV(record)
-			if err != nil {
-				return err
-			}
-		} else {
-			// write record
-			err := e.writer.WriteProto(record)
-			if err != nil {
-				return err
-			}
+		return e.write(record)
+	}
+	return nil
+}
+
+// write serializes the record and exports its metrics
+func (e *LayerEncoder) write(record proto.Message) error {
+
+	if e.writer.IsCSV() {
+		_, err := e.writer.WriteCSV(record)
+		if err != nil {
+			return err
+		}
+	} else {
+		// write record
+		err := e.writer.WriteProto(record)
+		if err != nil {
+			return err
 		}
+	}
 
-		// export metrics if configured
-		if e.export {
-			// assert to audit record
-			if p, ok := record.(types.AuditRecord); ok {
-				// export metrics
-				p.Inc()
-			} else {
-				fmt.Printf("type: %#v\n", record)
-				log.Fatal("type does not implement the types.AuditRecord interface")
-			}
+	// export metrics if configured
+	if e.export {
+		// assert to audit record
+		if p, ok := record.(types.AuditRecord); ok {
+			// export metrics
+			p.Inc()
+		} else {
+			fmt.Printf("type: %#v\n", record)
+			log.Fatal("type does not implement the types.AuditRecord interface")
 		}
 	}
+
+	// run detection rules if configured
+	evaluateRules(record)
+
 	return nil
 }
 
@@ -268,5 +305,9 @@ func (e *LayerEncoder) GetChan() <-chan []byte {
 
 // Destroy closes and flushes all writers
 func (e *LayerEncoder) Destroy() (name string, size int64) {
+	if e.streamDecoded {
+		// decode the remaining TCP streams before the writer is closed
+		flushStreams()
+	}
 	return e.writer.Close()
 }
@@ -17,37 +17,37 @@ import (
 	"fmt"
 	"log"
 	"sort"
-	"sync"
 	"sync/atomic"
 
+	"github.com/dreadl0ck/gopacket"
 	"github.com/dreadl0ck/netcap/types"
 	"github.com/dreadl0ck/netcap/utils"
 	"github.com/golang/protobuf/proto"
-	"github.com/dreadl0ck/gopacket"
 )
 
-type AtomicLinkFlowMap struct {
-	Items map[uint64]*types.LinkFlow
-	sync.Mutex
-}
-
 var (
-	LinkFlows = &AtomicLinkFlowMap{
-		Items: make(map[uint64]*types.LinkFlow),
-	}
+	// LinkFlows holds all active link layer flows
+	LinkFlows               = NewFlowTable("LinkFlow", 0, 0, 0)
 	linkFlowEncoderInstance *CustomEncoder
-	linkFlows               int64
 )
 
 var linkFlowEncoder = CreateCustomEncoder(types.Type_NC_LinkFlow, "LinkFlow", func(d *CustomEncoder) error {
 	linkFlowEncoderInstance = d
+	LinkFlows = newFlowTable("LinkFlow")
 	return nil
 }, func(p gopacket.Packet) proto.Message {
 	if ll := p.LinkLayer(); ll != nil {
 
+		var (
+			id      = ll.LinkFlow().FastHash()
+			ts      = p.Metadata().Timestamp
+			expired []interface{}
+		)
+
 		// lookup flow
 		LinkFlows.Lock()
-		if flow, ok := LinkFlows.Items[ll.LinkFlow().FastHash()]; ok {
+		if f, ok := LinkFlows.Get(id, ts); ok {
+			flow := f.(*types.LinkFlow)
 
 			// flow exists. update fields
 			calcDuration := false
@@ -98,37 +98,26 @@ var linkFlowEncoder = CreateCustomEncoder(types.Type_NC_LinkFlow, "LinkFlow", fu
 			lf.Proto = ll.LayerType().String()
 			lf.NumPackets = 1
 			lf.TotalSize = int64(len(p.Data()))
-			LinkFlows.Items[ll.LinkFlow().FastHash()] = lf
-
-			// continuously flush flows
-			linkFlows++
-			if linkFlows%flowFlushInterval == 0 {
-
-				var selectFlows []*types.LinkFlow
-				for id, f := range LinkFlows.Items {
-					// flush entries whose last timestamp is flowTimeOut older than current packet
-					if p.Metadata().Timestamp.Sub(utils.StringToTime(f.TimestampLast)) > flowTimeOut {
-						selectFlows = append(selectFlows, f)
-						// cleanup
-						delete(LinkFlows.Items, id)
-					}
-				}
-
-				// do this in background
-				go func() {
-					for _, f := range selectFlows {
-						writeLinkFlow(f)
-					}
-				}()
+			if evicted := LinkFlows.Put(id, lf, ts); evicted != nil {
+				expired = append(expired, evicted)
 			}
 		}
+		expired = append(expired, LinkFlows.Expire(ts)...)
 		LinkFlows.Unlock()
+
+		// write timed out and evicted flows
+		for _, f := range expired {
+			writeLinkFlow(f.(*types.LinkFlow))
+		}
 	}
 	return nil
 }, func(e *CustomEncoder) error {
 	if !e.writer.IsChanWriter {
-		for _, f := range LinkFlows.Items {
-			writeLinkFlow(f)
+		LinkFlows.Lock()
+		flows := LinkFlows.Flush()
+		LinkFlows.Unlock()
+		for _, f := range flows {
+			writeLinkFlow(f.(*types.LinkFlow))
 		}
 	}
 	return nil
@@ -140,17 +129,26 @@ func writeLinkFlow(f *types.LinkFlow) {
 	if err != nil {
 		log.Fatal("failed to write audit record: ", err)
 	}
+
+	evaluateRules(f)
 }
 
 func DumpTop5LinkFlows() {
 
 	println("Top 5 Link Layer Flows:")
-	if len(LinkFlows.Items) == 0 {
+	var all []*types.LinkFlow
+	LinkFlows.Lock()
+	LinkFlows.Each(func(f interface{}) {
+		all = append(all, f.(*types.LinkFlow))
+	})
+	LinkFlows.Unlock()
+
+	if len(all) == 0 {
 		return
 	}
 
-	hits := make([]int64, len(LinkFlows.Items))
-	for _, f := range LinkFlows.Items {
+	hits := make([]int64, len(all))
+	for _, f := range all {
 		hits = append(hits, f.NumPackets)
 	}
 	sort.Slice(hits, func(i int, j int) bool {
@@ -169,9 +167,9 @@ func DumpTop5LinkFlows() {
 		bound = hits[len(hits)-numTop]
 		flows = map[uint64]*types.LinkFlow{}
 	)
-	for uid, f := range LinkFlows.Items {
+	for _, f := range all {
 		if f.NumPackets >= bound {
-			flows[uid] = f
+			flows[f.UID] = f
 		}
 	}
 	// print the highest volume flows in order
@@ -0,0 +1,294 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/mail"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// maximum number of commands added to a MailSession audit record
+	maxMailCommands = 1000
+
+	// streams with longer lines are not decoded any further
+	maxMailLine = 1024 * 1024
+
+	// messages are truncated after this size
+	maxMailSize = 64 * 1024 * 1024
+)
+
+var (
+	// set in postinit, nil if the encoder is not active
+	mailEncoderInstance        *CustomEncoder
+	mailSessionEncoderInstance *CustomEncoder
+)
+
+var mailEncoder = CreateCustomEncoder(types.Type_NC_Mail, "Mail", func(e *CustomEncoder) error {
+	mailEncoderInstance = e
+	registerMailDecoders()
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// mail protocols are decoded after stream reassembly
+	return nil
+}, flushMailStreams)
+
+var mailSessionEncoder = CreateCustomEncoder(types.Type_NC_MailSession, "MailSession", func(e *CustomEncoder) error {
+	mailSessionEncoderInstance = e
+	registerMailDecoders()
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// mail protocols are decoded after stream reassembly
+	return nil
+}, flushMailStreams)
+
+// registerMailDecoders registers the decoders for SMTP, POP3 and IMAP and ensures TCP stream reassembly is enabled
+func registerMailDecoders() {
+	streamDecoders[25] = newSMTPDecoder
+	streamDecoders[587] = newSMTPDecoder
+	streamDecoders[110] = newPOP3Decoder
+	streamDecoders[143] = newIMAPDecoder
+	HTTPActive = true
+}
+
+// flushMailStreams writes the remaining sessions before the writers are closed
+func flushMailStreams(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+}
+
+// mailSession contains the state shared by the SMTP, POP3 and IMAP decoders
+type mailSession struct {
+	parent  *tcpStream
+	session *types.MailSession
+
+	// incomplete lines
+	client []byte
+	server []byte
+
+	// mechanism of the current authentication command and if the server requested a response
+	auth         string
+	continuation bool
+
+	// set after STARTTLS, or if a line exceeds the maximum length
+	done bool
+}
+
+func newMailSession(t *tcpStream, protocol string) mailSession {
+	net, transport := t.clientFlows()
+	return mailSession{
+		parent: t,
+		session: &types.MailSession{
+			Protocol: protocol,
+			SrcIP:    net.Src().String(),
+			DstIP:    net.Dst().String(),
+			SrcPort:  int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+			DstPort:  int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+			ConnUID:  t.connUID,
+		},
+	}
+}
+
+// feed appends the data to the buffer of the direction and passes the buffer to next,
+// until next cannot consume any more data. next returns the number of bytes consumed.
+func (s *mailSession) feed(client bool, data []byte, ts time.Time, next func(client bool, buf []byte, ts time.Time) int) {
+
+	if s.done {
+		return
+	}
+
+	if s.session.Timestamp == "" {
+		s.session.Timestamp = utils.TimeToString(ts)
+	}
+	s.session.TimestampLast = utils.TimeToString(ts)
+
+	buf := &s.server
+	if client {
+		buf = &s.client
+	}
+	*buf = append(*buf, data...)
+
+	for len(*buf) > 0 && !s.done {
+		n := next(client, *buf, ts)
+		if n == 0 {
+			if len(*buf) > maxMailLine {
+				errorMap.Inc("mail: line too long")
+				s.done = true
+			}
+			break
+		}
+		*buf = (*buf)[n:]
+	}
+
+	// release the memory once all lines have been processed
+	if len(*buf) == 0 || s.done {
+		*buf = nil
+	}
+}
+
+// mailLine returns the first line of the buffer including the line break, or nil if the line is incomplete
+func mailLine(buf []byte) []byte {
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		return buf[:i+1]
+	}
+	return nil
+}
+
+// command adds a command to the session and returns it, so the status can be set once the server replied
+func (s *mailSession) command(ts time.Time, verb, arg string) *types.MailCommand {
+	c := &types.MailCommand{
+		Timestamp: utils.TimeToString(ts),
+		Command:   csvField(verb),
+		Argument:  csvField(arg),
+	}
+	if len(s.session.Commands) < maxMailCommands {
+		s.session.Commands = append(s.session.Commands, c)
+	}
+	return c
+}
+
+// authResponse extracts the user from the response of the client to an authentication challenge
+func (s *mailSession) authResponse(response string) {
+	switch s.auth {
+	case "PLAIN":
+		if user := mail.PlainUser(response); user != "" {
+			s.session.AuthUser = csvField(user)
+		}
+	case "LOGIN":
+		// the first response contains the user, the second the password
+		if s.session.AuthUser == "" {
+			s.session.AuthUser = csvField(mail.DecodeBase64(response))
+		}
+	}
+}
+
+// collectMessages returns true if the messages are needed for the Mail audit records
+func (s *mailSession) collectMessages() bool {
+	return mailEncoderInstance != nil
+}
+
+// appendMessage appends data to the message, until the maximum size has been reached
+func appendMessage(msg, data []byte) []byte {
+	if len(msg)+len(data) > maxMailSize {
+		return msg
+	}
+	return append(msg, data...)
+}
+
+// writeMail writes the audit record for a transferred message and extracts its attachments
+func (s *mailSession) writeMail(data []byte, command string, fromClient bool, ts time.Time, envelopeFrom string, envelopeTo []string) {
+
+	s.session.NumMessages++
+
+	if mailEncoderInstance == nil {
+		return
+	}
+
+	net, transport := s.parent.clientFlows()
+	if !fromClient {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+
+	m := &types.Mail{
+		Timestamp:    utils.TimeToString(ts),
+		Protocol:     s.session.Protocol,
+		Command:      command,
+		EnvelopeFrom: csvField(envelopeFrom),
+		EnvelopeTo:   csvFields(envelopeTo),
+		Length:       int64(len(data)),
+		SHA256:       mail.Hash(data),
+		SrcIP:        net.Src().String(),
+		DstIP:        net.Dst().String(),
+		SrcPort:      int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+		DstPort:      int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+		ConnUID:      s.parent.connUID,
+	}
+
+	msg, err := mail.ParseMessage(data)
+	if err != nil {
+		errorMap.Inc("mail: " + err.Error())
+	}
+	if msg != nil {
+		m.From = csvField(msg.From)
+		m.To = csvFields(msg.To)
+		m.Cc = csvFields(msg.Cc)
+		m.Subject = csvField(msg.Subject)
+		m.MessageID = csvField(msg.MessageID)
+		m.Date = csvField(msg.Date)
+		m.XMailer = csvField(msg.XMailer)
+		m.ContentType = csvField(msg.ContentType)
+
+		for _, a := range msg.Attachments {
+			att := &types.MailAttachment{
+				Name:        csvField(a.Name),
+				ContentType: csvField(a.ContentType),
+				MIME:        strings.Split(http.DetectContentType(a.Data), ";")[0],
+				Length:      int64(len(a.Data)),
+				SHA256:      mail.Hash(a.Data),
+			}
+
+			f := s.parent.newFile(m.Timestamp, "MailAttachment", "", a.Name, a.ContentType, fromClient)
+			f.Protocol = s.session.Protocol
+			extractFile(f, a.Data)
+			att.Location = f.Location
+
+			m.Attachments = append(m.Attachments, att)
+		}
+	}
+
+	// export metrics if configured
+	if mailEncoderInstance.export {
+		m.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&mailEncoderInstance.numRecords, 1)
+	err = mailEncoderInstance.writer.Write(m)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(m)
+}
+
+// writeSession writes the MailSession audit record, once the connection has been closed
+func (s *mailSession) writeSession() {
+
+	if mailSessionEncoderInstance == nil || s.session.Timestamp == "" {
+		return
+	}
+
+	// export metrics if configured
+	if mailSessionEncoderInstance.export {
+		s.session.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&mailSessionEncoderInstance.numRecords, 1)
+	err := mailSessionEncoderInstance.writer.Write(s.session)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(s.session)
+}
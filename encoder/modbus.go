@@ -14,28 +14,221 @@
 package encoder
 
 import (
+	"time"
+
 	"github.com/dreadl0ck/gopacket"
 	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/modbus"
 	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
 	"github.com/golang/protobuf/proto"
 )
 
+// maximum number of unanswered requests kept per connection for pairing
+const maxModbusPending = 1000
+
 var modbusEncoder = CreateLayerEncoder(types.Type_NC_Modbus, layers.LayerTypeModbus, func(layer gopacket.Layer, timestamp string) proto.Message {
 	if m, ok := layer.(*layers.Modbus); ok {
-		var payload []byte
-		if CapturePayload {
-			payload = m.ReqResp
-		}
-		return &types.Modbus{
-			Timestamp:     timestamp,
-			TransactionID: int32(m.TransactionID),
-			ProtocolID:    int32(m.ProtocolID),
-			Length:        int32(m.Length),
-			UnitID:        int32(m.UnitID),
-			Payload:       payload,
-			Exception:     m.Exception,
-			FunctionCode:  int32(m.FunctionCode),
-		}
+		// Modbus over TCP is decoded after stream reassembly
+		return newModbus(m, timestamp)
 	}
 	return nil
 })
+
+// newModbus creates the audit record with the MBAP header fields
+func newModbus(m *layers.Modbus, timestamp string) *types.Modbus {
+	payload, hash := capturePayload("Modbus", m.ReqResp)
+	return &types.Modbus{
+		Timestamp:     timestamp,
+		TransactionID: int32(m.TransactionID),
+		ProtocolID:    int32(m.ProtocolID),
+		Length:        int32(m.Length),
+		UnitID:        int32(m.UnitID),
+		Payload:       payload,
+		Exception:     m.Exception,
+		FunctionCode:  int32(m.FunctionCode),
+		PayloadHash:   hash,
+		FunctionName:  modbus.FunctionName(m.FunctionCode),
+	}
+}
+
+// setModbusPDU adds the function specific fields to the audit record
+func setModbusPDU(r *types.Modbus, p *modbus.PDU) {
+	r.ReadAddress = int32(p.ReadAddress)
+	r.ReadQuantity = int32(p.ReadQuantity)
+	r.WriteAddress = int32(p.WriteAddress)
+	r.WriteQuantity = int32(p.WriteQuantity)
+	for _, v := range p.Values {
+		r.Values = append(r.Values, uint32(v))
+	}
+	if p.Exception {
+		r.ExceptionCode = int32(p.ExceptionCode)
+		r.ExceptionName = modbus.ExceptionName(p.ExceptionCode)
+	}
+	if p.Function == modbus.Diagnostics {
+		r.SubFunction = int32(p.SubFunction)
+		r.SubFunctionName = modbus.SubFunctionName(p.SubFunction)
+	}
+}
+
+// modbusRequest is a request waiting for its response
+type modbusRequest struct {
+	pdu  *modbus.PDU
+	unit uint8
+	ts   time.Time
+}
+
+// modbusDecoder splits a TCP stream into ADUs and pairs requests and responses by their transaction ID
+type modbusDecoder struct {
+	parent *tcpStream
+
+	// incomplete ADUs
+	client []byte
+	server []byte
+
+	// requests by transaction ID
+	pending map[uint16]*modbusRequest
+
+	// set if the stream does not contain Modbus ADUs
+	invalid bool
+}
+
+func newModbusDecoder(t *tcpStream) streamDecoder {
+	return &modbusDecoder{
+		parent:  t,
+		pending: make(map[uint16]*modbusRequest),
+	}
+}
+
+func (d *modbusDecoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.invalid {
+		return
+	}
+
+	buf := &d.server
+	if client {
+		buf = &d.client
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		length, ok, err := modbus.FrameLength(*buf)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			d.invalid = true
+			d.client, d.server = nil, nil
+			return
+		}
+		if !ok || len(*buf) < length {
+			break
+		}
+		d.handle(client, (*buf)[:length], ts)
+		*buf = (*buf)[length:]
+	}
+
+	// release the memory once all ADUs have been processed
+	if len(*buf) == 0 {
+		*buf = nil
+	}
+}
+
+// close adds the unanswered requests to the device profiles
+func (d *modbusDecoder) close() {
+	for id, req := range d.pending {
+		d.profile(req, nil)
+		delete(d.pending, id)
+	}
+}
+
+// handle decodes an ADU, pairs it and writes its audit record
+func (d *modbusDecoder) handle(client bool, data []byte, ts time.Time) {
+
+	m := &layers.Modbus{}
+	err := m.DecodeFromBytes(data, gopacket.NilDecodeFeedback)
+	if err != nil {
+		errorMap.Inc(err.Error())
+		return
+	}
+	r := newModbus(m, utils.TimeToString(ts))
+	r.Request = client
+
+	pdu := data[7:]
+	if client {
+		p, err := modbus.ParseRequest(pdu)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+		setModbusPDU(r, p)
+		r.Control = p.IsControl()
+
+		// a request with the same transaction ID replaces the previous one
+		if prev, ok := d.pending[m.TransactionID]; ok {
+			d.profile(prev, nil)
+		} else if len(d.pending) >= maxModbusPending {
+			errorMap.Inc("Modbus: too many unanswered requests")
+			d.close()
+		}
+		d.pending[m.TransactionID] = &modbusRequest{pdu: p, unit: m.UnitID, ts: ts}
+	} else {
+		req := d.pending[m.TransactionID]
+		if req != nil && (req.unit != m.UnitID || req.pdu.Function != m.FunctionCode) {
+			req = nil
+		}
+		var reqPDU *modbus.PDU
+		if req != nil {
+			reqPDU = req.pdu
+		}
+		p, err := modbus.ParseResponse(pdu, reqPDU)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+		setModbusPDU(r, p)
+		if req != nil {
+			r.Paired = true
+			r.Latency = ts.Sub(req.ts).Nanoseconds()
+			delete(d.pending, m.TransactionID)
+			d.profile(req, p)
+		}
+	}
+
+	d.write(client, r)
+}
+
+// profile adds a transaction to the device profiles, if the ModbusDevice encoder is active
+func (d *modbusDecoder) profile(req *modbusRequest, res *modbus.PDU) {
+	if modbusProfiles == nil {
+		return
+	}
+	net, _ := d.parent.clientFlows()
+	modbusProfiles.Add(utils.TimeToString(req.ts), net.Src().String(), net.Dst().String(), req.unit, req.pdu, res)
+}
+
+// write writes the audit record, if the Modbus encoder is active
+func (d *modbusDecoder) write(client bool, r *types.Modbus) {
+
+	// the writer is only set if the Modbus encoder is active
+	if modbusEncoder.writer == nil {
+		return
+	}
+
+	if AddContext {
+		net, transport := d.parent.clientFlows()
+		if !client {
+			net, transport = net.Reverse(), transport.Reverse()
+		}
+		r.Context = &types.PacketContext{
+			SrcIP:       net.Src().String(),
+			DstIP:       net.Dst().String(),
+			SrcPort:     transport.Src().String(),
+			DstPort:     transport.Dst().String(),
+			CommunityID: tcpCommunityID(net, transport),
+			ConnUID:     d.parent.connUID,
+		}
+	}
+
+	err := modbusEncoder.write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"flag"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/modbus"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagModbusMaxRegisters = flag.Int("modbus-max-registers", 10000, "maximum number of coils, inputs and registers tracked per Modbus device")
+
+	// modbusProfiles is updated with the transactions of all Modbus connections, nil if the ModbusDevice encoder is not active
+	modbusProfiles *modbus.Profiles
+)
+
+var modbusDeviceEncoder = CreateCustomEncoder(types.Type_NC_ModbusDevice, "ModbusDevice", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for Modbus TCP and ensure TCP stream reassembly is enabled
+
+	modbusProfiles = modbus.NewProfiles(*flagModbusMaxRegisters)
+	streamDecoders[modbus.Port] = newModbusDecoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// the profiles are updated from the reassembled Modbus connections
+	return nil
+}, func(e *CustomEncoder) error {
+
+	// deinit:
+	// decode the remaining streams and write the profiles of all devices
+
+	flushStreams()
+
+	for _, d := range modbusProfiles.Entries() {
+
+		// export metrics if configured
+		if e.export {
+			d.Inc()
+		}
+
+		// write record to disk
+		atomic.AddInt64(&e.numRecords, 1)
+		err := e.writer.Write(d)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+
+		evaluateRules(d)
+	}
+
+	return nil
+})
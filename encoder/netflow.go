@@ -0,0 +1,68 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+
+	"github.com/dreadl0ck/netcap/netflow"
+	"github.com/pkg/errors"
+)
+
+var (
+	flagNetFlowAddr    = flag.String("netflow-addr", "", "export the flows as NetFlow v9 or IPFIX messages to the collector at the UDP address, e.g. 127.0.0.1:4739")
+	flagNetFlowFile    = flag.String("netflow-file", "", "write the NetFlow v9 or IPFIX messages for the flows to the file at path")
+	flagNetFlowVersion = flag.Int("netflow-version", netflow.VersionIPFIX, "version of the exported messages, 9 for NetFlow v9 or 10 for IPFIX")
+	flagNetFlowRecords = flag.String("netflow-records", "Flow", "audit records exported with -netflow-addr or -netflow-file, either Flow or Connection")
+
+	// exporters for the written flows and connections, nil if the export is not configured
+	flowExporter *netflow.Exporter
+	connExporter *netflow.Exporter
+)
+
+// newNetFlowExporter returns an exporter if the export is configured for the named audit record type
+func newNetFlowExporter(typ string) (*netflow.Exporter, error) {
+	if *flagNetFlowAddr == "" && *flagNetFlowFile == "" {
+		return nil, nil
+	}
+	if *flagNetFlowRecords != "Flow" && *flagNetFlowRecords != "Connection" {
+		return nil, errors.Errorf("invalid value for -netflow-records: %q, must be Flow or Connection", *flagNetFlowRecords)
+	}
+	if *flagNetFlowRecords != typ {
+		return nil, nil
+	}
+	return netflow.Open(*flagNetFlowAddr, *flagNetFlowFile, *flagNetFlowVersion)
+}
+
+// exportNetFlow adds a Flow or Connection to the messages of the exporter
+func exportNetFlow(e *netflow.Exporter, record interface{}) {
+	if e == nil {
+		return
+	}
+	if r, ok := netflow.FromAuditRecord(record); ok {
+		if err := e.Add(r); err != nil {
+			errorMap.Inc(err.Error())
+		}
+	}
+}
+
+// closeNetFlowExporter sends the remaining records and closes the exporter
+func closeNetFlowExporter(e *netflow.Exporter) {
+	if e == nil {
+		return
+	}
+	if err := e.Close(); err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
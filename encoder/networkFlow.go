@@ -17,38 +17,38 @@ import (
 	"fmt"
 	"log"
 	"sort"
-	"sync"
 	"sync/atomic"
 
 	"github.com/dreadl0ck/netcap/types"
 	"github.com/dreadl0ck/netcap/utils"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/dreadl0ck/gopacket"
+	"github.com/golang/protobuf/proto"
 )
 
-type AtomicNetworkFlowMap struct {
-	Items map[uint64]*types.NetworkFlow
-	sync.Mutex
-}
-
 var (
-	NetworkFlows = &AtomicNetworkFlowMap{
-		Items: make(map[uint64]*types.NetworkFlow),
-	}
+	// NetworkFlows holds all active network layer flows
+	NetworkFlows               = NewFlowTable("NetworkFlow", 0, 0, 0)
 	networkFlowEncoderInstance *CustomEncoder
-	netFlows                   int64
 )
 
 var networkFlowEncoder = CreateCustomEncoder(types.Type_NC_NetworkFlow, "NetworkFlow", func(d *CustomEncoder) error {
 	networkFlowEncoderInstance = d
+	NetworkFlows = newFlowTable("NetworkFlow")
 	return nil
 }, func(p gopacket.Packet) proto.Message {
 	if ll := p.NetworkLayer(); ll != nil {
 
+		var (
+			id      = ll.NetworkFlow().FastHash()
+			ts      = p.Metadata().Timestamp
+			expired []interface{}
+		)
+
 		// lookup flow
 		NetworkFlows.Lock()
-		if flow, ok := NetworkFlows.Items[ll.NetworkFlow().FastHash()]; ok {
+		if f, ok := NetworkFlows.Get(id, ts); ok {
+			flow := f.(*types.NetworkFlow)
 
 			// flow exists. update fields
 			calcDuration := false
@@ -99,37 +99,26 @@ var networkFlowEncoder = CreateCustomEncoder(types.Type_NC_NetworkFlow, "Network
 			lf.Proto = ll.LayerType().String()
 			lf.NumPackets = 1
 			lf.TotalSize = int64(len(p.Data()))
-			NetworkFlows.Items[ll.NetworkFlow().FastHash()] = lf
-
-			// continuously flush flows
-			netFlows++
-			if netFlows%flowFlushInterval == 0 {
-
-				var selectFlows []*types.NetworkFlow
-				for id, f := range NetworkFlows.Items {
-					// flush entries whose last timestamp is flowTimeOut older than current packet
-					if p.Metadata().Timestamp.Sub(utils.StringToTime(f.TimestampLast)) > flowTimeOut {
-						selectFlows = append(selectFlows, f)
-						// cleanup
-						delete(NetworkFlows.Items, id)
-					}
-				}
-
-				// do this in background
-				go func() {
-					for _, f := range selectFlows {
-						writeNetworkFlow(f)
-					}
-				}()
+			if evicted := NetworkFlows.Put(id, lf, ts); evicted != nil {
+				expired = append(expired, evicted)
 			}
 		}
+		expired = append(expired, NetworkFlows.Expire(ts)...)
 		NetworkFlows.Unlock()
+
+		// write timed out and evicted flows
+		for _, f := range expired {
+			writeNetworkFlow(f.(*types.NetworkFlow))
+		}
 	}
 	return nil
 }, func(e *CustomEncoder) error {
 	if !e.writer.IsChanWriter {
-		for _, f := range NetworkFlows.Items {
-			writeNetworkFlow(f)
+		NetworkFlows.Lock()
+		flows := NetworkFlows.Flush()
+		NetworkFlows.Unlock()
+		for _, f := range flows {
+			writeNetworkFlow(f.(*types.NetworkFlow))
 		}
 	}
 	return nil
@@ -141,17 +130,26 @@ func writeNetworkFlow(f *types.NetworkFlow) {
 	if err != nil {
 		log.Fatal("failed to write audit record: ", err)
 	}
+
+	evaluateRules(f)
 }
 
 func DumpTop5NetworkFlows() {
 
 	println("Top 5 Network Layer Flows:")
-	if len(NetworkFlows.Items) == 0 {
+	var all []*types.NetworkFlow
+	NetworkFlows.Lock()
+	NetworkFlows.Each(func(f interface{}) {
+		all = append(all, f.(*types.NetworkFlow))
+	})
+	NetworkFlows.Unlock()
+
+	if len(all) == 0 {
 		return
 	}
 
-	hits := make([]int64, len(NetworkFlows.Items))
-	for _, f := range NetworkFlows.Items {
+	hits := make([]int64, len(all))
+	for _, f := range all {
 		hits = append(hits, f.NumPackets)
 	}
 	sort.Slice(hits, func(i int, j int) bool {
@@ -170,9 +168,9 @@ func DumpTop5NetworkFlows() {
 		bound = hits[len(hits)-numTop]
 		flows = map[uint64]*types.NetworkFlow{}
 	)
-	for uid, f := range NetworkFlows.Items {
+	for _, f := range all {
 		if f.NumPackets >= bound {
-			flows[uid] = f
+			flows[f.UID] = f
 		}
 	}
 	// print the highest volume flows in order
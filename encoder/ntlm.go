@@ -0,0 +1,141 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/ntlm"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+)
+
+// set in postinit, nil if the NTLM encoder is not active
+var ntlmEncoderInstance *CustomEncoder
+
+var ntlmEncoder = CreateCustomEncoder(types.Type_NC_NTLM, "NTLM", func(e *CustomEncoder) error {
+
+	// postinit:
+	// ensure TCP stream reassembly is enabled, NTLMSSP messages are embedded in other protocols
+
+	ntlmEncoderInstance = e
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// messages are handed to the encoder by the protocol decoders after stream reassembly
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// extractHTTPNTLM writes the NTLMSSP messages from the authentication headers of a request and its response
+func (t *tcpStream) extractHTTPNTLM(req *http.Request, res *http.Response) {
+
+	if ntlmEncoderInstance == nil {
+		return
+	}
+
+	// the header maps are indexed with the canonical keys
+	if req != nil {
+		for _, k := range []string{"Authorization", "Proxy-Authorization"} {
+			for _, v := range req.Header[k] {
+				if msg := httpNTLM(v); msg != nil {
+					t.writeNTLM(msg, req.Header.Get("netcap-ts"), "HTTP", true)
+				}
+			}
+		}
+	}
+	if res != nil {
+		for _, k := range []string{"Www-Authenticate", "Proxy-Authenticate"} {
+			for _, v := range res.Header[k] {
+				if msg := httpNTLM(v); msg != nil {
+					t.writeNTLM(msg, res.Header.Get("netcap-ts"), "HTTP", false)
+				}
+			}
+		}
+	}
+}
+
+// httpNTLM decodes the NTLMSSP message from the value of an authentication header,
+// either directly for the NTLM scheme or wrapped in SPNEGO for the Negotiate scheme
+func httpNTLM(value string) *ntlm.Message {
+
+	fields := strings.Fields(value)
+	if len(fields) != 2 || (!strings.EqualFold(fields[0], "NTLM") && !strings.EqualFold(fields[0], "Negotiate")) {
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil
+	}
+	if data = ntlm.Find(data); data == nil {
+		return nil
+	}
+	msg, err := ntlm.Parse(data)
+	if err != nil {
+		errorMap.Inc(err.Error())
+		return nil
+	}
+	return msg
+}
+
+// writeNTLM writes the audit record for an NTLMSSP message found in the stream
+func (t *tcpStream) writeNTLM(msg *ntlm.Message, ts string, protocol string, fromClient bool) {
+
+	if ntlmEncoderInstance == nil {
+		return
+	}
+
+	net, transport := t.clientFlows()
+	if !fromClient {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+
+	n := &types.NTLM{
+		Timestamp:   ts,
+		MessageType: ntlm.TypeName(msg.Type),
+		Flags:       msg.Flags,
+		User:        msg.User,
+		Domain:      msg.Domain,
+		Workstation: msg.Workstation,
+		TargetName:  msg.TargetName,
+		Protocol:    protocol,
+		SrcIP:       net.Src().String(),
+		DstIP:       net.Dst().String(),
+		SrcPort:     int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+		DstPort:     int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+		ConnUID:     t.connUID,
+	}
+
+	// export metrics if configured
+	if ntlmEncoderInstance.export {
+		n.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&ntlmEncoderInstance.numRecords, 1)
+	err := ntlmEncoderInstance.writer.Write(n)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(n)
+}
@@ -0,0 +1,77 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"flag"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/pdns"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagPassiveDNSMax = flag.Int("pdns-max", 1000000, "maximum number of answers and addresses kept in the passive DNS store")
+
+	// passiveDNSStore is updated with the answers of all written DNS records, nil if the PassiveDNS encoder is not active
+	passiveDNSStore *pdns.Store
+)
+
+var passiveDNSEncoder = CreateCustomEncoder(types.Type_NC_PassiveDNS, "PassiveDNS", func(e *CustomEncoder) error {
+	passiveDNSStore = pdns.NewStore(*flagPassiveDNSMax)
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// the store is updated from the DNS records
+	return nil
+}, func(e *CustomEncoder) error {
+
+	// deinit:
+	// write the answers seen during the whole capture
+
+	for _, r := range passiveDNSStore.Entries() {
+
+		// export metrics if configured
+		if e.export {
+			r.Inc()
+		}
+
+		// write record to disk
+		atomic.AddInt64(&e.numRecords, 1)
+		err := e.writer.Write(r)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+
+		evaluateRules(r)
+	}
+
+	return nil
+})
+
+// updatePassiveDNS adds the answers of the DNS record to the passive DNS store
+func updatePassiveDNS(d *types.DNS) {
+	if passiveDNSStore != nil {
+		passiveDNSStore.AddRecord(d)
+	}
+}
+
+// dstHostname returns the name the address was most recently resolved from,
+// or an empty string if it is unknown or the PassiveDNS encoder is not active
+func dstHostname(addr string) string {
+	if passiveDNSStore == nil {
+		return ""
+	}
+	return passiveDNSStore.Hostname(addr)
+}
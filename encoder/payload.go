@@ -0,0 +1,262 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mgutz/ansi"
+)
+
+// payloadFull is the limit value for capturing the complete payload
+const payloadFull = -1
+
+// payloadRule describes how the payload for an encoder is captured
+// limit is the maximum number of bytes, 0 disables capture and payloadFull captures everything
+// if hash is set, the hex encoded SHA-256 of the complete payload is stored instead of the raw bytes
+type payloadRule struct {
+	limit int
+	hash  bool
+}
+
+// capture returns true if the rule results in any payload data
+func (r payloadRule) capture() bool {
+	return r.limit != 0 || r.hash
+}
+
+var (
+	flagPayloadFlowMax = flag.Int("payload-flow-max", 64*1024, "maximum number of payload bytes kept per Flow and Connection, if the full payload is captured")
+
+	// payload capture policy, keyed by encoder name
+	// the wildcard rule "*" applies to all encoders without a rule of their own
+	payloadPolicy = map[string]payloadRule{}
+
+	// encoders whose audit records carry a payload
+	payloadEncoders = map[string]struct{}{
+		"Ethernet":   {},
+		"IPv4":       {},
+		"IPv6":       {},
+		"TCP":        {},
+		"UDP":        {},
+		"USB":        {},
+		"Modbus":     {},
+		"CIP":        {},
+		"Flow":       {},
+		"Connection": {},
+		"HTTP":       {},
+	}
+)
+
+// parsePayloadPolicy parses a payload policy in the format Name:Value,Name:Value,...
+// valid values are: full, hash or a number of bytes to truncate the payload to, 0 disables capture
+// a name without a value captures the full payload, the name * sets the default for all encoders
+// for backwards compatibility, true and all enable full payload capture for all encoders
+func parsePayloadPolicy(s string) (map[string]payloadRule, error) {
+
+	policy := make(map[string]payloadRule)
+
+	switch strings.TrimSpace(s) {
+	case "", "false", "none":
+		return policy, nil
+	case "true", "all":
+		policy["*"] = payloadRule{limit: payloadFull}
+		return policy, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var (
+			parts = strings.SplitN(entry, ":", 2)
+			name  = parts[0]
+			rule  = payloadRule{limit: payloadFull}
+		)
+		if name != "*" {
+			if _, ok := allEncoderNames[name]; !ok {
+				return nil, errors.New("invalid encoder: " + name)
+			}
+		}
+		if len(parts) == 2 {
+			switch v := strings.TrimSpace(parts[1]); v {
+			case "full":
+			case "hash":
+				rule.hash = true
+			default:
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					return nil, errors.New("invalid payload setting for " + name + ": " + v)
+				}
+				rule.limit = n
+			}
+		}
+		policy[name] = rule
+	}
+	return policy, nil
+}
+
+// payloadFlag is the value of the -payload flag.
+// it is a boolean flag to stay compatible with earlier versions, where -payload enabled full payload capture
+type payloadFlag string
+
+func (f *payloadFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return string(*f)
+}
+
+func (f *payloadFlag) Set(s string) error {
+	*f = payloadFlag(s)
+	return nil
+}
+
+func (f *payloadFlag) IsBoolFlag() bool {
+	return true
+}
+
+// PayloadFlag defines the -payload flag on the default command line,
+// ParsePayloadFlag must be called after flag.Parse
+func PayloadFlag(usage string) *string {
+	f := new(payloadFlag)
+	flag.Var(f, "payload", usage)
+	return (*string)(f)
+}
+
+// ParsePayloadFlag accepts the payload policy as separate argument, e.g. -payload TCP:256.
+// Since -payload is a boolean flag, the flag package stops parsing at the policy,
+// so the remaining arguments are parsed after the policy has been consumed.
+func ParsePayloadFlag(p *string) {
+	if *p != "true" || flag.NArg() == 0 {
+		return
+	}
+	if _, err := parsePayloadPolicy(flag.Arg(0)); err != nil {
+		return
+	}
+	*p = flag.Arg(0)
+	// the default command line exits on errors
+	_ = flag.CommandLine.Parse(flag.Args()[1:])
+}
+
+// setPayloadPolicy parses and sets the payload policy, exits on invalid input
+func setPayloadPolicy(s string) {
+	p, err := parsePayloadPolicy(s)
+	if err != nil {
+		fmt.Println(ansi.Red + err.Error() + ansi.Reset)
+		os.Exit(1)
+	}
+	payloadPolicy = p
+}
+
+// payloadRuleFor returns the payload rule for the named encoder
+func payloadRuleFor(name string) payloadRule {
+	if r, ok := payloadPolicy[name]; ok {
+		return r
+	}
+	return payloadPolicy["*"]
+}
+
+// ContainsPayloads returns true if the audit records of the named encoder will contain raw payload data
+func ContainsPayloads(name string) bool {
+	if _, ok := payloadEncoders[name]; !ok {
+		return false
+	}
+	r := payloadRuleFor(name)
+	return r.limit != 0 && !r.hash
+}
+
+// capturePayload applies the payload policy of the named encoder to the data
+// and returns the payload to store and the hash, if configured
+func capturePayload(name string, data []byte) ([]byte, string) {
+	r := payloadRuleFor(name)
+	if !r.capture() || len(data) == 0 {
+		return nil, ""
+	}
+	if r.hash {
+		h := sha256.Sum256(data)
+		return nil, hex.EncodeToString(h[:])
+	}
+	if r.limit != payloadFull && len(data) > r.limit {
+		return data[:r.limit], ""
+	}
+	return data, ""
+}
+
+// payloadAccumulator collects the payloads of multiple packets for records like Flows and Connections
+type payloadAccumulator struct {
+	sync.Mutex
+	name   string
+	hashes map[interface{}]hash.Hash
+}
+
+// newPayloadAccumulator returns a payloadAccumulator for the named encoder
+func newPayloadAccumulator(name string) *payloadAccumulator {
+	return &payloadAccumulator{
+		name:   name,
+		hashes: make(map[interface{}]hash.Hash),
+	}
+}
+
+// add appends the data to the payload buffer of the record, respecting the configured limit
+// since records are kept until they time out, full capture is limited by -payload-flow-max
+func (a *payloadAccumulator) add(record interface{}, buf *[]byte, data []byte) {
+	r := payloadRuleFor(a.name)
+	if !r.capture() || len(data) == 0 {
+		return
+	}
+	if r.hash {
+		a.Lock()
+		h, ok := a.hashes[record]
+		if !ok {
+			h = sha256.New()
+			a.hashes[record] = h
+		}
+		h.Write(data)
+		a.Unlock()
+		return
+	}
+	limit := r.limit
+	if limit == payloadFull {
+		limit = *flagPayloadFlowMax
+	}
+	remaining := limit - len(*buf)
+	if remaining <= 0 {
+		return
+	}
+	if len(data) > remaining {
+		data = data[:remaining]
+	}
+	*buf = append(*buf, data...)
+}
+
+// finish returns the hex encoded hash for the record, if any, and releases its state
+func (a *payloadAccumulator) finish(record interface{}) string {
+	a.Lock()
+	defer a.Unlock()
+	h, ok := a.hashes[record]
+	if !ok {
+		return ""
+	}
+	delete(a.hashes, record)
+	return hex.EncodeToString(h.Sum(nil))
+}
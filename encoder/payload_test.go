@@ -0,0 +1,147 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestParsePayloadPolicy(t *testing.T) {
+
+	valid := []struct {
+		in   string
+		want map[string]payloadRule
+	}{
+		{"", map[string]payloadRule{}},
+		{"false", map[string]payloadRule{}},
+		{"true", map[string]payloadRule{"*": {limit: payloadFull}}},
+		{"all", map[string]payloadRule{"*": {limit: payloadFull}}},
+		{"TCP", map[string]payloadRule{"TCP": {limit: payloadFull}}},
+		{"TCP:256, UDP:hash,Flow:full,*:0", map[string]payloadRule{
+			"TCP":  {limit: 256},
+			"UDP":  {hash: true, limit: payloadFull},
+			"Flow": {limit: payloadFull},
+			"*":    {limit: 0},
+		}},
+	}
+	for _, c := range valid {
+		got, err := parsePayloadPolicy(c.in)
+		if err != nil {
+			t.Fatalf("%q: %v", c.in, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: expected %v, got %v", c.in, c.want, got)
+		}
+	}
+
+	for _, in := range []string{"Unknown", "TCP:-1", "TCP:some", "TCP:256,Foo:hash"} {
+		if _, err := parsePayloadPolicy(in); err == nil {
+			t.Fatalf("%q: expected an error", in)
+		}
+	}
+}
+
+// withPayloadPolicy sets the payload policy and returns a function to restore the previous one
+func withPayloadPolicy(t *testing.T, s string) func() {
+	p, err := parsePayloadPolicy(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := payloadPolicy
+	payloadPolicy = p
+	return func() {
+		payloadPolicy = prev
+	}
+}
+
+func TestCapturePayload(t *testing.T) {
+
+	defer withPayloadPolicy(t, "TCP:4,UDP:hash,*:full,Ethernet:0")()
+
+	data := []byte("payload data")
+	if p, h := capturePayload("TCP", data); string(p) != "payl" || h != "" {
+		t.Fatalf("expected truncated payload, got %q %q", p, h)
+	}
+	sum := sha256.Sum256(data)
+	if p, h := capturePayload("UDP", data); p != nil || h != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected hash, got %q %q", p, h)
+	}
+	if p, _ := capturePayload("IPv4", data); string(p) != string(data) {
+		t.Fatalf("expected full payload from the wildcard rule, got %q", p)
+	}
+	if p, h := capturePayload("Ethernet", data); p != nil || h != "" {
+		t.Fatalf("expected no payload, got %q %q", p, h)
+	}
+	if !ContainsPayloads("TCP") || ContainsPayloads("UDP") || ContainsPayloads("Ethernet") {
+		t.Fatal("unexpected ContainsPayloads result")
+	}
+}
+
+func TestPayloadAccumulator(t *testing.T) {
+
+	defer withPayloadPolicy(t, "Flow:6,Connection:hash")()
+
+	// truncated to the limit over several packets
+	var (
+		flows = newPayloadAccumulator("Flow")
+		buf   []byte
+	)
+	for _, d := range []string{"abcd", "efgh", "ijkl"} {
+		flows.add(&buf, &buf, []byte(d))
+	}
+	if string(buf) != "abcdef" {
+		t.Fatalf("expected truncated payload, got %q", buf)
+	}
+	if h := flows.finish(&buf); h != "" {
+		t.Fatal("unexpected hash", h)
+	}
+
+	// hashed over all packets, the state is released after finishing the record
+	var (
+		conns  = newPayloadAccumulator("Connection")
+		record = new(int)
+		other  []byte
+	)
+	conns.add(record, &other, []byte("abcd"))
+	conns.add(record, &other, []byte("efgh"))
+	sum := sha256.Sum256([]byte("abcdefgh"))
+	if h := conns.finish(record); h != hex.EncodeToString(sum[:]) || other != nil {
+		t.Fatalf("unexpected hash %q, payload %q", h, other)
+	}
+	if _, ok := conns.hashes[record]; ok {
+		t.Fatal("hash state not released")
+	}
+}
+
+func TestPayloadAccumulatorFlowMax(t *testing.T) {
+
+	defer withPayloadPolicy(t, "Flow")()
+	defer func(v int) {
+		*flagPayloadFlowMax = v
+	}(*flagPayloadFlowMax)
+	*flagPayloadFlowMax = 5
+
+	var (
+		flows = newPayloadAccumulator("Flow")
+		buf   []byte
+	)
+	flows.add(&buf, &buf, []byte("abcd"))
+	flows.add(&buf, &buf, []byte("efgh"))
+	if string(buf) != "abcde" {
+		t.Fatalf("expected full payload limited by -payload-flow-max, got %q", buf)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"time"
+
+	"github.com/dreadl0ck/netcap/mail"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// pop3Decoder decodes the commands and responses of a POP3 session and the messages retrieved with RETR
+type pop3Decoder struct {
+	mailSession
+
+	// commands waiting for a response, in the order they have been sent
+	pending []*types.MailCommand
+
+	// set while the server sends a multiline response
+	multiline bool
+
+	// set if the multiline response contains the capabilities or a message
+	capabilities bool
+	retrieve     bool
+
+	message   []byte
+	messageTs time.Time
+}
+
+func newPOP3Decoder(t *tcpStream) streamDecoder {
+	return &pop3Decoder{mailSession: newMailSession(t, "POP3")}
+}
+
+func (d *pop3Decoder) decode(client bool, data []byte, ts time.Time) {
+	d.feed(client, data, ts, d.next)
+}
+
+func (d *pop3Decoder) close() {
+	d.writeSession()
+}
+
+func (d *pop3Decoder) next(client bool, buf []byte, ts time.Time) int {
+
+	l := mailLine(buf)
+	if l == nil {
+		return 0
+	}
+
+	if client {
+		d.clientLine(l, ts)
+	} else {
+		d.serverLine(l, ts)
+	}
+	return len(l)
+}
+
+func (d *pop3Decoder) clientLine(l []byte, ts time.Time) {
+
+	if d.continuation {
+		d.continuation = false
+		d.authResponse(string(l))
+		return
+	}
+
+	verb, arg := mail.ParseCommand(string(l))
+	switch verb {
+	case "USER":
+		d.session.AuthUser = csvField(arg)
+	case "PASS":
+		arg = ""
+	case "APOP":
+		// the digest is omitted from the argument
+		arg = mail.Atom(arg)
+		d.session.AuthMechanism = verb
+		d.session.AuthUser = csvField(arg)
+	case "AUTH":
+		mechanism, response := splitAuth(arg)
+		arg = mechanism
+		if mechanism != "" {
+			d.auth = mechanism
+			d.session.AuthMechanism = mechanism
+		}
+		if response != "" && response != "=" {
+			d.authResponse(response)
+		}
+	}
+
+	if len(d.pending) < maxMailCommands {
+		d.pending = append(d.pending, d.command(ts, verb, arg))
+	}
+}
+
+func (d *pop3Decoder) serverLine(l []byte, ts time.Time) {
+
+	if d.multiline {
+		switch {
+		case mail.IsTerminator(l):
+			d.multiline = false
+			if d.retrieve {
+				d.writeMail(d.message, "RETR", false, d.messageTs, "", nil)
+				d.message = nil
+			}
+		case d.retrieve:
+			if d.collectMessages() {
+				d.message = appendMessage(d.message, mail.Unstuff(l))
+			}
+		case d.capabilities:
+			if m := mail.Mechanisms(string(l)); len(m) > 0 {
+				d.session.AuthMechanisms = m
+			}
+		}
+		return
+	}
+
+	status, text, ok := mail.ParsePOP3Status(string(l))
+	if !ok {
+		return
+	}
+
+	if len(d.pending) == 0 {
+		// greeting
+		if d.session.Banner == "" {
+			d.session.Banner = csvField(text)
+		}
+		return
+	}
+
+	// continuation request for an authentication response
+	if status == "+" {
+		d.continuation = true
+		return
+	}
+
+	c := d.pending[0]
+	d.pending = d.pending[1:]
+	c.Status = status
+
+	if c.Command == "AUTH" {
+		d.auth = ""
+		d.continuation = false
+	}
+	if status != "+OK" {
+		return
+	}
+
+	d.capabilities = false
+	d.retrieve = false
+	switch c.Command {
+	case "RETR":
+		d.multiline = true
+		d.retrieve = true
+		d.message = []byte{}
+		d.messageTs = ts
+	case "CAPA":
+		d.multiline = true
+		d.capabilities = true
+	case "TOP":
+		d.multiline = true
+	case "LIST", "UIDL", "AUTH":
+		// without an argument, the response contains a line for each message or mechanism
+		d.multiline = c.Argument == ""
+	case "STLS":
+		d.session.StartTLS = true
+		d.done = true
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/ja3"
+	"github.com/dreadl0ck/netcap/quic"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/dreadl0ck/tlsx"
+	"github.com/golang/protobuf/proto"
+)
+
+// maximum number of ClientHellos that are reassembled at the same time,
+// if exceeded the incomplete ones are dropped
+const quicMaxPending = 10000
+
+var (
+	// incomplete ClientHellos spanning several Initial packets, by client address and connection ID
+	quicPending   = make(map[string]*quic.CryptoStream)
+	quicPendingMu sync.Mutex
+)
+
+var quicEncoder = CreateCustomEncoder(types.Type_NC_QUIC, "QUIC", nil, func(p gopacket.Packet) proto.Message {
+
+	l := p.Layer(layers.LayerTypeUDP)
+	if l == nil {
+		return nil
+	}
+	var (
+		udp  = l.(*layers.UDP)
+		data = udp.Payload
+	)
+
+	// the client can coalesce several packets into a single datagram
+	for len(data) > 0 {
+		h, err := quic.ParseLongHeader(data)
+		if err != nil || h.Type == quic.PacketRetry {
+			return nil
+		}
+		if h.Type != quic.PacketInitial {
+			data = data[h.PacketLength():]
+			continue
+		}
+
+		// decryption fails for the Initial packets of the server, since only the keys of the client are derived
+		plain, err := quic.DecryptInitial(h, data)
+		if err != nil {
+			return nil
+		}
+		frames, err := quic.CryptoFrames(plain)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+		if len(frames) > 0 {
+			if hello := quicClientHello(p, h, frames); hello != nil {
+				if r := newQUIC(p, h, hello); r != nil {
+					return r
+				}
+			}
+		}
+		data = data[h.PacketLength():]
+	}
+	return nil
+}, nil)
+
+// quicClientHello adds the frames to the handshake data of the client
+// and returns the ClientHello once it is complete
+func quicClientHello(p gopacket.Packet, h *quic.Header, frames []quic.CryptoFrame) []byte {
+
+	key := p.NetworkLayer().NetworkFlow().Src().String() + p.TransportLayer().TransportFlow().Src().String() + string(h.DCID)
+
+	quicPendingMu.Lock()
+	defer quicPendingMu.Unlock()
+
+	s, ok := quicPending[key]
+	if !ok {
+		if len(quicPending) >= quicMaxPending {
+			quicPending = make(map[string]*quic.CryptoStream)
+		}
+		s = new(quic.CryptoStream)
+		quicPending[key] = s
+	}
+	if err := s.Add(frames...); err != nil {
+		// not a ClientHello, or the handshake data is sent over and over again
+		errorMap.Inc(err.Error())
+		delete(quicPending, key)
+		return nil
+	}
+
+	msg := s.Message()
+	if msg != nil {
+		delete(quicPending, key)
+	}
+	return msg
+}
+
+// newQUIC creates the audit record for a ClientHello handshake message
+func newQUIC(p gopacket.Packet, h *quic.Header, msg []byte) *types.QUIC {
+
+	// prepend a TLS record header for the parser
+	var (
+		record = append([]byte{22, 3, 1, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+		hello  = tlsx.ClientHello{}
+	)
+	if err := hello.Unmarshall(record); err != nil {
+		errorMap.Inc(err.Error())
+		return nil
+	}
+
+	var (
+		cipherSuites    []int32
+		extensions      []int32
+		supportedGroups []int32
+		params          = make(map[string]string)
+	)
+	for _, v := range hello.CipherSuites {
+		cipherSuites = append(cipherSuites, int32(v))
+	}
+	for _, v := range hello.AllExtensions {
+		extensions = append(extensions, int32(v))
+	}
+	for _, v := range hello.SupportedGroups {
+		supportedGroups = append(supportedGroups, int32(v))
+	}
+
+	ext, err := quic.ClientHelloExtension(msg, quic.ExtensionTransportParameters)
+	if err == nil && ext == nil {
+		ext, err = quic.ClientHelloExtension(msg, quic.ExtensionTransportParametersDraft)
+	}
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+	tp, err := quic.ParseTransportParameters(ext)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+	for _, param := range tp {
+		params[param.Name()] = param.String()
+	}
+
+	var (
+		net       = p.NetworkLayer().NetworkFlow()
+		transport = p.TransportLayer().TransportFlow()
+	)
+	return &types.QUIC{
+		Timestamp:           utils.TimeToString(p.Metadata().Timestamp),
+		Version:             quic.VersionName(h.Version),
+		DCID:                hex.EncodeToString(h.DCID),
+		SCID:                hex.EncodeToString(h.SCID),
+		TokenLength:         int32(len(h.Token)),
+		SNI:                 hello.SNI,
+		ALPNs:               hello.ALPNs,
+		Ja3:                 ja3.DigestHex(&hello),
+		CipherSuites:        cipherSuites,
+		Extensions:          extensions,
+		SupportedGroups:     supportedGroups,
+		TransportParameters: params,
+		SrcIP:               net.Src().String(),
+		DstIP:               net.Dst().String(),
+		SrcPort:             int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+		DstPort:             int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+		ConnUID:             calcMd5(newConnectionID(p).String()),
+	}
+}
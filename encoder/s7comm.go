@@ -0,0 +1,218 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/s7"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+// maximum size of a S7 PDU that is joined from several COTP data TPDUs
+const maxS7PDUSize = 64 * 1024
+
+// set in postinit, nil if the S7Comm encoder is not active
+var s7CommEncoderInstance *CustomEncoder
+
+var s7CommEncoder = CreateCustomEncoder(types.Type_NC_S7Comm, "S7Comm", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for ISO-TSAP and ensure TCP stream reassembly is enabled
+
+	s7CommEncoderInstance = e
+	streamDecoders[s7.Port] = newS7Decoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// S7comm is decoded after stream reassembly
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// s7Decoder splits a TCP stream into TPKT packets and joins the COTP data TPDUs of a S7 PDU
+type s7Decoder struct {
+	parent *tcpStream
+
+	// incomplete TPKT packets
+	client []byte
+	server []byte
+
+	// data TPDUs without the end of TSDU mark
+	clientPDU []byte
+	serverPDU []byte
+
+	// set if the stream does not contain TPKT packets
+	invalid bool
+}
+
+func newS7Decoder(t *tcpStream) streamDecoder {
+	return &s7Decoder{parent: t}
+}
+
+func (d *s7Decoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.invalid {
+		return
+	}
+
+	buf, pdu := &d.server, &d.serverPDU
+	if client {
+		buf, pdu = &d.client, &d.clientPDU
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		length, ok, err := s7.FrameLength(*buf)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			d.invalid = true
+			d.client, d.server, d.clientPDU, d.serverPDU = nil, nil, nil, nil
+			return
+		}
+		if !ok || len(*buf) < length {
+			break
+		}
+
+		c, err := s7.ParseCOTP((*buf)[:length])
+		*buf = (*buf)[length:]
+		if err != nil {
+			errorMap.Inc(err.Error())
+			continue
+		}
+		if c.Type != s7.COTPData {
+			d.write(client, c, nil, ts)
+			continue
+		}
+		if len(*pdu)+len(c.Data) > maxS7PDUSize {
+			errorMap.Inc("S7: PDU too large")
+			*pdu = nil
+			continue
+		}
+		*pdu = append(*pdu, c.Data...)
+		if c.EOT {
+			d.write(client, c, *pdu, ts)
+			*pdu = nil
+		}
+	}
+
+	// release the memory once all packets have been processed
+	if len(*buf) == 0 {
+		*buf = nil
+	}
+}
+
+func (d *s7Decoder) close() {}
+
+// write creates the audit record for a COTP TPDU,
+// data contains the joined user data of data TPDUs
+func (d *s7Decoder) write(client bool, c *s7.COTP, data []byte, ts time.Time) {
+
+	if s7CommEncoderInstance == nil {
+		return
+	}
+
+	net, transport := d.parent.clientFlows()
+	if !client {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+
+	s := &types.S7Comm{
+		Timestamp: utils.TimeToString(ts),
+		COTPType:  s7.COTPTypeName(c.Type),
+		SrcIP:     net.Src().String(),
+		DstIP:     net.Dst().String(),
+		SrcPort:   int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+		DstPort:   int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+		ConnUID:   d.parent.connUID,
+	}
+
+	switch c.Type {
+	case s7.COTPConnectRequest, s7.COTPConnectConfirm:
+		s.SrcTSAP = s7.TSAPString(c.SrcTSAP)
+		s.DstTSAP = s7.TSAPString(c.DstTSAP)
+	case s7.COTPData:
+		if len(data) > 0 {
+			s.ProtocolID = int32(data[0])
+		}
+		// S7comm-plus is only recorded with its protocol ID
+		if s.ProtocolID == s7.ProtocolS7 {
+			m, err := s7.Parse(data)
+			if err != nil {
+				errorMap.Inc(err.Error())
+			} else {
+				setS7Message(s, m)
+			}
+		}
+	}
+
+	// export metrics if configured
+	if s7CommEncoderInstance.export {
+		s.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&s7CommEncoderInstance.numRecords, 1)
+	err := s7CommEncoderInstance.writer.Write(s)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(s)
+}
+
+// setS7Message adds the decoded S7 PDU to the audit record
+func setS7Message(s *types.S7Comm, m *s7.Message) {
+
+	s.ROSCTR = s7.ROSCTRName(m.ROSCTR)
+	s.PDUReference = int32(m.PDUReference)
+	s.ErrorClass = int32(m.ErrorClass)
+	s.ErrorCode = int32(m.ErrorCode)
+
+	if m.ROSCTR == s7.ROSCTRUserdata {
+		s.UserdataGroup = s7.UserdataGroupName(m.UserdataGroup)
+		s.UserdataSubfunction = int32(m.UserdataSubfunction)
+		return
+	}
+
+	// acknowledgements without data have no parameters
+	if m.ROSCTR != s7.ROSCTRAck {
+		s.FunctionCode = int32(m.Function)
+		s.FunctionName = s7.FunctionName(m.Function)
+	}
+	s.Block = m.Block
+	s.PIService = m.PIService
+	s.PDULength = int32(m.PDULength)
+	s.Control = m.IsControl()
+
+	for _, i := range m.Items {
+		s.Items = append(s.Items, &types.S7Item{
+			Area:          s7.AreaName(i.Area),
+			DBNumber:      int32(i.DBNumber),
+			Address:       i.String(),
+			TransportSize: s7.TransportSizeName(i.TransportSize),
+			Length:        int32(i.Length),
+		})
+	}
+	for _, c := range m.ReturnCodes {
+		s.ReturnCodes = append(s.ReturnCodes, int32(c))
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/scan"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagScanWindow = flag.Int("scan-window", 60, "time window in seconds for the scan detection, a scan ends after the source has been idle for this duration")
+	flagScanPorts  = flag.Int("scan-ports", 20, "number of distinct ports probed on a single host within the scan window to report a vertical scan")
+	flagScanHosts  = flag.Int("scan-hosts", 20, "number of distinct hosts probed within the scan window to report a horizontal sweep")
+)
+
+// number of processed packets after which idle scans are removed
+const scanSweepInterval = 10000
+
+var (
+	scanEncoderInstance *CustomEncoder
+	scanDetector        *scan.Detector
+
+	// latest packet timestamp, used to expire idle scans
+	scanNow time.Time
+
+	scanPackets int64
+	scanMu      sync.Mutex
+)
+
+var scanEncoder = CreateCustomEncoder(types.Type_NC_ScanEvent, "ScanEvent", func(e *CustomEncoder) error {
+	scanEncoderInstance = e
+	scanDetector = scan.NewDetector(time.Duration(*flagScanWindow)*time.Second, *flagScanPorts, *flagScanHosts)
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+
+	nl := p.NetworkLayer()
+	if nl == nil {
+		return nil
+	}
+
+	var (
+		ts       = p.Metadata().Timestamp
+		src, dst = nl.NetworkFlow().Endpoints()
+	)
+
+	scanMu.Lock()
+	if ts.After(scanNow) {
+		scanNow = ts
+	}
+	scanPackets++
+	if scanPackets%scanSweepInterval == 0 {
+		writeScanEvents(scanDetector.Expire(scanNow))
+	}
+	scanMu.Unlock()
+
+	if l := p.Layer(layers.LayerTypeTCP); l != nil {
+		tcp := l.(*layers.TCP)
+
+		// SYN+ACK and RST are responses to probes
+		if (tcp.SYN && tcp.ACK) || tcp.RST {
+			for _, t := range []string{scan.SYN, scan.FIN, scan.NULL, scan.Xmas} {
+				scanDetector.Answer(dst.String(), t, src.String(), uint16(tcp.SrcPort), tcp.SYN)
+			}
+			return nil
+		}
+		if tcp.ACK {
+			return nil
+		}
+
+		var scanType string
+		switch {
+		case tcp.SYN && !tcp.FIN:
+			scanType = scan.SYN
+		case tcp.FIN && tcp.PSH && tcp.URG:
+			scanType = scan.Xmas
+		case tcp.FIN && !tcp.PSH && !tcp.URG:
+			scanType = scan.FIN
+		case !tcp.FIN && !tcp.SYN && !tcp.PSH && !tcp.URG:
+			scanType = scan.NULL
+		default:
+			return nil
+		}
+		scanDetector.Probe(ts, src.String(), scanType, dst.String(), uint16(tcp.DstPort), true)
+
+	} else if l := p.Layer(layers.LayerTypeUDP); l != nil {
+		udp := l.(*layers.UDP)
+
+		// datagrams in response to a probe are not counted as probes themselves
+		if scanDetector.Answer(dst.String(), scan.UDP, src.String(), uint16(udp.SrcPort), true) {
+			return nil
+		}
+		// packets are processed concurrently, so a response may be seen before its request
+		// treat datagrams from a well known port to an ephemeral port as responses as well
+		if udp.SrcPort < 1024 && udp.DstPort >= 1024 {
+			return nil
+		}
+		scanDetector.Probe(ts, src.String(), scan.UDP, dst.String(), uint16(udp.DstPort), true)
+
+	} else if l := p.Layer(layers.LayerTypeICMPv4); l != nil {
+		switch l.(*layers.ICMPv4).TypeCode.Type() {
+		case layers.ICMPv4TypeEchoRequest:
+			scanDetector.Probe(ts, src.String(), scan.ICMP, dst.String(), 0, false)
+		case layers.ICMPv4TypeEchoReply:
+			scanDetector.Answer(dst.String(), scan.ICMP, src.String(), 0, false)
+		}
+	} else if l := p.Layer(layers.LayerTypeICMPv6); l != nil {
+		switch l.(*layers.ICMPv6).TypeCode.Type() {
+		case layers.ICMPv6TypeEchoRequest:
+			scanDetector.Probe(ts, src.String(), scan.ICMP, dst.String(), 0, false)
+		case layers.ICMPv6TypeEchoReply:
+			scanDetector.Answer(dst.String(), scan.ICMP, src.String(), 0, false)
+		}
+	}
+
+	// records are written once a scan has ended
+	return nil
+}, func(e *CustomEncoder) error {
+
+	// deinit:
+	// write the remaining scans
+
+	writeScanEvents(scanDetector.Flush())
+
+	return nil
+})
+
+func writeScanEvents(events []*types.ScanEvent) {
+	for _, e := range events {
+
+		// export metrics if configured
+		if scanEncoderInstance.export {
+			e.Inc()
+		}
+
+		// write record to disk
+		atomic.AddInt64(&scanEncoderInstance.numRecords, 1)
+		err := scanEncoderInstance.writer.Write(e)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+
+		evaluateRules(e)
+	}
+}
@@ -0,0 +1,530 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/netcap/smb"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// fsctlPipeTransceive is the IOCTL used for RPC calls over named pipes
+	fsctlPipeTransceive = 0x0011C017
+
+	// maximum size of a file reassembled from READ and WRITE data for file extraction
+	maxSMBFileSize = 64 * 1024 * 1024
+
+	// maximum number of unanswered requests and open files kept per connection
+	maxSMBPending = 1000
+	maxSMBFiles   = 1000
+)
+
+var (
+	// set in postinit, nil if the SMB encoder is not active
+	smbEncoderInstance *CustomEncoder
+
+	// file id used in related compound requests to refer to the file opened by the preceding CREATE
+	smbRelatedFileID = smb.FileID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+var smbEncoder = CreateCustomEncoder(types.Type_NC_SMB, "SMB", func(e *CustomEncoder) error {
+
+	// postinit:
+	// register the decoder for direct SMB over TCP and ensure TCP stream reassembly is enabled
+
+	smbEncoderInstance = e
+	streamDecoders[445] = newSMBDecoder
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+	// SMB is decoded after stream reassembly
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+type (
+	smbSession struct {
+		user   string
+		domain string
+		host   string
+	}
+
+	smbTreeKey struct {
+		session uint64
+		tree    uint32
+	}
+
+	smbTree struct {
+		path      string
+		shareType uint8
+	}
+
+	smbFile struct {
+		name  string
+		share string
+		pipe  bool
+
+		// data transferred with READ and WRITE, placed at the offsets of the operations.
+		// only collected if file extraction is enabled
+		data   []byte
+		ts     time.Time
+		upload bool
+	}
+
+	// smbRequest contains the state of a request until the server answered it
+	smbRequest struct {
+		ts     time.Time
+		header smb.Header
+
+		user, domain, host string
+
+		path          string
+		fileName      string
+		pipe          bool
+		desiredAccess uint32
+		disposition   uint32
+		offset        uint64
+		length        uint64
+		ctlCode       uint32
+
+		// file of a CREATE, READ or WRITE request and the data of a WRITE request
+		file *smbFile
+		data []byte
+	}
+
+	// smbDecoder keeps track of the sessions, trees and open files of a single TCP connection
+	smbDecoder struct {
+		parent *tcpStream
+
+		// incomplete messages
+		client []byte
+		server []byte
+
+		// set if the stream does not contain direct TCP transport framing
+		invalid bool
+
+		dialect  string
+		sessions map[uint64]*smbSession
+		trees    map[smbTreeKey]*smbTree
+		files    map[smb.FileID]*smbFile
+		pending  map[uint64]*smbRequest
+	}
+)
+
+func newSMBDecoder(t *tcpStream) streamDecoder {
+	return &smbDecoder{
+		parent:   t,
+		sessions: make(map[uint64]*smbSession),
+		trees:    make(map[smbTreeKey]*smbTree),
+		files:    make(map[smb.FileID]*smbFile),
+		pending:  make(map[uint64]*smbRequest),
+	}
+}
+
+// decode splits the stream into messages using the length from the transport header
+func (d *smbDecoder) decode(client bool, data []byte, ts time.Time) {
+
+	if d.invalid {
+		return
+	}
+
+	buf := &d.server
+	if client {
+		buf = &d.client
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		length, ok := smb.FrameLength(*buf)
+		if !ok {
+			break
+		}
+		if (*buf)[0] != 0 || length > smb.MaxMessageSize {
+			errorMap.Inc("smb: invalid transport header")
+			d.invalid = true
+			d.client, d.server = nil, nil
+			return
+		}
+		if len(*buf) < 4+length {
+			break
+		}
+		d.handle(client, (*buf)[4:4+length], ts)
+		*buf = (*buf)[4+length:]
+	}
+
+	// release the memory once all messages have been processed
+	if len(*buf) == 0 {
+		*buf = nil
+	}
+}
+
+// close writes the requests that have not been answered until the stream was closed
+// and extracts the files that have not been closed
+func (d *smbDecoder) close() {
+	for _, r := range d.pending {
+		d.write(r, nil)
+	}
+	d.pending = nil
+	for _, f := range d.files {
+		d.extract(f)
+	}
+	d.files = nil
+}
+
+func (d *smbDecoder) handle(client bool, data []byte, ts time.Time) {
+
+	msgs, err := smb.Decode(data)
+	if err != nil && err != smb.ErrProtocol {
+		// SMB1 messages are ignored, they are used by clients to negotiate SMB2 on older servers
+		errorMap.Inc(err.Error())
+	}
+
+	// name of the file opened by the last CREATE in a compound request
+	var related *smbFile
+
+	for _, m := range msgs {
+		if client && !m.IsResponse() {
+			related = d.request(m, ts, related)
+		} else if !client && m.IsResponse() {
+			d.response(m, ts)
+		}
+	}
+}
+
+// request stores the information required for the audit record until the server answers
+func (d *smbDecoder) request(m *smb.Message, ts time.Time, related *smbFile) *smbFile {
+
+	r := &smbRequest{
+		ts:     ts,
+		header: m.Header,
+	}
+	if t, ok := d.trees[smbTreeKey{m.SessionID, m.TreeID}]; ok {
+		r.path = t.path
+		r.pipe = t.shareType == smb.ShareTypePipe
+	}
+
+	// file returns the name of an open file, or the one opened by the preceding request of the compound
+	file := func(id smb.FileID) {
+		f := d.files[id]
+		if id == smbRelatedFileID && m.IsRelated() {
+			f = related
+		}
+		if f != nil {
+			r.fileName = f.name
+			r.pipe = f.pipe
+			r.file = f
+		}
+	}
+
+	switch m.Command {
+	case smb.CommandNegotiate:
+	case smb.CommandSessionSetup:
+		req, err := smb.ParseSessionSetupRequest(m)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			return related
+		}
+		if msg := req.NTLM(); msg != nil {
+			r.user = msg.User
+			r.domain = msg.Domain
+			r.host = msg.Workstation
+			d.parent.writeNTLM(msg, utils.TimeToString(ts), "SMB", true)
+		}
+	case smb.CommandTreeConnect:
+		path, err := smb.ParseTreeConnectRequest(m)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			return related
+		}
+		r.path = path
+	case smb.CommandCreate:
+		req, err := smb.ParseCreateRequest(m)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			return related
+		}
+		r.fileName = req.Name
+		r.desiredAccess = req.DesiredAccess
+		r.disposition = req.CreateDisposition
+		related = &smbFile{name: r.fileName, share: r.path, pipe: r.pipe}
+		r.file = related
+	case smb.CommandRead, smb.CommandWrite:
+		req, err := smb.ParseIORequest(m)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			return related
+		}
+		file(req.FileID)
+		r.offset = req.Offset
+		r.length = uint64(req.Length)
+		r.data = req.Data
+	case smb.CommandIoctl:
+		req, err := smb.ParseIoctlRequest(m)
+		if err != nil {
+			errorMap.Inc(err.Error())
+			return related
+		}
+		file(req.FileID)
+		r.ctlCode = req.CtlCode
+		if r.ctlCode == fsctlPipeTransceive {
+			r.pipe = true
+		}
+	case smb.CommandClose:
+		id, err := smb.ParseCloseRequest(m)
+		if err == nil {
+			if f, ok := d.files[id]; ok {
+				d.extract(f)
+			}
+			delete(d.files, id)
+		}
+		return related
+	default:
+		// no audit record for the remaining commands
+		return related
+	}
+
+	if _, ok := d.pending[m.MessageID]; !ok && len(d.pending) >= maxSMBPending {
+		errorMap.Inc("SMB: too many unanswered requests")
+		for _, p := range d.pending {
+			d.write(p, nil)
+		}
+		d.pending = make(map[uint64]*smbRequest)
+	}
+	d.pending[m.MessageID] = r
+	return related
+}
+
+// response completes the request with the results from the server and writes the audit record
+func (d *smbDecoder) response(m *smb.Message, ts time.Time) {
+
+	// the final response for an asynchronous operation follows later with the same message id
+	if m.Status == smb.StatusPending && m.IsAsync() {
+		return
+	}
+
+	r, ok := d.pending[m.MessageID]
+	if !ok {
+		return
+	}
+	delete(d.pending, m.MessageID)
+
+	var (
+		success = m.Status == smb.StatusSuccess
+		err     error
+	)
+
+	switch m.Command {
+	case smb.CommandNegotiate:
+		if success {
+			var res *smb.NegotiateResponse
+			if res, err = smb.ParseNegotiateResponse(m); err == nil {
+				d.dialect = smb.DialectName(res.Dialect)
+			}
+		}
+	case smb.CommandSessionSetup:
+		var res *smb.SessionSetupResponse
+		if res, err = smb.ParseSessionSetupResponse(m); err == nil {
+			if msg := res.NTLM(); msg != nil {
+				d.parent.writeNTLM(msg, utils.TimeToString(ts), "SMB", false)
+			}
+		}
+		// the authentication needs several round trips, only the last one is recorded
+		if m.Status == smb.StatusMoreProcessingRequired {
+			return
+		}
+		if success {
+			d.sessions[m.SessionID] = &smbSession{
+				user:   r.user,
+				domain: r.domain,
+				host:   r.host,
+			}
+		}
+	case smb.CommandTreeConnect:
+		if success {
+			var shareType uint8
+			if shareType, err = smb.ParseTreeConnectResponse(m); err == nil {
+				d.trees[smbTreeKey{m.SessionID, m.TreeID}] = &smbTree{
+					path:      r.path,
+					shareType: shareType,
+				}
+				r.pipe = shareType == smb.ShareTypePipe
+			}
+		}
+	case smb.CommandCreate:
+		r.length = 0
+		if success {
+			var res *smb.CreateResponse
+			if res, err = smb.ParseCreateResponse(m); err == nil {
+				r.file.pipe = r.pipe
+				if _, ok := d.files[res.FileID]; !ok && len(d.files) >= maxSMBFiles {
+					errorMap.Inc("SMB: too many open files")
+				} else {
+					d.files[res.FileID] = r.file
+				}
+				r.length = res.EndOfFile
+			}
+		}
+	case smb.CommandRead, smb.CommandWrite:
+		r.length = 0
+		if success || m.Status == smb.StatusBufferOverflow {
+			var res *smb.IOResponse
+			if res, err = smb.ParseIOResponse(m); err == nil {
+				r.length = uint64(res.Length)
+				if m.Command == smb.CommandRead {
+					d.collect(r.file, r.offset, res.Data, false, r.ts)
+				} else if uint64(len(r.data)) >= r.length {
+					d.collect(r.file, r.offset, r.data[:r.length], true, r.ts)
+				}
+			}
+		}
+	}
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	// use the session id assigned by the server
+	r.header.SessionID = m.SessionID
+	if m.Command == smb.CommandTreeConnect {
+		r.header.TreeID = m.TreeID
+	}
+
+	d.write(r, &m.Header)
+}
+
+// collect places the data of a READ or WRITE at its offset in the file,
+// if it overlaps or directly follows the data collected so far
+func (d *smbDecoder) collect(f *smbFile, offset uint64, data []byte, upload bool, ts time.Time) {
+
+	if fileEncoderInstance == nil || f == nil || f.pipe || len(data) == 0 {
+		return
+	}
+
+	// only contiguous data is collected, to avoid allocating the gaps up to large offsets
+	if offset > uint64(len(f.data)) {
+		errorMap.Inc("SMB: data at a gap in the file skipped for extraction")
+		return
+	}
+	end := offset + uint64(len(data))
+	if end > maxSMBFileSize {
+		errorMap.Inc("SMB: file exceeds the size limit for extraction")
+		return
+	}
+	if len(f.data) == 0 {
+		f.ts = ts
+	}
+	if uint64(len(f.data)) < end {
+		f.data = append(f.data[:offset], data...)
+	} else {
+		copy(f.data[offset:], data)
+	}
+	if upload {
+		f.upload = true
+	}
+}
+
+// extract hands the data transferred for the file to the file extraction
+func (d *smbDecoder) extract(f *smbFile) {
+
+	if len(f.data) == 0 {
+		return
+	}
+
+	source := "SMBDownload"
+	if f.upload {
+		source = "SMBUpload"
+	}
+
+	// names are relative to the share and use backslashes as separator
+	name := f.name
+	if i := strings.LastIndexByte(name, '\\'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	file := d.parent.newFile(utils.TimeToString(f.ts), source, f.share, name, "", f.upload)
+	file.Protocol = "SMB"
+	extractFile(file, f.data)
+
+	f.data = nil
+}
+
+// write creates the audit record for a request, res is nil if the request was not answered
+func (d *smbDecoder) write(r *smbRequest, res *smb.Header) {
+
+	if smbEncoderInstance == nil {
+		return
+	}
+
+	var (
+		net, transport = d.parent.clientFlows()
+		s              = &types.SMB{
+			Timestamp:   utils.TimeToString(r.ts),
+			Command:     smb.CommandName(r.header.Command),
+			Dialect:     d.dialect,
+			SessionID:   r.header.SessionID,
+			TreeID:      r.header.TreeID,
+			MessageID:   r.header.MessageID,
+			User:        r.user,
+			Domain:      r.domain,
+			Host:        r.host,
+			Share:       r.path,
+			FileName:    r.fileName,
+			AccessMask:  r.desiredAccess,
+			Disposition: r.disposition,
+			Offset:      r.offset,
+			Length:      r.length,
+			IsPipe:      r.pipe,
+			CtlCode:     r.ctlCode,
+			SrcIP:       net.Src().String(),
+			DstIP:       net.Dst().String(),
+			SrcPort:     int32(binary.BigEndian.Uint16(transport.Src().Raw())),
+			DstPort:     int32(binary.BigEndian.Uint16(transport.Dst().Raw())),
+			ConnUID:     d.parent.connUID,
+		}
+	)
+	if res != nil {
+		s.Status = smb.StatusName(res.Status)
+	}
+	if sess, ok := d.sessions[r.header.SessionID]; ok && r.header.Command != smb.CommandSessionSetup {
+		s.User = sess.user
+		s.Domain = sess.domain
+		s.Host = sess.host
+	}
+	if t, ok := d.trees[smbTreeKey{r.header.SessionID, r.header.TreeID}]; ok {
+		s.ShareType = smb.ShareTypeName(t.shareType)
+	}
+
+	// export metrics if configured
+	if smbEncoderInstance.export {
+		s.Inc()
+	}
+
+	// write record to disk
+	atomic.AddInt64(&smbEncoderInstance.numRecords, 1)
+	err := smbEncoderInstance.writer.Write(s)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+
+	evaluateRules(s)
+}
@@ -0,0 +1,242 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package encoder
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dreadl0ck/netcap/mail"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// smtpDecoder decodes the commands and replies of a SMTP session and the messages sent with DATA or BDAT
+type smtpDecoder struct {
+	mailSession
+
+	// commands waiting for a reply, in the order they have been sent
+	pending []*types.MailCommand
+
+	// lines of a multiline reply
+	reply []string
+
+	envelopeFrom string
+	envelopeTo   []string
+
+	// set while the client sends a message after DATA
+	data bool
+
+	// remaining bytes of a BDAT chunk and if it is the last chunk of the message
+	chunk     int
+	lastChunk bool
+
+	message        []byte
+	messageTs      time.Time
+	messageCommand string
+}
+
+func newSMTPDecoder(t *tcpStream) streamDecoder {
+	return &smtpDecoder{mailSession: newMailSession(t, "SMTP")}
+}
+
+func (d *smtpDecoder) decode(client bool, data []byte, ts time.Time) {
+	d.feed(client, data, ts, d.next)
+}
+
+func (d *smtpDecoder) close() {
+	d.writeSession()
+}
+
+func (d *smtpDecoder) next(client bool, buf []byte, ts time.Time) int {
+
+	if client && d.chunk > 0 {
+		n := d.chunk
+		if n > len(buf) {
+			n = len(buf)
+		}
+		d.appendMessage(buf[:n])
+		d.chunk -= n
+		if d.chunk == 0 && d.lastChunk {
+			d.finish()
+		}
+		return n
+	}
+
+	l := mailLine(buf)
+	if l == nil {
+		return 0
+	}
+
+	if client {
+		d.clientLine(l, ts)
+	} else {
+		d.serverLine(l)
+	}
+	return len(l)
+}
+
+func (d *smtpDecoder) clientLine(l []byte, ts time.Time) {
+
+	if d.data {
+		if mail.IsTerminator(l) {
+			d.data = false
+			d.finish()
+		} else {
+			d.appendMessage(mail.Unstuff(l))
+		}
+		return
+	}
+
+	if d.continuation {
+		d.continuation = false
+		d.authResponse(string(l))
+		return
+	}
+
+	verb, arg := mail.ParseCommand(string(l))
+	switch verb {
+	case "HELO", "EHLO":
+		d.session.Hostname = csvField(arg)
+	case "MAIL":
+		d.envelopeFrom = mail.Path(arg)
+		d.envelopeTo = nil
+	case "RCPT":
+		d.envelopeTo = append(d.envelopeTo, mail.Path(arg))
+	case "DATA":
+		d.data = true
+		d.startMessage(verb, ts)
+	case "BDAT":
+		fields := strings.Fields(arg)
+		if len(fields) > 0 {
+			n, err := strconv.Atoi(fields[0])
+			if err == nil && n >= 0 {
+				if d.message == nil {
+					d.startMessage(verb, ts)
+				}
+				d.chunk = n
+				d.lastChunk = len(fields) > 1 && strings.EqualFold(fields[1], "LAST")
+				if n == 0 && d.lastChunk {
+					d.finish()
+				}
+			}
+		}
+	case "AUTH":
+		// the initial response is omitted from the argument
+		mechanism, response := splitAuth(arg)
+		arg = mechanism
+		d.auth = mechanism
+		d.session.AuthMechanism = mechanism
+		if response != "" && response != "=" {
+			d.authResponse(response)
+		}
+	case "RSET":
+		d.envelopeFrom = ""
+		d.envelopeTo = nil
+		d.message = nil
+	}
+
+	if len(d.pending) < maxMailCommands {
+		d.pending = append(d.pending, d.command(ts, verb, arg))
+	}
+}
+
+func (d *smtpDecoder) serverLine(l []byte) {
+
+	code, text, last, ok := mail.ParseReply(string(l))
+	if !ok {
+		return
+	}
+	if !last {
+		if len(d.reply) < maxMailCommands {
+			d.reply = append(d.reply, text)
+		}
+		return
+	}
+	lines := append(d.reply, text)
+	d.reply = nil
+
+	if len(d.pending) == 0 {
+		// greeting
+		if d.session.Banner == "" {
+			d.session.Banner = csvField(lines[0])
+		}
+		return
+	}
+
+	// intermediate replies for DATA and AUTH do not complete the command
+	if code >= 300 && code < 400 {
+		if code == 334 {
+			d.continuation = true
+		}
+		return
+	}
+
+	c := d.pending[0]
+	d.pending = d.pending[1:]
+	c.Status = strconv.Itoa(code)
+
+	switch c.Command {
+	case "EHLO":
+		for _, l := range lines[1:] {
+			if m := mail.Mechanisms(l); len(m) > 0 {
+				d.session.AuthMechanisms = m
+			}
+		}
+	case "DATA":
+		// the server rejected the message before it was sent
+		if d.data && len(d.message) == 0 {
+			d.data = false
+			d.message = nil
+		}
+	case "AUTH":
+		d.auth = ""
+		d.continuation = false
+	case "STARTTLS":
+		if code == 220 {
+			d.session.StartTLS = true
+			d.done = true
+		}
+	}
+}
+
+func (d *smtpDecoder) startMessage(command string, ts time.Time) {
+	d.message = []byte{}
+	d.messageTs = ts
+	d.messageCommand = command
+}
+
+func (d *smtpDecoder) appendMessage(data []byte) {
+	if d.message != nil && d.collectMessages() {
+		d.message = appendMessage(d.message, data)
+	}
+}
+
+func (d *smtpDecoder) finish() {
+	d.writeMail(d.message, d.messageCommand, true, d.messageTs, d.envelopeFrom, d.envelopeTo)
+	d.message = nil
+	d.envelopeFrom = ""
+	d.envelopeTo = nil
+}
+
+// splitAuth splits the argument of an authentication command into the upper case mechanism and the initial response
+func splitAuth(arg string) (mechanism, response string) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	if len(fields) > 1 {
+		response = fields[1]
+	}
+	return strings.ToUpper(fields[0]), response
+}
@@ -23,12 +23,9 @@ import (
 var tcpEncoder = CreateLayerEncoder(types.Type_NC_TCP, layers.LayerTypeTCP, func(layer gopacket.Layer, timestamp string) proto.Message {
 	if tcp, ok := layer.(*layers.TCP); ok {
 		var (
-			opts    []*types.TCPOption
-			payload []byte
+			opts          []*types.TCPOption
+			payload, hash = capturePayload("TCP", layer.LayerPayload())
 		)
-		if CapturePayload {
-			payload = layer.LayerPayload()
-		}
 		for _, o := range tcp.Options {
 			opts = append(opts, &types.TCPOption{
 				OptionData:   o.OptionData,
@@ -60,6 +57,7 @@ var tcpEncoder = CreateLayerEncoder(types.Type_NC_TCP, layers.LayerTypeTCP, func
 			PayloadEntropy: Entropy(tcp.Payload),
 			PayloadSize:    int32(len(tcp.Payload)),
 			Payload:        payload,
+			PayloadHash:    hash,
 		}
 	}
 	return nil
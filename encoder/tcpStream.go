@@ -49,13 +49,18 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/dreadl0ck/gopacket"
 	"github.com/dreadl0ck/gopacket/layers"
 	"github.com/dreadl0ck/gopacket/reassembly"
+	"github.com/dreadl0ck/netcap/modbus"
+	"github.com/dreadl0ck/netcap/ntlm"
+	"github.com/dreadl0ck/netcap/utils"
 )
 
 // flags
@@ -106,6 +111,70 @@ var reassemblyStats struct {
 	overlapPackets      int
 }
 
+/*
+ * Application layer decoders
+ */
+
+// streamDecoder decodes the reassembled data of an application layer protocol
+// data is passed in the order it was reassembled, client indicates the direction
+type streamDecoder interface {
+	decode(client bool, data []byte, ts time.Time)
+	close()
+}
+
+// streamDecoderFactory creates a decoder for a new stream
+type streamDecoderFactory func(t *tcpStream) streamDecoder
+
+// streamDecoders maps the server ports to the decoders for their protocol
+// encoders register their decoders in postinit, before any packets are processed
+var streamDecoders = make(map[layers.TCPPort]streamDecoderFactory)
+
+// maximum number of announced streams that wait for their connection
+const maxExpectedStreams = 10000
+
+var (
+	// expectedStreams maps the listening address of announced streams to the decoders for their protocol,
+	// for protocols that negotiate the port of a connection dynamically, e.g. FTP data connections
+	expectedStreams   = make(map[string]streamDecoderFactory)
+	expectedStreamsMu sync.Mutex
+)
+
+// streamAddr returns the key of an address in the expected streams
+func streamAddr(ip string, port int) string {
+	return net.JoinHostPort(ip, strconv.Itoa(port))
+}
+
+// expectStream registers the decoder for the next stream to the address
+func expectStream(addr string, f streamDecoderFactory) {
+	expectedStreamsMu.Lock()
+	if len(expectedStreams) < maxExpectedStreams {
+		expectedStreams[addr] = f
+	}
+	expectedStreamsMu.Unlock()
+}
+
+// cancelExpectedStream removes an announced stream that has not been opened
+func cancelExpectedStream(addr string) {
+	expectedStreamsMu.Lock()
+	delete(expectedStreams, addr)
+	expectedStreamsMu.Unlock()
+}
+
+// takeExpectedStream returns and removes the decoder for a stream to the address,
+// or nil if no stream has been announced
+func takeExpectedStream(addr string) streamDecoderFactory {
+	expectedStreamsMu.Lock()
+	defer expectedStreamsMu.Unlock()
+	if len(expectedStreams) == 0 {
+		return nil
+	}
+	f, ok := expectedStreams[addr]
+	if ok {
+		delete(expectedStreams, addr)
+	}
+	return f
+}
+
 /*
  * The TCP factory: returns a new Stream
  */
@@ -118,8 +187,14 @@ type tcpStreamFactory struct {
 func (factory *tcpStreamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
 
 	logDebug("* NEW: %s %s\n", net, transport)
+
+	// Modbus TCP connections are long lived and usually established before the capture started,
+	// so they are decoded without the handshake
+	_, modbusActive := streamDecoders[modbus.Port]
+	forceStart := modbusActive && (tcp.SrcPort == modbus.Port || tcp.DstPort == modbus.Port)
+
 	fsmOptions := reassembly.TCPSimpleFSMOptions{
-		SupportMissingEstablishment: *allowmissinginit,
+		SupportMissingEstablishment: *allowmissinginit || forceStart,
 	}
 
 	stream := &tcpStream{
@@ -132,18 +207,43 @@ func (factory *tcpStreamFactory) New(net, transport gopacket.Flow, tcp *layers.T
 		ident:       fmt.Sprintf("%s:%s", net, transport),
 		optchecker:  reassembly.NewTCPOptionCheck(),
 		firstPacket: ac.GetCaptureInfo().Timestamp,
+		forceStart:  forceStart,
+	}
+
+	// assemble the UID of the connection the stream belongs to
+	id := ConnectionID{
+		NetworkFlowID:   net.FastHash(),
+		TransportFlowID: transport.FastHash(),
+	}
+	if c, ok := ac.(*Context); ok {
+		id.LinkFlowID = c.linkFlowID
+	}
+	stream.connUID = calcMd5(id.String())
+
+	// announced streams take precedence over the decoders for well known ports
+	if f := takeExpectedStream(streamAddr(net.Dst().String(), int(tcp.DstPort))); f != nil {
+		stream.decoder = f(stream)
+	} else if f := takeExpectedStream(streamAddr(net.Src().String(), int(tcp.SrcPort))); f != nil {
+		stream.reversed = true
+		stream.decoder = f(stream)
+	} else if f, ok := streamDecoders[tcp.DstPort]; ok {
+		stream.decoder = f(stream)
+	} else if f, ok := streamDecoders[tcp.SrcPort]; ok {
+		stream.reversed = true
+		stream.decoder = f(stream)
 	}
 
 	if stream.isHTTP {
+		stream.requestParsed = sync.NewCond(&stream.Mutex)
 		stream.client = httpReader{
-			bytes:    make(chan []byte),
+			bytes:    make(chan *httpData),
 			ident:    fmt.Sprintf("%s %s", net, transport),
 			hexdump:  *hexdump,
 			parent:   stream,
 			isClient: true,
 		}
 		stream.server = httpReader{
-			bytes:   make(chan []byte),
+			bytes:   make(chan *httpData),
 			ident:   fmt.Sprintf("%s %s", net.Reverse(), transport.Reverse()),
 			hexdump: *hexdump,
 			parent:  stream,
@@ -164,6 +264,9 @@ func (factory *tcpStreamFactory) WaitGoRoutines() {
 // Context is the assembler context
 type Context struct {
 	CaptureInfo gopacket.CaptureInfo
+
+	// hash of the link flow, used for the connection UID
+	linkFlowID uint64
 }
 
 // GetCaptureInfo returns the gopacket.CaptureInfo from the context
@@ -187,21 +290,44 @@ type tcpStream struct {
 	isHTTP   bool
 	reversed bool
 	ident    string
+	connUID  string
+
+	// set if the stream is started with the first packet when the handshake was not captured
+	forceStart bool
 
 	client httpReader
 	server httpReader
 
+	// decoder for the application layer protocol, if registered for the port
+	decoder streamDecoder
+
 	firstPacket time.Time
 
 	requests  []*http.Request
 	responses []*http.Response
 
+	// stream data collected for YARA scanning
+	yaraClient []byte
+	yaraServer []byte
+
 	// if set, indicates that either client or server http reader was closed already
 	last bool
 
+	// signaled when a request has been parsed or the client reader waits for more data,
+	// uses the lock of the stream
+	requestParsed *sync.Cond
+
 	sync.Mutex
 }
 
+// clientFlows returns the network and transport flows in the direction from client to server
+func (t *tcpStream) clientFlows() (net, transport gopacket.Flow) {
+	if t.reversed {
+		return t.net.Reverse(), t.transport.Reverse()
+	}
+	return t.net, t.transport
+}
+
 func (t *tcpStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) bool {
 	// FSM
 	if !t.tcpstate.CheckState(tcp, dir) {
@@ -215,6 +341,11 @@ func (t *tcpStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassem
 			return false
 		}
 	}
+	// start both directions with their first packet, instead of waiting for a SYN until the stream is flushed,
+	// to deliver the data in capture order
+	if t.forceStart && nextSeq == -1 {
+		*start = true
+	}
 	// Options
 	err := t.optchecker.Accept(tcp, ci, dir, nextSeq, start)
 	if err != nil {
@@ -281,61 +412,66 @@ func (t *tcpStream) ReassembledSG(sg reassembly.ScatterGather, ac reassembly.Ass
 	}
 
 	logDebug("%s: SG reassembled packet with %d bytes (start:%v,end:%v,skip:%d,saved:%d,nb:%d,%d,overlap:%d,%d)\n", ident, length, start, end, skip, saved, sgStats.Packets, sgStats.Chunks, sgStats.OverlapBytes, sgStats.OverlapPackets)
-	if skip == -1 && *allowmissinginit {
+	if skip == -1 && (*allowmissinginit || t.forceStart) {
 		// this is allowed
 	} else if skip != 0 {
 		// Missing bytes in stream: do not even try to parse it
 		return
 	}
 
-	// TODO add types and call handler to allow decoding several app layer protocols
 	data := sg.Fetch(length)
-	if t.isDNS {
-		var (
-			dns     = &layers.DNS{}
-			decoded []gopacket.LayerType
-		)
-		if len(data) < 2 {
-			if len(data) > 0 {
-				sg.KeepFrom(0)
-			}
-			return
-		}
-
-		var (
-			dnsSize = binary.BigEndian.Uint16(data[:2])
-			missing = int(dnsSize) - len(data[2:])
-		)
 
-		logDebug("dnsSize: %d, missing: %d\n", dnsSize, missing)
-
-		if missing > 0 {
-			logInfo("Missing some bytes: %d\n", missing)
-			sg.KeepFrom(0)
-			return
-		}
+	// DNS data might be delivered again after KeepFrom, so it is excluded here.
+	// HTTP bodies are scanned by the HTTP readers, scanning the stream as well would report each match twice
+	if yaraRules != nil && !t.isDNS && !t.isHTTP {
+		t.collectYARA(dir, data)
+	}
 
-		var (
-			p   = gopacket.NewDecodingLayerParser(layers.LayerTypeDNS, dns)
-			err = p.DecodeLayers(data[2:], &decoded)
-		)
-		if err != nil {
-			logError("DNS-parser", "Failed to decode DNS: %v\n", err)
-		} else {
-			logDebug("DNS: %s\n", gopacket.LayerDump(dns))
+	if t.isDNS {
+		// a segment can contain several length prefixed messages,
+		// incomplete data is kept and delivered again with the next segment
+		var offset int
+		for len(data)-offset >= 2 {
+			dnsSize := int(binary.BigEndian.Uint16(data[offset : offset+2]))
+			if missing := offset + 2 + dnsSize - len(data); missing > 0 {
+				logDebug("dnsSize: %d, missing: %d\n", dnsSize, missing)
+				break
+			}
+			t.decodeDNS(data[offset+2:offset+2+dnsSize], ac.GetCaptureInfo().Timestamp, (dir == reassembly.TCPDirClientToServer) != t.reversed)
+			offset += 2 + dnsSize
 		}
-		if len(data) > 2+int(dnsSize) {
-			sg.KeepFrom(2 + int(dnsSize))
+		if offset < len(data) {
+			sg.KeepFrom(offset)
 		}
 	} else if t.isHTTP {
 		if length > 0 {
 			if *hexdump {
 				logDebug("Feeding http with:\n%s", hex.Dump(data))
 			}
+			d := &httpData{
+				raw:       data,
+				timestamp: ac.GetCaptureInfo().Timestamp,
+			}
 			if dir == reassembly.TCPDirClientToServer && !t.reversed {
-				t.client.bytes <- data
+				// count the chunk before handing it over,
+				// so that a waiting response reader knows the client has data left to parse
+				t.Lock()
+				t.client.sent++
+				t.Unlock()
+				t.client.bytes <- d
 			} else {
-				t.server.bytes <- data
+				t.server.bytes <- d
+			}
+		}
+	} else if t.decoder != nil {
+		if length > 0 {
+			t.decoder.decode((dir == reassembly.TCPDirClientToServer) != t.reversed, data, ac.GetCaptureInfo().Timestamp)
+		}
+	} else if ntlmEncoderInstance != nil {
+		// search for NTLMSSP messages in protocols without a decoder, e.g. DCE/RPC or LDAP
+		if raw := ntlm.Find(data); raw != nil {
+			if msg, err := ntlm.Parse(raw); err == nil {
+				t.writeNTLM(msg, utils.TimeToString(ac.GetCaptureInfo().Timestamp), "TCP", (dir == reassembly.TCPDirClientToServer) != t.reversed)
 			}
 		}
 	}
@@ -343,6 +479,12 @@ func (t *tcpStream) ReassembledSG(sg reassembly.ScatterGather, ac reassembly.Ass
 
 func (t *tcpStream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
 	logDebug("%s: Connection closed\n", t.ident)
+	if yaraRules != nil {
+		t.scanYARA()
+	}
+	if t.decoder != nil {
+		t.decoder.close()
+	}
 	if t.isHTTP {
 		// closing here causes a panic sometimes because the channel is already closed
 		// as a temporary bugfix, closing the channels was omitted.
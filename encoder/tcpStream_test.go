@@ -0,0 +1,153 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/gopacket/reassembly"
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// testSG delivers reassembled data to a stream and records the offset passed to KeepFrom
+type testSG struct {
+	data     []byte
+	keepFrom int
+}
+
+func (sg *testSG) Lengths() (int, int) {
+	return len(sg.data), 0
+}
+
+func (sg *testSG) Fetch(length int) []byte {
+	return sg.data[:length]
+}
+
+func (sg *testSG) KeepFrom(offset int) {
+	sg.keepFrom = offset
+}
+
+func (sg *testSG) CaptureInfo(offset int) gopacket.CaptureInfo {
+	return gopacket.CaptureInfo{}
+}
+
+func (sg *testSG) Info() (reassembly.TCPFlowDirection, bool, bool, int) {
+	return reassembly.TCPDirClientToServer, true, false, 0
+}
+
+func (sg *testSG) Stats() reassembly.TCPAssemblyStats {
+	return reassembly.TCPAssemblyStats{}
+}
+
+// dnsOverTCP serializes a DNS query with a length prefix
+func dnsOverTCP(t *testing.T, id uint16) []byte {
+	var (
+		dns = &layers.DNS{
+			ID:      id,
+			RD:      true,
+			QDCount: 1,
+			Questions: []layers.DNSQuestion{{
+				Name:  []byte("example.com"),
+				Type:  layers.DNSTypeA,
+				Class: layers.DNSClassIN,
+			}},
+		}
+		buf = gopacket.NewSerializeBuffer()
+	)
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, 2, 2+len(buf.Bytes()))
+	binary.BigEndian.PutUint16(msg, uint16(len(buf.Bytes())))
+	return append(msg, buf.Bytes()...)
+}
+
+func TestDNSOverTCPSegments(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "netcap-dns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(w *netcap.Writer) {
+		dnsEncoder.writer = w
+	}(dnsEncoder.writer)
+	dnsEncoder.writer = netcap.NewWriter("DNS", false, false, true, dir, false, 0)
+
+	var (
+		s = &tcpStream{
+			net:       gopacket.NewFlow(layers.EndpointIPv4, net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}),
+			transport: gopacket.NewFlow(layers.EndpointTCPPort, []byte{0x9c, 0x40}, []byte{0, 53}),
+			isDNS:     true,
+		}
+		ac    = &Context{CaptureInfo: gopacket.CaptureInfo{Timestamp: time.Unix(1500000000, 0)}}
+		first = dnsOverTCP(t, 1)
+		third = dnsOverTCP(t, 3)
+	)
+
+	// two complete messages and the start of the third one
+	sg := &testSG{data: append(append(append([]byte{}, first...), dnsOverTCP(t, 2)...), third[:5]...)}
+	sg.keepFrom = -1
+	s.ReassembledSG(sg, ac)
+	if sg.keepFrom != 2*len(first) {
+		t.Fatalf("expected the incomplete message to be kept from offset %d, got %d", 2*len(first), sg.keepFrom)
+	}
+
+	// the kept data is delivered again along with the rest of the message
+	sg = &testSG{data: third, keepFrom: -1}
+	s.ReassembledSG(sg, ac)
+	if sg.keepFrom != -1 {
+		t.Fatal("nothing should be kept after a complete message, got offset", sg.keepFrom)
+	}
+
+	// a single byte of the length prefix is kept as well
+	sg = &testSG{data: third[:1], keepFrom: -1}
+	s.ReassembledSG(sg, ac)
+	if sg.keepFrom != 0 {
+		t.Fatal("expected the partial length prefix to be kept, got offset", sg.keepFrom)
+	}
+
+	name, _ := dnsEncoder.writer.Close()
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		idIndex int
+		ids     []string
+	)
+	for i, f := range (types.DNS{}).CSVHeader() {
+		if f == "ID" {
+			idIndex = i
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		ids = append(ids, strings.Split(line, ",")[idIndex])
+	}
+	if strings.Join(ids, " ") != "1 2 3" {
+		t.Fatal("expected records for the messages 1, 2 and 3, got", ids)
+	}
+}
@@ -136,6 +136,8 @@ var tlsEncoder = CreateCustomEncoder(types.Type_NC_TLSClientHello, "TLS", nil, f
 					SrcPort:          int32(srcPort),
 					DstPort:          int32(dstPort),
 					Extensions:       extensions,
+					CommunityID:      CommunityID(p),
+					DstHostname:      dstHostname(p.NetworkLayer().NetworkFlow().Dst().String()),
 				}
 			}
 		}
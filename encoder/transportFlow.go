@@ -18,38 +18,38 @@ import (
 	"log"
 	"sort"
 	"strconv"
-	"sync"
 	"sync/atomic"
 
 	"github.com/dreadl0ck/netcap/types"
 	"github.com/dreadl0ck/netcap/utils"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/dreadl0ck/gopacket"
+	"github.com/golang/protobuf/proto"
 )
 
-type AtomicTransportFlowMap struct {
-	Items map[uint64]*types.TransportFlow
-	sync.Mutex
-}
-
 var (
-	TransportFlows = &AtomicTransportFlowMap{
-		Items: make(map[uint64]*types.TransportFlow),
-	}
+	// TransportFlows holds all active transport layer flows
+	TransportFlows               = NewFlowTable("TransportFlow", 0, 0, 0)
 	transportFlowEncoderInstance *CustomEncoder
-	transportFlows               int64
 )
 
 var transportFlowEncoder = CreateCustomEncoder(types.Type_NC_TransportFlow, "TransportFlow", func(d *CustomEncoder) error {
 	transportFlowEncoderInstance = d
+	TransportFlows = newFlowTable("TransportFlow")
 	return nil
 }, func(p gopacket.Packet) proto.Message {
 	if ll := p.TransportLayer(); ll != nil {
 
+		var (
+			id      = ll.TransportFlow().FastHash()
+			ts      = p.Metadata().Timestamp
+			expired []interface{}
+		)
+
 		// lookup flow
 		TransportFlows.Lock()
-		if flow, ok := TransportFlows.Items[ll.TransportFlow().FastHash()]; ok {
+		if f, ok := TransportFlows.Get(id, ts); ok {
+			flow := f.(*types.TransportFlow)
 
 			// flow exists. update fields
 			calcDuration := false
@@ -106,37 +106,26 @@ var transportFlowEncoder = CreateCustomEncoder(types.Type_NC_TransportFlow, "Tra
 			lf.Proto = ll.LayerType().String()
 			lf.NumPackets = 1
 			lf.TotalSize = int64(len(p.Data()))
-			TransportFlows.Items[ll.TransportFlow().FastHash()] = lf
-
-			// continuously flush flows
-			transportFlows++
-			if netFlows%flowFlushInterval == 0 {
-
-				var selectFlows []*types.TransportFlow
-				for id, f := range TransportFlows.Items {
-					// flush entries whose last timestamp is flowTimeOut older than current packet
-					if p.Metadata().Timestamp.Sub(utils.StringToTime(f.TimestampLast)) > flowTimeOut {
-						selectFlows = append(selectFlows, f)
-						// cleanup
-						delete(TransportFlows.Items, id)
-					}
-				}
-
-				// do this in background
-				go func() {
-					for _, f := range selectFlows {
-						writeTransportFlow(f)
-					}
-				}()
+			if evicted := TransportFlows.Put(id, lf, ts); evicted != nil {
+				expired = append(expired, evicted)
 			}
 		}
+		expired = append(expired, TransportFlows.Expire(ts)...)
 		TransportFlows.Unlock()
+
+		// write timed out and evicted flows
+		for _, f := range expired {
+			writeTransportFlow(f.(*types.TransportFlow))
+		}
 	}
 	return nil
 }, func(e *CustomEncoder) error {
 	if !e.writer.IsChanWriter {
-		for _, f := range TransportFlows.Items {
-			writeTransportFlow(f)
+		TransportFlows.Lock()
+		flows := TransportFlows.Flush()
+		TransportFlows.Unlock()
+		for _, f := range flows {
+			writeTransportFlow(f.(*types.TransportFlow))
 		}
 	}
 	return nil
@@ -148,17 +137,26 @@ func writeTransportFlow(f *types.TransportFlow) {
 	if err != nil {
 		log.Fatal("failed to write audit record: ", err)
 	}
+
+	evaluateRules(f)
 }
 
 func DumpTop5TransportFlows() {
 
 	println("Top 5 Transport Layer Flows:")
-	if len(TransportFlows.Items) == 0 {
+	var all []*types.TransportFlow
+	TransportFlows.Lock()
+	TransportFlows.Each(func(f interface{}) {
+		all = append(all, f.(*types.TransportFlow))
+	})
+	TransportFlows.Unlock()
+
+	if len(all) == 0 {
 		return
 	}
 
-	hits := make([]int64, len(TransportFlows.Items))
-	for _, f := range TransportFlows.Items {
+	hits := make([]int64, len(all))
+	for _, f := range all {
 		hits = append(hits, f.NumPackets)
 	}
 	sort.Slice(hits, func(i int, j int) bool {
@@ -177,9 +175,9 @@ func DumpTop5TransportFlows() {
 		bound = hits[len(hits)-numTop]
 		flows = map[uint64]*types.TransportFlow{}
 	)
-	for uid, f := range TransportFlows.Items {
+	for _, f := range all {
 		if f.NumPackets >= bound {
-			flows[uid] = f
+			flows[f.UID] = f
 		}
 	}
 	// print the highest volume flows in order
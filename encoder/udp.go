@@ -22,10 +22,7 @@ import (
 
 var udpEncoder = CreateLayerEncoder(types.Type_NC_UDP, layers.LayerTypeUDP, func(layer gopacket.Layer, timestamp string) proto.Message {
 	if udp, ok := layer.(*layers.UDP); ok {
-		var payload []byte
-		if CapturePayload {
-			payload = layer.LayerPayload()
-		}
+		payload, hash := capturePayload("UDP", layer.LayerPayload())
 		return &types.UDP{
 			Timestamp:      timestamp,
 			SrcPort:        int32(udp.SrcPort),
@@ -35,6 +32,7 @@ var udpEncoder = CreateLayerEncoder(types.Type_NC_UDP, layers.LayerTypeUDP, func
 			PayloadEntropy: Entropy(udp.Payload),
 			PayloadSize:    int32(len(udp.Payload)),
 			Payload:        payload,
+			PayloadHash:    hash,
 		}
 	}
 	return nil
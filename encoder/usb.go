@@ -22,10 +22,7 @@ import (
 
 var usbEncoder = CreateLayerEncoder(types.Type_NC_USB, layers.LayerTypeUSB, func(layer gopacket.Layer, timestamp string) proto.Message {
 	if usb, ok := layer.(*layers.USB); ok {
-		var payload []byte
-		if CapturePayload {
-			payload = layer.LayerPayload()
-		}
+		payload, hash := capturePayload("USB", layer.LayerPayload())
 		return &types.USB{
 			Timestamp:              timestamp,
 			ID:                     uint64(usb.ID),
@@ -47,6 +44,7 @@ var usbEncoder = CreateLayerEncoder(types.Type_NC_USB, layers.LayerTypeUSB, func
 			UrbCopyOfTransferFlags: uint32(usb.UrbCopyOfTransferFlags),
 			IsoNumDesc:             uint32(usb.IsoNumDesc),
 			Payload:                payload,
+			PayloadHash:            hash,
 		}
 	}
 	return nil
@@ -17,6 +17,7 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"math"
 	"strconv"
@@ -115,3 +116,27 @@ func Entropy(data []byte) (entropy float64) {
 func pad(in interface{}, length int) string {
 	return fmt.Sprintf("%-"+strconv.Itoa(length)+"s", in)
 }
+
+// csvField replaces commas to avoid breaking the CSV
+func csvField(s string) string {
+	return strings.Replace(s, ",", "(comma)", -1)
+}
+
+// csvFields replaces commas in all values
+func csvFields(values []string) []string {
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = csvField(v)
+	}
+	return res
+}
+
+// flagIsSet returns whether the flag with the given name has been set on the command line
+func flagIsSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,186 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package encoder
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/gopacket/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/dreadl0ck/netcap/yara"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	flagYARARules      = flag.String("yara", "", "scan reassembled TCP streams, HTTP bodies and payloads with the YARA rules from the given file or directory")
+	flagYARAStreamSize = flag.Int("yara-stream-size", 1024*1024, "maximum number of bytes collected per TCP stream direction for YARA scanning")
+
+	// yaraRules is nil if no rules were configured
+	yaraRules *yara.Rules
+
+	// set in postinit, to avoid an initialization loop when writing from the handler
+	yaraEncoderInstance *CustomEncoder
+)
+
+var yaraEncoder = CreateCustomEncoder(types.Type_NC_YARAMatch, "YARAMatch", func(e *CustomEncoder) error {
+
+	// postinit:
+	// load rules and ensure TCP stream reassembly is enabled
+
+	if *flagYARARules == "" {
+		return nil
+	}
+
+	rules, err := yara.LoadDir(*flagYARARules)
+	if err != nil {
+		return err
+	}
+	fmt.Println("loaded", rules.Len(), "YARA rules from", *flagYARARules)
+
+	yaraRules = rules
+	yaraEncoderInstance = e
+
+	// stream data is only available after TCP stream reassembly
+	HTTPActive = true
+
+	return nil
+}, func(p gopacket.Packet) proto.Message {
+
+	if yaraRules == nil {
+		return nil
+	}
+
+	// TCP payloads are scanned after stream reassembly
+	// scan the payloads of all other transport layers
+	// and of network layers without a transport layer, e.g. ICMP
+	var (
+		payload   []byte
+		protocol  string
+		net       gopacket.Flow
+		transport gopacket.Flow
+	)
+	if nl := p.NetworkLayer(); nl != nil {
+		net = nl.NetworkFlow()
+		protocol = nl.LayerType().String()
+		payload = nl.LayerPayload()
+	} else {
+		return nil
+	}
+	if tl := p.TransportLayer(); tl != nil {
+		if tl.LayerType() == layers.LayerTypeTCP {
+			return nil
+		}
+		transport = tl.TransportFlow()
+		protocol = tl.LayerType().String()
+		payload = tl.LayerPayload()
+	} else if l := p.Layers(); len(l) > 2 {
+		// use the name of the layer following the network layer, e.g. ICMPv4
+		protocol = l[2].LayerType().String()
+	}
+
+	if len(payload) == 0 {
+		return nil
+	}
+
+	writeYARAMatches(yaraRules.Scan(payload), utils.TimeToString(p.Metadata().Timestamp), "Payload", protocol, net, transport, calcMd5(newConnectionID(p).String()), len(payload))
+
+	// records have been written already, since a single payload can produce multiple matches
+	return nil
+}, func(e *CustomEncoder) error {
+	flushStreams()
+	return nil
+})
+
+// writeYARAMatches writes an audit record for each match
+// the flows are used to determine the addresses and the UID of the flow that was scanned,
+// connUID is the UID of the connection the data belongs to
+func writeYARAMatches(matches []yara.Match, timestamp, source, protocol string, net, transport gopacket.Flow, connUID string, length int) {
+
+	if len(matches) == 0 {
+		return
+	}
+
+	var (
+		srcPort, _ = strconv.Atoi(transport.Src().String())
+		dstPort, _ = strconv.Atoi(transport.Dst().String())
+		flowUID    = calcMd5(fmt.Sprintf("%s:%s", net, transport))
+	)
+
+	for _, m := range matches {
+
+		y := &types.YARAMatch{
+			Timestamp:   timestamp,
+			Rule:        m.Rule,
+			Tags:        m.Tags,
+			Strings:     m.Strings,
+			Description: csvField(m.Meta["description"]),
+			Source:      source,
+			Protocol:    protocol,
+			SrcIP:       net.Src().String(),
+			DstIP:       net.Dst().String(),
+			SrcPort:     int32(srcPort),
+			DstPort:     int32(dstPort),
+			FlowUID:     flowUID,
+			Length:      int32(length),
+			ConnUID:     connUID,
+		}
+
+		// export metrics if configured
+		if yaraEncoderInstance.export {
+			y.Inc()
+		}
+
+		// write record to disk
+		atomic.AddInt64(&yaraEncoderInstance.numRecords, 1)
+		err := yaraEncoderInstance.writer.Write(y)
+		if err != nil {
+			errorMap.Inc(err.Error())
+		}
+
+		evaluateRules(y)
+	}
+}
+
+// collectYARA appends reassembled data for the given direction,
+// until the configured maximum stream size is reached
+func (t *tcpStream) collectYARA(dir reassembly.TCPFlowDirection, data []byte) {
+	buf := &t.yaraClient
+	if dir != reassembly.TCPDirClientToServer {
+		buf = &t.yaraServer
+	}
+	if missing := *flagYARAStreamSize - len(*buf); missing > 0 {
+		if len(data) > missing {
+			data = data[:missing]
+		}
+		*buf = append(*buf, data...)
+	}
+}
+
+// scanYARA scans the collected data for both directions of the stream
+func (t *tcpStream) scanYARA() {
+	ts := utils.TimeToString(t.firstPacket)
+	if len(t.yaraClient) > 0 {
+		writeYARAMatches(yaraRules.Scan(t.yaraClient), ts, "TCPStream", "TCP", t.net, t.transport, t.connUID, len(t.yaraClient))
+	}
+	if len(t.yaraServer) > 0 {
+		writeYARAMatches(yaraRules.Scan(t.yaraServer), ts, "TCPStream", "TCP", t.net.Reverse(), t.transport.Reverse(), t.connUID, len(t.yaraServer))
+	}
+	t.yaraClient, t.yaraServer = nil, nil
+}
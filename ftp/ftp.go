@@ -0,0 +1,156 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package ftp
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAddress is returned if the address of a data connection cannot be parsed.
+var ErrInvalidAddress = errors.New("invalid data connection address")
+
+// ParseCommand splits a command line into the upper case verb and its argument.
+func ParseCommand(line string) (verb, arg string) {
+	line = strings.TrimRight(line, "\r\n")
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		return strings.ToUpper(line[:i]), strings.TrimSpace(line[i+1:])
+	}
+	return strings.ToUpper(line), ""
+}
+
+// ParseReply parses the first line of a reply.
+// multiline is true if the reply continues until a line that starts with the code followed by a space.
+func ParseReply(line string) (code int, text string, multiline bool, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 3 {
+		return 0, "", false, false
+	}
+	code, err := strconv.Atoi(line[:3])
+	if err != nil || code < 100 || code > 599 {
+		return 0, "", false, false
+	}
+	if len(line) == 3 {
+		return code, "", false, true
+	}
+	switch line[3] {
+	case ' ':
+		return code, line[4:], false, true
+	case '-':
+		return code, line[4:], true, true
+	}
+	return 0, "", false, false
+}
+
+// IsLastLine returns true if the line terminates a multiline reply with the code.
+func IsLastLine(line string, code int) bool {
+	c := strconv.Itoa(code)
+	return strings.HasPrefix(line, c) && (len(line) == 3 || line[3] == ' ' || line[3] == '\r' || line[3] == '\n')
+}
+
+// ParsePORT parses the h1,h2,h3,h4,p1,p2 address of a PORT command.
+func ParsePORT(arg string) (net.IP, int, error) {
+	parts := strings.Split(strings.TrimSpace(arg), ",")
+	if len(parts) != 6 {
+		return nil, 0, ErrInvalidAddress
+	}
+	var b [6]byte
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 || n > 255 {
+			return nil, 0, ErrInvalidAddress
+		}
+		b[i] = byte(n)
+	}
+	return net.IPv4(b[0], b[1], b[2], b[3]), int(b[4])<<8 | int(b[5]), nil
+}
+
+// ParsePASV parses the address from the text of a 227 reply, e.g. Entering Passive Mode (10,0,0,1,4,1).
+// the parentheses are optional, the first sequence of six comma separated numbers is used.
+func ParsePASV(text string) (net.IP, int, error) {
+	start := strings.IndexAny(text, "0123456789")
+	for start >= 0 {
+		end := start
+		for end < len(text) && (text[end] >= '0' && text[end] <= '9' || text[end] == ',') {
+			end++
+		}
+		if ip, port, err := ParsePORT(text[start:end]); err == nil {
+			return ip, port, nil
+		}
+		next := strings.IndexAny(text[end:], "0123456789")
+		if next < 0 {
+			break
+		}
+		start = end + next
+	}
+	return nil, 0, ErrInvalidAddress
+}
+
+// ParseEPRT parses the address of an EPRT command, e.g. |2|::1|6275|.
+// the first character is the delimiter.
+func ParseEPRT(arg string) (net.IP, int, error) {
+	arg = strings.TrimSpace(arg)
+	if len(arg) < 2 {
+		return nil, 0, ErrInvalidAddress
+	}
+	parts := strings.Split(arg[1:], arg[:1])
+	if len(parts) < 3 {
+		return nil, 0, ErrInvalidAddress
+	}
+	ip := net.ParseIP(parts[1])
+	port, err := strconv.Atoi(parts[2])
+	if ip == nil || err != nil || port <= 0 || port > 65535 {
+		return nil, 0, ErrInvalidAddress
+	}
+	return ip, port, nil
+}
+
+// ParseEPSV parses the port from the text of a 229 reply, e.g. Entering Extended Passive Mode (|||6446|).
+// the data connection is made to the address of the control connection.
+func ParseEPSV(text string) (int, error) {
+	start := strings.IndexByte(text, '(')
+	end := strings.LastIndexByte(text, ')')
+	if start < 0 || end < start+5 {
+		return 0, ErrInvalidAddress
+	}
+	inner := text[start+1 : end]
+	parts := strings.Split(inner[1:], inner[:1])
+	if len(parts) < 3 {
+		return 0, ErrInvalidAddress
+	}
+	port, err := strconv.Atoi(parts[2])
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, ErrInvalidAddress
+	}
+	return port, nil
+}
+
+// IsTransfer returns true for the commands that transfer data over the data connection.
+func IsTransfer(verb string) bool {
+	switch verb {
+	case "RETR", "STOR", "STOU", "APPE", "LIST", "NLST", "MLSD":
+		return true
+	}
+	return false
+}
+
+// IsUpload returns true for the commands that transfer a file from the client to the server.
+func IsUpload(verb string) bool {
+	switch verb {
+	case "STOR", "STOU", "APPE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package ftp
+
+import (
+	"testing"
+)
+
+func TestParseReply(t *testing.T) {
+	code, text, multi, ok := ParseReply("220-Welcome\r\n")
+	if !ok || code != 220 || text != "Welcome" || !multi {
+		t.Fatal("unexpected reply", code, text, multi, ok)
+	}
+	code, _, multi, ok = ParseReply("226\r\n")
+	if !ok || code != 226 || multi {
+		t.Fatal("unexpected reply", code, multi, ok)
+	}
+	if _, _, _, ok = ParseReply(" 234 inside a multiline reply\r\n"); ok {
+		t.Fatal("expected invalid reply")
+	}
+	if IsLastLine("234 inside a multiline reply\r\n", 220) || !IsLastLine("220 done\r\n", 220) {
+		t.Fatal("unexpected last line")
+	}
+}
+
+func TestParsePORT(t *testing.T) {
+	ip, port, err := ParsePORT("192,168,1,10,195,80")
+	if err != nil || ip.String() != "192.168.1.10" || port != 50000 {
+		t.Fatal("unexpected address", ip, port, err)
+	}
+	if _, _, err = ParsePORT("192,168,1,300,1,1"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParsePASV(t *testing.T) {
+	for _, text := range []string{
+		"Entering Passive Mode (10,0,0,21,19,137).",
+		"Entering Passive Mode 10,0,0,21,19,137",
+		"=10,0,0,21,19,137",
+	} {
+		ip, port, err := ParsePASV(text)
+		if err != nil || ip.String() != "10.0.0.21" || port != 5001 {
+			t.Fatal("unexpected address", text, ip, port, err)
+		}
+	}
+	if _, _, err := ParsePASV("Entering Passive Mode"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseExtended(t *testing.T) {
+	ip, port, err := ParseEPRT("|2|2001:db8::1|6275|")
+	if err != nil || ip.String() != "2001:db8::1" || port != 6275 {
+		t.Fatal("unexpected address", ip, port, err)
+	}
+	ip, port, err = ParseEPRT("!1!10.0.0.1!21000!")
+	if err != nil || ip.String() != "10.0.0.1" || port != 21000 {
+		t.Fatal("unexpected address", ip, port, err)
+	}
+	port, err = ParseEPSV("Entering Extended Passive Mode (|||6446|)")
+	if err != nil || port != 6446 {
+		t.Fatal("unexpected port", port, err)
+	}
+	if _, err = ParseEPSV("Entering Extended Passive Mode"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	verb, arg := ParseCommand("retr my file.txt\r\n")
+	if verb != "RETR" || arg != "my file.txt" {
+		t.Fatal("unexpected command", verb, arg)
+	}
+	if !IsTransfer("LIST") || IsUpload("RETR") || !IsUpload("STOR") {
+		t.Fatal("unexpected transfer classification")
+	}
+}
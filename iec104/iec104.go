@@ -0,0 +1,328 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package iec104
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+const (
+	// Port is the TCP port registered for IEC 60870-5-104
+	Port = 2404
+
+	startByte = 0x68
+
+	// size of the start byte, the length and the control field
+	apciSize = 6
+
+	// size of the ASDU header with a two octet cause of transmission and common address
+	asduHeaderSize = 6
+
+	// size of an information object address
+	ioaSize = 3
+)
+
+var (
+	// ErrNoStart is returned if the data does not start with the start byte of an APDU.
+	ErrNoStart = errors.New("iec104: missing start byte")
+
+	// ErrInvalidLength is returned for APDUs with a length below the size of the control field.
+	ErrInvalidLength = errors.New("iec104: invalid APDU length")
+
+	// ErrTruncated is returned if the APDU ends within the ASDU header or an information object.
+	ErrTruncated = errors.New("iec104: truncated APDU")
+)
+
+// Frame formats of the APCI.
+const (
+	FormatI = "I"
+	FormatS = "S"
+	FormatU = "U"
+)
+
+// APDU is an application protocol data unit, the ASDU is only present for I-format APDUs.
+type APDU struct {
+	Format string
+
+	// sequence numbers of I-format and S-format APDUs
+	SendSeq uint16
+	RecvSeq uint16
+
+	// function bits of U-format APDUs
+	UFunction uint8
+
+	ASDU *ASDU
+}
+
+// ASDU is an application service data unit.
+type ASDU struct {
+	TypeID     uint8
+	Sequence   bool
+	NumObjects uint8
+
+	Cause      uint8
+	Negative   bool
+	Test       bool
+	Originator uint8
+
+	CommonAddress uint16
+
+	// information object addresses, for a sequence only the address of the first object
+	IOAs []uint32
+}
+
+// FrameLength returns the size of the APDU at the start of the data.
+// ok is false if the data is too short to contain the length.
+func FrameLength(data []byte) (n int, ok bool, err error) {
+	if len(data) < 2 {
+		return 0, false, nil
+	}
+	if data[0] != startByte {
+		return 0, false, ErrNoStart
+	}
+	if data[1] < 4 {
+		return 0, false, ErrInvalidLength
+	}
+	return int(data[1]) + 2, true, nil
+}
+
+// Parse parses a complete APDU.
+// for an ASDU that is cut short, the APDU is returned along with ErrTruncated.
+func Parse(data []byte) (*APDU, error) {
+	n, ok, err := FrameLength(data)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || len(data) < n {
+		return nil, ErrTruncated
+	}
+
+	var (
+		c = data[2:apciSize]
+		a = &APDU{}
+	)
+	switch {
+	case c[0]&0x01 == 0:
+		a.Format = FormatI
+		a.SendSeq = binary.LittleEndian.Uint16(c[0:2]) >> 1
+		a.RecvSeq = binary.LittleEndian.Uint16(c[2:4]) >> 1
+	case c[0]&0x03 == 0x01:
+		a.Format = FormatS
+		a.RecvSeq = binary.LittleEndian.Uint16(c[2:4]) >> 1
+		return a, nil
+	default:
+		a.Format = FormatU
+		a.UFunction = c[0] &^ 0x03
+		return a, nil
+	}
+
+	a.ASDU, err = parseASDU(data[apciSize:n])
+	return a, err
+}
+
+func parseASDU(data []byte) (*ASDU, error) {
+	if len(data) < asduHeaderSize {
+		return nil, ErrTruncated
+	}
+	s := &ASDU{
+		TypeID:        data[0],
+		Sequence:      data[1]&0x80 != 0,
+		NumObjects:    data[1] & 0x7f,
+		Cause:         data[2] & 0x3f,
+		Negative:      data[2]&0x40 != 0,
+		Test:          data[2]&0x80 != 0,
+		Originator:    data[3],
+		CommonAddress: binary.LittleEndian.Uint16(data[4:6]),
+	}
+	data = data[asduHeaderSize:]
+	if s.NumObjects == 0 {
+		return s, nil
+	}
+
+	size, known := elementSizes[s.TypeID]
+	if s.Sequence {
+		if len(data) < ioaSize {
+			return s, ErrTruncated
+		}
+		s.IOAs = append(s.IOAs, readIOA(data))
+		return s, nil
+	}
+	for i := 0; i < int(s.NumObjects); i++ {
+		if len(data) < ioaSize {
+			return s, ErrTruncated
+		}
+		s.IOAs = append(s.IOAs, readIOA(data))
+		if !known {
+			// the position of the next object is unknown
+			break
+		}
+		if len(data) < ioaSize+size {
+			return s, ErrTruncated
+		}
+		data = data[ioaSize+size:]
+	}
+	return s, nil
+}
+
+func readIOA(data []byte) uint32 {
+	return uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+}
+
+// elementSizes contains the size of the information elements of an object, by type ID
+var elementSizes = map[uint8]int{
+	1: 1, 3: 1, 5: 2, 7: 5, 9: 3, 11: 3, 13: 5, 15: 5, 21: 2,
+	30: 8, 31: 8, 32: 9, 33: 12, 34: 10, 35: 10, 36: 12, 37: 12, 38: 10, 39: 11, 40: 10,
+	45: 1, 46: 1, 47: 1, 48: 3, 49: 3, 50: 5, 51: 4,
+	58: 8, 59: 8, 60: 8, 61: 10, 62: 10, 63: 12, 64: 11, 70: 1,
+	100: 1, 101: 1, 102: 0, 103: 7, 104: 2, 105: 1, 106: 2, 107: 9,
+	110: 3, 111: 3, 112: 5, 113: 1,
+}
+
+// IsCommand returns true for the type IDs of commands in control direction,
+// process commands, system commands and parameters.
+func IsCommand(typeID uint8) bool {
+	return typeID >= 45 && typeID <= 69 || typeID >= 100 && typeID <= 113
+}
+
+var typeNames = map[uint8]string{
+	1:   "M_SP_NA_1",
+	2:   "M_SP_TA_1",
+	3:   "M_DP_NA_1",
+	4:   "M_DP_TA_1",
+	5:   "M_ST_NA_1",
+	6:   "M_ST_TA_1",
+	7:   "M_BO_NA_1",
+	8:   "M_BO_TA_1",
+	9:   "M_ME_NA_1",
+	10:  "M_ME_TA_1",
+	11:  "M_ME_NB_1",
+	12:  "M_ME_TB_1",
+	13:  "M_ME_NC_1",
+	14:  "M_ME_TC_1",
+	15:  "M_IT_NA_1",
+	16:  "M_IT_TA_1",
+	17:  "M_EP_TA_1",
+	18:  "M_EP_TB_1",
+	19:  "M_EP_TC_1",
+	20:  "M_PS_NA_1",
+	21:  "M_ME_ND_1",
+	30:  "M_SP_TB_1",
+	31:  "M_DP_TB_1",
+	32:  "M_ST_TB_1",
+	33:  "M_BO_TB_1",
+	34:  "M_ME_TD_1",
+	35:  "M_ME_TE_1",
+	36:  "M_ME_TF_1",
+	37:  "M_IT_TB_1",
+	38:  "M_EP_TD_1",
+	39:  "M_EP_TE_1",
+	40:  "M_EP_TF_1",
+	45:  "C_SC_NA_1",
+	46:  "C_DC_NA_1",
+	47:  "C_RC_NA_1",
+	48:  "C_SE_NA_1",
+	49:  "C_SE_NB_1",
+	50:  "C_SE_NC_1",
+	51:  "C_BO_NA_1",
+	58:  "C_SC_TA_1",
+	59:  "C_DC_TA_1",
+	60:  "C_RC_TA_1",
+	61:  "C_SE_TA_1",
+	62:  "C_SE_TB_1",
+	63:  "C_SE_TC_1",
+	64:  "C_BO_TA_1",
+	70:  "M_EI_NA_1",
+	100: "C_IC_NA_1",
+	101: "C_CI_NA_1",
+	102: "C_RD_NA_1",
+	103: "C_CS_NA_1",
+	104: "C_TS_NA_1",
+	105: "C_RP_NA_1",
+	106: "C_CD_NA_1",
+	107: "C_TS_TA_1",
+	110: "P_ME_NA_1",
+	111: "P_ME_NB_1",
+	112: "P_ME_NC_1",
+	113: "P_AC_NA_1",
+	120: "F_FR_NA_1",
+	121: "F_SR_NA_1",
+	122: "F_SC_NA_1",
+	123: "F_LS_NA_1",
+	124: "F_AF_NA_1",
+	125: "F_SG_NA_1",
+	126: "F_DR_TA_1",
+	127: "F_SC_NB_1",
+}
+
+// TypeName returns the name of a type ID.
+func TypeName(typeID uint8) string {
+	if name, ok := typeNames[typeID]; ok {
+		return name
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(typeID))
+}
+
+var causeNames = map[uint8]string{
+	1:  "per/cyc",
+	2:  "back",
+	3:  "spont",
+	4:  "init",
+	5:  "req",
+	6:  "act",
+	7:  "actcon",
+	8:  "deact",
+	9:  "deactcon",
+	10: "actterm",
+	11: "retrem",
+	12: "retloc",
+	13: "file",
+	20: "inrogen",
+	37: "reqcogen",
+	44: "unknown type",
+	45: "unknown cause",
+	46: "unknown common address",
+	47: "unknown object address",
+}
+
+// CauseName returns the name of a cause of transmission.
+func CauseName(cause uint8) string {
+	switch {
+	case cause >= 21 && cause <= 36:
+		return "inro" + strconv.Itoa(int(cause)-20)
+	case cause >= 38 && cause <= 41:
+		return "reqco" + strconv.Itoa(int(cause)-37)
+	}
+	if name, ok := causeNames[cause]; ok {
+		return name
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(cause))
+}
+
+var uFunctionNames = map[uint8]string{
+	0x04: "STARTDT act",
+	0x08: "STARTDT con",
+	0x10: "STOPDT act",
+	0x20: "STOPDT con",
+	0x40: "TESTFR act",
+	0x80: "TESTFR con",
+}
+
+// UFunctionName returns the name of the function of a U-format APDU.
+func UFunctionName(function uint8) string {
+	if name, ok := uFunctionNames[function]; ok {
+		return name
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(function))
+}
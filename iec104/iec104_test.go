@@ -0,0 +1,106 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package iec104
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseU(t *testing.T) {
+	a, err := Parse([]byte{0x68, 0x04, 0x07, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Format != FormatU || UFunctionName(a.UFunction) != "STARTDT act" || a.ASDU != nil {
+		t.Fatalf("unexpected APDU: %+v", a)
+	}
+	a, err = Parse([]byte{0x68, 0x04, 0x83, 0x00, 0x00, 0x00})
+	if err != nil || UFunctionName(a.UFunction) != "TESTFR con" {
+		t.Fatalf("unexpected APDU: %+v %v", a, err)
+	}
+}
+
+func TestParseS(t *testing.T) {
+	a, err := Parse([]byte{0x68, 0x04, 0x01, 0x00, 0x0a, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Format != FormatS || a.RecvSeq != 133 {
+		t.Fatalf("unexpected APDU: %+v", a)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	// single command on for object 0x001f40, activation
+	data := []byte{0x68, 0x0e, 0x02, 0x00, 0x06, 0x00, 45, 0x01, 0x06, 0x00, 0x01, 0x00, 0x40, 0x1f, 0x00, 0x81}
+	a, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Format != FormatI || a.SendSeq != 1 || a.RecvSeq != 3 {
+		t.Fatalf("unexpected APDU: %+v", a)
+	}
+	s := a.ASDU
+	if TypeName(s.TypeID) != "C_SC_NA_1" || !IsCommand(s.TypeID) || CauseName(s.Cause) != "act" || s.CommonAddress != 1 {
+		t.Fatalf("unexpected ASDU: %+v", s)
+	}
+	if !reflect.DeepEqual(s.IOAs, []uint32{8000}) {
+		t.Fatal("unexpected IOAs", s.IOAs)
+	}
+}
+
+func TestParseMeasurements(t *testing.T) {
+	// two scaled measured values, spontaneous, negative confirmation bit set for the test
+	data := []byte{0x68, 0x16, 0x00, 0x00, 0x00, 0x00, 11, 0x02, 0x43, 0x00, 0x05, 0x00,
+		0x01, 0x00, 0x00, 0x10, 0x00, 0x00,
+		0x02, 0x00, 0x00, 0x20, 0x00, 0x00,
+	}
+	a, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := a.ASDU
+	if s.NumObjects != 2 || s.Sequence || !s.Negative || s.Test || CauseName(s.Cause) != "spont" || IsCommand(s.TypeID) {
+		t.Fatalf("unexpected ASDU: %+v", s)
+	}
+	if !reflect.DeepEqual(s.IOAs, []uint32{1, 2}) {
+		t.Fatal("unexpected IOAs", s.IOAs)
+	}
+
+	// sequence of three single points starting at address 100
+	data = []byte{0x68, 0x10, 0x02, 0x00, 0x00, 0x00, 1, 0x83, 0x14, 0x00, 0x01, 0x00, 0x64, 0x00, 0x00, 0x01, 0x00, 0x01}
+	a, err = Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.ASDU.Sequence || a.ASDU.NumObjects != 3 || !reflect.DeepEqual(a.ASDU.IOAs, []uint32{100}) || CauseName(a.ASDU.Cause) != "inrogen" {
+		t.Fatalf("unexpected ASDU: %+v", a.ASDU)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse([]byte{0x05, 0x64, 0x05}); err != ErrNoStart {
+		t.Fatal("expected start error, got", err)
+	}
+	if _, err := Parse([]byte{0x68, 0x02, 0x00, 0x00}); err != ErrInvalidLength {
+		t.Fatal("expected length error, got", err)
+	}
+	a, err := Parse([]byte{0x68, 0x0c, 0x00, 0x00, 0x00, 0x00, 13, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00})
+	if err != ErrTruncated || a == nil || a.ASDU == nil {
+		t.Fatal("expected truncated error, got", err)
+	}
+	if CauseName(25) != "inro5" || TypeName(200) != "UNKNOWN_200" {
+		t.Fatal("unexpected names")
+	}
+}
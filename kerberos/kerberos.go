@@ -0,0 +1,380 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+// Package kerberos decodes the Kerberos V5 messages exchanged with a key distribution center (RFC 4120).
+//
+// The authentication service (AS) and ticket granting service (TGS) requests and replies,
+// as well as error messages are supported. Only the unencrypted parts of the messages are decoded,
+// which contain the principal names, realms and encryption types.
+package kerberos
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Message types, these are the application tags of the messages.
+const (
+	MsgASReq  = 10
+	MsgASRep  = 11
+	MsgTGSReq = 12
+	MsgTGSRep = 13
+	MsgAPReq  = 14
+	MsgAPRep  = 15
+	MsgError  = 30
+)
+
+// MaxMessageSize is the largest message accepted in a TCP stream,
+// larger length values indicate that the stream is not Kerberos or out of sync.
+const MaxMessageSize = 1024 * 1024
+
+// PADataEncTimestamp is the pre-authentication data type of an encrypted timestamp.
+const PADataEncTimestamp = 2
+
+var (
+	// ErrNotKerberos is returned if the data does not start with an application tag.
+	ErrNotKerberos = errors.New("kerberos: not a kerberos message")
+	// ErrUnsupported is returned for message types that are not decoded.
+	ErrUnsupported = errors.New("kerberos: unsupported message type")
+	// ErrStructure is returned if the message does not have the expected structure.
+	ErrStructure = errors.New("kerberos: invalid message structure")
+)
+
+// PrincipalName identifies a client or service.
+type PrincipalName struct {
+	NameType   int32
+	NameString []string
+}
+
+// String returns the components of the name separated by slashes, e.g. HTTP/www.example.com.
+func (p PrincipalName) String() string {
+	return strings.Join(p.NameString, "/")
+}
+
+// Message contains the unencrypted fields of a KDC request, reply or error.
+// Depending on the type, only a subset of the fields is set.
+type Message struct {
+	Type int
+
+	ClientRealm string
+	ClientName  PrincipalName
+	Realm       string
+	ServiceName PrincipalName
+
+	// requests
+	KDCOptions uint32
+	Nonce      uint32
+	Etypes     []int32
+
+	// pre-authentication data types of requests and replies
+	PADataTypes []int32
+
+	// encryption type of the encrypted timestamp used for pre-authentication, zero if there is none
+	PreAuthEtype int32
+
+	// replies
+	TicketEtype  int32
+	EncPartEtype int32
+
+	// errors
+	ErrorCode int32
+	ErrorText string
+}
+
+// FrameLength returns the length of the next message in a TCP stream,
+// which is prefixed with its length as four byte big endian integer.
+// ok is false if there is not enough data to read the length.
+func FrameLength(data []byte) (length int, ok bool) {
+	if len(data) < 4 {
+		return 0, false
+	}
+	// the highest bit is reserved
+	return int(binary.BigEndian.Uint32(data) & 0x7fffffff), true
+}
+
+// Parse decodes a KDC request, reply or error message.
+func Parse(data []byte) (*Message, error) {
+
+	var app asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &app); err != nil {
+		return nil, err
+	}
+	if app.Class != asn1.ClassApplication || !app.IsCompound {
+		return nil, ErrNotKerberos
+	}
+
+	var (
+		m   = &Message{Type: app.Tag}
+		err error
+	)
+	switch app.Tag {
+	case MsgASReq, MsgTGSReq:
+		err = m.parseRequest(app.Bytes)
+	case MsgASRep, MsgTGSRep:
+		err = m.parseReply(app.Bytes)
+	case MsgError:
+		err = m.parseError(app.Bytes)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KDC-REQ ::= SEQUENCE { pvno [1], msg-type [2], padata [3] OPTIONAL, req-body [4] }
+func (m *Message) parseRequest(data []byte) error {
+
+	f, err := sequence(data)
+	if err != nil {
+		return err
+	}
+	if err := m.parsePAData(f[3]); err != nil {
+		return err
+	}
+
+	// KDC-REQ-BODY ::= SEQUENCE { kdc-options [0], cname [1] OPTIONAL, realm [2], sname [3] OPTIONAL, ..., nonce [7], etype [8], ... }
+	body, err := sequence(f[4])
+	if err != nil {
+		return err
+	}
+	if b, ok := body[0]; ok {
+		var opts asn1.BitString
+		if _, err := asn1.Unmarshal(b, &opts); err != nil {
+			return err
+		}
+		var buf [4]byte
+		copy(buf[:], opts.Bytes)
+		m.KDCOptions = binary.BigEndian.Uint32(buf[:])
+	}
+	if m.ClientName, err = principal(body[1]); err != nil {
+		return err
+	}
+	if m.Realm, err = str(body[2]); err != nil {
+		return err
+	}
+	// the client is in the same realm for authentication service requests
+	if m.Type == MsgASReq {
+		m.ClientRealm = m.Realm
+	}
+	if m.ServiceName, err = principal(body[3]); err != nil {
+		return err
+	}
+	if b, ok := body[7]; ok {
+		var nonce int64
+		if _, err := asn1.Unmarshal(b, &nonce); err != nil {
+			return err
+		}
+		m.Nonce = uint32(nonce)
+	}
+	if b, ok := body[8]; ok {
+		if _, err := asn1.Unmarshal(b, &m.Etypes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KDC-REP ::= SEQUENCE { pvno [0], msg-type [1], padata [2] OPTIONAL, crealm [3], cname [4], ticket [5], enc-part [6] }
+func (m *Message) parseReply(data []byte) error {
+
+	f, err := sequence(data)
+	if err != nil {
+		return err
+	}
+	if err := m.parsePAData(f[2]); err != nil {
+		return err
+	}
+	if m.ClientRealm, err = str(f[3]); err != nil {
+		return err
+	}
+	if m.ClientName, err = principal(f[4]); err != nil {
+		return err
+	}
+
+	// Ticket ::= [APPLICATION 1] SEQUENCE { tkt-vno [0], realm [1], sname [2], enc-part [3] }
+	if b, ok := f[5]; ok {
+		var app asn1.RawValue
+		if _, err := asn1.Unmarshal(b, &app); err != nil {
+			return err
+		}
+		t, err := sequence(app.Bytes)
+		if err != nil {
+			return err
+		}
+		if m.Realm, err = str(t[1]); err != nil {
+			return err
+		}
+		if m.ServiceName, err = principal(t[2]); err != nil {
+			return err
+		}
+		if m.TicketEtype, err = etype(t[3]); err != nil {
+			return err
+		}
+	}
+	m.EncPartEtype, err = etype(f[6])
+	return err
+}
+
+// KRB-ERROR ::= SEQUENCE { ..., error-code [6], crealm [7] OPTIONAL, cname [8] OPTIONAL, realm [9], sname [10], e-text [11] OPTIONAL, ... }
+func (m *Message) parseError(data []byte) error {
+
+	f, err := sequence(data)
+	if err != nil {
+		return err
+	}
+	if b, ok := f[6]; ok {
+		if _, err := asn1.Unmarshal(b, &m.ErrorCode); err != nil {
+			return err
+		}
+	}
+	if m.ClientRealm, err = str(f[7]); err != nil {
+		return err
+	}
+	if m.ClientName, err = principal(f[8]); err != nil {
+		return err
+	}
+	if m.Realm, err = str(f[9]); err != nil {
+		return err
+	}
+	if m.ServiceName, err = principal(f[10]); err != nil {
+		return err
+	}
+	m.ErrorText, err = str(f[11])
+	return err
+}
+
+// PA-DATA ::= SEQUENCE { padata-type [1], padata-value [2] OCTET STRING }
+func (m *Message) parsePAData(data []byte) error {
+
+	if data == nil {
+		return nil
+	}
+	items, err := elements(data)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		pa, err := sequence(item.FullBytes)
+		if err != nil {
+			return err
+		}
+		var typ int32
+		if _, err := asn1.Unmarshal(pa[1], &typ); err != nil {
+			return err
+		}
+		m.PADataTypes = append(m.PADataTypes, typ)
+
+		if typ == PADataEncTimestamp {
+			var value []byte
+			if _, err := asn1.Unmarshal(pa[2], &value); err != nil {
+				return err
+			}
+			if m.PreAuthEtype, err = etype(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sequence returns the contents of the explicitly tagged fields of a SEQUENCE by their context tag
+func sequence(data []byte) (map[int][]byte, error) {
+	items, err := elements(data)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[int][]byte, len(items))
+	for _, f := range items {
+		if f.Class == asn1.ClassContextSpecific {
+			fields[f.Tag] = f.Bytes
+		}
+	}
+	return fields, nil
+}
+
+// elements returns the elements of a SEQUENCE
+func elements(data []byte) ([]asn1.RawValue, error) {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &seq); err != nil {
+		return nil, err
+	}
+	if seq.Class != asn1.ClassUniversal || seq.Tag != asn1.TagSequence {
+		return nil, ErrStructure
+	}
+	var (
+		items []asn1.RawValue
+		rest  = seq.Bytes
+	)
+	for len(rest) > 0 {
+		var (
+			v   asn1.RawValue
+			err error
+		)
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// str decodes a KerberosString, which is a GeneralString restricted to ASCII
+func str(data []byte) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+	var v asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &v); err != nil {
+		return "", err
+	}
+	return string(v.Bytes), nil
+}
+
+// PrincipalName ::= SEQUENCE { name-type [0], name-string [1] SEQUENCE OF KerberosString }
+func principal(data []byte) (p PrincipalName, err error) {
+	if data == nil {
+		return p, nil
+	}
+	f, err := sequence(data)
+	if err != nil {
+		return p, err
+	}
+	if _, err = asn1.Unmarshal(f[0], &p.NameType); err != nil {
+		return p, err
+	}
+	names, err := elements(f[1])
+	if err != nil {
+		return p, err
+	}
+	for _, n := range names {
+		p.NameString = append(p.NameString, string(n.Bytes))
+	}
+	return p, nil
+}
+
+// EncryptedData ::= SEQUENCE { etype [0], kvno [1] OPTIONAL, cipher [2] }
+func etype(data []byte) (int32, error) {
+	if data == nil {
+		return 0, nil
+	}
+	f, err := sequence(data)
+	if err != nil {
+		return 0, err
+	}
+	var e int32
+	_, err = asn1.Unmarshal(f[0], &e)
+	return e, err
+}
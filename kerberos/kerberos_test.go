@@ -0,0 +1,197 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package kerberos
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func raw(class, tag int, compound bool, content []byte) []byte {
+	b, err := asn1.Marshal(asn1.RawValue{Class: class, Tag: tag, IsCompound: compound, Bytes: content})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func field(tag int, content []byte) []byte {
+	return raw(asn1.ClassContextSpecific, tag, true, content)
+}
+
+func seq(items ...[]byte) []byte {
+	var content []byte
+	for _, i := range items {
+		content = append(content, i...)
+	}
+	return raw(asn1.ClassUniversal, asn1.TagSequence, true, content)
+}
+
+func integer(v int) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func kstring(s string) []byte {
+	return raw(asn1.ClassUniversal, asn1.TagGeneralString, false, []byte(s))
+}
+
+func octets(b []byte) []byte {
+	return raw(asn1.ClassUniversal, asn1.TagOctetString, false, b)
+}
+
+func name(typ int, components ...string) []byte {
+	var names [][]byte
+	for _, c := range components {
+		names = append(names, kstring(c))
+	}
+	return seq(field(0, integer(typ)), field(1, seq(names...)))
+}
+
+func encrypted(etype int) []byte {
+	return seq(field(0, integer(etype)), field(2, octets([]byte{1, 2, 3})))
+}
+
+func TestParseASReq(t *testing.T) {
+
+	body := seq(
+		field(0, raw(asn1.ClassUniversal, asn1.TagBitString, false, []byte{0, 0x40, 0x81, 0, 0x10})),
+		field(1, name(1, "alice")),
+		field(2, kstring("CORP.LOCAL")),
+		field(3, name(2, "krbtgt", "CORP.LOCAL")),
+		field(7, integer(12345)),
+		field(8, seq(integer(18), integer(17), integer(23))),
+	)
+	padata := seq(
+		seq(field(1, integer(PADataEncTimestamp)), field(2, octets(encrypted(18)))),
+		seq(field(1, integer(128)), field(2, octets([]byte{0x30, 0x00}))),
+	)
+	data := raw(asn1.ClassApplication, MsgASReq, true, seq(
+		field(1, integer(5)),
+		field(2, integer(MsgASReq)),
+		field(3, padata),
+		field(4, body),
+	))
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != MsgASReq || m.ClientName.String() != "alice" || m.Realm != "CORP.LOCAL" {
+		t.Fatal("unexpected message", m)
+	}
+	if m.ServiceName.String() != "krbtgt/CORP.LOCAL" || m.ServiceName.NameType != 2 {
+		t.Fatal("unexpected service name", m.ServiceName)
+	}
+	if m.KDCOptions != 0x40810010 || m.Nonce != 12345 {
+		t.Fatalf("unexpected options 0x%x or nonce %d", m.KDCOptions, m.Nonce)
+	}
+	if len(m.Etypes) != 3 || m.Etypes[2] != EtypeRC4HMAC {
+		t.Fatal("unexpected etypes", m.Etypes)
+	}
+	if m.PreAuthEtype != EtypeAES256CTSHMACSHA196 || len(m.PADataTypes) != 2 {
+		t.Fatal("unexpected pre-authentication", m.PreAuthEtype, m.PADataTypes)
+	}
+}
+
+func TestParseTGSRep(t *testing.T) {
+
+	ticket := raw(asn1.ClassApplication, 1, true, seq(
+		field(0, integer(5)),
+		field(1, kstring("CORP.LOCAL")),
+		field(2, name(2, "MSSQLSvc", "db01.corp.local:1433")),
+		field(3, encrypted(EtypeRC4HMAC)),
+	))
+	data := raw(asn1.ClassApplication, MsgTGSRep, true, seq(
+		field(0, integer(5)),
+		field(1, integer(MsgTGSRep)),
+		field(3, kstring("CORP.LOCAL")),
+		field(4, name(1, "alice")),
+		field(5, ticket),
+		field(6, encrypted(EtypeAES256CTSHMACSHA196)),
+	))
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != MsgTGSRep || m.ClientRealm != "CORP.LOCAL" || m.ClientName.String() != "alice" {
+		t.Fatal("unexpected message", m)
+	}
+	if m.ServiceName.String() != "MSSQLSvc/db01.corp.local:1433" || m.Realm != "CORP.LOCAL" {
+		t.Fatal("unexpected service", m.ServiceName, m.Realm)
+	}
+	if m.TicketEtype != EtypeRC4HMAC || m.EncPartEtype != EtypeAES256CTSHMACSHA196 {
+		t.Fatal("unexpected etypes", m.TicketEtype, m.EncPartEtype)
+	}
+	if !WeakEtype(m.TicketEtype) || WeakEtype(m.EncPartEtype) {
+		t.Fatal("unexpected weak etype classification")
+	}
+}
+
+func TestParseError(t *testing.T) {
+
+	data := raw(asn1.ClassApplication, MsgError, true, seq(
+		field(0, integer(5)),
+		field(1, integer(MsgError)),
+		field(6, integer(25)),
+		field(9, kstring("CORP.LOCAL")),
+		field(10, name(2, "krbtgt", "CORP.LOCAL")),
+		field(11, kstring("preauth required")),
+	))
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ErrorCode != 25 || ErrorName(m.ErrorCode) != "KDC_ERR_PREAUTH_REQUIRED" || m.ErrorText != "preauth required" {
+		t.Fatal("unexpected error", m)
+	}
+	if m.ServiceName.String() != "krbtgt/CORP.LOCAL" || m.ClientName.String() != "" {
+		t.Fatal("unexpected names", m.ServiceName, m.ClientName)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse(seq(integer(1))); err != ErrNotKerberos {
+		t.Fatal("expected not kerberos error, got", err)
+	}
+	if _, err := Parse(raw(asn1.ClassApplication, MsgAPReq, true, seq())); err != ErrUnsupported {
+		t.Fatal("expected unsupported error, got", err)
+	}
+	if _, err := Parse(raw(asn1.ClassApplication, MsgASReq, true, integer(1))); err != ErrStructure {
+		t.Fatal("expected structure error, got", err)
+	}
+	if _, err := Parse([]byte{0x6a, 0x10, 0x30}); err == nil {
+		t.Fatal("expected error for truncated message")
+	}
+}
+
+func TestFrameLength(t *testing.T) {
+	l, ok := FrameLength([]byte{0x80, 0, 0x01, 0x02})
+	if !ok || l != 0x0102 {
+		t.Fatal("unexpected length", l)
+	}
+	if _, ok := FrameLength([]byte{0}); ok {
+		t.Fatal("expected incomplete length")
+	}
+}
+
+func TestNames(t *testing.T) {
+	if MsgTypeName(MsgTGSReq) != "TGS-REQ" || EtypeName(EtypeRC4HMAC) != "rc4-hmac" || EtypeName(99) != "99" {
+		t.Fatal("unexpected names")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package kerberos
+
+import "strconv"
+
+// Encryption types.
+const (
+	EtypeDESCBCCRC           = 1
+	EtypeDESCBCMD5           = 3
+	EtypeAES128CTSHMACSHA196 = 17
+	EtypeAES256CTSHMACSHA196 = 18
+	EtypeAES128CTSHMACSHA256 = 19
+	EtypeAES256CTSHMACSHA384 = 20
+	EtypeRC4HMAC             = 23
+	EtypeRC4HMACExp          = 24
+)
+
+var etypeNames = map[int32]string{
+	EtypeDESCBCCRC:           "des-cbc-crc",
+	EtypeDESCBCMD5:           "des-cbc-md5",
+	EtypeAES128CTSHMACSHA196: "aes128-cts-hmac-sha1-96",
+	EtypeAES256CTSHMACSHA196: "aes256-cts-hmac-sha1-96",
+	EtypeAES128CTSHMACSHA256: "aes128-cts-hmac-sha256-128",
+	EtypeAES256CTSHMACSHA384: "aes256-cts-hmac-sha384-192",
+	EtypeRC4HMAC:             "rc4-hmac",
+	EtypeRC4HMACExp:          "rc4-hmac-exp",
+}
+
+// EtypeName returns the name of an encryption type.
+func EtypeName(e int32) string {
+	if n, ok := etypeNames[e]; ok {
+		return n
+	}
+	return strconv.Itoa(int(e))
+}
+
+// WeakEtype returns true for the DES and RC4 encryption types,
+// keys for RC4 are derived from the NT hash of the password without a salt.
+func WeakEtype(e int32) bool {
+	switch e {
+	case EtypeDESCBCCRC, EtypeDESCBCMD5, EtypeRC4HMAC, EtypeRC4HMACExp:
+		return true
+	}
+	return false
+}
+
+var msgTypeNames = map[int]string{
+	MsgASReq:  "AS-REQ",
+	MsgASRep:  "AS-REP",
+	MsgTGSReq: "TGS-REQ",
+	MsgTGSRep: "TGS-REP",
+	MsgAPReq:  "AP-REQ",
+	MsgAPRep:  "AP-REP",
+	MsgError:  "KRB-ERROR",
+}
+
+// MsgTypeName returns the name of a message type.
+func MsgTypeName(t int) string {
+	if n, ok := msgTypeNames[t]; ok {
+		return n
+	}
+	return strconv.Itoa(t)
+}
+
+var errorNames = map[int32]string{
+	0:  "KDC_ERR_NONE",
+	1:  "KDC_ERR_NAME_EXP",
+	2:  "KDC_ERR_SERVICE_EXP",
+	3:  "KDC_ERR_BAD_PVNO",
+	6:  "KDC_ERR_C_PRINCIPAL_UNKNOWN",
+	7:  "KDC_ERR_S_PRINCIPAL_UNKNOWN",
+	8:  "KDC_ERR_PRINCIPAL_NOT_UNIQUE",
+	12: "KDC_ERR_POLICY",
+	13: "KDC_ERR_BADOPTION",
+	14: "KDC_ERR_ETYPE_NOSUPP",
+	16: "KDC_ERR_PADATA_TYPE_NOSUPP",
+	18: "KDC_ERR_CLIENT_REVOKED",
+	20: "KDC_ERR_TGT_REVOKED",
+	23: "KDC_ERR_KEY_EXPIRED",
+	24: "KDC_ERR_PREAUTH_FAILED",
+	25: "KDC_ERR_PREAUTH_REQUIRED",
+	31: "KRB_AP_ERR_BAD_INTEGRITY",
+	32: "KRB_AP_ERR_TKT_EXPIRED",
+	34: "KRB_AP_ERR_REPEAT",
+	37: "KRB_AP_ERR_SKEW",
+	41: "KRB_AP_ERR_MODIFIED",
+	52: "KRB_ERR_RESPONSE_TOO_BIG",
+	60: "KRB_ERR_GENERIC",
+	68: "KDC_ERR_WRONG_REALM",
+}
+
+// ErrorName returns the name of an error code.
+func ErrorName(code int32) string {
+	if n, ok := errorNames[code]; ok {
+		return n
+	}
+	return strconv.Itoa(int(code))
+}
@@ -136,116 +136,7 @@ func Suricata(inputPcap string, outputPath string, useDescription bool, separato
 		return err
 	}
 
-	if len(labels) == 0 {
-		fmt.Println("no labels found.")
-		os.Exit(0)
-	}
-
-	fmt.Println("got", len(labels), "labels")
-
-	rows := [][]string{}
-	for c, num := range ClassificationMap {
-		rows = append(rows, []string{c, strconv.Itoa(num)})
-	}
-
-	// print alert summary
-	tui.Table(os.Stdout, []string{"Classification", "Count"}, rows)
-	fmt.Println()
-
-	// apply labels to data
-	// set outDir to current dir or flagOut
-	var outDir string
-	if outputPath != "" {
-		outDir = outputPath
-	} else {
-		outDir = "."
-	}
-
-	// label all layer data in outDir
-	// first read directory
-	files, err := ioutil.ReadDir(outDir)
-	if err != nil {
-		return err
-	}
-
-	var (
-		wg  sync.WaitGroup
-		pbs []*pb.ProgressBar
-	)
-
-	// iterate over all files in dir
-	for _, f := range files {
-		// check if its an audit record file
-		if strings.HasSuffix(f.Name(), ".ncap.gz") || strings.HasSuffix(f.Name(), ".ncap") {
-			wg.Add(1)
-
-			var (
-				// get record name
-				filename = f.Name()
-				typ      = strings.TrimSuffix(strings.TrimSuffix(filename, ".ncap.gz"), ".ncap")
-			)
-
-			// some record types need to be processed separately
-			// because the mapping logic differs for them
-			switch typ {
-			case "UDP":
-				pbs = append(pbs, UDP(&wg, filename, labels, outputPath, separator, selection))
-			case "TCP":
-				pbs = append(pbs, TCP(&wg, filename, labels, outputPath, separator, selection))
-			case "IPv4":
-				pbs = append(pbs, IPv4(&wg, filename, labels, outputPath, separator, selection))
-			case "IPv6":
-				pbs = append(pbs, IPv6(&wg, filename, labels, outputPath, separator, selection))
-			case "Connection":
-				pbs = append(pbs, Connections(&wg, filename, labels, outputPath, separator, selection))
-			case "Flow":
-				pbs = append(pbs, Flows(&wg, filename, labels, outputPath, separator, selection))
-			case "HTTP":
-				pbs = append(pbs, HTTP(&wg, filename, labels, outputPath, separator, selection))
-			case "TLS":
-				pbs = append(pbs, TLS(&wg, filename, labels, outputPath, separator, selection))
-			// LinkFlows can currently not be labeled with suricata because the alerts dont have L2 information
-			// case "LinkFlow":
-			// 	pbs = append(pbs, LinkFlow(&wg, filename, labels, outputPath, separator, selection))
-			case "NetworkFlow":
-				pbs = append(pbs, NetworkFlow(&wg, filename, labels, outputPath, separator, selection))
-			case "TransportFlow":
-				pbs = append(pbs, TransportFlow(&wg, filename, labels, outputPath, separator, selection))
-			default:
-				if !DisableLayerMapping {
-					// apply labels to all records by timestamp only
-					pbs = append(pbs, Layer(&wg, filename, typ, labelMap, labels, outputPath, separator, selection))
-				}
-			}
-		}
-	}
-
-	var pool *pb.Pool
-	if UseProgressBars {
-
-		// wait for goroutines to start and initialize
-		// otherwise progress bars will bug
-		time.Sleep(3 * time.Second)
-
-		// start pool
-		pool, err = pb.StartPool(pbs...)
-		if err != nil {
-			return err
-		}
-		utils.ClearScreen()
-	}
-
-	wg.Wait()
-
-	if UseProgressBars {
-		// close pool
-		if err := pool.Stop(); err != nil {
-			fmt.Println("failed to stop progress bar pool:", err)
-		}
-	}
-
-	fmt.Println("\ndone in", time.Since(start))
-	return nil
+	return applyLabels(start, labelMap, labels, outputPath, separator, selection)
 }
 
 // ParseSuricataFastLog returns labels for a given suricata fast.log contents.
@@ -423,3 +314,126 @@ func ParseSuricataFastLog(contents []byte, useDescription bool) (labelMap map[st
 
 	return labelMap, arr, nil
 }
+
+// applyLabels creates labeled CSV files for all audit records in the output directory.
+// labelMap is used to label layer records by timestamp, labels are used for all other record types.
+func applyLabels(start time.Time, labelMap map[string]*SuricataAlert, labels []*SuricataAlert, outputPath, separator, selection string) error {
+	if len(labels) == 0 {
+		fmt.Println("no labels found.")
+		os.Exit(0)
+	}
+
+	fmt.Println("got", len(labels), "labels")
+
+	rows := [][]string{}
+	for c, num := range ClassificationMap {
+		rows = append(rows, []string{c, strconv.Itoa(num)})
+	}
+
+	// print alert summary
+	tui.Table(os.Stdout, []string{"Classification", "Count"}, rows)
+	fmt.Println()
+
+	// apply labels to data
+	// set outDir to current dir or flagOut
+	var outDir string
+	if outputPath != "" {
+		outDir = outputPath
+	} else {
+		outDir = "."
+	}
+
+	// label all layer data in outDir
+	// first read directory
+	files, err := ioutil.ReadDir(outDir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg  sync.WaitGroup
+		pbs []*pb.ProgressBar
+	)
+
+	// iterate over all files in dir
+	for _, f := range files {
+		// check if its an audit record file
+		if strings.HasSuffix(f.Name(), ".ncap.gz") || strings.HasSuffix(f.Name(), ".ncap") {
+
+			var (
+				// get record name
+				filename = f.Name()
+				typ      = strings.TrimSuffix(strings.TrimSuffix(filename, ".ncap.gz"), ".ncap")
+			)
+
+			// YARA matches are a source of labels and not labeled themselves
+			if typ == "YARAMatch" {
+				continue
+			}
+
+			wg.Add(1)
+
+			// some record types need to be processed separately
+			// because the mapping logic differs for them
+			switch typ {
+			case "UDP":
+				pbs = append(pbs, UDP(&wg, filename, labels, outputPath, separator, selection))
+			case "TCP":
+				pbs = append(pbs, TCP(&wg, filename, labels, outputPath, separator, selection))
+			case "IPv4":
+				pbs = append(pbs, IPv4(&wg, filename, labels, outputPath, separator, selection))
+			case "IPv6":
+				pbs = append(pbs, IPv6(&wg, filename, labels, outputPath, separator, selection))
+			case "Connection":
+				pbs = append(pbs, Connections(&wg, filename, labels, outputPath, separator, selection))
+			case "Flow":
+				pbs = append(pbs, Flows(&wg, filename, labels, outputPath, separator, selection))
+			case "FlowFeatures":
+				pbs = append(pbs, FlowFeatures(&wg, filename, labels, outputPath, separator, selection))
+			case "HTTP":
+				pbs = append(pbs, HTTP(&wg, filename, labels, outputPath, separator, selection))
+			case "TLS":
+				pbs = append(pbs, TLS(&wg, filename, labels, outputPath, separator, selection))
+			// LinkFlows can currently not be labeled with suricata because the alerts dont have L2 information
+			// case "LinkFlow":
+			// 	pbs = append(pbs, LinkFlow(&wg, filename, labels, outputPath, separator, selection))
+			case "NetworkFlow":
+				pbs = append(pbs, NetworkFlow(&wg, filename, labels, outputPath, separator, selection))
+			case "TransportFlow":
+				pbs = append(pbs, TransportFlow(&wg, filename, labels, outputPath, separator, selection))
+			default:
+				if !DisableLayerMapping {
+					// apply labels to all records by timestamp only
+					pbs = append(pbs, Layer(&wg, filename, typ, labelMap, labels, outputPath, separator, selection))
+				}
+			}
+		}
+	}
+
+	var pool *pb.Pool
+	if UseProgressBars {
+
+		// wait for goroutines to start and initialize
+		// otherwise progress bars will bug
+		time.Sleep(3 * time.Second)
+
+		// start pool
+		pool, err = pb.StartPool(pbs...)
+		if err != nil {
+			return err
+		}
+		utils.ClearScreen()
+	}
+
+	wg.Wait()
+
+	if UseProgressBars {
+		// close pool
+		if err := pool.Stop(); err != nil {
+			fmt.Println("failed to stop progress bar pool:", err)
+		}
+	}
+
+	fmt.Println("\ndone in", time.Since(start))
+	return nil
+}
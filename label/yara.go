@@ -0,0 +1,237 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package label
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/gopacket/pcapgo"
+	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/dreadl0ck/netcap/yara"
+)
+
+// packetSource is implemented by the pcap and pcapng readers.
+type packetSource interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// YARA creates labeled CSV files for audit records derived from the provided input file
+// alerts are generated by scanning the packet payloads of the input pcap with the YARA rules at rulesPath
+// matches on reassembled TCP streams and HTTP bodies are added from the YARAMatch audit records,
+// that net.capture creates in the output directory when using the -yara flag.
+// if no output directory is specified, netcap audit records are expected in the current directory.
+func YARA(inputPcap, rulesPath, outputPath string, useDescription bool, separator, selection string) error {
+	start := time.Now()
+
+	rules, err := yara.LoadDir(rulesPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("scanning", inputPcap, "with", rules.Len(), "YARA rules...")
+
+	labelMap, labels, err := scanPcapYARA(inputPcap, rules, useDescription)
+	if err != nil {
+		return err
+	}
+
+	var outDir string
+	if outputPath != "" {
+		outDir = outputPath
+	} else {
+		outDir = "."
+	}
+
+	// add matches on stream data
+	// the timestamp refers to the first packet of the stream,
+	// so for packet based records only the first packet of the stream will be labeled
+	streamLabels, err := readYARAMatches(filepath.Join(outDir, "YARAMatch.ncap.gz"), useDescription)
+	if err != nil {
+		return err
+	}
+	labels = append(labels, streamLabels...)
+
+	return applyLabels(start, labelMap, labels, outputPath, separator, selection)
+}
+
+// scanPcapYARA scans the network and transport layer payloads of all packets in the file
+func scanPcapYARA(path string, rules *yara.Rules, useDescription bool) (labelMap map[string]*SuricataAlert, arr []*SuricataAlert, err error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	// try pcap first, then pcapng
+	var r packetSource
+	r, err = pcapgo.NewReader(f)
+	if err != nil {
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		r, err = pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	labelMap = make(map[string]*SuricataAlert)
+
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		p := gopacket.NewPacket(data, r.LinkType(), gopacket.Lazy)
+
+		nl := p.NetworkLayer()
+		if nl == nil {
+			continue
+		}
+
+		var (
+			payload = nl.LayerPayload()
+			proto   string
+			srcPort int
+			dstPort int
+		)
+		if tl := p.TransportLayer(); tl != nil {
+			payload = tl.LayerPayload()
+			proto = tl.LayerType().String()
+			srcPort, _ = strconv.Atoi(tl.TransportFlow().Src().String())
+			dstPort, _ = strconv.Atoi(tl.TransportFlow().Dst().String())
+		} else if l := p.Layers(); len(l) > 2 {
+			proto = l[2].LayerType().String()
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		for _, m := range rules.Scan(payload) {
+			a := &SuricataAlert{
+				Timestamp:      utils.TimeToString(ci.Timestamp),
+				Proto:          proto,
+				SrcIP:          nl.NetworkFlow().Src().String(),
+				SrcPort:        srcPort,
+				DstIP:          nl.NetworkFlow().Dst().String(),
+				DstPort:        dstPort,
+				Classification: m.Rule,
+				Description:    m.Meta["description"],
+			}
+			if !addYARAAlert(a, useDescription) {
+				continue
+			}
+
+			arr = append(arr, a)
+
+			// keep the first alert for each timestamp
+			if _, ok := labelMap[a.Timestamp]; !ok {
+				labelMap[a.Timestamp] = a
+			}
+		}
+	}
+
+	return labelMap, arr, nil
+}
+
+// readYARAMatches converts the stream and HTTP body matches from a YARAMatch audit record file to alerts
+// if the file does not exist, no alerts are returned
+func readYARAMatches(path string, useDescription bool) (arr []*SuricataAlert, err error) {
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	r, err := netcap.Open(path, netcap.DefaultBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	header := r.ReadHeader()
+	if header.Type != types.Type_NC_YARAMatch {
+		return nil, fmt.Errorf("file does not contain YARAMatch records: %s", header.Type.String())
+	}
+
+	m := new(types.YARAMatch)
+	for {
+		err := r.Next(m)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// payload matches are already collected when scanning the pcap
+		if m.Source == "Payload" {
+			continue
+		}
+
+		// matches on HTTP bodies are labeled as TCP
+		proto := m.Protocol
+		if proto == "HTTP" {
+			proto = "TCP"
+		}
+
+		a := &SuricataAlert{
+			Timestamp:      m.Timestamp,
+			Proto:          proto,
+			SrcIP:          m.SrcIP,
+			SrcPort:        int(m.SrcPort),
+			DstIP:          m.DstIP,
+			DstPort:        int(m.DstPort),
+			Classification: m.Rule,
+			Description:    m.Description,
+		}
+		if addYARAAlert(a, useDescription) {
+			arr = append(arr, a)
+		}
+	}
+
+	return arr, nil
+}
+
+// addYARAAlert applies the description and exclusion settings
+// and returns whether the alert shall be used for labeling
+func addYARAAlert(a *SuricataAlert, useDescription bool) bool {
+
+	// check if excluded prior to flipping
+	if excluded[a.Classification] {
+		return false
+	}
+
+	// use the description from the rule meta section if requested and present
+	if useDescription && a.Description != "" {
+		a.Classification = a.Description
+	}
+
+	// count total occurrences of classification
+	ClassificationMap[a.Classification]++
+
+	return !excluded[a.Classification]
+}
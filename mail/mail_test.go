@@ -0,0 +1,142 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package mail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testMessage = "From: =?UTF-8?B?SsO2cmc=?= <joerg@example.com>\r\n" +
+	"To: Alice <alice@example.com>, bob@example.org\r\n" +
+	"Subject: =?ISO-8859-1?Q?Rechnung_f=FCr_M=E4rz?=\r\n" +
+	"Message-ID: <1234@mail.example.com>\r\n" +
+	"Date: Mon, 14 Sep 2020 12:00:00 +0200\r\n" +
+	"X-Mailer: Mailer 1.0\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"see attached\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream; name=\"invoice.exe\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"Content-Disposition: attachment; filename=\"invoice.exe\"\r\n" +
+	"\r\n" +
+	"TVqQAAMAAAAE\r\n" +
+	"AAAA//8AALgA\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"Content-Disposition: attachment\r\n" +
+	"\r\n" +
+	"caf=C3=A9\r\n" +
+	"--outer--\r\n"
+
+func TestParseMessage(t *testing.T) {
+
+	m, err := ParseMessage([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.From != "Jörg <joerg@example.com>" || m.Subject != "Rechnung für März" {
+		t.Errorf("unexpected decoded headers: %q %q", m.From, m.Subject)
+	}
+	if strings.Join(m.To, ",") != "alice@example.com,bob@example.org" {
+		t.Errorf("unexpected recipients: %v", m.To)
+	}
+	if m.MessageID != "1234@mail.example.com" || m.XMailer != "Mailer 1.0" || m.Date == "" {
+		t.Errorf("unexpected headers: %+v", m)
+	}
+
+	if len(m.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(m.Attachments))
+	}
+	a := m.Attachments[0]
+	if a.Name != "invoice.exe" || a.ContentType != "application/octet-stream" || !bytes.HasPrefix(a.Data, []byte("MZ")) || len(a.Data) != 18 {
+		t.Errorf("unexpected attachment: %q %q %x", a.Name, a.ContentType, a.Data)
+	}
+	if a := m.Attachments[1]; a.Name != "" || string(a.Data) != "café" {
+		t.Errorf("unexpected quoted-printable attachment: %q %q", a.Name, a.Data)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		line string
+		code int
+		text string
+		last bool
+		ok   bool
+	}{
+		{"250-mail.example.com\r\n", 250, "mail.example.com", false, true},
+		{"250 AUTH PLAIN LOGIN\r\n", 250, "AUTH PLAIN LOGIN", true, true},
+		{"354\r\n", 354, "", true, true},
+		{"hello\r\n", 0, "", false, false},
+		{"999 x\r\n", 0, "", false, false},
+	}
+	for _, test := range tests {
+		code, text, last, ok := ParseReply(test.line)
+		if code != test.code || text != test.text || last != test.last || ok != test.ok {
+			t.Errorf("%q: unexpected result %d %q %v %v", test.line, code, text, last, ok)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	if verb, arg := ParseCommand("mail FROM:<a@example.com> SIZE=10\r\n"); verb != "MAIL" || Path(arg) != "a@example.com" {
+		t.Errorf("unexpected command: %s %s", verb, arg)
+	}
+	if p := Path("TO: b@example.com"); p != "b@example.com" {
+		t.Errorf("unexpected path: %s", p)
+	}
+	if u := PlainUser("AHVzZXIAc2VjcmV0"); u != "user" {
+		t.Errorf("unexpected PLAIN user: %q", u)
+	}
+	if m := Mechanisms("AUTH PLAIN login"); strings.Join(m, " ") != "PLAIN LOGIN" {
+		t.Errorf("unexpected mechanisms: %v", m)
+	}
+	if m := Mechanisms("IMAP4rev1 STARTTLS AUTH=PLAIN AUTH=XOAUTH2"); strings.Join(m, " ") != "PLAIN XOAUTH2" {
+		t.Errorf("unexpected IMAP mechanisms: %v", m)
+	}
+	if s, text, ok := ParsePOP3Status("-ERR invalid password\r\n"); !ok || s != "-ERR" || text != "invalid password" {
+		t.Errorf("unexpected POP3 status: %s %s", s, text)
+	}
+	if !IsTerminator([]byte(".\r\n")) || string(Unstuff([]byte("..x\r\n"))) != ".x\r\n" {
+		t.Error("unexpected dot stuffing")
+	}
+}
+
+func TestIMAP(t *testing.T) {
+	tag, verb, arg := ParseIMAPLine("a1 login \"us\\\"er\" secret\r\n")
+	if tag != "a1" || verb != "LOGIN" || Atom(arg) != "us\"er" {
+		t.Errorf("unexpected command: %s %s %s", tag, verb, Atom(arg))
+	}
+	line := "* 1 FETCH (UID 5 BODY[] {310}\r\n"
+	if n, ok := Literal(line); !ok || n != 310 || !IsMessageLiteral(line) {
+		t.Errorf("expected message literal of 310 bytes")
+	}
+	if n, ok := Literal("a2 APPEND INBOX {12+}"); !ok || n != 12 {
+		t.Errorf("expected non synchronizing literal")
+	}
+	if IsMessageLiteral("* 1 FETCH (BODY[HEADER] {20}") {
+		t.Error("expected header literal to be ignored")
+	}
+}
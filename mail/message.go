@@ -0,0 +1,200 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+// Package mail implements parsing of the messages transferred with SMTP, POP3 and IMAP
+// and of the commands and replies of these protocols.
+package mail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// maximum nesting of multipart bodies
+const maxDepth = 10
+
+var wordDecoder = &mime.WordDecoder{}
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message contains the headers and attachments of a message.
+type Message struct {
+	From        string
+	To          []string
+	Cc          []string
+	Subject     string
+	MessageID   string
+	Date        string
+	XMailer     string
+	ContentType string
+	Attachments []*Attachment
+}
+
+// ParseMessage parses the headers of a RFC 5322 message and collects the attachments from its MIME parts.
+// If the body cannot be parsed, the message is returned with the attachments found so far along with the error.
+func ParseMessage(data []byte) (*Message, error) {
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		h = msg.Header
+		m = &Message{
+			From:        DecodeHeader(h.Get("From")),
+			To:          addresses(h.Get("To")),
+			Cc:          addresses(h.Get("Cc")),
+			Subject:     DecodeHeader(h.Get("Subject")),
+			MessageID:   strings.Trim(h.Get("Message-Id"), "<> "),
+			Date:        h.Get("Date"),
+			XMailer:     DecodeHeader(h.Get("X-Mailer")),
+			ContentType: h.Get("Content-Type"),
+		}
+	)
+	if m.XMailer == "" {
+		m.XMailer = DecodeHeader(h.Get("User-Agent"))
+	}
+
+	err = m.parsePart(textproto.MIMEHeader(h), msg.Body, 0)
+	return m, err
+}
+
+// parsePart collects the attachments of a part and recurses into multipart bodies.
+func (m *Message) parsePart(h textproto.MIMEHeader, body io.Reader, depth int) error {
+
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxDepth || params["boundary"] == "" {
+			return nil
+		}
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextRawPart()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			err = m.parsePart(p.Header, p, depth+1)
+			p.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	name := filename(h, params)
+	disposition, _, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if name == "" && disposition != "attachment" {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(decodeTransfer(body, h.Get("Content-Transfer-Encoding")))
+	if err != nil {
+		return err
+	}
+	m.Attachments = append(m.Attachments, &Attachment{
+		Name:        name,
+		ContentType: mediaType,
+		Data:        data,
+	})
+	return nil
+}
+
+// filename returns the decoded name of an attachment from the Content-Disposition or the Content-Type header.
+func filename(h textproto.MIMEHeader, contentTypeParams map[string]string) string {
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return DecodeHeader(params["filename"])
+	}
+	return DecodeHeader(contentTypeParams["name"])
+}
+
+// decodeTransfer removes the content transfer encoding.
+func decodeTransfer(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner removes whitespace from base64 encoded data, the decoder only ignores line breaks.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	j := 0
+	for _, b := range p[:n] {
+		if b != ' ' && b != '\t' {
+			p[j] = b
+			j++
+		}
+	}
+	return j, err
+}
+
+// addresses returns the addresses of a header with an address list,
+// or the decoded header if it cannot be parsed.
+func addresses(header string) []string {
+	if header == "" {
+		return nil
+	}
+	list, err := mail.ParseAddressList(header)
+	if err != nil {
+		return []string{DecodeHeader(header)}
+	}
+	res := make([]string, len(list))
+	for i, a := range list {
+		res[i] = a.Address
+	}
+	return res
+}
+
+// DecodeHeader decodes the RFC 2047 encoded words of a header,
+// the header is returned unchanged if the charset is not supported.
+func DecodeHeader(header string) string {
+	s, err := wordDecoder.DecodeHeader(header)
+	if err != nil {
+		return header
+	}
+	return s
+}
+
+// Hash returns the hex encoded SHA-256 of the data.
+func Hash(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
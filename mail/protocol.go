@@ -0,0 +1,194 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// ParseCommand splits a SMTP or POP3 command line into the upper case verb and its argument.
+func ParseCommand(line string) (verb, arg string) {
+	line = strings.TrimRight(line, "\r\n")
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		return strings.ToUpper(line[:i]), strings.TrimSpace(line[i+1:])
+	}
+	return strings.ToUpper(line), ""
+}
+
+// ParseReply parses a line of a SMTP reply.
+// last is false for the lines of a multiline reply that are followed by further lines.
+func ParseReply(line string) (code int, text string, last bool, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 3 {
+		return 0, "", false, false
+	}
+	code, err := strconv.Atoi(line[:3])
+	if err != nil || code < 100 || code > 599 {
+		return 0, "", false, false
+	}
+	if len(line) == 3 {
+		return code, "", true, true
+	}
+	switch line[3] {
+	case ' ':
+		return code, line[4:], true, true
+	case '-':
+		return code, line[4:], false, true
+	}
+	return 0, "", false, false
+}
+
+// Path returns the address of a MAIL FROM or RCPT TO argument, e.g. FROM:<user@example.com> SIZE=1024.
+func Path(arg string) string {
+	if i := strings.IndexByte(arg, ':'); i >= 0 {
+		arg = strings.TrimSpace(arg[i+1:])
+	}
+	if strings.HasPrefix(arg, "<") {
+		if i := strings.IndexByte(arg, '>'); i >= 0 {
+			return arg[1:i]
+		}
+	}
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		return arg[:i]
+	}
+	return arg
+}
+
+// Unstuff removes the leading dot from a line of a SMTP or POP3 message.
+func Unstuff(line []byte) []byte {
+	if bytes.HasPrefix(line, []byte("..")) {
+		return line[1:]
+	}
+	return line
+}
+
+// IsTerminator returns true for the line that ends a SMTP or POP3 message.
+func IsTerminator(line []byte) bool {
+	return bytes.Equal(line, []byte(".\r\n")) || bytes.Equal(line, []byte(".\n"))
+}
+
+// DecodeBase64 returns the decoded base64 string, or an empty string if it is not valid.
+func DecodeBase64(s string) string {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// PlainUser returns the authentication identity from the base64 encoded SASL PLAIN credentials.
+func PlainUser(s string) string {
+	parts := strings.Split(DecodeBase64(s), "\x00")
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Mechanisms returns the upper case SASL mechanisms of a capability line,
+// e.g. AUTH PLAIN LOGIN for SMTP, SASL PLAIN LOGIN for POP3 or AUTH=PLAIN AUTH=LOGIN for IMAP.
+func Mechanisms(line string) []string {
+	var (
+		fields = strings.Fields(strings.ToUpper(line))
+		res    []string
+	)
+	for i, f := range fields {
+		switch {
+		case strings.HasPrefix(f, "AUTH="):
+			res = append(res, f[5:])
+		case i > 0 && (fields[0] == "AUTH" || fields[0] == "SASL"):
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// ParsePOP3Status returns the status indicator of a POP3 response line, +OK, -ERR or + for continuations.
+func ParsePOP3Status(line string) (status, text string, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	for _, s := range []string{"+OK", "-ERR", "+"} {
+		if strings.HasPrefix(line, s) {
+			return s, strings.TrimSpace(line[len(s):]), true
+		}
+	}
+	return "", "", false
+}
+
+// ParseIMAPLine splits an IMAP command or response line into the tag, the upper case verb or status and the rest.
+// The tag of untagged responses is *, the tag of continuation requests is +.
+func ParseIMAPLine(line string) (tag, verb, arg string) {
+	line = strings.TrimRight(line, "\r\n")
+	fields := strings.SplitN(line, " ", 3)
+	switch len(fields) {
+	case 1:
+		return fields[0], "", ""
+	case 2:
+		return fields[0], strings.ToUpper(fields[1]), ""
+	}
+	return fields[0], strings.ToUpper(fields[1]), fields[2]
+}
+
+// Literal returns the size of the literal announced at the end of an IMAP line, e.g. {310} or {310+}.
+func Literal(line string) (int, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// IsMessageLiteral returns true if the literal at the end of a FETCH response line contains a whole message.
+func IsMessageLiteral(line string) bool {
+	line = strings.ToUpper(strings.TrimRight(line, "\r\n"))
+	if i := strings.LastIndexByte(line, '{'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	return strings.HasSuffix(line, "BODY[]") || strings.HasSuffix(line, "RFC822") || strings.HasSuffix(line, "BINARY[]")
+}
+
+// Atom returns the first atom or quoted string of an IMAP argument.
+func Atom(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if strings.HasPrefix(arg, "\"") {
+		var b strings.Builder
+		for i := 1; i < len(arg); i++ {
+			switch arg[i] {
+			case '\\':
+				if i+1 < len(arg) {
+					i++
+					b.WriteByte(arg[i])
+				}
+			case '"':
+				return b.String()
+			default:
+				b.WriteByte(arg[i])
+			}
+		}
+		return b.String()
+	}
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		return arg[:i]
+	}
+	return arg
+}
@@ -0,0 +1,347 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// Port is the TCP port of Modbus TCP
+	Port = 502
+
+	mbapSize = 7
+
+	// maximum size of an ADU, the MBAP header and a PDU of up to 253 bytes
+	maxADUSize = mbapSize + 253
+)
+
+// Function codes.
+const (
+	ReadCoils                  = 0x01
+	ReadDiscreteInputs         = 0x02
+	ReadHoldingRegisters       = 0x03
+	ReadInputRegisters         = 0x04
+	WriteSingleCoil            = 0x05
+	WriteSingleRegister        = 0x06
+	ReadExceptionStatus        = 0x07
+	Diagnostics                = 0x08
+	GetCommEventCounter        = 0x0b
+	GetCommEventLog            = 0x0c
+	WriteMultipleCoils         = 0x0f
+	WriteMultipleRegisters     = 0x10
+	ReportServerID             = 0x11
+	ReadFileRecord             = 0x14
+	WriteFileRecord            = 0x15
+	MaskWriteRegister          = 0x16
+	ReadWriteMultipleRegisters = 0x17
+	ReadFIFOQueue              = 0x18
+	EncapsulatedInterface      = 0x2b
+)
+
+var (
+	// ErrInvalidMBAP is returned if the data does not start with a valid MBAP header.
+	ErrInvalidMBAP = errors.New("modbus: invalid MBAP header")
+
+	// ErrTruncated is returned if the fields of a function end prematurely.
+	ErrTruncated = errors.New("modbus: truncated PDU")
+)
+
+// FrameLength returns the size of the ADU at the start of the data.
+// ok is false if the header is incomplete.
+func FrameLength(data []byte) (n int, ok bool, err error) {
+	if len(data) < mbapSize {
+		return 0, false, nil
+	}
+	n = 6 + int(binary.BigEndian.Uint16(data[4:6]))
+	if binary.BigEndian.Uint16(data[2:4]) != 0 || n < mbapSize+1 || n > maxADUSize {
+		return 0, false, ErrInvalidMBAP
+	}
+	return n, true, nil
+}
+
+// PDU contains the function specific fields of a request or response.
+type PDU struct {
+	Function      uint8
+	Exception     bool
+	ExceptionCode uint8
+
+	// range of coils, inputs or registers that is read
+	ReadAddress  uint16
+	ReadQuantity uint16
+
+	// range of coils or registers that is written
+	WriteAddress  uint16
+	WriteQuantity uint16
+
+	// register values, coil and input states as 0 or 1, the AND and OR mask of a mask write,
+	// the data of diagnostics or the status of read exception status
+	Values []uint16
+
+	// diagnostics sub-function
+	SubFunction uint16
+}
+
+// ParseRequest decodes a request PDU, starting with the function code.
+func ParseRequest(pdu []byte) (*PDU, error) {
+	if len(pdu) < 1 {
+		return nil, ErrTruncated
+	}
+	var (
+		p    = &PDU{Function: pdu[0]}
+		data = pdu[1:]
+	)
+
+	switch p.Function {
+	case ReadCoils, ReadDiscreteInputs, ReadHoldingRegisters, ReadInputRegisters:
+		if len(data) < 4 {
+			return p, ErrTruncated
+		}
+		p.ReadAddress = binary.BigEndian.Uint16(data[0:2])
+		p.ReadQuantity = binary.BigEndian.Uint16(data[2:4])
+	case WriteSingleCoil, WriteSingleRegister:
+		if err := p.parseWriteSingle(data); err != nil {
+			return p, err
+		}
+	case WriteMultipleCoils, WriteMultipleRegisters:
+		if len(data) < 5 {
+			return p, ErrTruncated
+		}
+		p.WriteAddress = binary.BigEndian.Uint16(data[0:2])
+		p.WriteQuantity = binary.BigEndian.Uint16(data[2:4])
+		values, ok := byteCount(data[4:])
+		if p.Function == WriteMultipleCoils {
+			p.Values = unpackBits(values, int(p.WriteQuantity))
+		} else {
+			p.Values = registers(values)
+		}
+		if !ok {
+			return p, ErrTruncated
+		}
+	case MaskWriteRegister:
+		if err := p.parseMaskWrite(data); err != nil {
+			return p, err
+		}
+	case ReadWriteMultipleRegisters:
+		if len(data) < 9 {
+			return p, ErrTruncated
+		}
+		p.ReadAddress = binary.BigEndian.Uint16(data[0:2])
+		p.ReadQuantity = binary.BigEndian.Uint16(data[2:4])
+		p.WriteAddress = binary.BigEndian.Uint16(data[4:6])
+		p.WriteQuantity = binary.BigEndian.Uint16(data[6:8])
+		values, ok := byteCount(data[8:])
+		p.Values = registers(values)
+		if !ok {
+			return p, ErrTruncated
+		}
+	case Diagnostics:
+		if err := p.parseDiagnostics(data); err != nil {
+			return p, err
+		}
+	case ReadFIFOQueue:
+		if len(data) < 2 {
+			return p, ErrTruncated
+		}
+		p.ReadAddress = binary.BigEndian.Uint16(data[0:2])
+	}
+	return p, nil
+}
+
+// ParseResponse decodes a response PDU, starting with the function code.
+// If the request of the transaction is known, the ranges that are not repeated in the response are taken from it,
+// and the states of coils and inputs are limited to the requested quantity.
+func ParseResponse(pdu []byte, req *PDU) (*PDU, error) {
+	if len(pdu) < 1 {
+		return nil, ErrTruncated
+	}
+	var (
+		p    = &PDU{Function: pdu[0] & 0x7f}
+		data = pdu[1:]
+		err  error
+	)
+	if req != nil && req.Function != p.Function {
+		req = nil
+	}
+	if req != nil {
+		p.ReadAddress, p.ReadQuantity = req.ReadAddress, req.ReadQuantity
+		p.WriteAddress, p.WriteQuantity = req.WriteAddress, req.WriteQuantity
+		p.SubFunction = req.SubFunction
+	}
+
+	if pdu[0]&0x80 != 0 {
+		p.Exception = true
+		if len(data) < 1 {
+			return p, ErrTruncated
+		}
+		p.ExceptionCode = data[0]
+		return p, nil
+	}
+
+	switch p.Function {
+	case ReadCoils, ReadDiscreteInputs:
+		values, ok := byteCount(data)
+		n := len(values) * 8
+		if req != nil && int(req.ReadQuantity) < n {
+			n = int(req.ReadQuantity)
+		}
+		p.Values = unpackBits(values, n)
+		if !ok {
+			err = ErrTruncated
+		}
+	case ReadHoldingRegisters, ReadInputRegisters, ReadWriteMultipleRegisters:
+		values, ok := byteCount(data)
+		p.Values = registers(values)
+		if !ok {
+			err = ErrTruncated
+		}
+	case WriteSingleCoil, WriteSingleRegister:
+		err = p.parseWriteSingle(data)
+	case WriteMultipleCoils, WriteMultipleRegisters:
+		if len(data) < 4 {
+			return p, ErrTruncated
+		}
+		p.WriteAddress = binary.BigEndian.Uint16(data[0:2])
+		p.WriteQuantity = binary.BigEndian.Uint16(data[2:4])
+	case MaskWriteRegister:
+		err = p.parseMaskWrite(data)
+	case Diagnostics:
+		err = p.parseDiagnostics(data)
+	case ReadExceptionStatus:
+		if len(data) < 1 {
+			return p, ErrTruncated
+		}
+		p.Values = []uint16{uint16(data[0])}
+	}
+	return p, err
+}
+
+// parseWriteSingle decodes the address and value of a single coil or register, requests and responses are identical.
+func (p *PDU) parseWriteSingle(data []byte) error {
+	if len(data) < 4 {
+		return ErrTruncated
+	}
+	p.WriteAddress = binary.BigEndian.Uint16(data[0:2])
+	p.WriteQuantity = 1
+	v := binary.BigEndian.Uint16(data[2:4])
+	if p.Function == WriteSingleCoil {
+		// ON is 0xFF00, OFF is 0x0000
+		if v == 0xff00 {
+			v = 1
+		} else {
+			v = 0
+		}
+	}
+	p.Values = []uint16{v}
+	return nil
+}
+
+// parseMaskWrite decodes the address and the AND and OR mask, requests and responses are identical.
+func (p *PDU) parseMaskWrite(data []byte) error {
+	if len(data) < 6 {
+		return ErrTruncated
+	}
+	p.WriteAddress = binary.BigEndian.Uint16(data[0:2])
+	p.WriteQuantity = 1
+	p.Values = registers(data[2:6])
+	return nil
+}
+
+// parseDiagnostics decodes the sub-function and its data, requests and responses are identical.
+func (p *PDU) parseDiagnostics(data []byte) error {
+	if len(data) < 2 {
+		return ErrTruncated
+	}
+	p.SubFunction = binary.BigEndian.Uint16(data[0:2])
+	p.Values = registers(data[2:])
+	return nil
+}
+
+// byteCount returns the values after the byte count, ok is false if they are incomplete.
+func byteCount(data []byte) (values []byte, ok bool) {
+	if len(data) < 1 {
+		return nil, false
+	}
+	n := int(data[0])
+	data = data[1:]
+	if len(data) < n {
+		return data, false
+	}
+	return data[:n], true
+}
+
+// registers decodes big endian register values, a trailing odd byte is ignored.
+func registers(data []byte) []uint16 {
+	if len(data) < 2 {
+		return nil
+	}
+	v := make([]uint16, len(data)/2)
+	for i := range v {
+		v[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return v
+}
+
+// unpackBits returns the states of up to n coils or inputs, the first coil is the least significant bit.
+func unpackBits(data []byte, n int) []uint16 {
+	if n > len(data)*8 {
+		n = len(data) * 8
+	}
+	if n <= 0 {
+		return nil
+	}
+	v := make([]uint16, n)
+	for i := range v {
+		v[i] = uint16(data[i/8]>>(uint(i)%8)) & 1
+	}
+	return v
+}
+
+// IsWrite returns true for functions that write coils, registers or files.
+func IsWrite(function uint8) bool {
+	switch function {
+	case WriteSingleCoil, WriteSingleRegister, WriteMultipleCoils, WriteMultipleRegisters,
+		WriteFileRecord, MaskWriteRegister, ReadWriteMultipleRegisters:
+		return true
+	}
+	return false
+}
+
+// IsControl returns true if the request writes to the device, restarts its communication,
+// forces it into listen only mode or clears its counters.
+func (p *PDU) IsControl() bool {
+	if p.Function == Diagnostics {
+		switch p.SubFunction {
+		case 1, 4, 10, 20:
+			return true
+		}
+		return false
+	}
+	return IsWrite(p.Function)
+}
+
+// Table returns the data table accessed by a function, or an empty string.
+func Table(function uint8) string {
+	switch function {
+	case ReadCoils, WriteSingleCoil, WriteMultipleCoils:
+		return "coils"
+	case ReadDiscreteInputs:
+		return "discrete-inputs"
+	case ReadHoldingRegisters, WriteSingleRegister, WriteMultipleRegisters, MaskWriteRegister, ReadWriteMultipleRegisters:
+		return "holding-registers"
+	case ReadInputRegisters:
+		return "input-registers"
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameLength(t *testing.T) {
+	adu := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x6b, 0x00, 0x03}
+	n, ok, err := FrameLength(adu)
+	if err != nil || !ok || n != len(adu) {
+		t.Fatal("unexpected frame length", n, ok, err)
+	}
+	if _, ok, err := FrameLength(adu[:5]); ok || err != nil {
+		t.Fatal("expected incomplete header")
+	}
+	if _, _, err := FrameLength([]byte{0x00, 0x01, 0x00, 0x01, 0x00, 0x06, 0x01}); err != ErrInvalidMBAP {
+		t.Fatal("expected invalid protocol ID, got", err)
+	}
+}
+
+func TestReadHoldingRegisters(t *testing.T) {
+	req, err := ParseRequest([]byte{0x03, 0x00, 0x6b, 0x00, 0x03})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FunctionName(req.Function) != "READ_HOLDING_REGISTERS" || req.ReadAddress != 107 || req.ReadQuantity != 3 || req.IsControl() {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	res, err := ParseResponse([]byte{0x03, 0x06, 0x02, 0x2b, 0x00, 0x00, 0x00, 0x64}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ReadAddress != 107 || res.ReadQuantity != 3 || !reflect.DeepEqual(res.Values, []uint16{555, 0, 100}) {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
+
+func TestReadCoils(t *testing.T) {
+	req, err := ParseRequest([]byte{0x01, 0x00, 0x13, 0x00, 0x0a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ParseResponse([]byte{0x01, 0x02, 0xcd, 0x01}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0xcd is 11001101, the first coil is the least significant bit, limited to the 10 requested coils
+	if !reflect.DeepEqual(res.Values, []uint16{1, 0, 1, 1, 0, 0, 1, 1, 1, 0}) {
+		t.Fatal("unexpected coils", res.Values)
+	}
+	// without the request all bits are returned
+	res, _ = ParseResponse([]byte{0x01, 0x02, 0xcd, 0x01}, nil)
+	if len(res.Values) != 16 {
+		t.Fatal("unexpected coils", res.Values)
+	}
+}
+
+func TestWrites(t *testing.T) {
+	p, err := ParseRequest([]byte{0x05, 0x00, 0xac, 0xff, 0x00})
+	if err != nil || p.WriteAddress != 172 || p.WriteQuantity != 1 || !reflect.DeepEqual(p.Values, []uint16{1}) || !p.IsControl() {
+		t.Fatalf("unexpected request: %+v %v", p, err)
+	}
+	p, err = ParseRequest([]byte{0x0f, 0x00, 0x13, 0x00, 0x0a, 0x02, 0xcd, 0x01})
+	if err != nil || p.WriteAddress != 19 || p.WriteQuantity != 10 || len(p.Values) != 10 || p.Values[2] != 1 {
+		t.Fatalf("unexpected request: %+v %v", p, err)
+	}
+	p, err = ParseRequest([]byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0a, 0x01, 0x02})
+	if err != nil || Table(p.Function) != "holding-registers" || !reflect.DeepEqual(p.Values, []uint16{10, 258}) {
+		t.Fatalf("unexpected request: %+v %v", p, err)
+	}
+	res, err := ParseResponse([]byte{0x10, 0x00, 0x01, 0x00, 0x02}, p)
+	if err != nil || res.WriteAddress != 1 || res.WriteQuantity != 2 || res.Exception {
+		t.Fatalf("unexpected response: %+v %v", res, err)
+	}
+	p, err = ParseRequest([]byte{0x17, 0x00, 0x03, 0x00, 0x06, 0x00, 0x0e, 0x00, 0x03, 0x06, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff})
+	if err != nil || p.ReadAddress != 3 || p.ReadQuantity != 6 || p.WriteAddress != 14 || p.WriteQuantity != 3 || len(p.Values) != 3 {
+		t.Fatalf("unexpected request: %+v %v", p, err)
+	}
+	if _, err := ParseRequest([]byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00}); err != ErrTruncated {
+		t.Fatal("expected truncated error, got", err)
+	}
+}
+
+func TestException(t *testing.T) {
+	req, _ := ParseRequest([]byte{0x06, 0x00, 0x01, 0x00, 0x03})
+	res, err := ParseResponse([]byte{0x86, 0x02}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Exception || res.Function != WriteSingleRegister || ExceptionName(res.ExceptionCode) != "ILLEGAL_DATA_ADDRESS" || res.WriteAddress != 1 {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+	// the request of another function is ignored
+	res, _ = ParseResponse([]byte{0x83, 0x02}, req)
+	if res.WriteAddress != 0 || FunctionName(0x83) != "READ_HOLDING_REGISTERS" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	p, err := ParseRequest([]byte{0x08, 0x00, 0x04, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if SubFunctionName(p.SubFunction) != "FORCE_LISTEN_ONLY_MODE" || !p.IsControl() {
+		t.Fatalf("unexpected request: %+v", p)
+	}
+	p, _ = ParseRequest([]byte{0x08, 0x00, 0x00, 0xa5, 0x37})
+	if p.IsControl() || !reflect.DeepEqual(p.Values, []uint16{0xa537}) {
+		t.Fatalf("unexpected request: %+v", p)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	s := NewProfiles(5)
+
+	read, _ := ParseRequest([]byte{0x03, 0x00, 0x00, 0x00, 0x02})
+	res, _ := ParseResponse([]byte{0x03, 0x04, 0x00, 0x07, 0x00, 0x08}, read)
+	s.Add("1.000000", "10.0.0.2", "10.0.0.1", 1, read, res)
+
+	write, _ := ParseRequest([]byte{0x06, 0x00, 0x01, 0x00, 0x2a})
+	res, _ = ParseResponse([]byte{0x06, 0x00, 0x01, 0x00, 0x2a}, write)
+	s.Add("2.000000", "10.0.0.3", "10.0.0.1", 1, write, res)
+
+	failed, _ := ParseRequest([]byte{0x10, 0x00, 0x02, 0x00, 0x04, 0x08, 0, 1, 0, 2, 0, 3, 0, 4})
+	res, _ = ParseResponse([]byte{0x90, 0x02}, failed)
+	s.Add("3.000000", "10.0.0.3", "10.0.0.1", 1, failed, res)
+
+	s.Add("4.000000", "10.0.0.2", "10.0.0.1", 2, read, nil)
+
+	// unanswered requests are added when the connection is closed
+	s.Add("2.500000", "10.0.0.3", "10.0.0.1", 1, write, nil)
+
+	e := s.Entries()
+	if len(e) != 2 {
+		t.Fatal("unexpected number of devices", len(e))
+	}
+	d := e[0]
+	if d.UnitID != 1 || d.Timestamp != "1.000000" || d.TimestampLast != "3.000000" || d.NumRequests != 4 || d.NumResponses != 3 || d.NumUnanswered != 1 || d.NumExceptions != 1 {
+		t.Fatalf("unexpected device: %+v", d)
+	}
+	if !reflect.DeepEqual(d.Clients, []string{"10.0.0.2", "10.0.0.3"}) || len(d.Functions) != 3 || d.Functions[1].Requests != 2 || d.Functions[2].Exceptions != 1 {
+		t.Fatalf("unexpected device: %+v", d)
+	}
+	// the failed write covers registers 2 to 5, register 5 exceeds the limit
+	if len(d.Registers) != 5 {
+		t.Fatal("unexpected registers", d.Registers)
+	}
+	r0, r1, r2 := d.Registers[0], d.Registers[1], d.Registers[2]
+	if r0.Reads != 1 || r0.LastValue != 7 || r1.Reads != 1 || r1.Writes != 2 || r1.LastValue != 42 || r2.Writes != 1 || r2.Exceptions != 1 || r2.LastValue != -1 {
+		t.Fatal("unexpected registers", d.Registers)
+	}
+	if e[1].UnitID != 2 || e[1].NumUnanswered != 1 || e[1].Registers[0].LastValue != -1 {
+		t.Fatalf("unexpected device: %+v", e[1])
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package modbus
+
+import "strconv"
+
+var functionNames = map[uint8]string{
+	ReadCoils:                  "READ_COILS",
+	ReadDiscreteInputs:         "READ_DISCRETE_INPUTS",
+	ReadHoldingRegisters:       "READ_HOLDING_REGISTERS",
+	ReadInputRegisters:         "READ_INPUT_REGISTERS",
+	WriteSingleCoil:            "WRITE_SINGLE_COIL",
+	WriteSingleRegister:        "WRITE_SINGLE_REGISTER",
+	ReadExceptionStatus:        "READ_EXCEPTION_STATUS",
+	Diagnostics:                "DIAGNOSTICS",
+	GetCommEventCounter:        "GET_COMM_EVENT_COUNTER",
+	GetCommEventLog:            "GET_COMM_EVENT_LOG",
+	WriteMultipleCoils:         "WRITE_MULTIPLE_COILS",
+	WriteMultipleRegisters:     "WRITE_MULTIPLE_REGISTERS",
+	ReportServerID:             "REPORT_SERVER_ID",
+	ReadFileRecord:             "READ_FILE_RECORD",
+	WriteFileRecord:            "WRITE_FILE_RECORD",
+	MaskWriteRegister:          "MASK_WRITE_REGISTER",
+	ReadWriteMultipleRegisters: "READ_WRITE_MULTIPLE_REGISTERS",
+	ReadFIFOQueue:              "READ_FIFO_QUEUE",
+	EncapsulatedInterface:      "ENCAPSULATED_INTERFACE_TRANSPORT",
+}
+
+var exceptionNames = map[uint8]string{
+	0x01: "ILLEGAL_FUNCTION",
+	0x02: "ILLEGAL_DATA_ADDRESS",
+	0x03: "ILLEGAL_DATA_VALUE",
+	0x04: "SERVER_DEVICE_FAILURE",
+	0x05: "ACKNOWLEDGE",
+	0x06: "SERVER_DEVICE_BUSY",
+	0x08: "MEMORY_PARITY_ERROR",
+	0x0a: "GATEWAY_PATH_UNAVAILABLE",
+	0x0b: "GATEWAY_TARGET_DEVICE_FAILED_TO_RESPOND",
+}
+
+var subFunctionNames = map[uint16]string{
+	0:  "RETURN_QUERY_DATA",
+	1:  "RESTART_COMMUNICATIONS_OPTION",
+	2:  "RETURN_DIAGNOSTIC_REGISTER",
+	3:  "CHANGE_ASCII_INPUT_DELIMITER",
+	4:  "FORCE_LISTEN_ONLY_MODE",
+	10: "CLEAR_COUNTERS_AND_DIAGNOSTIC_REGISTER",
+	11: "RETURN_BUS_MESSAGE_COUNT",
+	12: "RETURN_BUS_COMMUNICATION_ERROR_COUNT",
+	13: "RETURN_BUS_EXCEPTION_ERROR_COUNT",
+	14: "RETURN_SERVER_MESSAGE_COUNT",
+	15: "RETURN_SERVER_NO_RESPONSE_COUNT",
+	16: "RETURN_SERVER_NAK_COUNT",
+	17: "RETURN_SERVER_BUSY_COUNT",
+	18: "RETURN_BUS_CHARACTER_OVERRUN_COUNT",
+	20: "CLEAR_OVERRUN_COUNTER_AND_FLAG",
+}
+
+// FunctionName returns the name of a function code, without the exception bit.
+func FunctionName(function uint8) string {
+	if n, ok := functionNames[function&0x7f]; ok {
+		return n
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(function&0x7f))
+}
+
+// ExceptionName returns the name of an exception code.
+func ExceptionName(code uint8) string {
+	if n, ok := exceptionNames[code]; ok {
+		return n
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(code))
+}
+
+// SubFunctionName returns the name of a diagnostics sub-function.
+func SubFunctionName(sub uint16) string {
+	if n, ok := subFunctionNames[sub]; ok {
+		return n
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(sub))
+}
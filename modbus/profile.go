@@ -0,0 +1,221 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package modbus
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+type deviceKey struct {
+	server string
+	unit   uint8
+}
+
+type registerKey struct {
+	table   string
+	address uint16
+}
+
+type device struct {
+	record    *types.ModbusDevice
+	clients   map[string]struct{}
+	functions map[uint8]*types.ModbusFunction
+	registers map[registerKey]*types.ModbusRegister
+}
+
+// Profiles keeps the functions used and the coils, inputs and registers accessed on each server and unit.
+type Profiles struct {
+	mu sync.Mutex
+
+	// maximum number of coils, inputs and registers tracked per device,
+	// accesses to further addresses are only counted in the totals
+	maxRegisters int
+
+	devices map[deviceKey]*device
+}
+
+// NewProfiles returns a new store, that tracks up to maxRegisters addresses per device.
+func NewProfiles(maxRegisters int) *Profiles {
+	return &Profiles{
+		maxRegisters: maxRegisters,
+		devices:      make(map[deviceKey]*device),
+	}
+}
+
+// Add adds a transaction between the client and the unit of the server.
+// res is nil if the request was not answered.
+func (p *Profiles) Add(timestamp, client, server string, unit uint8, req, res *PDU) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := deviceKey{server: server, unit: unit}
+	d, ok := p.devices[k]
+	if !ok {
+		d = &device{
+			record: &types.ModbusDevice{
+				Timestamp: timestamp,
+				ServerIP:  server,
+				UnitID:    int32(unit),
+			},
+			clients:   make(map[string]struct{}),
+			functions: make(map[uint8]*types.ModbusFunction),
+			registers: make(map[registerKey]*types.ModbusRegister),
+		}
+		p.devices[k] = d
+	}
+	// unanswered requests are added when the connection is closed
+	ts := utils.StringToTime(timestamp)
+	if ts.Before(utils.StringToTime(d.record.Timestamp)) {
+		d.record.Timestamp = timestamp
+	}
+	if d.record.TimestampLast == "" || ts.After(utils.StringToTime(d.record.TimestampLast)) {
+		d.record.TimestampLast = timestamp
+	}
+	d.record.NumRequests++
+	d.clients[client] = struct{}{}
+
+	f, ok := d.functions[req.Function]
+	if !ok {
+		f = &types.ModbusFunction{
+			FunctionCode: int32(req.Function),
+			Name:         FunctionName(req.Function),
+		}
+		d.functions[req.Function] = f
+	}
+	f.Requests++
+
+	var exception bool
+	if res == nil {
+		d.record.NumUnanswered++
+	} else {
+		d.record.NumResponses++
+		if res.Exception {
+			exception = true
+			d.record.NumExceptions++
+			f.Exceptions++
+		}
+	}
+
+	table := Table(req.Function)
+	if table == "" {
+		return
+	}
+
+	// read values are only known from successful responses
+	if req.ReadQuantity > 0 {
+		var values []uint16
+		if res != nil && !exception {
+			values = res.Values
+		}
+		for i := 0; i < int(req.ReadQuantity); i++ {
+			r := p.register(d, table, req.ReadAddress+uint16(i))
+			if r == nil {
+				continue
+			}
+			r.Reads++
+			if exception {
+				r.Exceptions++
+			}
+			if i < len(values) {
+				r.LastValue = int32(values[i])
+			}
+		}
+	}
+
+	if req.WriteQuantity > 0 {
+		// the masks of a mask write are not the value of the register
+		success := res != nil && !exception && req.Function != MaskWriteRegister
+		for i := 0; i < int(req.WriteQuantity); i++ {
+			r := p.register(d, table, req.WriteAddress+uint16(i))
+			if r == nil {
+				continue
+			}
+			r.Writes++
+			if exception {
+				r.Exceptions++
+			}
+			if success && i < len(req.Values) {
+				r.LastValue = int32(req.Values[i])
+			}
+		}
+	}
+}
+
+// register returns the entry for the address, or nil if the device has reached the maximum number of entries.
+func (p *Profiles) register(d *device, table string, address uint16) *types.ModbusRegister {
+	k := registerKey{table: table, address: address}
+	if r, ok := d.registers[k]; ok {
+		return r
+	}
+	if len(d.registers) >= p.maxRegisters {
+		return nil
+	}
+	r := &types.ModbusRegister{
+		Table:     table,
+		Address:   int32(address),
+		LastValue: -1,
+	}
+	d.registers[k] = r
+	return r
+}
+
+// Entries returns the profiles of all devices, sorted by server and unit.
+func (p *Profiles) Entries() []*types.ModbusDevice {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]*types.ModbusDevice, 0, len(p.devices))
+	for _, d := range p.devices {
+		r := d.record
+
+		r.Clients = make([]string, 0, len(d.clients))
+		for c := range d.clients {
+			r.Clients = append(r.Clients, c)
+		}
+		sort.Strings(r.Clients)
+
+		r.Functions = make([]*types.ModbusFunction, 0, len(d.functions))
+		for _, f := range d.functions {
+			r.Functions = append(r.Functions, f)
+		}
+		sort.Slice(r.Functions, func(i, j int) bool {
+			return r.Functions[i].FunctionCode < r.Functions[j].FunctionCode
+		})
+
+		r.Registers = make([]*types.ModbusRegister, 0, len(d.registers))
+		for _, reg := range d.registers {
+			r.Registers = append(r.Registers, reg)
+		}
+		sort.Slice(r.Registers, func(i, j int) bool {
+			if r.Registers[i].Table != r.Registers[j].Table {
+				return r.Registers[i].Table < r.Registers[j].Table
+			}
+			return r.Registers[i].Address < r.Registers[j].Address
+		})
+
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ServerIP != out[j].ServerIP {
+			return out[i].ServerIP < out[j].ServerIP
+		}
+		return out[i].UnitID < out[j].UnitID
+	})
+	return out
+}
@@ -146,6 +146,46 @@ func InitRecord(typ types.Type) (record proto.Message) {
 		record = new(types.CIP)
 	case types.Type_NC_ENIP:
 		record = new(types.ENIP)
+	case types.Type_NC_YARAMatch:
+		record = new(types.YARAMatch)
+	case types.Type_NC_Alert:
+		record = new(types.Alert)
+	case types.Type_NC_ScanEvent:
+		record = new(types.ScanEvent)
+	case types.Type_NC_Beacon:
+		record = new(types.Beacon)
+	case types.Type_NC_DNSAnomaly:
+		record = new(types.DNSAnomaly)
+	case types.Type_NC_FlowFeatures:
+		record = new(types.FlowFeatures)
+	case types.Type_NC_File:
+		record = new(types.File)
+	case types.Type_NC_SMB:
+		record = new(types.SMB)
+	case types.Type_NC_Kerberos:
+		record = new(types.Kerberos)
+	case types.Type_NC_NTLM:
+		record = new(types.NTLM)
+	case types.Type_NC_QUIC:
+		record = new(types.QUIC)
+	case types.Type_NC_PassiveDNS:
+		record = new(types.PassiveDNS)
+	case types.Type_NC_Mail:
+		record = new(types.Mail)
+	case types.Type_NC_MailSession:
+		record = new(types.MailSession)
+	case types.Type_NC_FTP:
+		record = new(types.FTP)
+	case types.Type_NC_DNP3:
+		record = new(types.DNP3)
+	case types.Type_NC_IEC104:
+		record = new(types.IEC104)
+	case types.Type_NC_S7Comm:
+		record = new(types.S7Comm)
+	case types.Type_NC_BACnet:
+		record = new(types.BACnet)
+	case types.Type_NC_ModbusDevice:
+		record = new(types.ModbusDevice)
 	default:
 		panic("InitRecord: unknown type: " + typ.String())
 	}
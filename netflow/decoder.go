@@ -0,0 +1,328 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// length of variable length fields in IPFIX templates
+const variableLength = 65535
+
+type templateKey struct {
+	version uint16
+	domain  uint32
+	id      uint16
+}
+
+// Decoder decodes NetFlow v5, NetFlow v9 and IPFIX messages.
+// The templates are remembered per observation domain or source id,
+// data sets are skipped until their template has been received.
+// It is not safe for concurrent use.
+type Decoder struct {
+	templates map[templateKey][]field
+}
+
+// NewDecoder returns a new Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{
+		templates: make(map[templateKey][]field),
+	}
+}
+
+// header fields that are required to decode the data records
+type header struct {
+	version   uint16
+	count     int    // number of records for NetFlow v9
+	uptime    uint32 // system uptime in milliseconds for NetFlow v9
+	unixSecs  uint32
+	unixNsecs uint32 // residual nanoseconds for NetFlow v5
+	domain    uint32
+}
+
+// Decode decodes the message at the beginning of data
+// and returns its flow records and the length of the message.
+// The version is determined from the message header, sFlow datagrams must be decoded with DecodeSFlow.
+// A NetFlow v9 message ends after the number of records announced in its header,
+// so messages of both versions can be decoded from a stream, e.g. a file written by the Exporter.
+func (d *Decoder) Decode(data []byte) ([]*Record, int, error) {
+
+	if len(data) < 2 {
+		return nil, 0, errors.New("message too short")
+	}
+
+	var (
+		h   = header{version: binary.BigEndian.Uint16(data)}
+		end int
+		pos int
+	)
+	switch h.version {
+	case VersionNetFlow5:
+		return decodeNetFlow5(data)
+	case VersionNetFlow9:
+		if len(data) < headerSizeNetFlow9 {
+			return nil, 0, errors.New("NetFlow v9 header too short")
+		}
+		h.count = int(binary.BigEndian.Uint16(data[2:]))
+		h.uptime = binary.BigEndian.Uint32(data[4:])
+		h.unixSecs = binary.BigEndian.Uint32(data[8:])
+		h.domain = binary.BigEndian.Uint32(data[16:])
+		pos, end = headerSizeNetFlow9, len(data)
+	case VersionIPFIX:
+		if len(data) < headerSizeIPFIX {
+			return nil, 0, errors.New("IPFIX header too short")
+		}
+		end = int(binary.BigEndian.Uint16(data[2:]))
+		if end < headerSizeIPFIX || end > len(data) {
+			return nil, 0, errors.Errorf("invalid IPFIX message length %d", end)
+		}
+		h.unixSecs = binary.BigEndian.Uint32(data[4:])
+		h.domain = binary.BigEndian.Uint32(data[12:])
+		pos = headerSizeIPFIX
+	default:
+		return nil, 0, errors.Errorf("unsupported version %d", h.version)
+	}
+
+	var (
+		records []*Record
+		count   int
+	)
+	for pos+setHeaderSize <= end {
+		if h.version == VersionNetFlow9 && count >= h.count {
+			break
+		}
+
+		var (
+			id     = binary.BigEndian.Uint16(data[pos:])
+			length = int(binary.BigEndian.Uint16(data[pos+2:]))
+		)
+		if length < setHeaderSize || pos+length > end {
+			return records, 0, errors.Errorf("invalid set length %d", length)
+		}
+		set := data[pos+setHeaderSize : pos+length]
+		pos += length
+
+		switch {
+		case (h.version == VersionNetFlow9 && id == 0) || (h.version == VersionIPFIX && id == 2):
+			n, err := d.decodeTemplates(h, set)
+			if err != nil {
+				return records, 0, err
+			}
+			count += n
+		case id < 256:
+			// options templates and reserved sets
+		default:
+			fields, ok := d.templates[templateKey{h.version, h.domain, id}]
+			if !ok {
+				continue
+			}
+			recs, err := decodeData(h, fields, set)
+			if err != nil {
+				return records, 0, err
+			}
+			records = append(records, recs...)
+			count += len(recs)
+		}
+	}
+
+	if h.version == VersionNetFlow9 {
+		end = pos
+	}
+	return records, end, nil
+}
+
+// decodeTemplates decodes the templates of a set and returns their number
+func (d *Decoder) decodeTemplates(h header, set []byte) (int, error) {
+
+	var n int
+	for len(set) >= 4 {
+		var (
+			id         = binary.BigEndian.Uint16(set)
+			fieldCount = int(binary.BigEndian.Uint16(set[2:]))
+			fields     []field
+		)
+		set = set[4:]
+
+		// padding
+		if id == 0 && fieldCount == 0 {
+			break
+		}
+		for i := 0; i < fieldCount; i++ {
+			if len(set) < 4 {
+				return n, errors.Errorf("template %d too short", id)
+			}
+			f := field{
+				id:     binary.BigEndian.Uint16(set),
+				length: binary.BigEndian.Uint16(set[2:]),
+			}
+			set = set[4:]
+
+			// enterprise specific information elements are followed by the enterprise number,
+			// they are skipped when decoding the data records
+			if h.version == VersionIPFIX && f.id&0x8000 != 0 {
+				if len(set) < 4 {
+					return n, errors.Errorf("template %d too short", id)
+				}
+				set = set[4:]
+				f.id = 0
+			}
+			fields = append(fields, f)
+		}
+
+		k := templateKey{h.version, h.domain, id}
+		if fieldCount == 0 {
+			// template withdrawal
+			delete(d.templates, k)
+		} else {
+			d.templates[k] = fields
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// decodeData decodes the data records of a set
+func decodeData(h header, fields []field, set []byte) ([]*Record, error) {
+
+	// the minimum record length, to detect the padding at the end of the set
+	var min int
+	for _, f := range fields {
+		if f.length == variableLength {
+			min++
+		} else {
+			min += int(f.length)
+		}
+	}
+	if min == 0 {
+		return nil, errors.New("empty template")
+	}
+
+	var records []*Record
+	for len(set) >= min {
+		r := &Record{}
+		for _, f := range fields {
+			length := int(f.length)
+			if length == variableLength {
+				if len(set) < 1 {
+					return records, errors.New("data record too short")
+				}
+				length, set = int(set[0]), set[1:]
+				if length == 255 {
+					if len(set) < 2 {
+						return records, errors.New("data record too short")
+					}
+					length, set = int(binary.BigEndian.Uint16(set)), set[2:]
+				}
+			}
+			if len(set) < length {
+				return records, errors.New("data record too short")
+			}
+			r.setField(h, f.id, set[:length])
+			set = set[length:]
+		}
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// setField sets the value of the information element
+func (r *Record) setField(h header, id uint16, v []byte) {
+	switch id {
+	case ieSourceIPv4Address, ieSourceIPv6Address:
+		r.SrcIP = net.IP(append([]byte(nil), v...))
+	case ieDestinationIPv4Address, ieDestinationIPv6Address:
+		r.DstIP = net.IP(append([]byte(nil), v...))
+	case ieSourceTransportPort:
+		r.SrcPort = uint16(readUint(v))
+	case ieDestinationTransportPort:
+		r.DstPort = uint16(readUint(v))
+	case ieProtocolIdentifier:
+		r.Proto = uint8(readUint(v))
+	case ieSourceMacAddress:
+		r.SrcMAC = net.HardwareAddr(append([]byte(nil), v...))
+	case ieDestinationMacAddress:
+		r.DstMAC = net.HardwareAddr(append([]byte(nil), v...))
+	case ieOctetDeltaCount:
+		r.Bytes = readUint(v)
+	case iePacketDeltaCount:
+		r.Packets = readUint(v)
+	case ieOctetTotalCount:
+		// only used if the exporter does not send delta counts
+		if r.Bytes == 0 {
+			r.Bytes = readUint(v)
+		}
+	case iePacketTotalCount:
+		if r.Packets == 0 {
+			r.Packets = readUint(v)
+		}
+	case ieFlowStartMilliseconds:
+		r.Start = fromMillis(readUint(v))
+	case ieFlowEndMilliseconds:
+		r.End = fromMillis(readUint(v))
+	case ieFlowStartSeconds:
+		r.Start = time.Unix(int64(readUint(v)), 0)
+	case ieFlowEndSeconds:
+		r.End = time.Unix(int64(readUint(v)), 0)
+	case ieFlowStartMicroseconds, ieFlowStartNanoseconds:
+		r.Start = fromNTP(readUint(v))
+	case ieFlowEndMicroseconds, ieFlowEndNanoseconds:
+		r.End = fromNTP(readUint(v))
+	case ieFirstSwitched:
+		// relative to the system uptime from the header, IPFIX uses a separate information element for the init time
+		if h.version == VersionNetFlow9 {
+			r.Start = h.uptimeToTime(uint32(readUint(v)))
+		}
+	case ieLastSwitched:
+		if h.version == VersionNetFlow9 {
+			r.End = h.uptimeToTime(uint32(readUint(v)))
+		}
+	}
+}
+
+// uptimeToTime converts the system uptime in milliseconds to the wall clock time of the exporter
+func (h header) uptimeToTime(uptime uint32) time.Time {
+	boot := int64(h.unixSecs)*1000 + int64(h.unixNsecs)/int64(time.Millisecond) - int64(h.uptime)
+	return fromMillis(uint64(boot + int64(uptime)))
+}
+
+func fromMillis(ms uint64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
+
+// seconds between the NTP epoch in 1900 and the unix epoch
+const ntpEpochOffset = 2208988800
+
+// fromNTP converts a 64 bit NTP timestamp
+func fromNTP(v uint64) time.Time {
+	var (
+		secs = int64(v>>32) - ntpEpochOffset
+		frac = int64((v & 0xffffffff) * uint64(time.Second) >> 32)
+	)
+	return time.Unix(secs, frac)
+}
+
+// readUint reads an unsigned integer in network byte order,
+// values may use a reduced size encoding with less than 8 bytes
+func readUint(v []byte) (n uint64) {
+	for _, b := range v {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
@@ -0,0 +1,361 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package netflow
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maximum size of a message, to avoid fragmentation of the UDP datagrams
+	maxMessageSize = 1400
+
+	// the templates are repeated after this number of messages
+	templateInterval = 20
+
+	headerSizeNetFlow9 = 20
+	headerSizeIPFIX    = 16
+	setHeaderSize      = 4
+
+	// range of the NetFlow v9 system uptime in milliseconds
+	maxUptime = math.MaxUint32 * time.Millisecond
+)
+
+// Exporter encodes flow records as NetFlow v9 or IPFIX messages.
+// Records are buffered until a message is full or Flush is called,
+// every message is written with a single call to Write.
+// It is safe for concurrent use.
+type Exporter struct {
+	w       io.Writer
+	version uint16
+	domain  uint32
+
+	templates map[uint16][]field
+
+	// pending data records by template
+	pending map[uint16][]Record
+	size    int
+
+	// number of sent messages, and data records for IPFIX
+	numMessages uint32
+	numRecords  uint32
+
+	// virtual boot time of the exporter, the flow times of NetFlow v9 are relative to it.
+	// it is moved back for flows that started earlier, e.g. when reading a capture file
+	boot time.Time
+	// latest end of an added flow
+	last time.Time
+
+	sync.Mutex
+}
+
+// NewExporter returns an exporter that writes messages of the given version to w.
+// The observation domain, or source id for NetFlow v9, identifies the exporter at the collector.
+func NewExporter(w io.Writer, version int, domain uint32) (*Exporter, error) {
+	if version != VersionNetFlow9 && version != VersionIPFIX {
+		return nil, errors.Errorf("unsupported version %d, must be %d (NetFlow v9) or %d (IPFIX)", version, VersionNetFlow9, VersionIPFIX)
+	}
+	e := &Exporter{
+		w:         w,
+		version:   uint16(version),
+		domain:    domain,
+		templates: templates,
+		pending:   make(map[uint16][]Record),
+		boot:      time.Now().Truncate(time.Millisecond),
+	}
+	if version == VersionNetFlow9 {
+		e.templates = templatesNetFlow9
+	}
+	return e, nil
+}
+
+// Open returns an exporter that sends the messages to the collector at the UDP address,
+// or writes them to the file at path if addr is empty.
+// Close must be called to flush the remaining records and close the connection or file.
+func Open(addr, path string, version int) (*Exporter, error) {
+
+	var (
+		w   io.WriteCloser
+		err error
+	)
+	if addr != "" {
+		w, err = net.Dial("udp", addr)
+	} else {
+		w, err = os.Create(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := NewExporter(w, version, 0)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// Add adds a record to the current message.
+// The message is written once there is no space left for the record.
+func (e *Exporter) Add(r *Record) error {
+
+	var (
+		id     = r.template()
+		length = recordLength(e.templates[id])
+	)
+
+	e.Lock()
+	defer e.Unlock()
+
+	// a new set adds a set header and up to 3 bytes of padding
+	extra := length
+	if len(e.pending[id]) == 0 {
+		extra += setHeaderSize + 3
+	}
+	if e.size+extra > e.capacity() {
+		if err := e.flush(); err != nil {
+			return err
+		}
+		extra = length + setHeaderSize + 3
+	}
+
+	// the records are encoded when the message is written,
+	// since the NetFlow v9 flow times depend on the system uptime
+	e.pending[id] = append(e.pending[id], *r)
+	e.size += extra
+
+	if r.Start.Before(e.boot) {
+		e.boot = r.Start.Truncate(time.Millisecond)
+	}
+	if r.End.After(e.last) {
+		e.last = r.End
+	}
+
+	return nil
+}
+
+// Flush writes the pending records.
+func (e *Exporter) Flush() error {
+	e.Lock()
+	defer e.Unlock()
+	return e.flush()
+}
+
+// Close flushes the pending records and closes the underlying writer, if it implements io.Closer.
+func (e *Exporter) Close() error {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// capacity returns the space available for data sets in the current message
+// must be called with the lock held
+func (e *Exporter) capacity() int {
+	n := maxMessageSize - headerSizeIPFIX
+	if e.version == VersionNetFlow9 {
+		n = maxMessageSize - headerSizeNetFlow9
+	}
+	if e.sendTemplates() {
+		n -= len(e.templateSet())
+	}
+	return n
+}
+
+// sendTemplates checks if the templates must be included in the next message
+// must be called with the lock held
+func (e *Exporter) sendTemplates() bool {
+	return e.numMessages%templateInterval == 0
+}
+
+// flush writes the pending records as a single message
+// must be called with the lock held
+func (e *Exporter) flush() error {
+
+	if e.size == 0 {
+		return nil
+	}
+
+	var (
+		msg        = make([]byte, e.headerSize(), maxMessageSize)
+		numRecords int
+		numData    int
+		now        = e.exportTime()
+	)
+	if e.sendTemplates() {
+		msg = append(msg, e.templateSet()...)
+		numRecords += len(e.templates)
+	}
+	for _, id := range []uint16{templateIPv4, templateIPv6} {
+		records := e.pending[id]
+		if len(records) == 0 {
+			continue
+		}
+		start := len(msg)
+		msg = append(msg, 0, 0, 0, 0)
+		for i := range records {
+			for _, f := range e.templates[id] {
+				msg = e.appendField(msg, f, &records[i])
+			}
+		}
+		for len(msg)%4 != 0 {
+			msg = append(msg, 0)
+		}
+		binary.BigEndian.PutUint16(msg[start:], id)
+		binary.BigEndian.PutUint16(msg[start+2:], uint16(len(msg)-start))
+		numData += len(records)
+	}
+	numRecords += numData
+
+	if e.version == VersionNetFlow9 {
+		binary.BigEndian.PutUint16(msg[0:], VersionNetFlow9)
+		binary.BigEndian.PutUint16(msg[2:], uint16(numRecords))
+		binary.BigEndian.PutUint32(msg[4:], e.uptime(now))
+		binary.BigEndian.PutUint32(msg[8:], uint32(now.Unix()))
+		binary.BigEndian.PutUint32(msg[12:], e.numMessages)
+		binary.BigEndian.PutUint32(msg[16:], e.domain)
+	} else {
+		binary.BigEndian.PutUint16(msg[0:], VersionIPFIX)
+		binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)))
+		binary.BigEndian.PutUint32(msg[4:], uint32(now.Unix()))
+		binary.BigEndian.PutUint32(msg[8:], e.numRecords)
+		binary.BigEndian.PutUint32(msg[12:], e.domain)
+	}
+
+	e.pending = make(map[uint16][]Record)
+	e.size = 0
+	e.numMessages++
+	e.numRecords += uint32(numData)
+
+	_, err := e.w.Write(msg)
+	return err
+}
+
+// exportTime returns the time for the message header, in full seconds.
+// If the flows are older than the range of the NetFlow v9 system uptime,
+// which is the case for capture files, the end of the latest flow is used instead of the current time.
+// must be called with the lock held
+func (e *Exporter) exportTime() time.Time {
+	now := time.Now()
+	if e.version == VersionNetFlow9 && now.Sub(e.boot) > maxUptime {
+		now = e.last
+	}
+	t := now.Truncate(time.Second)
+	if t.Before(now) {
+		t = t.Add(time.Second)
+	}
+	return t
+}
+
+// uptime returns the milliseconds since the virtual boot time, limited to the range of the system uptime
+func (e *Exporter) uptime(t time.Time) uint32 {
+	d := t.Sub(e.boot)
+	switch {
+	case d < 0:
+		return 0
+	case d > maxUptime:
+		return math.MaxUint32
+	}
+	return uint32(d / time.Millisecond)
+}
+
+func (e *Exporter) headerSize() int {
+	if e.version == VersionNetFlow9 {
+		return headerSizeNetFlow9
+	}
+	return headerSizeIPFIX
+}
+
+// templateSet encodes the template set with all templates
+func (e *Exporter) templateSet() []byte {
+
+	// set id 0 is used for templates in NetFlow v9, 2 in IPFIX
+	var id uint16 = 2
+	if e.version == VersionNetFlow9 {
+		id = 0
+	}
+
+	b := make([]byte, setHeaderSize)
+	for _, t := range []uint16{templateIPv4, templateIPv6} {
+		fields := e.templates[t]
+		b = appendUint16(b, t)
+		b = appendUint16(b, uint16(len(fields)))
+		for _, f := range fields {
+			b = appendUint16(b, f.id)
+			b = appendUint16(b, f.length)
+		}
+	}
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+
+	return b
+}
+
+// appendField encodes the value of the field for the record
+func (e *Exporter) appendField(b []byte, f field, r *Record) []byte {
+	switch f.id {
+	case ieSourceIPv4Address:
+		return append(b, r.SrcIP.To4()...)
+	case ieDestinationIPv4Address:
+		return append(b, r.DstIP.To4()...)
+	case ieSourceIPv6Address:
+		return append(b, r.SrcIP.To16()...)
+	case ieDestinationIPv6Address:
+		return append(b, r.DstIP.To16()...)
+	case ieSourceTransportPort:
+		return appendUint16(b, r.SrcPort)
+	case ieDestinationTransportPort:
+		return appendUint16(b, r.DstPort)
+	case ieProtocolIdentifier:
+		return append(b, r.Proto)
+	case ieOctetDeltaCount:
+		return appendUint64(b, r.Bytes)
+	case iePacketDeltaCount:
+		return appendUint64(b, r.Packets)
+	case ieFlowStartMilliseconds:
+		return appendUint64(b, uint64(r.Start.UnixNano()/int64(time.Millisecond)))
+	case ieFlowEndMilliseconds:
+		return appendUint64(b, uint64(r.End.UnixNano()/int64(time.Millisecond)))
+	case ieFirstSwitched:
+		return appendUint32(b, e.uptime(r.Start))
+	case ieLastSwitched:
+		return appendUint32(b, e.uptime(r.End))
+	}
+	return append(b, make([]byte, f.length)...)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,229 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package netflow exports netcap Flow and Connection audit records as NetFlow v9 or IPFIX messages,
+// and decodes NetFlow v5, NetFlow v9, IPFIX and sFlow v5 datagrams.
+//
+// Two templates are used, one for IPv4 and one for IPv6 flows.
+// Both contain the addresses, ports, the IP protocol number, the number of bytes and packets
+// and the start and end time of the flow, in milliseconds since the epoch for IPFIX,
+// and relative to the system uptime from the message header for NetFlow v9.
+// The templates are sent with the first message and then periodically,
+// so collectors that join later are able to decode the data records.
+//
+// Connection audit records are bidirectional, they are exported as a single flow
+// with the totals of both directions.
+package netflow
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// Supported protocol versions.
+const (
+	VersionNetFlow5 = 5
+	VersionNetFlow9 = 9
+	VersionIPFIX    = 10
+)
+
+// information elements, the identifiers are the same for NetFlow v9 and IPFIX
+const (
+	ieOctetDeltaCount          = 1
+	iePacketDeltaCount         = 2
+	ieProtocolIdentifier       = 4
+	ieSourceTransportPort      = 7
+	ieSourceIPv4Address        = 8
+	ieDestinationTransportPort = 11
+	ieDestinationIPv4Address   = 12
+	ieLastSwitched             = 21
+	ieFirstSwitched            = 22
+	ieSourceIPv6Address        = 27
+	ieDestinationIPv6Address   = 28
+	ieSourceMacAddress         = 56
+	ieDestinationMacAddress    = 80
+	ieOctetTotalCount          = 85
+	iePacketTotalCount         = 86
+	ieFlowStartSeconds         = 150
+	ieFlowEndSeconds           = 151
+	ieFlowStartMilliseconds    = 152
+	ieFlowEndMilliseconds      = 153
+	ieFlowStartMicroseconds    = 154
+	ieFlowEndMicroseconds      = 155
+	ieFlowStartNanoseconds     = 156
+	ieFlowEndNanoseconds       = 157
+)
+
+// template ids, data sets use ids starting from 256
+const (
+	templateIPv4 = 256
+	templateIPv6 = 257
+)
+
+// field is a field specifier of a template
+type field struct {
+	id     uint16
+	length uint16
+}
+
+// templates used for the IPFIX export
+var templates = map[uint16][]field{
+	templateIPv4: {
+		{ieSourceIPv4Address, 4},
+		{ieDestinationIPv4Address, 4},
+		{ieSourceTransportPort, 2},
+		{ieDestinationTransportPort, 2},
+		{ieProtocolIdentifier, 1},
+		{ieOctetDeltaCount, 8},
+		{iePacketDeltaCount, 8},
+		{ieFlowStartMilliseconds, 8},
+		{ieFlowEndMilliseconds, 8},
+	},
+	templateIPv6: {
+		{ieSourceIPv6Address, 16},
+		{ieDestinationIPv6Address, 16},
+		{ieSourceTransportPort, 2},
+		{ieDestinationTransportPort, 2},
+		{ieProtocolIdentifier, 1},
+		{ieOctetDeltaCount, 8},
+		{iePacketDeltaCount, 8},
+		{ieFlowStartMilliseconds, 8},
+		{ieFlowEndMilliseconds, 8},
+	},
+}
+
+// templates used for the NetFlow v9 export,
+// which has no absolute timestamps, the flow times are relative to the system uptime
+var templatesNetFlow9 = map[uint16][]field{
+	templateIPv4: {
+		{ieSourceIPv4Address, 4},
+		{ieDestinationIPv4Address, 4},
+		{ieSourceTransportPort, 2},
+		{ieDestinationTransportPort, 2},
+		{ieProtocolIdentifier, 1},
+		{ieOctetDeltaCount, 8},
+		{iePacketDeltaCount, 8},
+		{ieFirstSwitched, 4},
+		{ieLastSwitched, 4},
+	},
+	templateIPv6: {
+		{ieSourceIPv6Address, 16},
+		{ieDestinationIPv6Address, 16},
+		{ieSourceTransportPort, 2},
+		{ieDestinationTransportPort, 2},
+		{ieProtocolIdentifier, 1},
+		{ieOctetDeltaCount, 8},
+		{iePacketDeltaCount, 8},
+		{ieFirstSwitched, 4},
+		{ieLastSwitched, 4},
+	},
+}
+
+// recordLength returns the length of a data record for the fields
+func recordLength(fields []field) (n int) {
+	for _, f := range fields {
+		n += int(f.length)
+	}
+	return n
+}
+
+// IP protocol numbers for the transport protocols of the audit records
+var protocols = map[string]uint8{
+	"TCP":  6,
+	"UDP":  17,
+	"SCTP": 132,
+}
+
+// Record is a unidirectional flow, as exported in a data record.
+// The MAC addresses are only set for decoded records, if the exporter included them.
+type Record struct {
+	SrcMAC  net.HardwareAddr
+	DstMAC  net.HardwareAddr
+	SrcIP   net.IP
+	DstIP   net.IP
+	SrcPort uint16
+	DstPort uint16
+	Proto   uint8
+	Bytes   uint64
+	Packets uint64
+	Start   time.Time
+	End     time.Time
+}
+
+// FromAuditRecord converts a Flow or Connection audit record.
+// false is returned for other types and records without valid IP addresses.
+func FromAuditRecord(record interface{}) (*Record, bool) {
+
+	var (
+		srcIP, dstIP, srcPort, dstPort, proto, first, last string
+		size, packets                                      int32
+	)
+	switch r := record.(type) {
+	case *types.Flow:
+		srcIP, dstIP, srcPort, dstPort, proto = r.SrcIP, r.DstIP, r.SrcPort, r.DstPort, r.TransportProto
+		first, last, size, packets = r.TimestampFirst, r.TimestampLast, r.TotalSize, r.NumPackets
+	case *types.Connection:
+		srcIP, dstIP, srcPort, dstPort, proto = r.SrcIP, r.DstIP, r.SrcPort, r.DstPort, r.TransportProto
+		first, last, size, packets = r.TimestampFirst, r.TimestampLast, r.TotalSize, r.NumPackets
+	default:
+		return nil, false
+	}
+
+	rec := &Record{
+		SrcIP:   net.ParseIP(srcIP),
+		DstIP:   net.ParseIP(dstIP),
+		SrcPort: parsePort(srcPort),
+		DstPort: parsePort(dstPort),
+		Proto:   protocols[proto],
+		Bytes:   uint64(size),
+		Packets: uint64(packets),
+		Start:   utils.StringToTime(first),
+		End:     utils.StringToTime(last),
+	}
+	if rec.SrcIP == nil || rec.DstIP == nil {
+		return nil, false
+	}
+	// the last timestamp is only set for flows with more than one packet
+	if rec.End.Before(rec.Start) {
+		rec.End = rec.Start
+	}
+
+	return rec, true
+}
+
+func parsePort(s string) uint16 {
+	p, _ := strconv.ParseUint(s, 10, 16)
+	return uint16(p)
+}
+
+// String returns a human readable representation of the record.
+func (r *Record) String() string {
+	return fmt.Sprintf("%s %s:%d -> %s:%d proto=%d bytes=%d packets=%d duration=%s",
+		r.Start.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		r.SrcIP, r.SrcPort, r.DstIP, r.DstPort,
+		r.Proto, r.Bytes, r.Packets, r.End.Sub(r.Start),
+	)
+}
+
+// template returns the id of the template for the record
+func (r *Record) template() uint16 {
+	if r.SrcIP.To4() != nil && r.DstIP.To4() != nil {
+		return templateIPv4
+	}
+	return templateIPv6
+}
@@ -0,0 +1,66 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	headerSizeNetFlow5 = 24
+	recordSizeNetFlow5 = 48
+)
+
+// decodeNetFlow5 decodes a NetFlow v5 message, which uses a fixed record format
+func decodeNetFlow5(data []byte) ([]*Record, int, error) {
+
+	if len(data) < headerSizeNetFlow5 {
+		return nil, 0, errors.New("NetFlow v5 header too short")
+	}
+
+	var (
+		count = int(binary.BigEndian.Uint16(data[2:]))
+		end   = headerSizeNetFlow5 + count*recordSizeNetFlow5
+		h     = header{
+			version:   VersionNetFlow5,
+			uptime:    binary.BigEndian.Uint32(data[4:]),
+			unixSecs:  binary.BigEndian.Uint32(data[8:]),
+			unixNsecs: binary.BigEndian.Uint32(data[12:]),
+		}
+	)
+	if end > len(data) {
+		return nil, 0, errors.Errorf("NetFlow v5 message with %d records too short: %d bytes", count, len(data))
+	}
+
+	records := make([]*Record, 0, count)
+	for pos := headerSizeNetFlow5; pos < end; pos += recordSizeNetFlow5 {
+		b := data[pos : pos+recordSizeNetFlow5]
+		records = append(records, &Record{
+			SrcIP:   net.IP(append([]byte(nil), b[0:4]...)),
+			DstIP:   net.IP(append([]byte(nil), b[4:8]...)),
+			Packets: uint64(binary.BigEndian.Uint32(b[16:])),
+			Bytes:   uint64(binary.BigEndian.Uint32(b[20:])),
+			Start:   h.uptimeToTime(binary.BigEndian.Uint32(b[24:])),
+			End:     h.uptimeToTime(binary.BigEndian.Uint32(b[28:])),
+			SrcPort: binary.BigEndian.Uint16(b[32:]),
+			DstPort: binary.BigEndian.Uint16(b[34:]),
+			Proto:   b[38],
+		})
+	}
+
+	return records, end, nil
+}
@@ -0,0 +1,468 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// messages collects every write as a separate message
+type messages [][]byte
+
+func (m *messages) Write(b []byte) (int, error) {
+	*m = append(*m, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func testRecords(n int) []*Record {
+	var (
+		start   = time.Unix(1500000000, 123000000)
+		records []*Record
+	)
+	for i := 0; i < n; i++ {
+		r := &Record{
+			SrcIP:   net.IPv4(10, 0, byte(i>>8), byte(i)).To4(),
+			DstIP:   net.IPv4(192, 168, 0, 1).To4(),
+			SrcPort: uint16(40000 + i),
+			DstPort: 443,
+			Proto:   6,
+			Bytes:   uint64(1000 * i),
+			Packets: uint64(i),
+			Start:   start.Add(time.Duration(i) * time.Second),
+			End:     start.Add(time.Duration(i)*time.Second + 1500*time.Millisecond),
+		}
+		if i%3 == 0 {
+			r.SrcIP = net.ParseIP("2001:db8::1")
+			r.DstIP = net.ParseIP("2001:db8::2")
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func equal(a, b *Record) bool {
+	return a.SrcIP.Equal(b.SrcIP) && a.DstIP.Equal(b.DstIP) &&
+		a.SrcPort == b.SrcPort && a.DstPort == b.DstPort && a.Proto == b.Proto &&
+		a.Bytes == b.Bytes && a.Packets == b.Packets &&
+		a.Start.Equal(b.Start) && a.End.Equal(b.End)
+}
+
+func TestExportDecode(t *testing.T) {
+	for _, version := range []int{VersionNetFlow9, VersionIPFIX} {
+
+		var (
+			msgs    messages
+			records = testRecords(1000)
+		)
+		e, err := NewExporter(&msgs, version, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range records {
+			if err := e.Add(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) < 2 {
+			t.Fatalf("version %d: expected multiple messages, got %d", version, len(msgs))
+		}
+
+		var (
+			d       = NewDecoder()
+			decoded []*Record
+		)
+		for i, m := range msgs {
+			if len(m) > maxMessageSize {
+				t.Fatalf("version %d: message %d exceeds the maximum size: %d", version, i, len(m))
+			}
+			if v := binary.BigEndian.Uint16(m); v != uint16(version) {
+				t.Fatalf("version %d: unexpected version %d", version, v)
+			}
+			recs, n, err := d.Decode(m)
+			if err != nil {
+				t.Fatalf("version %d: message %d: %s", version, i, err)
+			}
+			if n != len(m) {
+				t.Fatalf("version %d: message %d: decoded %d of %d bytes", version, i, n, len(m))
+			}
+			decoded = append(decoded, recs...)
+		}
+
+		if len(decoded) != len(records) {
+			t.Fatalf("version %d: expected %d records, got %d", version, len(records), len(decoded))
+		}
+
+		// records are grouped by template within a message, so compare by source port
+		bySrcPort := make(map[uint16]*Record)
+		for _, r := range decoded {
+			bySrcPort[r.SrcPort] = r
+		}
+		for _, r := range records {
+			if got := bySrcPort[r.SrcPort]; got == nil || !equal(r, got) {
+				t.Fatalf("version %d: expected %s, got %v", version, r, got)
+			}
+		}
+	}
+}
+
+// NetFlow v9 has no absolute timestamps, the flow times are relative to the system uptime
+func TestNetFlow9Uptime(t *testing.T) {
+
+	var (
+		msgs messages
+		now  = time.Now().Truncate(time.Millisecond)
+		r    = testRecords(1)[0]
+	)
+	r.Start = now.Add(-10 * time.Second)
+	r.End = now.Add(-2 * time.Second)
+
+	e, _ := NewExporter(&msgs, VersionNetFlow9, 0)
+	e.Add(r)
+	e.Close()
+
+	// template set header, template id and field count, then the field specifiers
+	m := msgs[0]
+	for i, id := range []uint16{ieFirstSwitched, ieLastSwitched} {
+		off := headerSizeNetFlow9 + setHeaderSize + 4 + (len(templatesNetFlow9[templateIPv4])-2+i)*4
+		if got := binary.BigEndian.Uint16(m[off:]); got != id {
+			t.Fatalf("expected information element %d, got %d", id, got)
+		}
+	}
+
+	// the uptime starts before the first flow
+	uptime := binary.BigEndian.Uint32(m[4:])
+	if uptime < 10000 || uptime > 20000 {
+		t.Fatalf("unexpected system uptime %d", uptime)
+	}
+
+	recs, _, err := NewDecoder().Decode(m)
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("expected 1 record, got %d, %v", len(recs), err)
+	}
+	if !equal(r, recs[0]) {
+		t.Fatalf("expected %s, got %s", r, recs[0])
+	}
+
+	// IPFIX uses absolute timestamps
+	msgs = nil
+	e, _ = NewExporter(&msgs, VersionIPFIX, 0)
+	e.Add(r)
+	e.Close()
+	for i, id := range []uint16{ieFlowStartMilliseconds, ieFlowEndMilliseconds} {
+		off := headerSizeIPFIX + setHeaderSize + 4 + (len(templates[templateIPv4])-2+i)*4
+		if got := binary.BigEndian.Uint16(msgs[0][off:]); got != id {
+			t.Fatalf("expected information element %d, got %d", id, got)
+		}
+	}
+}
+
+func TestSequenceNumbers(t *testing.T) {
+
+	var msgs messages
+	e, _ := NewExporter(&msgs, VersionIPFIX, 0)
+	for _, r := range testRecords(100) {
+		e.Add(r)
+	}
+	e.Close()
+
+	var (
+		d   = NewDecoder()
+		sum uint32
+	)
+	for _, m := range msgs {
+		if seq := binary.BigEndian.Uint32(m[8:]); seq != sum {
+			t.Fatalf("expected IPFIX sequence number %d, got %d", sum, seq)
+		}
+		recs, _, _ := d.Decode(m)
+		sum += uint32(len(recs))
+	}
+
+	msgs = nil
+	e, _ = NewExporter(&msgs, VersionNetFlow9, 0)
+	for _, r := range testRecords(100) {
+		e.Add(r)
+	}
+	e.Close()
+
+	for i, m := range msgs {
+		if seq := binary.BigEndian.Uint32(m[12:]); seq != uint32(i) {
+			t.Fatalf("expected NetFlow v9 sequence number %d, got %d", i, seq)
+		}
+	}
+}
+
+func TestTemplateRefresh(t *testing.T) {
+
+	var msgs messages
+	e, _ := NewExporter(&msgs, VersionIPFIX, 0)
+	for i := 0; i < templateInterval+1; i++ {
+		e.Add(testRecords(1)[0])
+		e.Flush()
+	}
+
+	for i, m := range msgs {
+		hasTemplates := binary.BigEndian.Uint16(m[headerSizeIPFIX:]) == 2
+		if hasTemplates != (i%templateInterval == 0) {
+			t.Fatalf("message %d: unexpected template set: %v", i, hasTemplates)
+		}
+	}
+
+	// data without templates is skipped
+	recs, _, err := NewDecoder().Decode(msgs[1])
+	if err != nil || len(recs) != 0 {
+		t.Fatalf("expected no records without templates, got %d, %v", len(recs), err)
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+
+	var (
+		buf     bytes.Buffer
+		records = testRecords(200)
+	)
+	e, _ := NewExporter(&buf, VersionNetFlow9, 0)
+	for _, r := range records {
+		e.Add(r)
+	}
+	e.Close()
+
+	var (
+		d     = NewDecoder()
+		data  = buf.Bytes()
+		total int
+	)
+	for len(data) > 0 {
+		recs, n, err := d.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(recs)
+		data = data[n:]
+	}
+	if total != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), total)
+	}
+}
+
+func TestFromAuditRecord(t *testing.T) {
+
+	r, ok := FromAuditRecord(&types.Flow{
+		TimestampFirst: "1500000000.500000",
+		TimestampLast:  "1500000002.000000",
+		TransportProto: "UDP",
+		SrcIP:          "10.0.0.1",
+		SrcPort:        "5353",
+		DstIP:          "10.0.0.2",
+		DstPort:        "53",
+		TotalSize:      120,
+		NumPackets:     2,
+	})
+	if !ok {
+		t.Fatal("failed to convert flow")
+	}
+	if r.Proto != 17 || r.SrcPort != 5353 || r.DstPort != 53 || r.Bytes != 120 || r.Packets != 2 ||
+		r.End.Sub(r.Start) != 1500*time.Millisecond || r.template() != templateIPv4 {
+		t.Fatalf("unexpected record: %s", r)
+	}
+
+	// single packet flows have no last timestamp
+	r, ok = FromAuditRecord(&types.Connection{
+		TimestampFirst: "1500000000.500000",
+		SrcIP:          "2001:db8::1",
+		DstIP:          "2001:db8::2",
+	})
+	if !ok || !r.End.Equal(r.Start) || r.template() != templateIPv6 {
+		t.Fatalf("unexpected record: %v", r)
+	}
+
+	if _, ok := FromAuditRecord(&types.Flow{SrcIP: "00:11:22:33:44:55"}); ok {
+		t.Fatal("expected flow without IP addresses to be rejected")
+	}
+	if _, ok := FromAuditRecord(&types.TCP{}); ok {
+		t.Fatal("expected other types to be rejected")
+	}
+}
+
+func TestDecodeNetFlow5(t *testing.T) {
+
+	var (
+		msg  = make([]byte, headerSizeNetFlow5+2*recordSizeNetFlow5)
+		boot = time.Unix(1500000000, 0)
+	)
+	binary.BigEndian.PutUint16(msg[0:], VersionNetFlow5)
+	binary.BigEndian.PutUint16(msg[2:], 2)
+	binary.BigEndian.PutUint32(msg[4:], 60000) // uptime
+	binary.BigEndian.PutUint32(msg[8:], uint32(boot.Unix()+60))
+
+	for i := 0; i < 2; i++ {
+		b := msg[headerSizeNetFlow5+i*recordSizeNetFlow5:]
+		copy(b[0:], net.IPv4(10, 0, 0, byte(i+1)).To4())
+		copy(b[4:], net.IPv4(10, 0, 0, 254).To4())
+		binary.BigEndian.PutUint32(b[16:], 10)    // packets
+		binary.BigEndian.PutUint32(b[20:], 1500)  // bytes
+		binary.BigEndian.PutUint32(b[24:], 1000)  // first
+		binary.BigEndian.PutUint32(b[28:], 31000) // last
+		binary.BigEndian.PutUint16(b[32:], 1234)
+		binary.BigEndian.PutUint16(b[34:], 53)
+		b[38] = 17
+	}
+
+	records, n, err := NewDecoder().Decode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(msg) || len(records) != 2 {
+		t.Fatalf("expected 2 records in %d bytes, got %d in %d bytes", len(msg), len(records), n)
+	}
+	r := records[1]
+	if r.SrcIP.String() != "10.0.0.2" || r.DstPort != 53 || r.Proto != 17 || r.Bytes != 1500 || r.Packets != 10 {
+		t.Fatalf("unexpected record: %s", r)
+	}
+	if !r.Start.Equal(boot.Add(time.Second)) || !r.End.Equal(boot.Add(31*time.Second)) {
+		t.Fatalf("unexpected times: %s - %s", r.Start, r.End)
+	}
+
+	if _, _, err := NewDecoder().Decode(msg[:len(msg)-1]); err == nil {
+		t.Fatal("expected error for truncated message")
+	}
+}
+
+func TestDecodeIPFIXFields(t *testing.T) {
+
+	// template with MAC addresses, total counts, a variable length and an enterprise specific field
+	// and microsecond timestamps in NTP format
+	var (
+		start = time.Unix(1500000000, 250000000)
+		ntp   = uint64(start.Unix()+ntpEpochOffset)<<32 | uint64(start.Nanosecond())<<32/uint64(time.Second)
+		tmpl  = []byte{
+			0, 2, 0, 0, // set header, length is set below
+			1, 0, 0, 8, // template 256 with 8 fields
+			0, ieSourceMacAddress, 0, 6,
+			0, ieSourceIPv4Address, 0, 4,
+			0, ieDestinationIPv4Address, 0, 4,
+			0, ieOctetTotalCount, 0, 4,
+			0, iePacketTotalCount, 0, 2,
+			0x80, 1, 0xff, 0xff, 0, 0, 0x12, 0x34, // enterprise field with variable length
+			0, ieFlowStartMicroseconds, 0, 8,
+			0, ieProtocolIdentifier, 0, 1,
+		}
+		data = []byte{
+			1, 0, 0, 0, // set header
+			0, 1, 2, 3, 4, 5,
+			192, 168, 1, 1,
+			192, 168, 1, 2,
+			0, 0, 1, 0,
+			0, 2,
+			3, 'a', 'b', 'c',
+		}
+	)
+	data = append(data, appendUint64(nil, ntp)...)
+	data = append(data, 1, 0, 0) // protocol and padding
+	binary.BigEndian.PutUint16(tmpl[2:], uint16(len(tmpl)))
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+
+	msg := make([]byte, headerSizeIPFIX)
+	msg = append(append(msg, tmpl...), data...)
+	binary.BigEndian.PutUint16(msg[0:], VersionIPFIX)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)))
+
+	records, _, err := NewDecoder().Decode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.SrcMAC.String() != "00:01:02:03:04:05" || r.DstIP.String() != "192.168.1.2" ||
+		r.Bytes != 256 || r.Packets != 2 || r.Proto != 1 || !r.Start.Equal(start) {
+		t.Fatalf("unexpected record: %s %s", r.SrcMAC, r)
+	}
+}
+
+// sflowDatagram builds a sFlow datagram with a single flow sample
+func sflowDatagram(expanded bool, header []byte) []byte {
+
+	u32 := func(b []byte, v ...uint32) []byte {
+		for _, x := range v {
+			var buf [4]byte
+			binary.BigEndian.PutUint32(buf[:], x)
+			b = append(b, buf[:]...)
+		}
+		return b
+	}
+
+	// raw packet header record
+	record := u32(nil, SFlowProtoIPv4, 1500, 0, uint32(len(header)))
+	record = append(record, header...)
+	for len(record)%4 != 0 {
+		record = append(record, 0)
+	}
+
+	// flow sample
+	var sample []byte
+	format := uint32(sFlowFlowSample)
+	if expanded {
+		format = sFlowExpandedFlowSample
+		sample = u32(sample, 1, 0, 3, 512, 1000, 0, 0, 1, 0, 2)
+	} else {
+		sample = u32(sample, 1, 3, 512, 1000, 0, 1, 2)
+	}
+	sample = u32(sample, 2)
+	sample = u32(sample, 2001, 4, 0) // extended switch data is skipped
+	sample = u32(sample, sFlowRawPacketHeader, uint32(len(record)))
+	sample = append(sample, record...)
+
+	d := u32(nil, VersionSFlow, 1, 0x0a000001, 0, 1, 1000, 2)
+	d = u32(d, 2, 8, 0, 0) // counter sample is skipped
+	d = u32(d, format, uint32(len(sample)))
+	return append(d, sample...)
+}
+
+func TestDecodeSFlow(t *testing.T) {
+
+	header := []byte{0x45, 0, 0, 20, 0, 0, 0, 0, 64, 6, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2, 0xff}
+
+	for _, expanded := range []bool{false, true} {
+		data := sflowDatagram(expanded, header)
+		if !IsSFlow(data) || IsSFlow([]byte{0, VersionNetFlow9, 0, 0}) {
+			t.Fatal("unexpected sFlow detection")
+		}
+
+		samples, n, err := DecodeSFlow(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(data) || len(samples) != 1 {
+			t.Fatalf("expected 1 sample in %d bytes, got %d in %d bytes", len(data), len(samples), n)
+		}
+		s := samples[0]
+		if s.Protocol != SFlowProtoIPv4 || s.FrameLength != 1500 || s.SamplingRate != 512 || !bytes.Equal(s.Data, header) {
+			t.Fatalf("unexpected sample: %+v", s)
+		}
+
+		if _, _, err := DecodeSFlow(data[:len(data)-4]); err == nil {
+			t.Fatal("expected error for truncated datagram")
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package netflow
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// VersionSFlow is the supported sFlow version.
+const VersionSFlow = 5
+
+// sFlow sample and record formats, for the standard enterprise 0
+const (
+	sFlowFlowSample         = 1
+	sFlowExpandedFlowSample = 3
+	sFlowRawPacketHeader    = 1
+)
+
+// header protocols of sampled packets
+const (
+	SFlowProtoEthernet = 1
+	SFlowProtoIPv4     = 11
+	SFlowProtoIPv6     = 12
+)
+
+// Sample is a packet header sampled by an sFlow agent.
+type Sample struct {
+	// header protocol, one of the SFlowProto constants
+	Protocol uint32
+	// length of the original packet
+	FrameLength uint32
+	// one packet out of SamplingRate packets is sampled
+	SamplingRate uint32
+	// the sampled header, usually truncated to the first 128 bytes of the packet
+	Data []byte
+}
+
+// IsSFlow checks if the datagram starts with an sFlow v5 header.
+// NetFlow and IPFIX messages start with a 16 bit version, sFlow uses a 32 bit version.
+func IsSFlow(data []byte) bool {
+	return len(data) >= 4 && binary.BigEndian.Uint32(data) == VersionSFlow
+}
+
+// DecodeSFlow decodes the sampled packet headers of an sFlow v5 datagram
+// and returns them with the length of the datagram.
+// Counter samples and other record formats are skipped.
+func DecodeSFlow(data []byte) ([]*Sample, int, error) {
+
+	d := &xdr{data: data}
+
+	if v := d.uint32(); v != VersionSFlow {
+		return nil, 0, errors.Errorf("unsupported sFlow version %d", v)
+	}
+
+	// agent address
+	switch d.uint32() {
+	case 1:
+		d.skip(4)
+	case 2:
+		d.skip(16)
+	}
+	// sub agent id, sequence number and uptime
+	d.skip(12)
+
+	var (
+		numSamples = int(d.uint32())
+		samples    []*Sample
+	)
+	for i := 0; i < numSamples && d.err == nil; i++ {
+		var (
+			format = d.uint32()
+			sample = d.bytes(int(d.uint32()))
+		)
+		if d.err != nil {
+			break
+		}
+		if format == sFlowFlowSample || format == sFlowExpandedFlowSample {
+			samples = append(samples, decodeFlowSample(format, sample)...)
+		}
+	}
+	if d.err != nil {
+		return samples, 0, d.err
+	}
+
+	return samples, d.pos, nil
+}
+
+// decodeFlowSample decodes the raw packet headers of a flow sample
+func decodeFlowSample(format uint32, data []byte) []*Sample {
+
+	d := &xdr{data: data}
+
+	// sequence number and source id
+	d.skip(4)
+	if format == sFlowExpandedFlowSample {
+		d.skip(4)
+	}
+	d.skip(4)
+
+	rate := d.uint32()
+
+	// sample pool, drops, input and output interface
+	if format == sFlowExpandedFlowSample {
+		d.skip(24)
+	} else {
+		d.skip(16)
+	}
+
+	var (
+		numRecords = int(d.uint32())
+		samples    []*Sample
+	)
+	for i := 0; i < numRecords && d.err == nil; i++ {
+		var (
+			recordFormat = d.uint32()
+			record       = d.bytes(int(d.uint32()))
+		)
+		if d.err != nil || recordFormat != sFlowRawPacketHeader {
+			continue
+		}
+
+		r := &xdr{data: record}
+		s := &Sample{
+			Protocol:     r.uint32(),
+			FrameLength:  r.uint32(),
+			SamplingRate: rate,
+		}
+		// stripped bytes
+		r.skip(4)
+		s.Data = append([]byte(nil), r.bytes(int(r.uint32()))...)
+		if r.err == nil {
+			samples = append(samples, s)
+		}
+	}
+
+	return samples
+}
+
+// xdr reads the XDR encoded fields of sFlow datagrams,
+// after the first error all reads return zero values
+type xdr struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (x *xdr) uint32() uint32 {
+	b := x.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (x *xdr) skip(n int) {
+	x.bytes(n)
+}
+
+// bytes returns the next n bytes and skips the padding to a multiple of 4 bytes
+func (x *xdr) bytes(n int) []byte {
+	padded := (n + 3) &^ 3
+	if x.err != nil || n < 0 || x.pos+padded > len(x.data) {
+		if x.err == nil {
+			x.err = errors.New("sFlow datagram too short")
+		}
+		return nil
+	}
+	b := x.data[x.pos : x.pos+n]
+	x.pos += padded
+	return b
+}
@@ -0,0 +1,158 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+// Package ntlm parses NTLMSSP messages, as they are embedded in SMB, HTTP and other protocols.
+//
+// NTLM authentication consists of three messages: the client sends a NEGOTIATE message,
+// the server answers with a CHALLENGE and the client proves its identity with an AUTHENTICATE message,
+// which contains the user name, domain and workstation of the client in clear text.
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Signature is the prefix of all NTLMSSP messages.
+const Signature = "NTLMSSP\x00"
+
+// Message types.
+const (
+	TypeNegotiate    = 1
+	TypeChallenge    = 2
+	TypeAuthenticate = 3
+)
+
+var typeNames = map[uint32]string{
+	TypeNegotiate:    "NEGOTIATE",
+	TypeChallenge:    "CHALLENGE",
+	TypeAuthenticate: "AUTHENTICATE",
+}
+
+// TypeName returns the name of a message type.
+func TypeName(t uint32) string {
+	if n, ok := typeNames[t]; ok {
+		return n
+	}
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+// FlagUnicode indicates that the strings in the message are encoded as UTF-16LE.
+const FlagUnicode = 0x00000001
+
+var (
+	// ErrTooShort is returned if the message is smaller than its fixed size fields.
+	ErrTooShort = errors.New("ntlm: message too short")
+	// ErrSignature is returned if the message does not start with the NTLMSSP signature.
+	ErrSignature = errors.New("ntlm: invalid signature")
+)
+
+// Message contains the fields of an NTLMSSP message.
+// Depending on the type, only a subset of the fields is set.
+type Message struct {
+	Type  uint32
+	Flags uint32
+
+	// NEGOTIATE and AUTHENTICATE
+	Domain      string
+	Workstation string
+
+	// AUTHENTICATE
+	User string
+
+	// CHALLENGE
+	TargetName string
+}
+
+// Find returns the NTLMSSP message contained in data, e.g. in a SPNEGO token,
+// or nil if there is none.
+func Find(data []byte) []byte {
+	i := bytes.Index(data, []byte(Signature))
+	if i < 0 {
+		return nil
+	}
+	return data[i:]
+}
+
+// Parse decodes an NTLMSSP message.
+func Parse(data []byte) (*Message, error) {
+
+	if len(data) < 12 {
+		return nil, ErrTooShort
+	}
+	if string(data[:8]) != Signature {
+		return nil, ErrSignature
+	}
+
+	m := &Message{
+		Type: binary.LittleEndian.Uint32(data[8:12]),
+	}
+
+	switch m.Type {
+	case TypeNegotiate:
+		if len(data) < 16 {
+			return nil, ErrTooShort
+		}
+		m.Flags = binary.LittleEndian.Uint32(data[12:16])
+		// domain and workstation are optional and always OEM encoded
+		if len(data) >= 32 {
+			m.Domain = field(data, 16, false)
+			m.Workstation = field(data, 24, false)
+		}
+	case TypeChallenge:
+		if len(data) < 24 {
+			return nil, ErrTooShort
+		}
+		m.Flags = binary.LittleEndian.Uint32(data[20:24])
+		m.TargetName = field(data, 12, m.Flags&FlagUnicode != 0)
+	case TypeAuthenticate:
+		if len(data) < 64 {
+			return nil, ErrTooShort
+		}
+		m.Flags = binary.LittleEndian.Uint32(data[60:64])
+		unicode := m.Flags&FlagUnicode != 0
+		m.Domain = field(data, 28, unicode)
+		m.User = field(data, 36, unicode)
+		m.Workstation = field(data, 44, unicode)
+	}
+
+	return m, nil
+}
+
+// field returns the string referenced by the length and offset fields at the given position,
+// or an empty string if it is out of bounds
+func field(data []byte, pos int, unicode bool) string {
+	var (
+		length = int(binary.LittleEndian.Uint16(data[pos:]))
+		offset = int(binary.LittleEndian.Uint32(data[pos+4:]))
+	)
+	if length == 0 || offset+length > len(data) {
+		return ""
+	}
+	b := data[offset : offset+length]
+	if !unicode {
+		return string(b)
+	}
+	return DecodeUTF16(b)
+}
+
+// DecodeUTF16 decodes a little endian UTF-16 string.
+func DecodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
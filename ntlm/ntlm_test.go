@@ -0,0 +1,100 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package ntlm
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+// authenticate returns an AUTHENTICATE message with the given unicode fields
+func authenticate(domain, user, workstation string) []byte {
+	msg := make([]byte, 72)
+	copy(msg, Signature)
+	binary.LittleEndian.PutUint32(msg[8:], TypeAuthenticate)
+	binary.LittleEndian.PutUint32(msg[60:], FlagUnicode)
+	for i, s := range []string{domain, user, workstation} {
+		b := encodeUTF16(s)
+		pos := 28 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(b)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(b)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(len(msg)))
+		msg = append(msg, b...)
+	}
+	return msg
+}
+
+func TestParseAuthenticate(t *testing.T) {
+
+	// embedded in a SPNEGO token
+	data := append([]byte{0xa1, 0x82, 0x01, 0x00}, authenticate("CORP", "alice", "WS01")...)
+
+	m, err := Parse(Find(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != TypeAuthenticate || m.Domain != "CORP" || m.User != "alice" || m.Workstation != "WS01" {
+		t.Fatal("unexpected message", m)
+	}
+}
+
+func TestParseNegotiate(t *testing.T) {
+
+	msg := make([]byte, 32)
+	copy(msg, Signature)
+	binary.LittleEndian.PutUint32(msg[8:], TypeNegotiate)
+	binary.LittleEndian.PutUint32(msg[12:], 0xe2088297)
+
+	m, err := Parse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != TypeNegotiate || m.Flags != 0xe2088297 || m.Domain != "" {
+		t.Fatal("unexpected message", m)
+	}
+	if TypeName(m.Type) != "NEGOTIATE" || TypeName(7) != "7" {
+		t.Fatal("unexpected type names")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if Find([]byte("no token here")) != nil {
+		t.Fatal("expected no message")
+	}
+	if _, err := Parse([]byte("NTLMSSP")); err != ErrTooShort {
+		t.Fatal("expected too short error, got", err)
+	}
+	if _, err := Parse([]byte("NTLMSSX\x00\x01\x00\x00\x00")); err != ErrSignature {
+		t.Fatal("expected signature error, got", err)
+	}
+
+	// field offset beyond the message
+	msg := authenticate("CORP", "alice", "WS01")
+	binary.LittleEndian.PutUint32(msg[40:], 1000)
+	m, err := Parse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.User != "" || m.Domain != "CORP" {
+		t.Fatal("unexpected message", m)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+// Package pdns implements a passive DNS store, which is built from the answers of DNS audit records.
+package pdns
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+type key struct {
+	name   string
+	typ    string
+	answer string
+}
+
+// host is the name an address was most recently resolved from
+type host struct {
+	name string
+	last time.Time
+}
+
+// Store keeps the first seen and last seen time and the number of responses
+// for each answer of a name and record type.
+type Store struct {
+	mu  sync.Mutex
+	max int
+
+	entries map[key]*types.PassiveDNS
+
+	// A and AAAA answers by address
+	hosts map[string]host
+}
+
+// NewStore returns a new store, that keeps up to max answers and max resolved addresses.
+func NewStore(max int) *Store {
+	return &Store{
+		max:     max,
+		entries: make(map[key]*types.PassiveDNS),
+		hosts:   make(map[string]host),
+	}
+}
+
+// AddRecord adds the answers of a DNS response to the store.
+// Addresses are mapped to the queried name, which might differ from the name of the answer for CNAME chains.
+func (s *Store) AddRecord(d *types.DNS) {
+
+	if !d.QR || len(d.Answers) == 0 {
+		return
+	}
+
+	var queried string
+	if len(d.Questions) > 0 {
+		queried = string(d.Questions[0].Name)
+	}
+
+	for _, a := range d.Answers {
+		answer, ok := Answer(a)
+		if !ok {
+			continue
+		}
+		typ := layers.DNSType(a.Type).String()
+		s.add(string(a.Name), typ, answer, d.Timestamp, d.Timestamp, 1)
+
+		if queried != "" && (typ == "A" || typ == "AAAA") {
+			s.setHost(answer, Normalize(queried), utils.StringToTime(d.Timestamp))
+		}
+	}
+}
+
+// AddEntry merges an entry, e.g. read from a PassiveDNS audit record file, into the store.
+func (s *Store) AddEntry(e *types.PassiveDNS) {
+	s.add(e.Name, e.Type, e.Answer, e.Timestamp, e.TimestampLast, e.Count)
+	if e.Type == "A" || e.Type == "AAAA" {
+		s.setHost(e.Answer, Normalize(e.Name), utils.StringToTime(e.TimestampLast))
+	}
+}
+
+func (s *Store) add(name, typ, answer, first, last string, count int64) {
+
+	k := key{name: Normalize(name), typ: typ, answer: answer}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[k]
+	if !ok {
+		if len(s.entries) >= s.max {
+			return
+		}
+		s.entries[k] = &types.PassiveDNS{
+			Timestamp:     first,
+			TimestampLast: last,
+			Name:          k.name,
+			Type:          typ,
+			Answer:        answer,
+			Count:         count,
+		}
+		return
+	}
+
+	e.Count += count
+	if utils.StringToTime(first).Before(utils.StringToTime(e.Timestamp)) {
+		e.Timestamp = first
+	}
+	if utils.StringToTime(last).After(utils.StringToTime(e.TimestampLast)) {
+		e.TimestampLast = last
+	}
+}
+
+func (s *Store) setHost(addr, name string, ts time.Time) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[addr]
+	if ok && h.last.After(ts) {
+		return
+	}
+	if !ok && len(s.hosts) >= s.max {
+		return
+	}
+	s.hosts[addr] = host{name: name, last: ts}
+}
+
+// Hostname returns the name the address was most recently resolved from,
+// or an empty string if the address has not been seen in a response.
+func (s *Store) Hostname(addr string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hosts[addr].name
+}
+
+// Name returns the history of the name and its subdomains, ordered by first seen time.
+func (s *Store) Name(name string) []*types.PassiveDNS {
+	name = Normalize(name)
+	return s.filter(func(e *types.PassiveDNS) bool {
+		return e.Name == name || strings.HasSuffix(e.Name, "."+name)
+	})
+}
+
+// Resolved returns the entries with the given answer, e.g. all names that resolved to an address,
+// including the CNAME records that lead to these names.
+func (s *Store) Resolved(answer string) []*types.PassiveDNS {
+
+	var (
+		targets = map[string]bool{Normalize(answer): true}
+		aliases = make(map[string][]string)
+		queue   []string
+	)
+
+	s.mu.Lock()
+	for k := range s.entries {
+		if strings.ToLower(k.answer) == Normalize(answer) {
+			queue = append(queue, k.name)
+		}
+		if k.typ == "CNAME" {
+			aliases[k.answer] = append(aliases[k.answer], k.name)
+		}
+	}
+	s.mu.Unlock()
+
+	// follow the chains backwards from the names with the answer
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if targets[name] {
+			continue
+		}
+		targets[name] = true
+		queue = append(queue, aliases[name]...)
+	}
+
+	return s.filter(func(e *types.PassiveDNS) bool {
+		return targets[strings.ToLower(e.Answer)]
+	})
+}
+
+// Entries returns all entries, ordered by first seen time.
+func (s *Store) Entries() []*types.PassiveDNS {
+	return s.filter(func(e *types.PassiveDNS) bool {
+		return true
+	})
+}
+
+// Len returns the number of entries.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// filter returns copies of the matching entries, ordered by first seen time and name.
+func (s *Store) filter(match func(e *types.PassiveDNS) bool) []*types.PassiveDNS {
+
+	s.mu.Lock()
+	var res []*types.PassiveDNS
+	for _, e := range s.entries {
+		if match(e) {
+			res = append(res, &types.PassiveDNS{
+				Timestamp:     e.Timestamp,
+				TimestampLast: e.TimestampLast,
+				Name:          e.Name,
+				Type:          e.Type,
+				Answer:        e.Answer,
+				Count:         e.Count,
+			})
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		ti, tj := utils.StringToTime(res[i].Timestamp), utils.StringToTime(res[j].Timestamp)
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		if res[i].Type != res[j].Type {
+			return res[i].Type < res[j].Type
+		}
+		return res[i].Answer < res[j].Answer
+	})
+	return res
+}
+
+// Answer returns the address or name of a resource record.
+// Records of other types than A, AAAA, CNAME, NS, PTR, MX, SRV and TXT are not stored.
+func Answer(r *types.DNSResourceRecord) (string, bool) {
+	switch layers.DNSType(r.Type) {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		return r.IP, r.IP != ""
+	case layers.DNSTypeCNAME:
+		return Normalize(string(r.CNAME)), len(r.CNAME) > 0
+	case layers.DNSTypeNS:
+		return Normalize(string(r.NS)), len(r.NS) > 0
+	case layers.DNSTypePTR:
+		return Normalize(string(r.PTR)), len(r.PTR) > 0
+	case layers.DNSTypeMX:
+		if r.MX == nil || len(r.MX.Name) == 0 {
+			return "", false
+		}
+		return Normalize(string(r.MX.Name)), true
+	case layers.DNSTypeSRV:
+		if r.SRV == nil || len(r.SRV.Name) == 0 {
+			return "", false
+		}
+		return Normalize(string(r.SRV.Name)) + ":" + strconv.Itoa(int(r.SRV.Port)), true
+	case layers.DNSTypeTXT:
+		txts := make([]string, len(r.TXTs))
+		for i, t := range r.TXTs {
+			txts[i] = string(t)
+		}
+		return strings.Join(txts, " "), len(txts) > 0
+	}
+	return "", false
+}
+
+// Normalize returns the name in lower case and without a trailing dot.
+func Normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
@@ -0,0 +1,154 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package pdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+func response(ts time.Time, name string, answers ...*types.DNSResourceRecord) *types.DNS {
+	return &types.DNS{
+		Timestamp: utils.TimeToString(ts),
+		QR:        true,
+		Questions: []*types.DNSQuestion{{Name: []byte(name), Type: int32(layers.DNSTypeA), Class: 1}},
+		Answers:   answers,
+	}
+}
+
+func a(name, ip string) *types.DNSResourceRecord {
+	return &types.DNSResourceRecord{Name: []byte(name), Type: int32(layers.DNSTypeA), IP: ip}
+}
+
+func cname(name, target string) *types.DNSResourceRecord {
+	return &types.DNSResourceRecord{Name: []byte(name), Type: int32(layers.DNSTypeCNAME), CNAME: []byte(target)}
+}
+
+func TestStore(t *testing.T) {
+
+	var (
+		s     = NewStore(100)
+		start = time.Unix(1500000000, 0)
+	)
+
+	s.AddRecord(response(start.Add(time.Minute), "evil.com", a("evil.com", "10.1.2.3")))
+	s.AddRecord(response(start, "Evil.com.", a("evil.com.", "10.1.2.3")))
+	s.AddRecord(response(start.Add(2*time.Minute), "www.evil.com", cname("www.evil.com", "cdn.example.net"), a("cdn.example.net", "10.9.9.9")))
+	s.AddRecord(response(start.Add(3*time.Minute), "other.org", a("other.org", "10.1.2.3")))
+
+	// queries are ignored
+	q := response(start, "ignored.com", a("ignored.com", "10.1.2.3"))
+	q.QR = false
+	s.AddRecord(q)
+
+	if s.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d", s.Len())
+	}
+
+	history := s.Name("evil.com")
+	if len(history) != 2 {
+		t.Fatalf("expected 2 entries for evil.com, got %d", len(history))
+	}
+	e := history[0]
+	if e.Name != "evil.com" || e.Type != "A" || e.Answer != "10.1.2.3" || e.Count != 2 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e.Timestamp != utils.TimeToString(start) || e.TimestampLast != utils.TimeToString(start.Add(time.Minute)) {
+		t.Errorf("unexpected first and last seen: %s %s", e.Timestamp, e.TimestampLast)
+	}
+	if history[1].Type != "CNAME" || history[1].Answer != "cdn.example.net" {
+		t.Errorf("unexpected entry: %+v", history[1])
+	}
+
+	resolved := s.Resolved("10.1.2.3")
+	if len(resolved) != 2 || resolved[0].Name != "evil.com" || resolved[1].Name != "other.org" {
+		t.Fatalf("unexpected names for 10.1.2.3: %+v", resolved)
+	}
+
+	resolved = s.Resolved("10.9.9.9")
+	if len(resolved) != 2 || resolved[0].Name != "cdn.example.net" || resolved[1].Name != "www.evil.com" {
+		t.Fatalf("expected CNAME chain for 10.9.9.9: %+v", resolved)
+	}
+
+	if h := s.Hostname("10.1.2.3"); h != "other.org" {
+		t.Errorf("expected most recent hostname other.org, got %q", h)
+	}
+	if h := s.Hostname("10.9.9.9"); h != "www.evil.com" {
+		t.Errorf("expected queried name www.evil.com, got %q", h)
+	}
+	if h := s.Hostname("10.0.0.1"); h != "" {
+		t.Errorf("expected no hostname, got %q", h)
+	}
+}
+
+func TestAddEntry(t *testing.T) {
+
+	var (
+		s     = NewStore(1)
+		start = time.Unix(1500000000, 0)
+	)
+
+	s.AddEntry(&types.PassiveDNS{
+		Timestamp:     utils.TimeToString(start.Add(time.Hour)),
+		TimestampLast: utils.TimeToString(start.Add(2 * time.Hour)),
+		Name:          "evil.com",
+		Type:          "A",
+		Answer:        "10.1.2.3",
+		Count:         5,
+	})
+	s.AddRecord(response(start, "evil.com", a("evil.com", "10.1.2.3")))
+
+	// the store is full
+	s.AddRecord(response(start, "other.org", a("other.org", "10.4.5.6")))
+
+	entries := s.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Count != 6 || e.Timestamp != utils.TimeToString(start) || e.TimestampLast != utils.TimeToString(start.Add(2*time.Hour)) {
+		t.Errorf("unexpected merged entry: %+v", e)
+	}
+	if h := s.Hostname("10.1.2.3"); h != "evil.com" {
+		t.Errorf("expected hostname evil.com, got %q", h)
+	}
+	if h := s.Hostname("10.4.5.6"); h != "" {
+		t.Errorf("expected no hostname for an address beyond the limit, got %q", h)
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	tests := []struct {
+		r      *types.DNSResourceRecord
+		answer string
+	}{
+		{&types.DNSResourceRecord{Type: int32(layers.DNSTypeAAAA), IP: "2001:db8::1"}, "2001:db8::1"},
+		{&types.DNSResourceRecord{Type: int32(layers.DNSTypeNS), NS: []byte("NS1.Example.com.")}, "ns1.example.com"},
+		{&types.DNSResourceRecord{Type: int32(layers.DNSTypeMX), MX: &types.DNSMX{Name: []byte("mail.example.com")}}, "mail.example.com"},
+		{&types.DNSResourceRecord{Type: int32(layers.DNSTypeSRV), SRV: &types.DNSSRV{Name: []byte("dc.example.com"), Port: 389}}, "dc.example.com:389"},
+		{&types.DNSResourceRecord{Type: int32(layers.DNSTypeTXT), TXTs: [][]byte{[]byte("v=spf1"), []byte("-all")}}, "v=spf1 -all"},
+	}
+	for _, test := range tests {
+		answer, ok := Answer(test.r)
+		if !ok || answer != test.answer {
+			t.Errorf("expected %q, got %q", test.answer, answer)
+		}
+	}
+	if _, ok := Answer(&types.DNSResourceRecord{Type: int32(layers.DNSTypeSOA)}); ok {
+		t.Error("expected SOA records to be ignored")
+	}
+}
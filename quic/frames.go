@@ -0,0 +1,162 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package quic
+
+import (
+	"errors"
+	"sort"
+)
+
+// Frame types that can appear in Initial packets.
+const (
+	FramePadding            = 0x00
+	FramePing               = 0x01
+	FrameAck                = 0x02
+	FrameAckECN             = 0x03
+	FrameCrypto             = 0x06
+	FrameConnectionClose    = 0x1c
+	FrameConnectionCloseApp = 0x1d
+)
+
+// ErrFrame is returned for frames that are malformed or not allowed in Initial packets.
+var ErrFrame = errors.New("quic: invalid frame")
+
+// MaxCryptoStreamSize is the maximum number of bytes buffered by a CryptoStream,
+// which is well above the size of a ClientHello.
+const MaxCryptoStreamSize = 64 * 1024
+
+// ErrCryptoStreamSize is returned if the data of a CryptoStream exceeds MaxCryptoStreamSize.
+var ErrCryptoStreamSize = errors.New("quic: crypto stream too large")
+
+// CryptoFrame contains a part of the TLS handshake data.
+type CryptoFrame struct {
+	Offset uint64
+	Data   []byte
+}
+
+// CryptoFrames returns the CRYPTO frames from the decrypted payload of an Initial packet.
+func CryptoFrames(payload []byte) ([]CryptoFrame, error) {
+
+	var frames []CryptoFrame
+	for len(payload) > 0 {
+		typ, n := ReadVarint(payload)
+		if n == 0 {
+			return frames, ErrFrame
+		}
+		payload = payload[n:]
+
+		switch typ {
+		case FramePadding, FramePing:
+		case FrameAck, FrameAckECN:
+			// largest acknowledged, delay, range count and first range
+			var fields [4]uint64
+			for i := range fields {
+				if fields[i], n = ReadVarint(payload); n == 0 {
+					return frames, ErrFrame
+				}
+				payload = payload[n:]
+			}
+			// gap and length of each additional range, followed by the ECN counts
+			count := fields[2] * 2
+			if typ == FrameAckECN {
+				count += 3
+			}
+			for i := uint64(0); i < count; i++ {
+				if _, n = ReadVarint(payload); n == 0 {
+					return frames, ErrFrame
+				}
+				payload = payload[n:]
+			}
+		case FrameCrypto:
+			offset, n := ReadVarint(payload)
+			if n == 0 {
+				return frames, ErrFrame
+			}
+			payload = payload[n:]
+			length, n := ReadVarint(payload)
+			if n == 0 || uint64(len(payload)-n) < length {
+				return frames, ErrFrame
+			}
+			payload = payload[n:]
+			frames = append(frames, CryptoFrame{
+				Offset: offset,
+				Data:   payload[:length],
+			})
+			payload = payload[length:]
+		case FrameConnectionClose, FrameConnectionCloseApp:
+			// no further frames are processed after the connection was closed
+			return frames, nil
+		default:
+			return frames, ErrFrame
+		}
+	}
+	return frames, nil
+}
+
+// CryptoStream reassembles the handshake data from CRYPTO frames,
+// which can arrive out of order and span several packets.
+type CryptoStream struct {
+	frames []CryptoFrame
+	size   int
+}
+
+// Add adds the frames to the stream, the data is copied.
+// If the stream would exceed MaxCryptoStreamSize, ErrCryptoStreamSize is returned
+// and the remaining frames are not added.
+func (s *CryptoStream) Add(frames ...CryptoFrame) error {
+	for _, f := range frames {
+		if f.Offset+uint64(len(f.Data)) > MaxCryptoStreamSize || s.size+len(f.Data) > MaxCryptoStreamSize {
+			return ErrCryptoStreamSize
+		}
+		s.size += len(f.Data)
+		s.frames = append(s.frames, CryptoFrame{
+			Offset: f.Offset,
+			Data:   append([]byte(nil), f.Data...),
+		})
+	}
+	return nil
+}
+
+// Data returns the contiguous data from the start of the stream.
+func (s *CryptoStream) Data() []byte {
+
+	sort.Slice(s.frames, func(i, j int) bool {
+		return s.frames[i].Offset < s.frames[j].Offset
+	})
+
+	var data []byte
+	for _, f := range s.frames {
+		end := f.Offset + uint64(len(f.Data))
+		if f.Offset > uint64(len(data)) {
+			break
+		}
+		if end > uint64(len(data)) {
+			data = append(data, f.Data[uint64(len(data))-f.Offset:]...)
+		}
+	}
+	return data
+}
+
+// Message returns the first handshake message of the stream,
+// or nil if it has not been received completely.
+func (s *CryptoStream) Message() []byte {
+	data := s.Data()
+	if len(data) < 4 {
+		return nil
+	}
+	length := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if len(data) < 4+length {
+		return nil
+	}
+	return data[:4+length]
+}
@@ -0,0 +1,168 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package quic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// ErrNotInitial is returned when decrypting a packet that is not an Initial packet.
+var ErrNotInitial = errors.New("quic: not an initial packet")
+
+var (
+	saltV1      = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+	saltV2      = []byte{0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9}
+	saltDraft29 = []byte{0xaf, 0xbf, 0xec, 0x28, 0x99, 0x93, 0xd2, 0x4c, 0x9e, 0x97, 0x86, 0xf1, 0x9c, 0x61, 0x11, 0xe0, 0x43, 0x90, 0xa8, 0x99}
+	saltDraft23 = []byte{0xc3, 0xee, 0xf7, 0x12, 0xc7, 0x2e, 0xbb, 0x5a, 0x11, 0xa7, 0xd2, 0x43, 0x2b, 0xb4, 0x63, 0x65, 0xbe, 0xf9, 0xf5, 0x02}
+)
+
+// initialSalt returns the salt and the prefix of the key derivation labels for a version
+func initialSalt(version uint32) (salt []byte, labelPrefix string, err error) {
+	switch {
+	case version == Version1:
+		return saltV1, "quic ", nil
+	case version == Version2:
+		return saltV2, "quicv2 ", nil
+	case version >= 0xff00001d && version <= 0xff000022:
+		return saltDraft29, "quic ", nil
+	case version >= 0xff000017 && version <= 0xff00001c:
+		return saltDraft23, "quic ", nil
+	}
+	return nil, "", ErrUnsupportedVersion
+}
+
+// Keys protect the packets sent by one endpoint.
+type Keys struct {
+	Key []byte
+	IV  []byte
+	HP  []byte
+}
+
+// ClientInitialKeys derives the keys for the Initial packets of the client
+// from the destination connection ID of its first Initial packet.
+func ClientInitialKeys(version uint32, dcid []byte) (*Keys, error) {
+
+	salt, prefix, err := initialSalt(version)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		initialSecret = hkdfExtract(sha256.New, salt, dcid)
+		clientSecret  = hkdfExpandLabel(initialSecret, "client in", 32)
+	)
+	return &Keys{
+		Key: hkdfExpandLabel(clientSecret, prefix+"key", 16),
+		IV:  hkdfExpandLabel(clientSecret, prefix+"iv", 12),
+		HP:  hkdfExpandLabel(clientSecret, prefix+"hp", 16),
+	}, nil
+}
+
+// DecryptInitial removes the header protection of a client Initial packet and decrypts its payload.
+// data must start with the packet, h is the header parsed from it.
+func DecryptInitial(h *Header, data []byte) ([]byte, error) {
+
+	if h.Type != PacketInitial {
+		return nil, ErrNotInitial
+	}
+	if h.Length < 20 || h.PacketLength() > len(data) {
+		return nil, ErrTooShort
+	}
+
+	keys, err := ClientInitialKeys(h.Version, h.DCID)
+	if err != nil {
+		return nil, err
+	}
+
+	hp, err := aes.NewCipher(keys.HP)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sample starts four bytes after the packet number offset, as if the packet number had its maximum length
+	var (
+		packet = append([]byte(nil), data[:h.PacketLength()]...)
+		mask   = make([]byte, aes.BlockSize)
+	)
+	hp.Encrypt(mask, packet[h.pnOffset+4:h.pnOffset+4+aes.BlockSize])
+
+	packet[0] ^= mask[0] & 0x0f
+	var (
+		pnLen = int(packet[0]&0x03) + 1
+		pn    uint64
+	)
+	for i := 0; i < pnLen; i++ {
+		packet[h.pnOffset+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(packet[h.pnOffset+i])
+	}
+
+	block, err := aes.NewCipher(keys.Key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// the nonce is the IV combined with the packet number
+	// the truncated packet number equals the full one for the first packets of a connection
+	nonce := append([]byte(nil), keys.IV...)
+	var pnBytes [8]byte
+	binary.BigEndian.PutUint64(pnBytes[:], pn)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-8+i] ^= pnBytes[i]
+	}
+
+	var (
+		hdr     = packet[:h.pnOffset+pnLen]
+		payload = packet[h.pnOffset+pnLen:]
+	)
+	return aead.Open(payload[:0], nonce, payload, hdr)
+}
+
+func hkdfExtract(h func() hash.Hash, salt, secret []byte) []byte {
+	mac := hmac.New(h, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label from TLS 1.3 with SHA-256 and an empty context
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+
+	label = "tls13 " + label
+	info := make([]byte, 0, 4+len(label))
+	info = append(info, byte(length>>8), byte(length), byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0)
+
+	var (
+		out  []byte
+		prev []byte
+	)
+	for i := byte(1); len(out) < length; i++ {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
@@ -0,0 +1,151 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package quic
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// TLS extensions carrying the transport parameters, drafts used a different code point.
+const (
+	ExtensionTransportParameters      = 0x39
+	ExtensionTransportParametersDraft = 0xffa5
+)
+
+// ErrClientHello is returned for malformed ClientHello messages.
+var ErrClientHello = errors.New("quic: invalid client hello")
+
+var parameterNames = map[uint64]string{
+	0x00:   "original_destination_connection_id",
+	0x01:   "max_idle_timeout",
+	0x02:   "stateless_reset_token",
+	0x03:   "max_udp_payload_size",
+	0x04:   "initial_max_data",
+	0x05:   "initial_max_stream_data_bidi_local",
+	0x06:   "initial_max_stream_data_bidi_remote",
+	0x07:   "initial_max_stream_data_uni",
+	0x08:   "initial_max_streams_bidi",
+	0x09:   "initial_max_streams_uni",
+	0x0a:   "ack_delay_exponent",
+	0x0b:   "max_ack_delay",
+	0x0c:   "disable_active_migration",
+	0x0d:   "preferred_address",
+	0x0e:   "active_connection_id_limit",
+	0x0f:   "initial_source_connection_id",
+	0x10:   "retry_source_connection_id",
+	0x11:   "version_information",
+	0x20:   "max_datagram_frame_size",
+	0x2ab2: "grease_quic_bit",
+}
+
+// parameters with an integer value
+var integerParameters = map[uint64]bool{
+	0x01: true, 0x03: true, 0x04: true, 0x05: true, 0x06: true, 0x07: true,
+	0x08: true, 0x09: true, 0x0a: true, 0x0b: true, 0x0e: true, 0x20: true,
+}
+
+// TransportParameter is a parameter sent by an endpoint during the handshake.
+type TransportParameter struct {
+	ID    uint64
+	Value []byte
+}
+
+// Name returns the name of the parameter, or its hex id if it is unknown.
+func (p TransportParameter) Name() string {
+	if n, ok := parameterNames[p.ID]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%x", p.ID)
+}
+
+// String returns the value as decimal number for integer parameters and hex encoded otherwise.
+func (p TransportParameter) String() string {
+	if integerParameters[p.ID] {
+		if v, n := ReadVarint(p.Value); n == len(p.Value) {
+			return strconv.FormatUint(v, 10)
+		}
+	}
+	return hex.EncodeToString(p.Value)
+}
+
+// ParseTransportParameters decodes the contents of the transport parameters extension.
+func ParseTransportParameters(data []byte) ([]TransportParameter, error) {
+	var params []TransportParameter
+	for len(data) > 0 {
+		id, n := ReadVarint(data)
+		if n == 0 {
+			return params, ErrFrame
+		}
+		data = data[n:]
+		length, n := ReadVarint(data)
+		if n == 0 || uint64(len(data)-n) < length {
+			return params, ErrFrame
+		}
+		data = data[n:]
+		params = append(params, TransportParameter{
+			ID:    id,
+			Value: data[:length],
+		})
+		data = data[length:]
+	}
+	return params, nil
+}
+
+// ClientHelloExtension returns the data of an extension from a ClientHello handshake message,
+// or nil if it is not present.
+func ClientHelloExtension(hello []byte, typ uint16) ([]byte, error) {
+
+	// handshake header, version and random
+	pos := 4 + 2 + 32
+
+	// session id, cipher suites and compression methods
+	for _, lenSize := range []int{1, 2, 1} {
+		if pos+lenSize > len(hello) {
+			return nil, ErrClientHello
+		}
+		l := int(hello[pos])
+		if lenSize == 2 {
+			l = int(binary.BigEndian.Uint16(hello[pos:]))
+		}
+		pos += lenSize + l
+	}
+
+	if pos+2 > len(hello) {
+		return nil, ErrClientHello
+	}
+	end := pos + 2 + int(binary.BigEndian.Uint16(hello[pos:]))
+	if end > len(hello) {
+		return nil, ErrClientHello
+	}
+	pos += 2
+
+	for pos+4 <= end {
+		var (
+			t = binary.BigEndian.Uint16(hello[pos:])
+			l = int(binary.BigEndian.Uint16(hello[pos+2:]))
+		)
+		pos += 4
+		if pos+l > end {
+			return nil, ErrClientHello
+		}
+		if t == typ {
+			return hello[pos : pos+l], nil
+		}
+		pos += l
+	}
+	return nil, nil
+}
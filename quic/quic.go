@@ -0,0 +1,186 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+// Package quic decodes the Initial packets of a QUIC connection (RFC 9000, RFC 9001, RFC 9369).
+//
+// The Initial packets are encrypted with keys derived from the destination connection ID chosen by the client
+// and a version specific salt, so they can be decrypted by a passive observer.
+// The first Initial packets of the client carry the TLS ClientHello in CRYPTO frames.
+package quic
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Versions.
+const (
+	Version1       = 0x00000001
+	Version2       = 0x6b3343cf
+	VersionDraft29 = 0xff00001d
+)
+
+// Packet types, the values are normalized to the type bits of version 1.
+const (
+	PacketInitial   = 0
+	PacketZeroRTT   = 1
+	PacketHandshake = 2
+	PacketRetry     = 3
+)
+
+// MaxConnectionIDLength is the maximum length of a connection ID in version 1.
+const MaxConnectionIDLength = 20
+
+var (
+	// ErrTooShort is returned if the packet is smaller than its header fields indicate.
+	ErrTooShort = errors.New("quic: packet too short")
+	// ErrShortHeader is returned for packets with a short header, which are sent after the handshake.
+	ErrShortHeader = errors.New("quic: not a long header packet")
+	// ErrVersionNegotiation is returned for version negotiation packets.
+	ErrVersionNegotiation = errors.New("quic: version negotiation packet")
+	// ErrUnsupportedVersion is returned for versions without known initial salt.
+	ErrUnsupportedVersion = errors.New("quic: unsupported version")
+	// ErrConnectionID is returned for connection IDs longer than allowed.
+	ErrConnectionID = errors.New("quic: invalid connection id length")
+)
+
+// Header is the long header of a QUIC packet.
+type Header struct {
+	Type    uint8
+	Version uint32
+	DCID    []byte
+	SCID    []byte
+
+	// Initial packets only
+	Token []byte
+
+	// Length of the packet number and payload, zero for Retry packets
+	Length int
+
+	// offset of the packet number
+	pnOffset int
+}
+
+// PacketLength returns the size of the packet including the header,
+// several packets can be coalesced into a single UDP datagram.
+func (h *Header) PacketLength() int {
+	return h.pnOffset + h.Length
+}
+
+// ParseLongHeader decodes the long header of a QUIC packet.
+func ParseLongHeader(data []byte) (*Header, error) {
+
+	if len(data) < 7 {
+		return nil, ErrTooShort
+	}
+	if data[0]&0x80 == 0 {
+		return nil, ErrShortHeader
+	}
+
+	h := &Header{
+		Version: binary.BigEndian.Uint32(data[1:5]),
+	}
+	if h.Version == 0 {
+		return nil, ErrVersionNegotiation
+	}
+	h.Type = packetType(h.Version, data[0])
+
+	var (
+		pos = 5
+		err error
+	)
+	if h.DCID, pos, err = connectionID(data, pos); err != nil {
+		return nil, err
+	}
+	if h.SCID, pos, err = connectionID(data, pos); err != nil {
+		return nil, err
+	}
+
+	if h.Type == PacketRetry {
+		h.pnOffset = len(data)
+		return h, nil
+	}
+
+	if h.Type == PacketInitial {
+		tokenLen, n := ReadVarint(data[pos:])
+		if n == 0 || uint64(len(data)-pos-n) < tokenLen {
+			return nil, ErrTooShort
+		}
+		pos += n
+		h.Token = data[pos : pos+int(tokenLen)]
+		pos += int(tokenLen)
+	}
+
+	length, n := ReadVarint(data[pos:])
+	if n == 0 || uint64(len(data)-pos-n) < length {
+		return nil, ErrTooShort
+	}
+	h.pnOffset = pos + n
+	h.Length = int(length)
+
+	return h, nil
+}
+
+// packetType returns the type of a long header packet,
+// version 2 uses different values for the type bits
+func packetType(version uint32, first byte) uint8 {
+	t := (first & 0x30) >> 4
+	if version == Version2 {
+		return (t + 3) % 4
+	}
+	return t
+}
+
+func connectionID(data []byte, pos int) ([]byte, int, error) {
+	if pos >= len(data) {
+		return nil, pos, ErrTooShort
+	}
+	l := int(data[pos])
+	if l > MaxConnectionIDLength {
+		return nil, pos, ErrConnectionID
+	}
+	pos++
+	if pos+l > len(data) {
+		return nil, pos, ErrTooShort
+	}
+	return data[pos : pos+l], pos + l, nil
+}
+
+// ReadVarint decodes a variable length integer, n is zero if the data is too short.
+func ReadVarint(data []byte) (v uint64, n int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	n = 1 << (data[0] >> 6)
+	if len(data) < n {
+		return 0, 0
+	}
+	v = uint64(data[0] & 0x3f)
+	for _, b := range data[1:n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, n
+}
+
+// VersionName returns the name of a version.
+func VersionName(v uint32) string {
+	switch {
+	case v == Version1:
+		return "1"
+	case v == Version2:
+		return "2"
+	case v>>8 == 0xff0000:
+		return fmt.Sprintf("draft-%d", v&0xff)
+	}
+	return fmt.Sprintf("0x%08x", v)
+}
@@ -0,0 +1,266 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package quic
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+var testDCID = []byte{0x83, 0x94, 0xc8, 0xf0, 0x3e, 0x51, 0x57, 0x08}
+
+func TestClientInitialKeys(t *testing.T) {
+
+	tests := []struct {
+		version     uint32
+		key, iv, hp string
+	}{
+		// RFC 9001 Appendix A.1
+		{Version1, "1f369613dd76d5467730efcbe3b1a22d", "fa044b2f42a3fd3b46fb255c", "9f50449e04a0e810283a1e9933adedd2"},
+		// RFC 9369 Appendix A.1
+		{Version2, "8b1a0bc121284290a29e0971b5cd045d", "91f73e2351d8fa91660e909f", "45b95e15235d6f45a6b19cbcb0294ba9"},
+	}
+	for _, test := range tests {
+		keys, err := ClientInitialKeys(test.version, testDCID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(keys.Key) != test.key || hex.EncodeToString(keys.IV) != test.iv || hex.EncodeToString(keys.HP) != test.hp {
+			t.Fatalf("unexpected keys for version %s: %x %x %x", VersionName(test.version), keys.Key, keys.IV, keys.HP)
+		}
+	}
+
+	if _, err := ClientInitialKeys(0x12345678, testDCID); err != ErrUnsupportedVersion {
+		t.Fatal("expected unsupported version error, got", err)
+	}
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, byte(v>>8)|0x40, byte(v))
+	}
+	return append(b, byte(v>>24)|0x80, byte(v>>16), byte(v>>8), byte(v))
+}
+
+// clientHello returns a ClientHello handshake message with the given extensions
+func clientHello(extensions ...[]byte) []byte {
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0)                            // session id
+	body = append(body, 0, 4, 0x13, 0x01, 0x13, 0x02) // cipher suites
+	body = append(body, 1, 0)                         // compression
+	var ext []byte
+	for _, e := range extensions {
+		ext = append(ext, e...)
+	}
+	body = append(body, byte(len(ext)>>8), byte(len(ext)))
+	body = append(body, ext...)
+	return append([]byte{1, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func extension(typ uint16, data []byte) []byte {
+	return append([]byte{byte(typ >> 8), byte(typ), byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+// protect builds an Initial packet with a two byte packet number, encrypts the payload and applies header protection
+func protect(t *testing.T, version uint32, first byte, payload []byte) []byte {
+
+	keys, err := ClientInitialKeys(version, testDCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pn := []byte{0x00, 0x02}
+	hdr := []byte{first | 0x01, byte(version >> 24), byte(version >> 16), byte(version >> 8), byte(version)}
+	hdr = append(hdr, byte(len(testDCID)))
+	hdr = append(hdr, testDCID...)
+	hdr = append(hdr, 0) // scid
+	hdr = append(hdr, 0) // token
+	hdr = appendVarint(hdr, uint64(len(pn)+len(payload)+16))
+	pnOffset := len(hdr)
+	hdr = append(hdr, pn...)
+
+	block, _ := aes.NewCipher(keys.Key)
+	aead, _ := cipher.NewGCM(block)
+	nonce := append([]byte(nil), keys.IV...)
+	nonce[len(nonce)-1] ^= 0x02
+	packet := aead.Seal(append([]byte(nil), hdr...), nonce, payload, hdr)
+
+	hp, _ := aes.NewCipher(keys.HP)
+	mask := make([]byte, 16)
+	hp.Encrypt(mask, packet[pnOffset+4:pnOffset+20])
+	packet[0] ^= mask[0] & 0x0f
+	packet[pnOffset] ^= mask[1]
+	packet[pnOffset+1] ^= mask[2]
+
+	return packet
+}
+
+func TestDecryptInitial(t *testing.T) {
+
+	params := appendVarint(appendVarint(appendVarint(nil, 0x04), 4), 1048576)
+	params = append(params, 0x0c, 0x00)
+	hello := clientHello(
+		extension(0x00, []byte("\x00\x0e\x00\x00\x0bexample.com")),
+		extension(ExtensionTransportParameters, params),
+	)
+
+	// the ClientHello is split into two CRYPTO frames in reverse order, preceded by a PING and followed by padding
+	split := 20
+	payload := []byte{FramePing, FrameCrypto}
+	payload = appendVarint(payload, uint64(split))
+	payload = appendVarint(payload, uint64(len(hello)-split))
+	payload = append(payload, hello[split:]...)
+	payload = append(payload, FrameCrypto, 0)
+	payload = appendVarint(payload, uint64(split))
+	payload = append(payload, hello[:split]...)
+	payload = append(payload, make([]byte, 100)...)
+
+	for _, test := range []struct {
+		version uint32
+		first   byte
+	}{
+		{Version1, 0xc0},
+		{Version2, 0xd0},
+	} {
+		packet := protect(t, test.version, test.first, payload)
+		// coalesced with another packet
+		datagram := append(packet, 0xe0, 0, 0, 0, 1)
+
+		h, err := ParseLongHeader(datagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Type != PacketInitial || h.Version != test.version || !bytes.Equal(h.DCID, testDCID) || h.PacketLength() != len(packet) {
+			t.Fatal("unexpected header", h)
+		}
+
+		plain, err := DecryptInitial(h, datagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, payload) {
+			t.Fatal("payload mismatch")
+		}
+
+		frames, err := CryptoFrames(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 2 {
+			t.Fatal("expected 2 frames, got", len(frames))
+		}
+
+		var s CryptoStream
+		s.Add(frames[0])
+		if s.Message() != nil {
+			t.Fatal("expected incomplete message")
+		}
+		s.Add(frames[1])
+		if !bytes.Equal(s.Message(), hello) {
+			t.Fatal("reassembled message mismatch")
+		}
+
+		ext, err := ClientHelloExtension(s.Message(), ExtensionTransportParameters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tp, err := ParseTransportParameters(ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tp) != 2 || tp[0].Name() != "initial_max_data" || tp[0].String() != "1048576" || tp[1].Name() != "disable_active_migration" || tp[1].String() != "" {
+			t.Fatal("unexpected transport parameters", tp)
+		}
+	}
+}
+
+func TestDecryptInitialTampered(t *testing.T) {
+	packet := protect(t, Version1, 0xc0, append([]byte{FramePing}, make([]byte, 50)...))
+	packet[len(packet)-1] ^= 0xff
+	h, err := ParseLongHeader(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptInitial(h, packet); err == nil {
+		t.Fatal("expected authentication error")
+	}
+}
+
+func TestCryptoStreamSize(t *testing.T) {
+	var s CryptoStream
+	if err := s.Add(CryptoFrame{Offset: MaxCryptoStreamSize, Data: []byte{1}}); err != ErrCryptoStreamSize {
+		t.Fatal("expected ErrCryptoStreamSize for a frame beyond the maximum offset, got", err)
+	}
+
+	// retransmissions of the same data count as well
+	data := make([]byte, 1024)
+	for i := 0; i < MaxCryptoStreamSize/len(data); i++ {
+		if err := s.Add(CryptoFrame{Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(CryptoFrame{Data: data}); err != ErrCryptoStreamSize {
+		t.Fatal("expected ErrCryptoStreamSize, got", err)
+	}
+}
+
+func TestParseLongHeaderErrors(t *testing.T) {
+	if _, err := ParseLongHeader([]byte{0x40, 1, 2, 3, 4, 5, 6, 7}); err != ErrShortHeader {
+		t.Fatal("expected short header error, got", err)
+	}
+	if _, err := ParseLongHeader([]byte{0xc0, 0, 0, 0, 0, 0, 0}); err != ErrVersionNegotiation {
+		t.Fatal("expected version negotiation error, got", err)
+	}
+	if _, err := ParseLongHeader([]byte{0xc0, 0, 0, 0, 1, 21, 0}); err != ErrConnectionID {
+		t.Fatal("expected connection id error, got", err)
+	}
+	// length exceeds the datagram
+	if _, err := ParseLongHeader([]byte{0xc0, 0, 0, 0, 1, 0, 0, 0, 0x40, 0xff}); err != ErrTooShort {
+		t.Fatal("expected too short error, got", err)
+	}
+}
+
+func TestReadVarint(t *testing.T) {
+	// RFC 9000 Appendix A.1
+	for _, test := range []struct {
+		data string
+		v    uint64
+	}{
+		{"c2197c5eff14e88c", 151288809941952652},
+		{"9d7f3e7d", 494878333},
+		{"7bbd", 15293},
+		{"25", 37},
+	} {
+		b, _ := hex.DecodeString(test.data)
+		v, n := ReadVarint(b)
+		if v != test.v || n != len(b) {
+			t.Fatal("unexpected value", v, n)
+		}
+	}
+	if _, n := ReadVarint([]byte{0x80, 1}); n != 0 {
+		t.Fatal("expected incomplete varint")
+	}
+}
+
+func TestVersionName(t *testing.T) {
+	if VersionName(Version1) != "1" || VersionName(VersionDraft29) != "draft-29" || VersionName(0x51303530) != "0x51303530" {
+		t.Fatal("unexpected version names")
+	}
+}
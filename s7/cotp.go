@@ -0,0 +1,154 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package s7
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// Port is the TCP port of ISO-TSAP, used by S7comm
+	Port = 102
+
+	tpktSize = 4
+
+	// ProtocolS7 is the protocol ID of S7comm
+	ProtocolS7 = 0x32
+
+	// ProtocolS7Plus is the protocol ID of S7comm-plus, which is not decoded any further
+	ProtocolS7Plus = 0x72
+)
+
+var (
+	// ErrInvalidTPKT is returned if the data does not start with a TPKT header.
+	ErrInvalidTPKT = errors.New("s7: invalid TPKT header")
+
+	// ErrTruncated is returned if a header or parameter ends prematurely.
+	ErrTruncated = errors.New("s7: truncated data")
+)
+
+// FrameLength returns the size of the TPKT packet at the start of the data.
+// ok is false if the header is incomplete.
+func FrameLength(data []byte) (n int, ok bool, err error) {
+	if len(data) < tpktSize {
+		return 0, false, nil
+	}
+	n = int(binary.BigEndian.Uint16(data[2:4]))
+	if data[0] != 3 || n < tpktSize+2 {
+		return 0, false, ErrInvalidTPKT
+	}
+	return n, true, nil
+}
+
+// COTP PDU types.
+const (
+	COTPConnectRequest = 0xe0
+	COTPConnectConfirm = 0xd0
+	COTPDisconnect     = 0x80
+	COTPData           = 0xf0
+)
+
+// COTP is the header of a connection oriented transport PDU.
+type COTP struct {
+	Type uint8
+
+	// last data unit of a fragmented data TPDU
+	EOT bool
+
+	// transport service access points of connection requests and confirms
+	SrcTSAP uint16
+	DstTSAP uint16
+
+	// user data of a data TPDU
+	Data []byte
+}
+
+// ParseCOTP parses the COTP header from a complete TPKT packet.
+func ParseCOTP(data []byte) (*COTP, error) {
+	if len(data) < tpktSize+2 {
+		return nil, ErrTruncated
+	}
+	data = data[tpktSize:]
+	length := int(data[0])
+	if len(data) < 1+length || length < 1 {
+		return nil, ErrTruncated
+	}
+	c := &COTP{Type: data[1] & 0xf0}
+	header := data[1 : 1+length]
+
+	switch c.Type {
+	case COTPData:
+		if len(header) < 2 {
+			return nil, ErrTruncated
+		}
+		c.EOT = header[1]&0x80 != 0
+		c.Data = data[1+length:]
+	case COTPConnectRequest, COTPConnectConfirm:
+		// destination and source reference and class, followed by the parameters
+		if len(header) < 6 {
+			return nil, ErrTruncated
+		}
+		params := header[6:]
+		for len(params) >= 2 {
+			code, size := params[0], int(params[1])
+			if len(params) < 2+size {
+				break
+			}
+			if size == 2 {
+				switch code {
+				case 0xc1:
+					c.SrcTSAP = binary.BigEndian.Uint16(params[2:4])
+				case 0xc2:
+					c.DstTSAP = binary.BigEndian.Uint16(params[2:4])
+				}
+			}
+			params = params[2+size:]
+		}
+	}
+	return c, nil
+}
+
+// COTPTypeName returns the abbreviation of a COTP PDU type.
+func COTPTypeName(t uint8) string {
+	switch t {
+	case COTPConnectRequest:
+		return "CR"
+	case COTPConnectConfirm:
+		return "CC"
+	case COTPDisconnect:
+		return "DR"
+	case 0xc0:
+		return "DC"
+	case COTPData:
+		return "DT"
+	case 0x10:
+		return "ED"
+	case 0x20:
+		return "EA"
+	case 0x50:
+		return "RJ"
+	case 0x60:
+		return "AK"
+	case 0x70:
+		return "ER"
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(t))
+}
+
+// TSAPString formats a TSAP, for S7 the second byte contains the rack and the slot.
+func TSAPString(tsap uint16) string {
+	return fmt.Sprintf("%02x.%02x", tsap>>8, tsap&0xff)
+}
@@ -0,0 +1,402 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ROSCTR values, the PDU type of S7comm.
+const (
+	ROSCTRJob      = 1
+	ROSCTRAck      = 2
+	ROSCTRAckData  = 3
+	ROSCTRUserdata = 7
+)
+
+// Function codes of job and ack data PDUs.
+const (
+	FuncCPUServices        = 0x00
+	FuncReadVar            = 0x04
+	FuncWriteVar           = 0x05
+	FuncRequestDownload    = 0x1a
+	FuncDownloadBlock      = 0x1b
+	FuncDownloadEnded      = 0x1c
+	FuncStartUpload        = 0x1d
+	FuncUpload             = 0x1e
+	FuncEndUpload          = 0x1f
+	FuncPIService          = 0x28
+	FuncPLCStop            = 0x29
+	FuncSetupCommunication = 0xf0
+)
+
+// maximum number of items decoded from a read or write request
+const maxItems = 100
+
+// Message is a S7comm PDU.
+type Message struct {
+	ROSCTR       uint8
+	PDUReference uint16
+	ErrorClass   uint8
+	ErrorCode    uint8
+
+	Function uint8
+
+	// variables of read and write requests
+	Items []*Item
+
+	// return codes of the data items of read and write responses
+	ReturnCodes []uint8
+
+	// block name of download and upload functions, e.g. _0A00001P
+	Block string
+
+	// program invocation service of PI service and PLC stop functions, e.g. P_PROGRAM
+	PIService string
+
+	// function group and subfunction of userdata PDUs
+	UserdataGroup       uint8
+	UserdataSubfunction uint8
+
+	// negotiated PDU size of setup communication
+	PDULength uint16
+}
+
+// Item is the address of a variable in a read or write request.
+type Item struct {
+	TransportSize uint8
+	Length        uint16
+	DBNumber      uint16
+	Area          uint8
+
+	// bit address
+	Address uint32
+}
+
+// Parse decodes a S7comm PDU from the user data of a COTP data TPDU.
+// The parameters are decoded as far as possible, an error is only returned for an invalid header.
+func Parse(data []byte) (*Message, error) {
+	if len(data) < 10 {
+		return nil, ErrTruncated
+	}
+	if data[0] != ProtocolS7 {
+		return nil, fmt.Errorf("s7: invalid protocol id 0x%02x", data[0])
+	}
+	m := &Message{
+		ROSCTR:       data[1],
+		PDUReference: binary.BigEndian.Uint16(data[4:6]),
+	}
+	var (
+		paramLen = int(binary.BigEndian.Uint16(data[6:8]))
+		dataLen  = int(binary.BigEndian.Uint16(data[8:10]))
+		off      = 10
+	)
+	if m.ROSCTR == ROSCTRAck || m.ROSCTR == ROSCTRAckData {
+		if len(data) < 12 {
+			return nil, ErrTruncated
+		}
+		m.ErrorClass, m.ErrorCode = data[10], data[11]
+		off = 12
+	}
+	params := data[off:]
+	if len(params) > paramLen {
+		params = params[:paramLen]
+	}
+	var payload []byte
+	if off+paramLen < len(data) {
+		payload = data[off+paramLen:]
+		if len(payload) > dataLen {
+			payload = payload[:dataLen]
+		}
+	}
+
+	if m.ROSCTR == ROSCTRUserdata {
+		// parameter head, length, method and type with function group followed by the subfunction
+		if len(params) >= 7 {
+			m.UserdataGroup = params[5] & 0x0f
+			m.UserdataSubfunction = params[6]
+		}
+		return m, nil
+	}
+	if len(params) == 0 {
+		return m, nil
+	}
+	m.Function = params[0]
+
+	switch m.Function {
+	case FuncReadVar, FuncWriteVar:
+		if len(params) < 2 {
+			break
+		}
+		switch m.ROSCTR {
+		case ROSCTRJob:
+			m.Items = parseItems(params[2:], int(params[1]))
+		case ROSCTRAckData:
+			// read responses carry the values after each return code
+			m.ReturnCodes = parseReturnCodes(payload, int(params[1]), m.Function == FuncReadVar)
+		}
+	case FuncRequestDownload, FuncDownloadBlock, FuncDownloadEnded, FuncStartUpload, FuncUpload, FuncEndUpload:
+		// function, status, two unknown bytes and the upload ID, followed by the file name
+		if m.ROSCTR == ROSCTRJob && len(params) > 9 {
+			m.Block = readString(params[8:])
+		}
+	case FuncPIService:
+		// function, seven unknown bytes, parameter block with length, followed by the service name
+		if m.ROSCTR == ROSCTRJob && len(params) > 10 {
+			n := int(binary.BigEndian.Uint16(params[8:10]))
+			if len(params) > 10+n {
+				m.PIService = readString(params[10+n:])
+			}
+		}
+	case FuncPLCStop:
+		// function, five unknown bytes, followed by the service name
+		if m.ROSCTR == ROSCTRJob && len(params) > 7 {
+			m.PIService = readString(params[6:])
+		}
+	case FuncSetupCommunication:
+		if len(params) >= 8 {
+			m.PDULength = binary.BigEndian.Uint16(params[6:8])
+		}
+	}
+	return m, nil
+}
+
+// parseItems decodes the variable specifications of read and write requests.
+func parseItems(data []byte, count int) (items []*Item) {
+	for i := 0; i < count && i < maxItems; i++ {
+		if len(data) < 2 {
+			break
+		}
+		size := int(data[1])
+		if len(data) < 2+size {
+			break
+		}
+		// only the S7ANY syntax is decoded, other addressing modes are skipped
+		if size >= 10 && data[2] == 0x10 {
+			spec := data[2 : 2+size]
+			items = append(items, &Item{
+				TransportSize: spec[1],
+				Length:        binary.BigEndian.Uint16(spec[2:4]),
+				DBNumber:      binary.BigEndian.Uint16(spec[4:6]),
+				Area:          spec[6],
+				Address:       uint32(spec[7])<<16 | uint32(spec[8])<<8 | uint32(spec[9]),
+			})
+		}
+		data = data[2+size:]
+	}
+	return
+}
+
+// parseReturnCodes collects the return codes of the data items.
+// Items of read responses carry data that is padded to an even length, except for the last item.
+func parseReturnCodes(data []byte, count int, withData bool) (codes []uint8) {
+	for i := 0; i < count && i < maxItems; i++ {
+		if len(data) == 0 {
+			break
+		}
+		codes = append(codes, data[0])
+		if !withData {
+			data = data[1:]
+			continue
+		}
+		if len(data) < 4 {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(data[2:4]))
+		// the length is in bits, except for octet strings, reals and raw data
+		switch data[1] {
+		case 0x03, 0x04, 0x05:
+			n = (n + 7) / 8
+		}
+		n += 4
+		if n%2 != 0 && i < count-1 {
+			n++
+		}
+		if n > len(data) {
+			break
+		}
+		data = data[n:]
+	}
+	return
+}
+
+// readString returns the string with the length in the first byte.
+func readString(data []byte) string {
+	n := int(data[0])
+	if len(data) < 1+n {
+		n = len(data) - 1
+	}
+	return strings.TrimRight(string(data[1:1+n]), "\x00")
+}
+
+// IsControl returns true for functions that change the program or the operating state of the PLC.
+func (m *Message) IsControl() bool {
+	switch m.Function {
+	case FuncWriteVar, FuncRequestDownload, FuncDownloadBlock, FuncDownloadEnded, FuncPIService, FuncPLCStop:
+		return m.ROSCTR == ROSCTRJob
+	}
+	return false
+}
+
+// ROSCTRName returns the name of a PDU type.
+func ROSCTRName(r uint8) string {
+	switch r {
+	case ROSCTRJob:
+		return "Job"
+	case ROSCTRAck:
+		return "Ack"
+	case ROSCTRAckData:
+		return "Ack_Data"
+	case ROSCTRUserdata:
+		return "Userdata"
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(r))
+}
+
+// FunctionName returns the name of a function code.
+func FunctionName(f uint8) string {
+	switch f {
+	case FuncCPUServices:
+		return "CPU_SERVICES"
+	case FuncReadVar:
+		return "READ_VAR"
+	case FuncWriteVar:
+		return "WRITE_VAR"
+	case FuncRequestDownload:
+		return "REQUEST_DOWNLOAD"
+	case FuncDownloadBlock:
+		return "DOWNLOAD_BLOCK"
+	case FuncDownloadEnded:
+		return "DOWNLOAD_ENDED"
+	case FuncStartUpload:
+		return "START_UPLOAD"
+	case FuncUpload:
+		return "UPLOAD"
+	case FuncEndUpload:
+		return "END_UPLOAD"
+	case FuncPIService:
+		return "PI_SERVICE"
+	case FuncPLCStop:
+		return "PLC_STOP"
+	case FuncSetupCommunication:
+		return "SETUP_COMMUNICATION"
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(f))
+}
+
+// UserdataGroupName returns the name of a userdata function group.
+func UserdataGroupName(g uint8) string {
+	switch g {
+	case 0:
+		return "MODE_TRANSITION"
+	case 1:
+		return "PROGRAMMER_COMMANDS"
+	case 2:
+		return "CYCLIC_DATA"
+	case 3:
+		return "BLOCK_FUNCTIONS"
+	case 4:
+		return "CPU_FUNCTIONS"
+	case 5:
+		return "SECURITY"
+	case 6:
+		return "PBC_BSEND_BRECV"
+	case 7:
+		return "TIME_FUNCTIONS"
+	case 0xf:
+		return "NC_PROGRAMMING"
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(g))
+}
+
+// AreaName returns the name of a memory area.
+func AreaName(a uint8) string {
+	switch a {
+	case 0x03:
+		return "SYSINFO"
+	case 0x05:
+		return "SYSFLAGS"
+	case 0x06:
+		return "ANAIN"
+	case 0x07:
+		return "ANAOUT"
+	case 0x1c:
+		return "C"
+	case 0x1d:
+		return "T"
+	case 0x80:
+		return "P"
+	case 0x81:
+		return "I"
+	case 0x82:
+		return "Q"
+	case 0x83:
+		return "M"
+	case 0x84:
+		return "DB"
+	case 0x85:
+		return "DI"
+	case 0x86:
+		return "L"
+	case 0x87:
+		return "V"
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(a))
+}
+
+// TransportSizeName returns the name of the transport size of a variable.
+func TransportSizeName(s uint8) string {
+	switch s {
+	case 0x01:
+		return "BIT"
+	case 0x02:
+		return "BYTE"
+	case 0x03:
+		return "CHAR"
+	case 0x04:
+		return "WORD"
+	case 0x05:
+		return "INT"
+	case 0x06:
+		return "DWORD"
+	case 0x07:
+		return "DINT"
+	case 0x08:
+		return "REAL"
+	case 0x1c:
+		return "COUNTER"
+	case 0x1d:
+		return "TIMER"
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(s))
+}
+
+// String formats the address of the item in the notation of the Siemens tools,
+// e.g. DB1.DBX10.0 or M4.2.
+func (i *Item) String() string {
+	var (
+		byteAddr = i.Address >> 3
+		bit      = i.Address & 7
+	)
+	switch i.Area {
+	case 0x84, 0x85:
+		return fmt.Sprintf("%s%d.DBX%d.%d", AreaName(i.Area), i.DBNumber, byteAddr, bit)
+	case 0x1c, 0x1d:
+		// counters and timers are addressed by their number
+		return fmt.Sprintf("%s%d", AreaName(i.Area), i.Address)
+	}
+	return fmt.Sprintf("%s%d.%d", AreaName(i.Area), byteAddr, bit)
+}
@@ -0,0 +1,150 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package s7
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tpkt wraps the S7 PDU into a TPKT packet with a COTP data TPDU.
+func tpkt(pdu []byte) []byte {
+	n := 7 + len(pdu)
+	return append([]byte{0x03, 0x00, byte(n >> 8), byte(n), 0x02, 0xf0, 0x80}, pdu...)
+}
+
+func TestParseConnectRequest(t *testing.T) {
+	data := []byte{0x03, 0x00, 0x00, 0x16, 0x11, 0xe0, 0x00, 0x00, 0x00, 0x01, 0x00, 0xc0, 0x01, 0x0a, 0xc1, 0x02, 0x01, 0x00, 0xc2, 0x02, 0x01, 0x02}
+	n, ok, err := FrameLength(data)
+	if err != nil || !ok || n != len(data) {
+		t.Fatal("unexpected frame length", n, ok, err)
+	}
+	c, err := ParseCOTP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if COTPTypeName(c.Type) != "CR" || c.SrcTSAP != 0x0100 || TSAPString(c.DstTSAP) != "01.02" {
+		t.Fatalf("unexpected COTP: %+v", c)
+	}
+}
+
+func TestFrameLength(t *testing.T) {
+	if _, ok, err := FrameLength([]byte{0x03, 0x00}); ok || err != nil {
+		t.Fatal("expected incomplete header")
+	}
+	if _, _, err := FrameLength([]byte{0x16, 0x03, 0x01, 0x00}); err != ErrInvalidTPKT {
+		t.Fatal("expected invalid TPKT, got", err)
+	}
+}
+
+func TestParseReadVar(t *testing.T) {
+	c, err := ParseCOTP(tpkt([]byte{
+		0x32, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0e, 0x00, 0x00,
+		0x04, 0x01, 0x12, 0x0a, 0x10, 0x02, 0x00, 0x04, 0x00, 0x01, 0x84, 0x00, 0x00, 0x52,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != COTPData || !c.EOT {
+		t.Fatalf("unexpected COTP: %+v", c)
+	}
+	m, err := Parse(c.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ROSCTRName(m.ROSCTR) != "Job" || FunctionName(m.Function) != "READ_VAR" || m.PDUReference != 1 || m.IsControl() {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+	if len(m.Items) != 1 {
+		t.Fatal("unexpected items", m.Items)
+	}
+	i := m.Items[0]
+	if i.String() != "DB1.DBX10.2" || TransportSizeName(i.TransportSize) != "BYTE" || i.Length != 4 {
+		t.Fatalf("unexpected item: %+v", i)
+	}
+
+	// response with the return code and four bytes of data
+	m, err = Parse([]byte{
+		0x32, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x08, 0x00, 0x00,
+		0x04, 0x01,
+		0xff, 0x04, 0x00, 0x20, 0x01, 0x02, 0x03, 0x04,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ROSCTRName(m.ROSCTR) != "Ack_Data" || m.Function != FuncReadVar || !reflect.DeepEqual(m.ReturnCodes, []uint8{0xff}) {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+}
+
+func TestParseWriteVarResponse(t *testing.T) {
+	m, err := Parse([]byte{
+		0x32, 0x03, 0x00, 0x00, 0x00, 0x02, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00,
+		0x05, 0x02,
+		0xff, 0x0a,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.ReturnCodes, []uint8{0xff, 0x0a}) {
+		t.Fatal("unexpected return codes", m.ReturnCodes)
+	}
+}
+
+func TestParsePLCStop(t *testing.T) {
+	m, err := Parse(append([]byte{
+		0x32, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x10, 0x00, 0x00,
+		0x29, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09,
+	}, "P_PROGRAM"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FunctionName(m.Function) != "PLC_STOP" || m.PIService != "P_PROGRAM" || !m.IsControl() {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+}
+
+func TestParseRequestDownload(t *testing.T) {
+	m, err := Parse(append([]byte{
+		0x32, 0x01, 0x00, 0x00, 0x00, 0x03, 0x00, 0x12, 0x00, 0x00,
+		0x1a, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09,
+	}, "_0A00001P"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FunctionName(m.Function) != "REQUEST_DOWNLOAD" || m.Block != "_0A00001P" || !m.IsControl() {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+}
+
+func TestParseSetupCommunication(t *testing.T) {
+	m, err := Parse([]byte{
+		0x32, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00,
+		0xf0, 0x00, 0x00, 0x01, 0x00, 0x01, 0x01, 0xe0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FunctionName(m.Function) != "SETUP_COMMUNICATION" || m.PDULength != 480 {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]byte{0x72, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}); err == nil {
+		t.Fatal("expected error for S7comm-plus")
+	}
+	if _, err := Parse([]byte{0x32, 0x01}); err != ErrTruncated {
+		t.Fatal("expected truncated error, got", err)
+	}
+}
@@ -0,0 +1,443 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package scan detects port scans and sweeps,
+// by tracking the probes of each source within a sliding time window.
+//
+// A source is reported as vertical scan if it probed at least the configured number of distinct ports on a single host,
+// as horizontal sweep if it probed at least the configured number of distinct hosts, or as block scan if both thresholds have been exceeded.
+// Once a threshold has been exceeded, all further probes of the source are added to the scan, until the source has been idle for the window.
+package scan
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// scan types
+const (
+	SYN  = "SYN"
+	FIN  = "FIN"
+	NULL = "NULL"
+	Xmas = "Xmas"
+	UDP  = "UDP"
+	ICMP = "ICMP"
+)
+
+const (
+	// maximum number of targets included in a ScanEvent
+	maxTargets = 256
+
+	// maximum number of probes per source that are tracked for responses
+	maxProbes = 100000
+
+	// maximum number of hosts and of ports over all hosts tracked per source after it has been flagged,
+	// since the state of a flagged source is not evicted until it is idle
+	maxHosts = 100000
+	maxPorts = 100000
+)
+
+type key struct {
+	src      string
+	scanType string
+}
+
+// probe is a single probe within the sliding window
+type probe struct {
+	ts      time.Time
+	host    string
+	port    uint16
+	hasPort bool
+	id      string
+}
+
+// host tracks the probes to a single target
+type host struct {
+	// number of probes in the window
+	count int
+	// number of probes per port in the window
+	ports map[uint16]int
+}
+
+// response is the state of all probes to a host and port
+type response struct {
+	// number of probes in the window
+	count    int
+	answered bool
+	open     bool
+}
+
+// state tracks the probes of a single source for one scan type
+type state struct {
+	first time.Time
+	last  time.Time
+
+	// probes within the window, ordered by their arrival
+	window []probe
+
+	// probes per host
+	hosts map[string]*host
+	// host order, to preserve the sequence of targets in the audit record
+	// only collected once the state has been flagged, up to maxTargets
+	order []string
+
+	// responses by host and port
+	responses map[string]*response
+
+	// number of ports over all hosts, only counted once the state has been flagged
+	numPorts int
+
+	numProbes   int64
+	numAnswered int64
+	numOpen     int64
+
+	// the scan thresholds have been exceeded within the window,
+	// the window is no longer evicted and all further probes are added to the scan.
+	// state is kept until the source has been idle for the window
+	flagged bool
+}
+
+// Detector collects probes and reports scans.
+// It is safe for concurrent use.
+type Detector struct {
+	// Window is the duration of the sliding window
+	Window time.Duration
+	// Ports is the number of distinct ports on a single host to report a vertical scan
+	Ports int
+	// Hosts is the number of distinct hosts to report a horizontal sweep
+	Hosts int
+
+	scans map[key]*state
+	sync.Mutex
+}
+
+// NewDetector returns a new Detector.
+func NewDetector(window time.Duration, ports, hosts int) *Detector {
+	return &Detector{
+		Window: window,
+		Ports:  ports,
+		Hosts:  hosts,
+		scans:  make(map[key]*state),
+	}
+}
+
+// Probe adds a probe from src to the port on dst.
+// hasPort is false for probes without a port, such as ICMP echo requests.
+func (d *Detector) Probe(ts time.Time, src, scanType, dst string, port uint16, hasPort bool) {
+
+	d.Lock()
+	defer d.Unlock()
+
+	k := key{src: src, scanType: scanType}
+	s, ok := d.scans[k]
+	if !ok {
+		s = &state{
+			first:     ts,
+			last:      ts,
+			hosts:     make(map[string]*host),
+			responses: make(map[string]*response),
+		}
+		d.scans[k] = s
+	}
+
+	if ts.After(s.last) {
+		s.last = ts
+	}
+	if !s.flagged {
+		d.evict(s, ts)
+	}
+	if ts.Before(s.first) {
+		s.first = ts
+	}
+
+	p := probe{ts: ts, host: dst, port: port, hasPort: hasPort, id: probeID(dst, port)}
+	s.add(p)
+	if !s.flagged {
+		s.window = append(s.window, p)
+		if d.exceeded(s, dst) {
+			s.flag()
+		}
+	}
+}
+
+// Answer marks the probe from src to the port on dst as answered
+// and returns true if the probe was known.
+func (d *Detector) Answer(src, scanType, dst string, port uint16, open bool) bool {
+
+	d.Lock()
+	defer d.Unlock()
+
+	s, ok := d.scans[key{src: src, scanType: scanType}]
+	if !ok {
+		return false
+	}
+
+	r, ok := s.responses[probeID(dst, port)]
+	if !ok {
+		return false
+	}
+	if !r.answered {
+		r.answered = true
+		s.numAnswered++
+	}
+	if open && !r.open {
+		r.open = true
+		s.numOpen++
+	}
+	return true
+}
+
+// Expire removes the sources that have been idle for the window at the given time,
+// and returns the scans among them.
+func (d *Detector) Expire(now time.Time) []*types.ScanEvent {
+	return d.expire(func(s *state) bool {
+		return now.Sub(s.last) > d.Window
+	})
+}
+
+// Flush removes all sources and returns the scans among them.
+func (d *Detector) Flush() []*types.ScanEvent {
+	return d.expire(func(s *state) bool {
+		return true
+	})
+}
+
+func (d *Detector) expire(expired func(s *state) bool) (out []*types.ScanEvent) {
+
+	d.Lock()
+	defer d.Unlock()
+
+	for k, s := range d.scans {
+		if !expired(s) {
+			continue
+		}
+		if s.isScan() {
+			out = append(out, d.event(k, s))
+		}
+		delete(d.scans, k)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Timestamp < out[j].Timestamp
+	})
+
+	return out
+}
+
+// add adds the probe to the counters of the state
+func (s *state) add(p probe) {
+
+	s.numProbes++
+
+	h, ok := s.hosts[p.host]
+	if !ok && (!s.flagged || len(s.hosts) < maxHosts) {
+		h = &host{ports: make(map[uint16]int)}
+		s.hosts[p.host] = h
+		if s.flagged && len(s.order) < maxTargets {
+			s.order = append(s.order, p.host)
+		}
+	}
+	if h != nil {
+		h.count++
+		if _, known := h.ports[p.port]; p.hasPort && (known || !s.flagged || s.numPorts < maxPorts) {
+			if !known && s.flagged {
+				s.numPorts++
+			}
+			h.ports[p.port]++
+		}
+	}
+
+	r, ok := s.responses[p.id]
+	if !ok {
+		if len(s.responses) >= maxProbes {
+			return
+		}
+		r = &response{}
+		s.responses[p.id] = r
+	}
+	r.count++
+}
+
+// evict removes the probes that are older than the window at ts from the state
+func (d *Detector) evict(s *state, ts time.Time) {
+
+	var (
+		start = ts.Add(-d.Window)
+		i     int
+	)
+	for ; i < len(s.window) && s.window[i].ts.Before(start); i++ {
+		p := s.window[i]
+		s.numProbes--
+
+		h := s.hosts[p.host]
+		if h.count--; h.count == 0 {
+			delete(s.hosts, p.host)
+		} else if p.hasPort {
+			if h.ports[p.port]--; h.ports[p.port] == 0 {
+				delete(h.ports, p.port)
+			}
+		}
+
+		if r, ok := s.responses[p.id]; ok {
+			if r.count--; r.count == 0 {
+				if r.answered {
+					s.numAnswered--
+				}
+				if r.open {
+					s.numOpen--
+				}
+				delete(s.responses, p.id)
+			}
+		}
+	}
+	if i == 0 {
+		return
+	}
+	s.window = s.window[i:]
+	if len(s.window) > 0 {
+		s.first = s.window[0].ts
+	} else {
+		s.first = ts
+	}
+}
+
+// flag marks the state as scan and collects the targets in the order of the probes in the window,
+// the window is released afterwards
+func (s *state) flag() {
+	s.flagged = true
+	seen := make(map[string]struct{}, len(s.hosts))
+	for _, p := range s.window {
+		if _, ok := seen[p.host]; !ok && len(s.order) < maxTargets {
+			seen[p.host] = struct{}{}
+			s.order = append(s.order, p.host)
+		}
+	}
+	for _, h := range s.hosts {
+		s.numPorts += len(h.ports)
+	}
+	s.window = nil
+}
+
+// exceeded checks if the probes in the window exceed a threshold after a probe to host has been added
+func (d *Detector) exceeded(s *state, host string) bool {
+	return len(s.hosts[host].ports) >= d.Ports || len(s.hosts) >= d.Hosts
+}
+
+// kind returns the kind of scan if the thresholds have been exceeded, or an empty string.
+func (d *Detector) kind(s *state) string {
+
+	var vertical bool
+	for _, h := range s.hosts {
+		if len(h.ports) >= d.Ports {
+			vertical = true
+			break
+		}
+	}
+	horizontal := len(s.hosts) >= d.Hosts
+
+	switch {
+	case vertical && horizontal:
+		return "block"
+	case vertical:
+		return "vertical"
+	case horizontal:
+		return "horizontal"
+	}
+	return ""
+}
+
+// isScan checks if the thresholds have been exceeded.
+// regular clients contact many hosts as well, but most of their connections are accepted,
+// thus sources with more than half of the probes accepted are ignored
+func (s *state) isScan() bool {
+	if !s.flagged {
+		return false
+	}
+	return s.numOpen*2 <= s.numProbes
+}
+
+func (d *Detector) event(k key, s *state) *types.ScanEvent {
+
+	var (
+		targets = s.order
+		ports   = make(map[uint16]struct{})
+		dur     = s.last.Sub(s.first)
+		rate    float64
+	)
+	if len(targets) > maxTargets {
+		targets = targets[:maxTargets]
+	}
+	for _, h := range s.hosts {
+		for port := range h.ports {
+			ports[port] = struct{}{}
+		}
+	}
+	if dur > 0 {
+		rate = float64(s.numProbes) / dur.Seconds()
+	}
+
+	return &types.ScanEvent{
+		Timestamp:     utils.TimeToString(s.first),
+		TimestampLast: utils.TimeToString(s.last),
+		SrcIP:         k.src,
+		ScanType:      k.scanType,
+		Kind:          d.kind(s),
+		Targets:       targets,
+		NumTargets:    int32(len(s.hosts)),
+		Ports:         PortRanges(ports),
+		NumPorts:      int32(len(ports)),
+		NumProbes:     s.numProbes,
+		NumAnswered:   s.numAnswered,
+		NumOpen:       s.numOpen,
+		Duration:      int64(dur),
+		Rate:          rate,
+	}
+}
+
+func probeID(host string, port uint16) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// PortRanges formats the ports as sorted list of ranges, e.g. 20-25,80,443
+func PortRanges(ports map[uint16]struct{}) string {
+
+	sorted := make([]int, 0, len(ports))
+	for p := range ports {
+		sorted = append(sorted, int(p))
+	}
+	sort.Ints(sorted)
+
+	var b strings.Builder
+	for i := 0; i < len(sorted); i++ {
+		start := sorted[i]
+		for i+1 < len(sorted) && sorted[i+1] == sorted[i]+1 {
+			i++
+		}
+		if b.Len() > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(strconv.Itoa(start))
+		if sorted[i] != start {
+			b.WriteString("-")
+			b.WriteString(strconv.Itoa(sorted[i]))
+		}
+	}
+	return b.String()
+}
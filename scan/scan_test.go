@@ -0,0 +1,174 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package scan
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+var start = time.Unix(1500000000, 0)
+
+func TestVertical(t *testing.T) {
+
+	d := NewDetector(time.Minute, 10, 10)
+	for i := 0; i < 20; i++ {
+		d.Probe(start.Add(time.Duration(i)*time.Second), "10.0.0.1", SYN, "10.0.0.2", uint16(20+i), true)
+	}
+	d.Answer("10.0.0.1", SYN, "10.0.0.2", 22, true)
+	d.Answer("10.0.0.1", SYN, "10.0.0.2", 23, false)
+
+	events := d.Flush()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 scan, got %d", len(events))
+	}
+	e := events[0]
+	if e.Kind != "vertical" || e.ScanType != SYN || e.NumTargets != 1 || e.NumPorts != 20 || e.Ports != "20-39" {
+		t.Fatalf("unexpected scan: %+v", e)
+	}
+	if e.NumProbes != 20 || e.NumAnswered != 2 || e.NumOpen != 1 {
+		t.Fatalf("unexpected counters: %+v", e)
+	}
+	if e.Duration != int64(19*time.Second) {
+		t.Fatal("unexpected duration", e.Duration)
+	}
+}
+
+func TestHorizontal(t *testing.T) {
+
+	d := NewDetector(time.Minute, 10, 10)
+	for i := 0; i < 15; i++ {
+		d.Probe(start.Add(time.Duration(i)*time.Second), "10.0.0.1", ICMP, "10.0.1."+strconv.Itoa(i), 0, false)
+	}
+
+	events := d.Flush()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 scan, got %d", len(events))
+	}
+	e := events[0]
+	if e.Kind != "horizontal" || e.NumTargets != 15 || e.NumPorts != 0 || e.Targets[0] != "10.0.1.0" || e.Targets[14] != "10.0.1.14" {
+		t.Fatalf("unexpected scan: %+v", e)
+	}
+}
+
+// probes that are spread over a longer period than the window must not be reported,
+// regardless of how the window boundaries are aligned
+func TestSlidingWindow(t *testing.T) {
+
+	d := NewDetector(time.Minute, 10, 100)
+
+	// one probe every 7 seconds, at most 9 probes fall into a window of 60 seconds
+	for i := 0; i < 100; i++ {
+		d.Probe(start.Add(time.Duration(i)*7*time.Second), "10.0.0.1", SYN, "10.0.0.2", uint16(i), true)
+	}
+	if events := d.Flush(); len(events) != 0 {
+		t.Fatalf("expected no scan, got %+v", events[0])
+	}
+
+	// a single probe starts the window, followed by 5 probes at its end and 5 after it,
+	// a tumbling window would have split them
+	d = NewDetector(time.Minute, 10, 100)
+	d.Probe(start, "10.0.0.1", SYN, "10.0.0.3", 80, true)
+	for i := 0; i < 5; i++ {
+		d.Probe(start.Add(time.Duration(50+i)*time.Second), "10.0.0.1", SYN, "10.0.0.2", uint16(i), true)
+	}
+	for i := 5; i < 10; i++ {
+		d.Probe(start.Add(time.Duration(65+i)*time.Second), "10.0.0.1", SYN, "10.0.0.2", uint16(i), true)
+	}
+
+	events := d.Flush()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 scan, got %d", len(events))
+	}
+	e := events[0]
+	if e.NumProbes != 10 || e.Timestamp != utils.TimeToString(start.Add(50*time.Second)) || e.NumTargets != 1 {
+		t.Fatalf("unexpected scan: %+v", e)
+	}
+}
+
+func TestSlidingWindowEviction(t *testing.T) {
+
+	d := NewDetector(time.Minute, 5, 100)
+
+	// 4 ports on the first host, expired once the fifth probe arrives
+	for i := 0; i < 4; i++ {
+		d.Probe(start.Add(time.Duration(i)*time.Second), "10.0.0.1", SYN, "10.0.0.2", uint16(i), true)
+	}
+	d.Answer("10.0.0.1", SYN, "10.0.0.2", 0, true)
+	d.Probe(start.Add(2*time.Minute), "10.0.0.1", SYN, "10.0.0.2", 4, true)
+
+	s := d.scans[key{src: "10.0.0.1", scanType: SYN}]
+	if s.numProbes != 1 || s.numAnswered != 0 || s.numOpen != 0 || len(s.hosts["10.0.0.2"].ports) != 1 || len(s.responses) != 1 {
+		t.Fatalf("unexpected state after eviction: %+v", s)
+	}
+	if !s.first.Equal(start.Add(2 * time.Minute)) {
+		t.Fatal("unexpected first timestamp", s.first)
+	}
+	if len(d.Expire(start.Add(3*time.Minute))) != 0 || len(d.scans) != 1 {
+		t.Fatal("state expired before the source was idle for the window")
+	}
+	if len(d.Expire(start.Add(4*time.Minute))) != 0 || len(d.scans) != 0 {
+		t.Fatal("idle state not expired")
+	}
+}
+
+// sources with most of their probes accepted are not reported
+func TestBusyClient(t *testing.T) {
+
+	d := NewDetector(time.Minute, 100, 10)
+	for i := 0; i < 20; i++ {
+		dst := "10.0.1." + strconv.Itoa(i)
+		d.Probe(start.Add(time.Duration(i)*time.Second), "10.0.0.1", SYN, dst, 443, true)
+		d.Answer("10.0.0.1", SYN, dst, 443, true)
+	}
+	if events := d.Flush(); len(events) != 0 {
+		t.Fatalf("expected no scan, got %+v", events[0])
+	}
+}
+
+func TestPortRanges(t *testing.T) {
+	ports := map[uint16]struct{}{80: {}, 20: {}, 21: {}, 22: {}, 443: {}, 444: {}}
+	if r := PortRanges(ports); r != "20-22,80,443-444" {
+		t.Fatal("unexpected port ranges", r)
+	}
+}
+
+// the state of a flagged source is limited, even though it is not evicted
+func TestLimits(t *testing.T) {
+
+	d := NewDetector(time.Minute, 10, 10)
+	for i := 0; i < maxHosts+1000; i++ {
+		d.Probe(start, "10.0.0.1", SYN, strconv.Itoa(i), uint16(i), true)
+	}
+	s := d.scans[key{src: "10.0.0.1", scanType: SYN}]
+	if len(s.hosts) != maxHosts || len(s.order) != maxTargets {
+		t.Fatalf("unexpected state size: %d hosts, %d targets", len(s.hosts), len(s.order))
+	}
+
+	// ports on known hosts
+	for i := 0; i < 1000; i++ {
+		d.Probe(start, "10.0.0.1", SYN, "0", uint16(1000+i), true)
+	}
+	if s.numPorts != maxPorts || len(s.hosts["0"].ports) != 1 {
+		t.Fatalf("unexpected number of ports: %d, %d on the first host", s.numPorts, len(s.hosts["0"].ports))
+	}
+
+	events := d.Flush()
+	if len(events) != 1 || events[0].NumTargets != maxHosts || events[0].NumProbes != maxHosts+2000 {
+		t.Fatalf("unexpected scans: %+v", events)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package smb
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/ntlm"
+)
+
+// FileID identifies an open file on the server.
+type FileID [16]byte
+
+// NegotiateResponse contains the dialect selected by the server.
+type NegotiateResponse struct {
+	SecurityMode uint16
+	Dialect      uint16
+}
+
+// ParseNegotiateResponse decodes the body of a NEGOTIATE response.
+func ParseNegotiateResponse(m *Message) (*NegotiateResponse, error) {
+	b := m.Body()
+	if len(b) < 6 {
+		return nil, ErrTooShort
+	}
+	return &NegotiateResponse{
+		SecurityMode: binary.LittleEndian.Uint16(b[2:]),
+		Dialect:      binary.LittleEndian.Uint16(b[4:]),
+	}, nil
+}
+
+// SessionSetupRequest contains the security token of a SESSION_SETUP request.
+type SessionSetupRequest struct {
+	SecurityBuffer []byte
+}
+
+// ParseSessionSetupRequest decodes the body of a SESSION_SETUP request.
+func ParseSessionSetupRequest(m *Message) (*SessionSetupRequest, error) {
+	b := m.Body()
+	if len(b) < 24 {
+		return nil, ErrTooShort
+	}
+	return &SessionSetupRequest{
+		SecurityBuffer: buffer(m.Data, int(binary.LittleEndian.Uint16(b[12:])), int(binary.LittleEndian.Uint16(b[14:]))),
+	}, nil
+}
+
+// NTLM returns the NTLMSSP message embedded in the security token, if any.
+func (r *SessionSetupRequest) NTLM() *ntlm.Message {
+	return findNTLM(r.SecurityBuffer)
+}
+
+// SessionSetupResponse contains the security token of a SESSION_SETUP response.
+type SessionSetupResponse struct {
+	SessionFlags   uint16
+	SecurityBuffer []byte
+}
+
+// ParseSessionSetupResponse decodes the body of a SESSION_SETUP response.
+func ParseSessionSetupResponse(m *Message) (*SessionSetupResponse, error) {
+	b := m.Body()
+	if len(b) < 8 {
+		return nil, ErrTooShort
+	}
+	return &SessionSetupResponse{
+		SessionFlags:   binary.LittleEndian.Uint16(b[2:]),
+		SecurityBuffer: buffer(m.Data, int(binary.LittleEndian.Uint16(b[4:])), int(binary.LittleEndian.Uint16(b[6:]))),
+	}, nil
+}
+
+// NTLM returns the NTLMSSP message embedded in the security token, if any.
+func (r *SessionSetupResponse) NTLM() *ntlm.Message {
+	return findNTLM(r.SecurityBuffer)
+}
+
+// findNTLM parses the NTLMSSP message contained in a security token
+func findNTLM(token []byte) *ntlm.Message {
+	data := ntlm.Find(token)
+	if data == nil {
+		return nil
+	}
+	msg, err := ntlm.Parse(data)
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+// ParseTreeConnectRequest decodes the share path of a TREE_CONNECT request.
+func ParseTreeConnectRequest(m *Message) (string, error) {
+	b := m.Body()
+	if len(b) < 8 {
+		return "", ErrTooShort
+	}
+	return ntlm.DecodeUTF16(buffer(m.Data, int(binary.LittleEndian.Uint16(b[4:])), int(binary.LittleEndian.Uint16(b[6:])))), nil
+}
+
+// ParseTreeConnectResponse decodes the share type of a TREE_CONNECT response.
+func ParseTreeConnectResponse(m *Message) (uint8, error) {
+	b := m.Body()
+	if len(b) < 3 {
+		return 0, ErrTooShort
+	}
+	return b[2], nil
+}
+
+// CreateRequest contains the file name and access parameters of a CREATE request.
+type CreateRequest struct {
+	DesiredAccess     uint32
+	ShareAccess       uint32
+	CreateDisposition uint32
+	CreateOptions     uint32
+	Name              string
+}
+
+// ParseCreateRequest decodes the body of a CREATE request.
+func ParseCreateRequest(m *Message) (*CreateRequest, error) {
+	b := m.Body()
+	if len(b) < 56 {
+		return nil, ErrTooShort
+	}
+	return &CreateRequest{
+		DesiredAccess:     binary.LittleEndian.Uint32(b[24:]),
+		ShareAccess:       binary.LittleEndian.Uint32(b[32:]),
+		CreateDisposition: binary.LittleEndian.Uint32(b[36:]),
+		CreateOptions:     binary.LittleEndian.Uint32(b[40:]),
+		Name:              ntlm.DecodeUTF16(buffer(m.Data, int(binary.LittleEndian.Uint16(b[44:])), int(binary.LittleEndian.Uint16(b[46:])))),
+	}, nil
+}
+
+// CreateResponse contains the result of a CREATE request.
+type CreateResponse struct {
+	CreateAction uint32
+	EndOfFile    uint64
+	FileID       FileID
+}
+
+// ParseCreateResponse decodes the body of a CREATE response.
+func ParseCreateResponse(m *Message) (*CreateResponse, error) {
+	b := m.Body()
+	if len(b) < 80 {
+		return nil, ErrTooShort
+	}
+	r := &CreateResponse{
+		CreateAction: binary.LittleEndian.Uint32(b[4:]),
+		EndOfFile:    binary.LittleEndian.Uint64(b[48:]),
+	}
+	copy(r.FileID[:], b[64:80])
+	return r, nil
+}
+
+// IORequest contains the parameters of a READ or WRITE request.
+type IORequest struct {
+	Length uint32
+	Offset uint64
+	FileID FileID
+
+	// Data contains the data of a WRITE request,
+	// it is nil if the data is not within the message
+	Data []byte
+}
+
+// ParseIORequest decodes the body of a READ or WRITE request,
+// both share the position of the length, offset and file id fields.
+func ParseIORequest(m *Message) (*IORequest, error) {
+	b := m.Body()
+	if len(b) < 32 {
+		return nil, ErrTooShort
+	}
+	r := &IORequest{
+		Length: binary.LittleEndian.Uint32(b[4:]),
+		Offset: binary.LittleEndian.Uint64(b[8:]),
+	}
+	copy(r.FileID[:], b[16:32])
+	if m.Command == CommandWrite {
+		r.Data = buffer(m.Data, int(binary.LittleEndian.Uint16(b[2:])), int(r.Length))
+	}
+	return r, nil
+}
+
+// IOResponse contains the results of a READ or WRITE response.
+type IOResponse struct {
+	// Length is the number of bytes transferred
+	Length uint32
+
+	// Data contains the data of a READ response,
+	// it is nil if the data is not within the message
+	Data []byte
+}
+
+// ParseIOResponse decodes the body of a READ or WRITE response.
+func ParseIOResponse(m *Message) (*IOResponse, error) {
+	b := m.Body()
+	if len(b) < 8 {
+		return nil, ErrTooShort
+	}
+	r := &IOResponse{
+		Length: binary.LittleEndian.Uint32(b[4:]),
+	}
+	if m.Command == CommandRead {
+		r.Data = buffer(m.Data, int(b[2]), int(r.Length))
+	}
+	return r, nil
+}
+
+// IoctlRequest contains the control code and target file of an IOCTL request.
+type IoctlRequest struct {
+	CtlCode uint32
+	FileID  FileID
+}
+
+// ParseIoctlRequest decodes the body of an IOCTL request.
+func ParseIoctlRequest(m *Message) (*IoctlRequest, error) {
+	b := m.Body()
+	if len(b) < 24 {
+		return nil, ErrTooShort
+	}
+	r := &IoctlRequest{
+		CtlCode: binary.LittleEndian.Uint32(b[4:]),
+	}
+	copy(r.FileID[:], b[8:24])
+	return r, nil
+}
+
+// ParseCloseRequest decodes the file id of a CLOSE request.
+func ParseCloseRequest(m *Message) (FileID, error) {
+	var id FileID
+	b := m.Body()
+	if len(b) < 24 {
+		return id, ErrTooShort
+	}
+	copy(id[:], b[8:24])
+	return id, nil
+}
+
+// ShareName returns the name of the share from a UNC path, e.g. IPC$ for \\server\IPC$.
+func ShareName(path string) string {
+	if i := strings.LastIndex(path, `\`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
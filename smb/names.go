@@ -0,0 +1,174 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package smb
+
+import "fmt"
+
+// Commands.
+const (
+	CommandNegotiate      = 0x0000
+	CommandSessionSetup   = 0x0001
+	CommandLogoff         = 0x0002
+	CommandTreeConnect    = 0x0003
+	CommandTreeDisconnect = 0x0004
+	CommandCreate         = 0x0005
+	CommandClose          = 0x0006
+	CommandFlush          = 0x0007
+	CommandRead           = 0x0008
+	CommandWrite          = 0x0009
+	CommandLock           = 0x000A
+	CommandIoctl          = 0x000B
+	CommandCancel         = 0x000C
+	CommandEcho           = 0x000D
+	CommandQueryDirectory = 0x000E
+	CommandChangeNotify   = 0x000F
+	CommandQueryInfo      = 0x0010
+	CommandSetInfo        = 0x0011
+	CommandOplockBreak    = 0x0012
+)
+
+var commandNames = map[uint16]string{
+	CommandNegotiate:      "NEGOTIATE",
+	CommandSessionSetup:   "SESSION_SETUP",
+	CommandLogoff:         "LOGOFF",
+	CommandTreeConnect:    "TREE_CONNECT",
+	CommandTreeDisconnect: "TREE_DISCONNECT",
+	CommandCreate:         "CREATE",
+	CommandClose:          "CLOSE",
+	CommandFlush:          "FLUSH",
+	CommandRead:           "READ",
+	CommandWrite:          "WRITE",
+	CommandLock:           "LOCK",
+	CommandIoctl:          "IOCTL",
+	CommandCancel:         "CANCEL",
+	CommandEcho:           "ECHO",
+	CommandQueryDirectory: "QUERY_DIRECTORY",
+	CommandChangeNotify:   "CHANGE_NOTIFY",
+	CommandQueryInfo:      "QUERY_INFO",
+	CommandSetInfo:        "SET_INFO",
+	CommandOplockBreak:    "OPLOCK_BREAK",
+}
+
+// CommandName returns the name of the command.
+func CommandName(c uint16) string {
+	if n, ok := commandNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("UNKNOWN_%d", c)
+}
+
+// Status codes.
+const (
+	StatusSuccess                = 0x00000000
+	StatusPending                = 0x00000103
+	StatusBufferOverflow         = 0x80000005
+	StatusNoMoreFiles            = 0x80000006
+	StatusInvalidParameter       = 0xC000000D
+	StatusEndOfFile              = 0xC0000011
+	StatusMoreProcessingRequired = 0xC0000016
+	StatusAccessDenied           = 0xC0000022
+	StatusObjectNameNotFound     = 0xC0000034
+	StatusObjectNameCollision    = 0xC0000035
+	StatusObjectPathNotFound     = 0xC000003A
+	StatusSharingViolation       = 0xC0000043
+	StatusLogonFailure           = 0xC000006D
+	StatusAccountRestriction     = 0xC000006E
+	StatusPasswordExpired        = 0xC0000071
+	StatusAccountDisabled        = 0xC0000072
+	StatusNotSupported           = 0xC00000BB
+	StatusBadNetworkName         = 0xC00000CC
+	StatusFileClosed             = 0xC0000128
+	StatusUserSessionDeleted     = 0xC0000203
+	StatusAccountLockedOut       = 0xC0000234
+	StatusNetworkSessionExpired  = 0xC000035C
+)
+
+var statusNames = map[uint32]string{
+	StatusSuccess:                "STATUS_SUCCESS",
+	StatusPending:                "STATUS_PENDING",
+	StatusBufferOverflow:         "STATUS_BUFFER_OVERFLOW",
+	StatusNoMoreFiles:            "STATUS_NO_MORE_FILES",
+	StatusInvalidParameter:       "STATUS_INVALID_PARAMETER",
+	StatusEndOfFile:              "STATUS_END_OF_FILE",
+	StatusMoreProcessingRequired: "STATUS_MORE_PROCESSING_REQUIRED",
+	StatusAccessDenied:           "STATUS_ACCESS_DENIED",
+	StatusObjectNameNotFound:     "STATUS_OBJECT_NAME_NOT_FOUND",
+	StatusObjectNameCollision:    "STATUS_OBJECT_NAME_COLLISION",
+	StatusObjectPathNotFound:     "STATUS_OBJECT_PATH_NOT_FOUND",
+	StatusSharingViolation:       "STATUS_SHARING_VIOLATION",
+	StatusLogonFailure:           "STATUS_LOGON_FAILURE",
+	StatusAccountRestriction:     "STATUS_ACCOUNT_RESTRICTION",
+	StatusPasswordExpired:        "STATUS_PASSWORD_EXPIRED",
+	StatusAccountDisabled:        "STATUS_ACCOUNT_DISABLED",
+	StatusNotSupported:           "STATUS_NOT_SUPPORTED",
+	StatusBadNetworkName:         "STATUS_BAD_NETWORK_NAME",
+	StatusFileClosed:             "STATUS_FILE_CLOSED",
+	StatusUserSessionDeleted:     "STATUS_USER_SESSION_DELETED",
+	StatusAccountLockedOut:       "STATUS_ACCOUNT_LOCKED_OUT",
+	StatusNetworkSessionExpired:  "STATUS_NETWORK_SESSION_EXPIRED",
+}
+
+// StatusName returns the name of a NT status code, or its hex value if it is unknown.
+func StatusName(s uint32) string {
+	if n, ok := statusNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%08X", s)
+}
+
+// Dialects.
+const (
+	Dialect202      = 0x0202
+	Dialect210      = 0x0210
+	Dialect300      = 0x0300
+	Dialect302      = 0x0302
+	Dialect311      = 0x0311
+	DialectWildcard = 0x02FF
+)
+
+var dialectNames = map[uint16]string{
+	Dialect202:      "2.0.2",
+	Dialect210:      "2.1",
+	Dialect300:      "3.0",
+	Dialect302:      "3.0.2",
+	Dialect311:      "3.1.1",
+	DialectWildcard: "2.???",
+}
+
+// DialectName returns the version string of a dialect.
+func DialectName(d uint16) string {
+	if n, ok := dialectNames[d]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%04X", d)
+}
+
+// Share types.
+const (
+	ShareTypeDisk  = 0x01
+	ShareTypePipe  = 0x02
+	ShareTypePrint = 0x03
+)
+
+// ShareTypeName returns the name of a share type.
+func ShareTypeName(t uint8) string {
+	switch t {
+	case ShareTypeDisk:
+		return "DISK"
+	case ShareTypePipe:
+		return "PIPE"
+	case ShareTypePrint:
+		return "PRINT"
+	}
+	return fmt.Sprintf("UNKNOWN_%d", t)
+}
@@ -0,0 +1,177 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+// Package smb decodes SMB2 and SMB3 messages from the reassembled data of a TCP stream.
+//
+// On TCP port 445, each message is prefixed with a four byte header,
+// which contains the length of the message in the lower three bytes.
+// A message can contain several compounded SMB2 commands, which are linked by the NextCommand field.
+// Messages encrypted with SMB3 start with a transform header, their contents can not be decoded.
+package smb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protocol identifiers at the start of a message.
+const (
+	ProtocolSMB1      = "\xffSMB"
+	ProtocolSMB2      = "\xfeSMB"
+	ProtocolTransform = "\xfdSMB"
+)
+
+// HeaderSize is the size of the SMB2 header.
+const HeaderSize = 64
+
+// MaxMessageSize is the largest message accepted in a stream,
+// larger length values indicate that the stream is not SMB or out of sync.
+const MaxMessageSize = 16 * 1024 * 1024
+
+// Header flags.
+const (
+	FlagResponse = 0x00000001
+	FlagAsync    = 0x00000002
+	FlagRelated  = 0x00000004
+	FlagSigned   = 0x00000008
+)
+
+var (
+	// ErrTooShort is returned if the data is smaller than the structure that is decoded.
+	ErrTooShort = errors.New("smb: message too short")
+	// ErrProtocol is returned for messages that are not SMB2.
+	ErrProtocol = errors.New("smb: not an SMB2 message")
+	// ErrEncrypted is returned for messages with an SMB3 transform header.
+	ErrEncrypted = errors.New("smb: message is encrypted")
+)
+
+// Header is the SMB2 packet header.
+type Header struct {
+	CreditCharge uint16
+	Status       uint32
+	Command      uint16
+	Credits      uint16
+	Flags        uint32
+	NextCommand  uint32
+	MessageID    uint64
+	AsyncID      uint64
+	TreeID       uint32
+	SessionID    uint64
+}
+
+// IsResponse returns true for messages sent by the server.
+func (h *Header) IsResponse() bool {
+	return h.Flags&FlagResponse != 0
+}
+
+// IsAsync returns true if the header contains an AsyncID instead of a TreeID.
+func (h *Header) IsAsync() bool {
+	return h.Flags&FlagAsync != 0
+}
+
+// IsRelated returns true if the message is part of a related compound.
+func (h *Header) IsRelated() bool {
+	return h.Flags&FlagRelated != 0
+}
+
+// Message is a single SMB2 command with its header.
+type Message struct {
+	Header
+
+	// Data contains the message starting with the header,
+	// buffer offsets in the command structures are relative to the start of the header.
+	Data []byte
+}
+
+// Body returns the command specific part of the message.
+func (m *Message) Body() []byte {
+	return m.Data[HeaderSize:]
+}
+
+// FrameLength returns the length of the next message in a stream,
+// which starts with the four byte direct TCP transport header.
+// ok is false if there is not enough data to read the header.
+func FrameLength(data []byte) (length int, ok bool) {
+	if len(data) < 4 {
+		return 0, false
+	}
+	return int(data[1])<<16 | int(data[2])<<8 | int(data[3]), true
+}
+
+// Decode parses the compounded SMB2 commands of a message without the transport header.
+func Decode(data []byte) ([]*Message, error) {
+
+	if len(data) < 4 {
+		return nil, ErrTooShort
+	}
+	switch string(data[:4]) {
+	case ProtocolSMB2:
+	case ProtocolTransform:
+		return nil, ErrEncrypted
+	default:
+		return nil, ErrProtocol
+	}
+
+	var msgs []*Message
+	for {
+		if len(data) < HeaderSize {
+			return msgs, ErrTooShort
+		}
+		if string(data[:4]) != ProtocolSMB2 {
+			return msgs, ErrProtocol
+		}
+
+		h := Header{
+			CreditCharge: binary.LittleEndian.Uint16(data[6:]),
+			Status:       binary.LittleEndian.Uint32(data[8:]),
+			Command:      binary.LittleEndian.Uint16(data[12:]),
+			Credits:      binary.LittleEndian.Uint16(data[14:]),
+			Flags:        binary.LittleEndian.Uint32(data[16:]),
+			NextCommand:  binary.LittleEndian.Uint32(data[20:]),
+			MessageID:    binary.LittleEndian.Uint64(data[24:]),
+			SessionID:    binary.LittleEndian.Uint64(data[40:]),
+		}
+		if h.IsAsync() {
+			h.AsyncID = binary.LittleEndian.Uint64(data[32:])
+		} else {
+			h.TreeID = binary.LittleEndian.Uint32(data[36:])
+		}
+
+		end := len(data)
+		if h.NextCommand != 0 {
+			if int(h.NextCommand) < HeaderSize || int(h.NextCommand) > len(data) {
+				return msgs, fmt.Errorf("smb: invalid next command offset %d", h.NextCommand)
+			}
+			end = int(h.NextCommand)
+		}
+
+		msgs = append(msgs, &Message{
+			Header: h,
+			Data:   data[:end],
+		})
+
+		if h.NextCommand == 0 {
+			return msgs, nil
+		}
+		data = data[end:]
+	}
+}
+
+// buffer returns the data referenced by an offset relative to the start of the header and a length,
+// or nil if it is out of bounds
+func buffer(data []byte, offset, length int) []byte {
+	if offset < HeaderSize || length <= 0 || offset+length > len(data) {
+		return nil
+	}
+	return data[offset : offset+length]
+}
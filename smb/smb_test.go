@@ -0,0 +1,262 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package smb
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// message returns an SMB2 header followed by the body
+func message(command uint16, flags uint32, messageID uint64, body []byte) []byte {
+	h := make([]byte, HeaderSize)
+	copy(h, ProtocolSMB2)
+	binary.LittleEndian.PutUint16(h[4:], HeaderSize)
+	binary.LittleEndian.PutUint16(h[12:], command)
+	binary.LittleEndian.PutUint32(h[16:], flags)
+	binary.LittleEndian.PutUint64(h[24:], messageID)
+	binary.LittleEndian.PutUint32(h[36:], 7)
+	binary.LittleEndian.PutUint64(h[40:], 0x1122334455667788)
+	return append(h, body...)
+}
+
+func encodeUTF16(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+func TestFrameLength(t *testing.T) {
+	if _, ok := FrameLength([]byte{0, 0}); ok {
+		t.Fatal("expected incomplete header")
+	}
+	l, ok := FrameLength([]byte{0, 0x01, 0x02, 0x03})
+	if !ok || l != 0x010203 {
+		t.Fatal("unexpected length", l)
+	}
+}
+
+func TestDecodeCompound(t *testing.T) {
+
+	first := message(CommandCreate, 0, 1, make([]byte, 56))
+	binary.LittleEndian.PutUint32(first[20:], uint32(len(first)))
+	second := message(CommandRead, FlagRelated, 2, make([]byte, 48))
+
+	msgs, err := Decode(append(first, second...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatal("expected 2 messages, got", len(msgs))
+	}
+	if msgs[0].Command != CommandCreate || msgs[1].Command != CommandRead {
+		t.Fatal("unexpected commands", msgs[0].Command, msgs[1].Command)
+	}
+	if msgs[1].MessageID != 2 || !msgs[1].IsRelated() || msgs[1].TreeID != 7 {
+		t.Fatal("unexpected header", msgs[1].Header)
+	}
+	if len(msgs[0].Data) != len(first) {
+		t.Fatal("first message not truncated at next command")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode([]byte("\xffSMB")); err != ErrProtocol {
+		t.Fatal("expected protocol error, got", err)
+	}
+	if _, err := Decode([]byte("\xfdSMB")); err != ErrEncrypted {
+		t.Fatal("expected encrypted error, got", err)
+	}
+	if _, err := Decode([]byte("\xfeSMB\x00")); err != ErrTooShort {
+		t.Fatal("expected too short error, got", err)
+	}
+	bad := message(CommandEcho, 0, 1, make([]byte, 4))
+	binary.LittleEndian.PutUint32(bad[20:], 1000)
+	if _, err := Decode(bad); err == nil {
+		t.Fatal("expected error for invalid next command offset")
+	}
+}
+
+func TestTreeConnect(t *testing.T) {
+
+	path := encodeUTF16(`\\fileserver\IPC$`)
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:], 9)
+	binary.LittleEndian.PutUint16(body[4:], HeaderSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(path)))
+
+	msgs, err := Decode(message(CommandTreeConnect, 0, 3, append(body, path...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseTreeConnectRequest(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != `\\fileserver\IPC$` || ShareName(p) != "IPC$" {
+		t.Fatal("unexpected path", p)
+	}
+}
+
+func TestCreate(t *testing.T) {
+
+	name := encodeUTF16("srvsvc")
+	body := make([]byte, 56)
+	binary.LittleEndian.PutUint32(body[24:], 0x0012019f)
+	binary.LittleEndian.PutUint32(body[36:], 1)
+	binary.LittleEndian.PutUint16(body[44:], HeaderSize+56)
+	binary.LittleEndian.PutUint16(body[46:], uint16(len(name)))
+
+	msgs, err := Decode(message(CommandCreate, 0, 4, append(body, name...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ParseCreateRequest(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "srvsvc" || r.DesiredAccess != 0x0012019f || r.CreateDisposition != 1 {
+		t.Fatal("unexpected create request", r)
+	}
+
+	body = make([]byte, 88)
+	binary.LittleEndian.PutUint32(body[4:], 1)
+	binary.LittleEndian.PutUint64(body[48:], 4096)
+	body[64] = 0xAA
+	msgs, err = Decode(message(CommandCreate, FlagResponse, 4, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ParseCreateResponse(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msgs[0].IsResponse() || res.EndOfFile != 4096 || res.FileID[0] != 0xAA {
+		t.Fatal("unexpected create response", res)
+	}
+}
+
+func TestTruncatedBuffers(t *testing.T) {
+
+	// name offset points beyond the message
+	body := make([]byte, 56)
+	binary.LittleEndian.PutUint16(body[44:], 500)
+	binary.LittleEndian.PutUint16(body[46:], 10)
+
+	msgs, err := Decode(message(CommandCreate, 0, 5, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ParseCreateRequest(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "" {
+		t.Fatal("expected empty name, got", r.Name)
+	}
+
+	msgs, err = Decode(message(CommandRead, 0, 6, make([]byte, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseIORequest(msgs[0]); err != ErrTooShort {
+		t.Fatal("expected too short error, got", err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+
+	data := []byte("file contents")
+
+	// write request with the data following the fixed part of the body
+	body := make([]byte, 48)
+	binary.LittleEndian.PutUint16(body[0:], 49)
+	binary.LittleEndian.PutUint16(body[2:], HeaderSize+48)
+	binary.LittleEndian.PutUint32(body[4:], uint32(len(data)))
+	binary.LittleEndian.PutUint64(body[8:], 4096)
+	body[16] = 0xAA
+
+	msgs, err := Decode(message(CommandWrite, 0, 7, append(body, data...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := ParseIORequest(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Offset != 4096 || req.FileID[0] != 0xAA || string(req.Data) != "file contents" {
+		t.Fatal("unexpected write request", req)
+	}
+
+	// read response
+	body = make([]byte, 16)
+	binary.LittleEndian.PutUint16(body[0:], 17)
+	body[2] = HeaderSize + 16
+	binary.LittleEndian.PutUint32(body[4:], uint32(len(data)))
+
+	msgs, err = Decode(message(CommandRead, FlagResponse, 8, append(body, data...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ParseIOResponse(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Length != uint32(len(data)) || string(res.Data) != "file contents" {
+		t.Fatal("unexpected read response", res)
+	}
+
+	// the length exceeds the message
+	binary.LittleEndian.PutUint32(body[4:], 1000)
+	msgs, _ = Decode(message(CommandRead, FlagResponse, 9, append(body, data...)))
+	if res, err = ParseIOResponse(msgs[0]); err != nil || res.Data != nil {
+		t.Fatal("expected no data for invalid length", res, err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	if CommandName(CommandSessionSetup) != "SESSION_SETUP" || CommandName(0x99) != "UNKNOWN_153" {
+		t.Fatal("unexpected command names")
+	}
+	if StatusName(StatusAccessDenied) != "STATUS_ACCESS_DENIED" || StatusName(0xC0001234) != "0xC0001234" {
+		t.Fatal("unexpected status names")
+	}
+	if DialectName(Dialect311) != "3.1.1" || ShareTypeName(ShareTypePipe) != "PIPE" {
+		t.Fatal("unexpected dialect or share type names")
+	}
+}
+
+func TestSessionSetupResponse(t *testing.T) {
+
+	challenge := []byte("NTLMSSP\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00")
+	token := append([]byte{0xa1, 0x81, 0xc2}, challenge...)
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[4:], HeaderSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(token)))
+
+	msgs, err := Decode(message(CommandSessionSetup, FlagResponse, 1, append(body, token...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ParseSessionSetupResponse(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := res.NTLM()
+	if msg == nil || msg.Type != 2 || msg.Flags != 1 {
+		t.Fatal("unexpected NTLM message", msg)
+	}
+}
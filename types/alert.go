@@ -0,0 +1,89 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsAlert = []string{
+	"Timestamp",
+	"RuleID",
+	"Title",
+	"Description",
+	"Severity",
+	"Tags",
+	"RecordType",
+	"SrcIP",
+	"DstIP",
+	"Group",
+	"Count",
+}
+
+func (a Alert) CSVHeader() []string {
+	return filter(fieldsAlert)
+}
+
+func (a Alert) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.RuleID,
+		a.Title,
+		a.Description,
+		a.Severity,
+		join(a.Tags...),
+		a.RecordType,
+		a.SrcIP,
+		a.DstIP,
+		a.Group,
+		strconv.FormatInt(a.Count, 10),
+	})
+}
+
+func (a Alert) Time() string {
+	return a.Timestamp
+}
+
+func (a Alert) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&a)
+}
+
+var alertMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Alert.String()),
+		Help: Type_NC_Alert.String() + " audit records",
+	},
+	fieldsAlert[1:],
+)
+
+func init() {
+	prometheus.MustRegister(alertMetric)
+}
+
+func (a Alert) Inc() {
+	alertMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+}
+
+func (a *Alert) SetPacketContext(ctx *PacketContext) {}
+
+func (a Alert) Src() string {
+	return a.SrcIP
+}
+
+func (a Alert) Dst() string {
+	return a.DstIP
+}
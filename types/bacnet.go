@@ -0,0 +1,120 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsBACnet = []string{
+	"Timestamp",
+	"BVLCFunction",
+	"ForwardedAddress",
+	"NetworkMessage",
+	"NetworkMessageType",
+	"Priority",
+	"ExpectingReply",
+	"DNET",
+	"DADR",
+	"SNET",
+	"SADR",
+	"HopCount",
+	"APDUType",
+	"Segmented",
+	"InvokeID",
+	"ServiceChoice",
+	"ServiceName",
+	"Objects",
+	"ErrorClass",
+	"ErrorCode",
+	"Reason",
+	"Control",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (b BACnet) CSVHeader() []string {
+	return filter(fieldsBACnet)
+}
+
+func (b BACnet) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(b.Timestamp),
+		b.BVLCFunction,
+		b.ForwardedAddress,
+		strconv.FormatBool(b.NetworkMessage),
+		formatInt32(b.NetworkMessageType),
+		formatInt32(b.Priority),
+		strconv.FormatBool(b.ExpectingReply),
+		formatInt32(b.DNET),
+		b.DADR,
+		formatInt32(b.SNET),
+		b.SADR,
+		formatInt32(b.HopCount),
+		b.APDUType,
+		strconv.FormatBool(b.Segmented),
+		formatInt32(b.InvokeID),
+		formatInt32(b.ServiceChoice),
+		b.ServiceName,
+		join(b.Objects...),
+		formatInt32(b.ErrorClass),
+		formatInt32(b.ErrorCode),
+		formatInt32(b.Reason),
+		strconv.FormatBool(b.Control),
+		b.SrcIP,
+		b.DstIP,
+		formatInt32(b.SrcPort),
+		formatInt32(b.DstPort),
+		b.ConnUID,
+	})
+}
+
+func (b BACnet) Time() string {
+	return b.Timestamp
+}
+
+func (b BACnet) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&b)
+}
+
+var bacnetMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_BACnet.String()),
+		Help: Type_NC_BACnet.String() + " audit records",
+	},
+	[]string{"BVLCFunction", "APDUType", "ServiceName", "DstIP"},
+)
+
+func init() {
+	prometheus.MustRegister(bacnetMetric)
+}
+
+func (b BACnet) Inc() {
+	bacnetMetric.WithLabelValues(b.BVLCFunction, b.APDUType, b.ServiceName, b.DstIP).Inc()
+}
+
+func (b *BACnet) SetPacketContext(ctx *PacketContext) {}
+
+func (b BACnet) Src() string {
+	return b.SrcIP
+}
+
+func (b BACnet) Dst() string {
+	return b.DstIP
+}
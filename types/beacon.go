@@ -0,0 +1,104 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsBeacon = []string{
+	"Timestamp",
+	"TimestampLast",
+	"SrcIP",
+	"DstIP",
+	"DstPort",
+	"Proto",
+	"NumConnections",
+	"MeanInterval",
+	"MedianInterval",
+	"Jitter",
+	"IntervalMAD",
+	"IntervalSkew",
+	"MeanSize",
+	"SizeVariance",
+	"SizeSkew",
+	"TimingScore",
+	"SizeScore",
+	"Score",
+	"Rank",
+}
+
+func (b Beacon) CSVHeader() []string {
+	return filter(fieldsBeacon)
+}
+
+func (b Beacon) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(b.Timestamp),
+		formatTimestamp(b.TimestampLast),
+		b.SrcIP,
+		b.DstIP,
+		formatInt32(b.DstPort),
+		b.Proto,
+		formatInt32(b.NumConnections),
+		formatInt64(b.MeanInterval),
+		formatInt64(b.MedianInterval),
+		formatInt64(b.Jitter),
+		formatInt64(b.IntervalMAD),
+		formatFloat64(b.IntervalSkew),
+		formatFloat64(b.MeanSize),
+		formatFloat64(b.SizeVariance),
+		formatFloat64(b.SizeSkew),
+		formatFloat64(b.TimingScore),
+		formatFloat64(b.SizeScore),
+		formatFloat64(b.Score),
+		formatInt32(b.Rank),
+	})
+}
+
+func (b Beacon) Time() string {
+	return b.Timestamp
+}
+
+func (b Beacon) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&b)
+}
+
+var beaconMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Beacon.String()),
+		Help: Type_NC_Beacon.String() + " audit records",
+	},
+	fieldsBeacon[1:],
+)
+
+func init() {
+	prometheus.MustRegister(beaconMetric)
+}
+
+func (b Beacon) Inc() {
+	beaconMetric.WithLabelValues(b.CSVRecord()[1:]...).Inc()
+}
+
+func (b *Beacon) SetPacketContext(ctx *PacketContext) {}
+
+func (b Beacon) Src() string {
+	return b.SrcIP
+}
+
+func (b Beacon) Dst() string {
+	return b.DstIP
+}
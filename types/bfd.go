@@ -46,7 +46,7 @@ var fieldsBFD = []string{
 }
 
 func (a BFD) CSVHeader() []string {
-	return filter(fieldsBFD)
+	return filter(append(fieldsBFD, fieldsContext...))
 }
 
 func (a BFD) CSVRecord() []string {
@@ -54,7 +54,7 @@ func (a BFD) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Version),                        // int32
 		formatInt32(a.Diagnostic),                     // int32
@@ -76,7 +76,7 @@ func (a BFD) CSVRecord() []string {
 		a.Context.DstIP,
 		a.Context.SrcPort,
 		a.Context.DstPort,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a BFD) Time() string {
@@ -114,7 +114,7 @@ func init() {
 }
 
 func (a BFD) Inc() {
-	bfdMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	bfdMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsBFD)]...).Inc()
 }
 
 func (a *BFD) SetPacketContext(ctx *PacketContext) {
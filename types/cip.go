@@ -30,6 +30,7 @@ var fieldsCIP = []string{
 	"Status",           // int32
 	"AdditionalStatus", // []uint32
 	"Data",             // []byte
+	"DataHash",         // string
 	"SrcIP",
 	"DstIP",
 	"SrcPort",
@@ -37,7 +38,7 @@ var fieldsCIP = []string{
 }
 
 func (a CIP) CSVHeader() []string {
-	return filter(fieldsCIP)
+	return filter(append(fieldsCIP, fieldsContext...))
 }
 
 func (a CIP) CSVRecord() []string {
@@ -51,7 +52,7 @@ func (a CIP) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		strconv.FormatBool(a.Response), // bool
 		formatInt32(a.ServiceID),       // int32
@@ -60,11 +61,12 @@ func (a CIP) CSVRecord() []string {
 		formatInt32(a.Status),          // int32
 		strings.Join(additional, ""),   // []uint32
 		hex.EncodeToString(a.Data),     // []byte
+		a.DataHash,                     // string
 		a.Context.SrcIP,
 		a.Context.DstIP,
 		a.Context.SrcPort,
 		a.Context.DstPort,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a CIP) Time() string {
@@ -88,7 +90,7 @@ func init() {
 }
 
 func (a CIP) Inc() {
-	cipMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	cipMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsCIP)]...).Inc()
 }
 
 func (a *CIP) SetPacketContext(ctx *PacketContext) {
@@ -14,6 +14,7 @@
 package types
 
 import (
+	"encoding/hex"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -37,6 +38,12 @@ var fieldsConnection = []string{
 	"UID",
 	"Duration",
 	"TimestampLast",
+	"CommunityID",
+	"Payload",
+	"PayloadHash",
+	"DstHostname",
+	"ControlUID",
+	"ControlCommand",
 }
 
 func (c Connection) CSVHeader() []string {
@@ -62,6 +69,12 @@ func (c Connection) CSVRecord() []string {
 		c.UID,
 		formatInt64(c.Duration),
 		formatTimestamp(c.TimestampLast),
+		c.CommunityID,
+		hex.EncodeToString(c.Payload),
+		c.PayloadHash,
+		c.DstHostname,
+		c.ControlUID,
+		c.ControlCommand,
 	})
 }
 
@@ -44,7 +44,7 @@ var fieldsDHCPv4 = []string{
 }
 
 func (d DHCPv4) CSVHeader() []string {
-	return filter(fieldsDHCPv4)
+	return filter(append(fieldsDHCPv4, fieldsContext...))
 }
 
 func (d DHCPv4) CSVRecord() []string {
@@ -56,7 +56,7 @@ func (d DHCPv4) CSVRecord() []string {
 	if d.Context == nil {
 		d.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(d.Timestamp),     // string
 		formatInt32(d.Operation),         // int32
 		formatInt32(d.HardwareType),      // int32
@@ -77,7 +77,7 @@ func (d DHCPv4) CSVRecord() []string {
 		d.Context.DstIP,
 		d.Context.SrcPort,
 		d.Context.DstPort,
-	})
+	}, d.Context.identifiers()...))
 }
 
 func (d DHCPv4) Time() string {
@@ -113,7 +113,7 @@ func init() {
 }
 
 func (a DHCPv4) Inc() {
-	dhcp4Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	dhcp4Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsDHCPv4)]...).Inc()
 }
 
 func (a *DHCPv4) SetPacketContext(ctx *PacketContext) {
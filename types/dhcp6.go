@@ -35,7 +35,7 @@ var fieldsDHCPv6 = []string{
 }
 
 func (d DHCPv6) CSVHeader() []string {
-	return filter(fieldsDHCPv6)
+	return filter(append(fieldsDHCPv6, fieldsContext...))
 }
 
 func (d DHCPv6) CSVRecord() []string {
@@ -47,7 +47,7 @@ func (d DHCPv6) CSVRecord() []string {
 	if d.Context == nil {
 		d.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(d.Timestamp),        // string
 		formatInt32(d.MsgType),              // int32
 		formatInt32(d.HopCount),             // int32
@@ -59,7 +59,7 @@ func (d DHCPv6) CSVRecord() []string {
 		d.Context.DstIP,
 		d.Context.SrcPort,
 		d.Context.DstPort,
-	})
+	}, d.Context.identifiers()...))
 }
 
 func (d DHCPv6) Time() string {
@@ -95,7 +95,7 @@ func init() {
 }
 
 func (a DHCPv6) Inc() {
-	dhcp6Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	dhcp6Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsDHCPv6)]...).Inc()
 }
 
 func (a *DHCPv6) SetPacketContext(ctx *PacketContext) {
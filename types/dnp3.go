@@ -0,0 +1,141 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsDNP3 = []string{
+	"Timestamp",
+	"Transport",
+	"FromMaster",
+	"Primary",
+	"LinkFunction",
+	"LinkFunctionName",
+	"Source",
+	"Destination",
+	"NumFrames",
+	"TransportSequence",
+	"AppSequence",
+	"Confirm",
+	"Unsolicited",
+	"FunctionCode",
+	"FunctionName",
+	"Control",
+	"IIN",
+	"IINFlags",
+	"Objects",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (d DNP3) CSVHeader() []string {
+	return filter(fieldsDNP3)
+}
+
+func (d DNP3) CSVRecord() []string {
+	var objects strings.Builder
+	for _, o := range d.Objects {
+		objects.WriteString(o.ToString())
+	}
+	return filter([]string{
+		formatTimestamp(d.Timestamp),
+		d.Transport,
+		strconv.FormatBool(d.FromMaster),
+		strconv.FormatBool(d.Primary),
+		formatInt32(d.LinkFunction),
+		d.LinkFunctionName,
+		formatInt32(d.Source),
+		formatInt32(d.Destination),
+		formatInt32(d.NumFrames),
+		formatInt32(d.TransportSequence),
+		formatInt32(d.AppSequence),
+		strconv.FormatBool(d.Confirm),
+		strconv.FormatBool(d.Unsolicited),
+		formatInt32(d.FunctionCode),
+		d.FunctionName,
+		strconv.FormatBool(d.Control),
+		formatInt32(d.IIN),
+		join(d.IINFlags...),
+		objects.String(),
+		d.SrcIP,
+		d.DstIP,
+		formatInt32(d.SrcPort),
+		formatInt32(d.DstPort),
+		d.ConnUID,
+	})
+}
+
+func (o DNP3Object) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(formatInt32(o.Group))
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(o.Variation))
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(o.Qualifier))
+	b.WriteString(Separator)
+	b.WriteString(o.Name)
+	b.WriteString(Separator)
+	b.WriteString(formatUint32(o.Start))
+	b.WriteString(Separator)
+	b.WriteString(formatUint32(o.Stop))
+	b.WriteString(Separator)
+	b.WriteString(formatUint32(o.Count))
+	b.WriteString(Separator)
+	b.WriteString(joinUints(o.Indexes))
+	b.WriteString(End)
+	return b.String()
+}
+
+func (d DNP3) Time() string {
+	return d.Timestamp
+}
+
+func (d DNP3) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&d)
+}
+
+var dnp3Metric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_DNP3.String()),
+		Help: Type_NC_DNP3.String() + " audit records",
+	},
+	[]string{"FunctionName", "Control", "Source", "Destination"},
+)
+
+func init() {
+	prometheus.MustRegister(dnp3Metric)
+}
+
+func (d DNP3) Inc() {
+	dnp3Metric.WithLabelValues(d.FunctionName, strconv.FormatBool(d.Control), formatInt32(d.Source), formatInt32(d.Destination)).Inc()
+}
+
+func (d *DNP3) SetPacketContext(ctx *PacketContext) {}
+
+func (d DNP3) Src() string {
+	return d.SrcIP
+}
+
+func (d DNP3) Dst() string {
+	return d.DstIP
+}
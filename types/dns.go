@@ -40,6 +40,7 @@ var fieldsDNS = []string{
 	"Answers",      // []*DNSResourceRecord
 	"Authorities",  // []*DNSResourceRecord
 	"Additionals",  // []*DNSResourceRecord
+	"Transport",    // string
 	"SrcIP",
 	"DstIP",
 	"SrcPort",
@@ -47,7 +48,7 @@ var fieldsDNS = []string{
 }
 
 func (d DNS) CSVHeader() []string {
-	return filter(fieldsDNS)
+	return filter(append(fieldsDNS, fieldsContext...))
 }
 
 func (d DNS) CSVRecord() []string {
@@ -73,7 +74,7 @@ func (d DNS) CSVRecord() []string {
 	if d.Context == nil {
 		d.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(d.Timestamp),
 		formatInt32(d.ID),             // int32
 		strconv.FormatBool(d.QR),      // bool
@@ -92,11 +93,12 @@ func (d DNS) CSVRecord() []string {
 		strings.Join(answers, ""),     // []*DNSResourceRecord
 		strings.Join(authorities, ""), // []*DNSResourceRecord
 		strings.Join(additionals, ""), // []*DNSResourceRecord
+		d.Transport,                   // string
 		d.Context.SrcIP,
 		d.Context.DstIP,
 		d.Context.SrcPort,
 		d.Context.DstPort,
-	})
+	}, d.Context.identifiers()...))
 }
 
 func (d DNS) Time() string {
@@ -214,7 +216,7 @@ func init() {
 }
 
 func (a DNS) Inc() {
-	dnsMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	dnsMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsDNS)]...).Inc()
 }
 
 func (a *DNS) SetPacketContext(ctx *PacketContext) {
@@ -0,0 +1,106 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsDNSAnomaly = []string{
+	"Timestamp",
+	"SrcIP",
+	"DstIP",
+	"Name",
+	"ParentDomain",
+	"QueryType",
+	"Length",
+	"NumLabels",
+	"LongestLabel",
+	"SubdomainEntropy",
+	"DomainEntropy",
+	"DigitRatio",
+	"VowelRatio",
+	"SpecialRatio",
+	"BigramScore",
+	"UniqueSubdomains",
+	"TXTNullQueries",
+	"TunnelScore",
+	"DGAScore",
+	"Reason",
+}
+
+func (d DNSAnomaly) CSVHeader() []string {
+	return filter(fieldsDNSAnomaly)
+}
+
+func (d DNSAnomaly) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(d.Timestamp),
+		d.SrcIP,
+		d.DstIP,
+		d.Name,
+		d.ParentDomain,
+		d.QueryType,
+		formatInt32(d.Length),
+		formatInt32(d.NumLabels),
+		formatInt32(d.LongestLabel),
+		formatFloat64(d.SubdomainEntropy),
+		formatFloat64(d.DomainEntropy),
+		formatFloat64(d.DigitRatio),
+		formatFloat64(d.VowelRatio),
+		formatFloat64(d.SpecialRatio),
+		formatFloat64(d.BigramScore),
+		formatInt32(d.UniqueSubdomains),
+		formatInt32(d.TXTNullQueries),
+		formatFloat64(d.TunnelScore),
+		formatFloat64(d.DGAScore),
+		d.Reason,
+	})
+}
+
+func (d DNSAnomaly) Time() string {
+	return d.Timestamp
+}
+
+func (d DNSAnomaly) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&d)
+}
+
+var dnsAnomalyMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_DNSAnomaly.String()),
+		Help: Type_NC_DNSAnomaly.String() + " audit records",
+	},
+	fieldsDNSAnomaly[1:],
+)
+
+func init() {
+	prometheus.MustRegister(dnsAnomalyMetric)
+}
+
+func (d DNSAnomaly) Inc() {
+	dnsAnomalyMetric.WithLabelValues(d.CSVRecord()[1:]...).Inc()
+}
+
+func (d *DNSAnomaly) SetPacketContext(ctx *PacketContext) {}
+
+func (d DNSAnomaly) Src() string {
+	return d.SrcIP
+}
+
+func (d DNSAnomaly) Dst() string {
+	return d.DstIP
+}
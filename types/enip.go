@@ -36,14 +36,14 @@ var fieldsENIP = []string{
 }
 
 func (e ENIP) CSVHeader() []string {
-	return filter(fieldsENIP)
+	return filter(append(fieldsENIP, fieldsContext...))
 }
 func (e ENIP) CSVRecord() []string {
 	// prevent accessing nil pointer
 	if e.Context == nil {
 		e.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(e.Timestamp),
 		formatUint32(e.Command),             // uint32
 		formatUint32(e.Length),              // uint32
@@ -56,7 +56,7 @@ func (e ENIP) CSVRecord() []string {
 		e.Context.DstIP,
 		e.Context.SrcPort,
 		e.Context.DstPort,
-	})
+	}, e.Context.identifiers()...))
 }
 
 func (e ENIP) Time() string {
@@ -82,7 +82,7 @@ func init() {
 }
 
 func (a ENIP) Inc() {
-	enipMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	enipMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsENIP)]...).Inc()
 }
 
 func (a *ENIP) SetPacketContext(ctx *PacketContext) {
@@ -14,6 +14,7 @@
 package types
 
 import (
+	"encoding/hex"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,6 +27,8 @@ var fieldsEthernet = []string{
 	"EthernetType",   // int32
 	"PayloadEntropy", // float64
 	"PayloadSize",    // int32
+	"Payload",        // []byte
+	"PayloadHash",    // string
 }
 
 func (e Ethernet) CSVHeader() []string {
@@ -39,6 +42,8 @@ func (e Ethernet) CSVRecord() []string {
 		formatInt32(e.EthernetType),     // int32
 		formatFloat64(e.PayloadEntropy), // float64
 		formatInt32(e.PayloadSize),      // int32
+		hex.EncodeToString(e.Payload),   // []byte
+		e.PayloadHash,                   // string
 	})
 }
 
@@ -0,0 +1,114 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsFile = []string{
+	"Timestamp",
+	"Name",
+	"Length",
+	"MD5",
+	"SHA1",
+	"SHA256",
+	"MIME",
+	"ContentType",
+	"Entropy",
+	"Source",
+	"Protocol",
+	"Host",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+	"Location",
+}
+
+func (f File) CSVHeader() []string {
+	return filter(fieldsFile)
+}
+
+func (f File) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(f.Timestamp),
+		f.Name,
+		formatInt64(f.Length),
+		f.MD5,
+		f.SHA1,
+		f.SHA256,
+		f.MIME,
+		f.ContentType,
+		strconv.FormatFloat(f.Entropy, 'f', 6, 64),
+		f.Source,
+		f.Protocol,
+		f.Host,
+		f.SrcIP,
+		f.DstIP,
+		formatInt32(f.SrcPort),
+		formatInt32(f.DstPort),
+		f.ConnUID,
+		f.Location,
+	})
+}
+
+func (f File) Time() string {
+	return f.Timestamp
+}
+
+func (f File) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&f)
+}
+
+var (
+	fileMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: strings.ToLower(Type_NC_File.String()),
+			Help: Type_NC_File.String() + " audit records",
+		},
+		[]string{"MIME", "Source", "Protocol"},
+	)
+	fileSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    strings.ToLower(Type_NC_File.String()) + "_size",
+			Help:    Type_NC_File.String() + " sizes",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+		},
+		[]string{"MIME"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(fileMetric)
+	prometheus.MustRegister(fileSize)
+}
+
+func (f File) Inc() {
+	fileMetric.WithLabelValues(f.MIME, f.Source, f.Protocol).Inc()
+	fileSize.WithLabelValues(f.MIME).Observe(float64(f.Length))
+}
+
+func (f *File) SetPacketContext(ctx *PacketContext) {}
+
+func (f File) Src() string {
+	return f.SrcIP
+}
+
+func (f File) Dst() string {
+	return f.DstIP
+}
@@ -14,6 +14,7 @@
 package types
 
 import (
+	"encoding/hex"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -37,6 +38,10 @@ var fieldsFlow = []string{
 	"UID",
 	"Duration",
 	"TimestampLast",
+	"CommunityID",
+	"Payload",
+	"PayloadHash",
+	"DstHostname",
 }
 
 func (f Flow) CSVHeader() []string {
@@ -62,6 +67,10 @@ func (f Flow) CSVRecord() []string {
 		f.UID,
 		formatInt64(f.Duration),
 		formatTimestamp(f.TimestampLast),
+		f.CommunityID,
+		hex.EncodeToString(f.Payload),
+		f.PayloadHash,
+		f.DstHostname,
 	})
 }
 
@@ -0,0 +1,232 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsFlowFeatures = []string{
+	"Timestamp",
+	"FlowID",
+	"SrcIP",
+	"SrcPort",
+	"DstIP",
+	"DstPort",
+	"Protocol",
+	"Duration",
+	"TotalFwdPackets",
+	"TotalBwdPackets",
+	"TotalLengthFwdPackets",
+	"TotalLengthBwdPackets",
+	"FwdPacketLengthMax",
+	"FwdPacketLengthMin",
+	"FwdPacketLengthMean",
+	"FwdPacketLengthStd",
+	"BwdPacketLengthMax",
+	"BwdPacketLengthMin",
+	"BwdPacketLengthMean",
+	"BwdPacketLengthStd",
+	"FlowBytesPerSec",
+	"FlowPacketsPerSec",
+	"FlowIATMean",
+	"FlowIATStd",
+	"FlowIATMax",
+	"FlowIATMin",
+	"FwdIATTotal",
+	"FwdIATMean",
+	"FwdIATStd",
+	"FwdIATMax",
+	"FwdIATMin",
+	"BwdIATTotal",
+	"BwdIATMean",
+	"BwdIATStd",
+	"BwdIATMax",
+	"BwdIATMin",
+	"FwdPSHFlags",
+	"BwdPSHFlags",
+	"FwdURGFlags",
+	"BwdURGFlags",
+	"FwdHeaderLength",
+	"BwdHeaderLength",
+	"FwdPacketsPerSec",
+	"BwdPacketsPerSec",
+	"PacketLengthMin",
+	"PacketLengthMax",
+	"PacketLengthMean",
+	"PacketLengthStd",
+	"PacketLengthVariance",
+	"FINFlagCount",
+	"SYNFlagCount",
+	"RSTFlagCount",
+	"PSHFlagCount",
+	"ACKFlagCount",
+	"URGFlagCount",
+	"CWEFlagCount",
+	"ECEFlagCount",
+	"DownUpRatio",
+	"AveragePacketSize",
+	"AvgFwdSegmentSize",
+	"AvgBwdSegmentSize",
+	"FwdAvgBytesBulk",
+	"FwdAvgPacketsBulk",
+	"FwdAvgBulkRate",
+	"BwdAvgBytesBulk",
+	"BwdAvgPacketsBulk",
+	"BwdAvgBulkRate",
+	"SubflowFwdPackets",
+	"SubflowFwdBytes",
+	"SubflowBwdPackets",
+	"SubflowBwdBytes",
+	"InitWinBytesFwd",
+	"InitWinBytesBwd",
+	"ActDataPktFwd",
+	"MinSegSizeFwd",
+	"ActiveMean",
+	"ActiveStd",
+	"ActiveMax",
+	"ActiveMin",
+	"IdleMean",
+	"IdleStd",
+	"IdleMax",
+	"IdleMin",
+}
+
+func (f FlowFeatures) CSVHeader() []string {
+	return filter(fieldsFlowFeatures)
+}
+
+func (f FlowFeatures) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(f.Timestamp),
+		f.FlowID,
+		f.SrcIP,
+		formatInt32(f.SrcPort),
+		f.DstIP,
+		formatInt32(f.DstPort),
+		formatInt32(f.Protocol),
+		formatInt64(f.Duration),
+		formatInt64(f.TotalFwdPackets),
+		formatInt64(f.TotalBwdPackets),
+		formatInt64(f.TotalLengthFwdPackets),
+		formatInt64(f.TotalLengthBwdPackets),
+		formatInt64(f.FwdPacketLengthMax),
+		formatInt64(f.FwdPacketLengthMin),
+		formatFloat64(f.FwdPacketLengthMean),
+		formatFloat64(f.FwdPacketLengthStd),
+		formatInt64(f.BwdPacketLengthMax),
+		formatInt64(f.BwdPacketLengthMin),
+		formatFloat64(f.BwdPacketLengthMean),
+		formatFloat64(f.BwdPacketLengthStd),
+		formatFloat64(f.FlowBytesPerSec),
+		formatFloat64(f.FlowPacketsPerSec),
+		formatFloat64(f.FlowIATMean),
+		formatFloat64(f.FlowIATStd),
+		formatFloat64(f.FlowIATMax),
+		formatFloat64(f.FlowIATMin),
+		formatFloat64(f.FwdIATTotal),
+		formatFloat64(f.FwdIATMean),
+		formatFloat64(f.FwdIATStd),
+		formatFloat64(f.FwdIATMax),
+		formatFloat64(f.FwdIATMin),
+		formatFloat64(f.BwdIATTotal),
+		formatFloat64(f.BwdIATMean),
+		formatFloat64(f.BwdIATStd),
+		formatFloat64(f.BwdIATMax),
+		formatFloat64(f.BwdIATMin),
+		formatInt32(f.FwdPSHFlags),
+		formatInt32(f.BwdPSHFlags),
+		formatInt32(f.FwdURGFlags),
+		formatInt32(f.BwdURGFlags),
+		formatInt64(f.FwdHeaderLength),
+		formatInt64(f.BwdHeaderLength),
+		formatFloat64(f.FwdPacketsPerSec),
+		formatFloat64(f.BwdPacketsPerSec),
+		formatInt64(f.PacketLengthMin),
+		formatInt64(f.PacketLengthMax),
+		formatFloat64(f.PacketLengthMean),
+		formatFloat64(f.PacketLengthStd),
+		formatFloat64(f.PacketLengthVariance),
+		formatInt32(f.FINFlagCount),
+		formatInt32(f.SYNFlagCount),
+		formatInt32(f.RSTFlagCount),
+		formatInt32(f.PSHFlagCount),
+		formatInt32(f.ACKFlagCount),
+		formatInt32(f.URGFlagCount),
+		formatInt32(f.CWEFlagCount),
+		formatInt32(f.ECEFlagCount),
+		formatFloat64(f.DownUpRatio),
+		formatFloat64(f.AveragePacketSize),
+		formatFloat64(f.AvgFwdSegmentSize),
+		formatFloat64(f.AvgBwdSegmentSize),
+		formatFloat64(f.FwdAvgBytesBulk),
+		formatFloat64(f.FwdAvgPacketsBulk),
+		formatFloat64(f.FwdAvgBulkRate),
+		formatFloat64(f.BwdAvgBytesBulk),
+		formatFloat64(f.BwdAvgPacketsBulk),
+		formatFloat64(f.BwdAvgBulkRate),
+		formatInt64(f.SubflowFwdPackets),
+		formatInt64(f.SubflowFwdBytes),
+		formatInt64(f.SubflowBwdPackets),
+		formatInt64(f.SubflowBwdBytes),
+		formatInt32(f.InitWinBytesFwd),
+		formatInt32(f.InitWinBytesBwd),
+		formatInt64(f.ActDataPktFwd),
+		formatInt64(f.MinSegSizeFwd),
+		formatFloat64(f.ActiveMean),
+		formatFloat64(f.ActiveStd),
+		formatFloat64(f.ActiveMax),
+		formatFloat64(f.ActiveMin),
+		formatFloat64(f.IdleMean),
+		formatFloat64(f.IdleStd),
+		formatFloat64(f.IdleMax),
+		formatFloat64(f.IdleMin),
+	})
+}
+
+func (f FlowFeatures) Time() string {
+	return f.Timestamp
+}
+
+func (f FlowFeatures) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&f)
+}
+
+var flowFeaturesMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_FlowFeatures.String()),
+		Help: Type_NC_FlowFeatures.String() + " audit records",
+	},
+	fieldsFlowFeatures[1:],
+)
+
+func init() {
+	prometheus.MustRegister(flowFeaturesMetric)
+}
+
+func (f FlowFeatures) Inc() {
+	flowFeaturesMetric.WithLabelValues(f.CSVRecord()[1:]...).Inc()
+}
+
+func (f *FlowFeatures) SetPacketContext(ctx *PacketContext) {}
+
+func (f FlowFeatures) Src() string {
+	return f.SrcIP
+}
+
+func (f FlowFeatures) Dst() string {
+	return f.DstIP
+}
@@ -0,0 +1,124 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsFTP = []string{
+	"Timestamp",
+	"TimestampLast",
+	"Banner",
+	"User",
+	"Commands",
+	"NumTransfers",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (f FTP) CSVHeader() []string {
+	return filter(fieldsFTP)
+}
+
+func (f FTP) CSVRecord() []string {
+	var commands strings.Builder
+	for _, c := range f.Commands {
+		commands.WriteString(c.ToString())
+	}
+	return filter([]string{
+		formatTimestamp(f.Timestamp),
+		formatTimestamp(f.TimestampLast),
+		f.Banner,
+		f.User,
+		commands.String(),
+		formatInt32(f.NumTransfers),
+		f.SrcIP,
+		f.DstIP,
+		formatInt32(f.SrcPort),
+		formatInt32(f.DstPort),
+		f.ConnUID,
+	})
+}
+
+func (c FTPCommand) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(formatTimestamp(c.Timestamp))
+	b.WriteString(Separator)
+	b.WriteString(c.Command)
+	b.WriteString(Separator)
+	b.WriteString(c.Argument)
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(c.ReplyCode))
+	b.WriteString(Separator)
+	b.WriteString(c.ReplyMessage)
+	b.WriteString(Separator)
+	b.WriteString(c.DataAddress)
+	b.WriteString(Separator)
+	b.WriteString(c.DataConnUID)
+	b.WriteString(End)
+	return b.String()
+}
+
+func (f FTP) Time() string {
+	return f.Timestamp
+}
+
+func (f FTP) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&f)
+}
+
+var ftpMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_FTP.String()),
+		Help: Type_NC_FTP.String() + " audit records",
+	},
+	[]string{"User", "DstIP"},
+)
+
+var ftpCommandMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_FTP.String()) + "_commands",
+		Help: Type_NC_FTP.String() + " commands",
+	},
+	[]string{"Command", "ReplyCode"},
+)
+
+func init() {
+	prometheus.MustRegister(ftpMetric)
+	prometheus.MustRegister(ftpCommandMetric)
+}
+
+func (f FTP) Inc() {
+	ftpMetric.WithLabelValues(f.User, f.DstIP).Inc()
+	for _, c := range f.Commands {
+		ftpCommandMetric.WithLabelValues(c.Command, formatInt32(c.ReplyCode)).Inc()
+	}
+}
+
+func (f *FTP) SetPacketContext(ctx *PacketContext) {}
+
+func (f FTP) Src() string {
+	return f.SrcIP
+}
+
+func (f FTP) Dst() string {
+	return f.DstIP
+}
@@ -37,7 +37,7 @@ var fieldsGeneve = []string{
 }
 
 func (i Geneve) CSVHeader() []string {
-	return filter(fieldsGeneve)
+	return filter(append(fieldsGeneve, fieldsContext...))
 }
 
 func (i Geneve) CSVRecord() []string {
@@ -49,7 +49,7 @@ func (i Geneve) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.Version),               // int32
 		formatInt32(i.OptionsLength),         // int32
@@ -62,7 +62,7 @@ func (i Geneve) CSVRecord() []string {
 		i.Context.DstIP,
 		i.Context.SrcPort,
 		i.Context.DstPort,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i Geneve) Time() string {
@@ -104,7 +104,7 @@ func init() {
 }
 
 func (a Geneve) Inc() {
-	geneveMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	geneveMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsGeneve)]...).Inc()
 }
 
 func (a *Geneve) SetPacketContext(ctx *PacketContext) {
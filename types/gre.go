@@ -46,7 +46,7 @@ var fieldsGRE = []string{
 }
 
 func (a GRE) CSVHeader() []string {
-	return filter(fieldsGRE)
+	return filter(append(fieldsGRE, fieldsContext...))
 }
 
 func (a GRE) CSVRecord() []string {
@@ -54,7 +54,7 @@ func (a GRE) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		strconv.FormatBool(a.ChecksumPresent),   // bool
 		strconv.FormatBool(a.RoutingPresent),    // bool
@@ -76,7 +76,7 @@ func (a GRE) CSVRecord() []string {
 		a.Context.DstIP,
 		a.Context.SrcPort,
 		a.Context.DstPort,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a GRE) Time() string {
@@ -123,7 +123,7 @@ func init() {
 }
 
 func (a GRE) Inc() {
-	greMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	greMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsGRE)]...).Inc()
 }
 
 func (a *GRE) SetPacketContext(ctx *PacketContext) {
@@ -14,6 +14,8 @@
 package types
 
 import (
+	"encoding/hex"
+	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -37,10 +39,30 @@ var fieldsHTTP = []string{
 	"ReqContentEncoding",
 	"ResContentEncoding",
 	"ServerName",
+	"CommunityID",
+}
+
+var fieldsHTTPDetails = []string{
+	"ReqBody",
+	"ReqBodySize",
+	"ReqBodyHash",
+	"ReqBodyMIME",
+	"ResBody",
+	"ResBodySize",
+	"ResBodyHash",
+	"ResBodyMIME",
+	"FormParts",
+	"RequestHeader",
+	"ResponseHeader",
+	"ResTimestamp",
+	"ServerLatency",
+	"DstHostname",
+	"ReqBodyTruncated",
+	"ResBodyTruncated",
 }
 
 func (h HTTP) CSVHeader() []string {
-	return filter(fieldsHTTP)
+	return filter(append(fieldsHTTP, fieldsHTTPDetails...))
 }
 
 func (h HTTP) CSVRecord() []string {
@@ -62,9 +84,54 @@ func (h HTTP) CSVRecord() []string {
 		h.ReqContentEncoding,
 		h.ResContentEncoding,
 		h.ServerName,
+		h.CommunityID,
+		hex.EncodeToString(h.ReqBody),
+		formatInt32(h.ReqBodySize),
+		h.ReqBodyHash,
+		h.ReqBodyMIME,
+		hex.EncodeToString(h.ResBody),
+		formatInt32(h.ResBodySize),
+		h.ResBodyHash,
+		h.ResBodyMIME,
+		h.getFormPartString(),
+		joinMap(h.RequestHeader),
+		joinMap(h.ResponseHeader),
+		formatTimestamp(h.ResTimestamp),
+		formatInt64(h.ServerLatency),
+		h.DstHostname,
+		strconv.FormatBool(h.ReqBodyTruncated),
+		strconv.FormatBool(h.ResBodyTruncated),
 	})
 }
 
+func (h HTTP) getFormPartString() string {
+	var b strings.Builder
+	for _, p := range h.FormParts {
+		b.WriteString(p.ToString())
+	}
+	return b.String()
+}
+
+func (p HTTPFormPart) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(p.Name)
+	b.WriteString(Separator)
+	b.WriteString(p.FileName)
+	b.WriteString(Separator)
+	b.WriteString(p.ContentType)
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(p.Length))
+	b.WriteString(Separator)
+	b.WriteString(p.Hash)
+	b.WriteString(Separator)
+	b.WriteString(p.MIME)
+	b.WriteString(Separator)
+	b.WriteString(strconv.FormatBool(p.Truncated))
+	b.WriteString(End)
+	return b.String()
+}
+
 func (f HTTP) Time() string {
 	return f.Timestamp
 }
@@ -86,7 +153,7 @@ func init() {
 }
 
 func (a HTTP) Inc() {
-	httpMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	httpMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsHTTP)]...).Inc()
 }
 
 func (a *HTTP) SetPacketContext(ctx *PacketContext) {}
@@ -30,7 +30,7 @@ var fieldsICMPv4 = []string{
 }
 
 func (i ICMPv4) CSVHeader() []string {
-	return filter(fieldsICMPv4)
+	return filter(append(fieldsICMPv4, fieldsContext...))
 }
 
 func (i ICMPv4) CSVRecord() []string {
@@ -38,7 +38,7 @@ func (i ICMPv4) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.TypeCode),
 		formatInt32(i.Checksum),
@@ -46,7 +46,7 @@ func (i ICMPv4) CSVRecord() []string {
 		formatInt32(i.Seq),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv4) Time() string {
@@ -70,7 +70,7 @@ func init() {
 }
 
 func (a ICMPv4) Inc() {
-	icmp4Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp4Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv4)]...).Inc()
 }
 
 func (a *ICMPv4) SetPacketContext(ctx *PacketContext) {
@@ -28,7 +28,7 @@ var fieldsICMPv6 = []string{
 }
 
 func (i ICMPv6) CSVHeader() []string {
-	return filter(fieldsICMPv6)
+	return filter(append(fieldsICMPv6, fieldsContext...))
 }
 
 func (i ICMPv6) CSVRecord() []string {
@@ -36,13 +36,13 @@ func (i ICMPv6) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.TypeCode),
 		formatInt32(i.Checksum),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv6) Time() string {
@@ -66,7 +66,7 @@ func init() {
 }
 
 func (a ICMPv6) Inc() {
-	icmp6Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp6Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv6)]...).Inc()
 }
 
 func (a *ICMPv6) SetPacketContext(ctx *PacketContext) {
@@ -28,7 +28,7 @@ var fieldsICMPv6Echo = []string{
 }
 
 func (i ICMPv6Echo) CSVHeader() []string {
-	return filter(fieldsICMPv6Echo)
+	return filter(append(fieldsICMPv6Echo, fieldsContext...))
 }
 
 func (i ICMPv6Echo) CSVRecord() []string {
@@ -36,13 +36,13 @@ func (i ICMPv6Echo) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.Identifier),
 		formatInt32(i.SeqNumber),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv6Echo) Time() string {
@@ -66,7 +66,7 @@ func init() {
 }
 
 func (a ICMPv6Echo) Inc() {
-	icmp6eMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp6eMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv6Echo)]...).Inc()
 }
 
 func (a *ICMPv6Echo) SetPacketContext(ctx *PacketContext) {
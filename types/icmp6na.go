@@ -29,7 +29,7 @@ var fieldsICMPv6NeighborAdvertisement = []string{
 }
 
 func (i ICMPv6NeighborAdvertisement) CSVHeader() []string {
-	return filter(fieldsICMPv6NeighborAdvertisement)
+	return filter(append(fieldsICMPv6NeighborAdvertisement, fieldsContext...))
 }
 
 func (i ICMPv6NeighborAdvertisement) CSVRecord() []string {
@@ -41,14 +41,14 @@ func (i ICMPv6NeighborAdvertisement) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.Flags),
 		i.TargetAddress,
 		strings.Join(opts, ""),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv6NeighborAdvertisement) Time() string {
@@ -72,7 +72,7 @@ func init() {
 }
 
 func (a ICMPv6NeighborAdvertisement) Inc() {
-	icmp6naMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp6naMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv6NeighborAdvertisement)]...).Inc()
 }
 
 func (a *ICMPv6NeighborAdvertisement) SetPacketContext(ctx *PacketContext) {
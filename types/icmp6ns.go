@@ -28,7 +28,7 @@ var fieldsICMPv6NeighborSolicitation = []string{
 }
 
 func (i ICMPv6NeighborSolicitation) CSVHeader() []string {
-	return filter(fieldsICMPv6NeighborSolicitation)
+	return filter(append(fieldsICMPv6NeighborSolicitation, fieldsContext...))
 }
 
 func (i ICMPv6NeighborSolicitation) CSVRecord() []string {
@@ -40,13 +40,13 @@ func (i ICMPv6NeighborSolicitation) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		i.TargetAddress,
 		strings.Join(opts, ""),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv6NeighborSolicitation) Time() string {
@@ -70,7 +70,7 @@ func init() {
 }
 
 func (a ICMPv6NeighborSolicitation) Inc() {
-	icmp6nsMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp6nsMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv6NeighborSolicitation)]...).Inc()
 }
 
 func (a *ICMPv6NeighborSolicitation) SetPacketContext(ctx *PacketContext) {
@@ -32,7 +32,7 @@ var fieldsICMPv6RouterAdvertisement = []string{
 }
 
 func (i ICMPv6RouterAdvertisement) CSVHeader() []string {
-	return filter(fieldsICMPv6RouterAdvertisement)
+	return filter(append(fieldsICMPv6RouterAdvertisement, fieldsContext...))
 }
 
 func (i ICMPv6RouterAdvertisement) CSVRecord() []string {
@@ -44,7 +44,7 @@ func (i ICMPv6RouterAdvertisement) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.HopLimit),       // int32
 		formatInt32(i.Flags),          // int32
@@ -54,7 +54,7 @@ func (i ICMPv6RouterAdvertisement) CSVRecord() []string {
 		strings.Join(opts, ""),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv6RouterAdvertisement) Time() string {
@@ -78,7 +78,7 @@ func init() {
 }
 
 func (a ICMPv6RouterAdvertisement) Inc() {
-	icmp6raMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp6raMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv6RouterAdvertisement)]...).Inc()
 }
 
 func (a *ICMPv6RouterAdvertisement) SetPacketContext(ctx *PacketContext) {
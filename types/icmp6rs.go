@@ -28,7 +28,7 @@ var fieldsICMPv6RouterSolicitation = []string{
 }
 
 func (i ICMPv6RouterSolicitation) CSVHeader() []string {
-	return filter(fieldsICMPv6RouterSolicitation)
+	return filter(append(fieldsICMPv6RouterSolicitation, fieldsContext...))
 }
 
 func (i ICMPv6RouterSolicitation) CSVRecord() []string {
@@ -40,12 +40,12 @@ func (i ICMPv6RouterSolicitation) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		strings.Join(opts, ""),
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i ICMPv6RouterSolicitation) Time() string {
@@ -81,7 +81,7 @@ func init() {
 }
 
 func (a ICMPv6RouterSolicitation) Inc() {
-	icmp6rsMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	icmp6rsMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsICMPv6RouterSolicitation)]...).Inc()
 }
 
 func (a *ICMPv6RouterSolicitation) SetPacketContext(ctx *PacketContext) {
@@ -0,0 +1,111 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsIEC104 = []string{
+	"Timestamp",
+	"Format",
+	"UFunction",
+	"SendSeq",
+	"RecvSeq",
+	"TypeID",
+	"TypeName",
+	"Sequence",
+	"NumObjects",
+	"Cause",
+	"CauseName",
+	"Negative",
+	"Test",
+	"Originator",
+	"CommonAddress",
+	"IOAs",
+	"Command",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (i IEC104) CSVHeader() []string {
+	return filter(fieldsIEC104)
+}
+
+func (i IEC104) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(i.Timestamp),
+		i.Format,
+		i.UFunction,
+		formatInt32(i.SendSeq),
+		formatInt32(i.RecvSeq),
+		formatInt32(i.TypeID),
+		i.TypeName,
+		strconv.FormatBool(i.Sequence),
+		formatInt32(i.NumObjects),
+		formatInt32(i.Cause),
+		i.CauseName,
+		strconv.FormatBool(i.Negative),
+		strconv.FormatBool(i.Test),
+		formatInt32(i.Originator),
+		formatInt32(i.CommonAddress),
+		joinUints(i.IOAs),
+		strconv.FormatBool(i.Command),
+		i.SrcIP,
+		i.DstIP,
+		formatInt32(i.SrcPort),
+		formatInt32(i.DstPort),
+		i.ConnUID,
+	})
+}
+
+func (i IEC104) Time() string {
+	return i.Timestamp
+}
+
+func (i IEC104) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&i)
+}
+
+var iec104Metric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_IEC104.String()),
+		Help: Type_NC_IEC104.String() + " audit records",
+	},
+	[]string{"Format", "TypeName", "CauseName", "CommonAddress"},
+)
+
+func init() {
+	prometheus.MustRegister(iec104Metric)
+}
+
+func (i IEC104) Inc() {
+	iec104Metric.WithLabelValues(i.Format, i.TypeName, i.CauseName, formatInt32(i.CommonAddress)).Inc()
+}
+
+func (i *IEC104) SetPacketContext(ctx *PacketContext) {}
+
+func (i IEC104) Src() string {
+	return i.SrcIP
+}
+
+func (i IEC104) Dst() string {
+	return i.DstIP
+}
@@ -40,7 +40,7 @@ var fieldsIGMP = []string{
 }
 
 func (i IGMP) CSVHeader() []string {
-	return filter(fieldsIGMP)
+	return filter(append(fieldsIGMP, fieldsContext...))
 }
 
 func (i IGMP) CSVRecord() []string {
@@ -52,7 +52,7 @@ func (i IGMP) CSVRecord() []string {
 	if i.Context == nil {
 		i.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.Type),                           // int32
 		formatUint64(i.MaxResponseTime),               // uint64
@@ -68,7 +68,7 @@ func (i IGMP) CSVRecord() []string {
 		formatInt32(i.Version),                        // int32
 		i.Context.SrcIP,
 		i.Context.DstIP,
-	})
+	}, i.Context.identifiers()...))
 }
 
 func (i IGMP) Time() string {
@@ -110,7 +110,7 @@ func init() {
 }
 
 func (a IGMP) Inc() {
-	igmpMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	igmpMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsIGMP)]...).Inc()
 }
 
 func (a *IGMP) SetPacketContext(ctx *PacketContext) {
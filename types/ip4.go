@@ -39,10 +39,12 @@ var fieldsIPv4 = []string{
 	"Options",        // []*IPv4Option
 	"PayloadEntropy", // float64
 	"PayloadSize",    // int32
+	"Payload",        // []byte
+	"PayloadHash",    // string
 }
 
 func (i IPv4) CSVHeader() []string {
-	return filter(fieldsIPv4)
+	return filter(append(fieldsIPv4, fieldsContext...))
 }
 
 func (i IPv4) CSVRecord() []string {
@@ -50,7 +52,7 @@ func (i IPv4) CSVRecord() []string {
 	for _, o := range i.Options {
 		opts = append(opts, o.ToString())
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.Version),        // int32
 		formatInt32(i.IHL),            // int32
@@ -68,7 +70,9 @@ func (i IPv4) CSVRecord() []string {
 		strings.Join(opts, ""),        // []*IPv4Option
 		strconv.FormatFloat(i.PayloadEntropy, 'f', 6, 64), // float64
 		formatInt32(i.PayloadSize),                        // int32
-	})
+		hex.EncodeToString(i.Payload),                     // []byte
+		i.PayloadHash,                                     // string
+	}, i.Context.identifiers()...))
 }
 
 func (i IPv4) Time() string {
@@ -171,6 +175,7 @@ func (a *IPv4) SetPacketContext(ctx *PacketContext) {
 		SrcPort: ctx.SrcPort,
 		DstPort: ctx.DstPort,
 	}
+	a.Context.identify(ctx)
 }
 
 func (a IPv4) Src() string {
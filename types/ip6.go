@@ -14,6 +14,7 @@
 package types
 
 import (
+	"encoding/hex"
 	"strconv"
 	"strings"
 
@@ -33,10 +34,12 @@ var fieldsIPv6 = []string{
 	"PayloadEntropy", // float64
 	"PayloadSize",    // int32
 	"HopByHop",       // *IPv6HopByHop
+	"Payload",        // []byte
+	"PayloadHash",    // string
 }
 
 func (i IPv6) CSVHeader() []string {
-	return filter(fieldsIPv6)
+	return filter(append(fieldsIPv6, fieldsContext...))
 }
 
 func (i IPv6) CSVRecord() []string {
@@ -44,7 +47,7 @@ func (i IPv6) CSVRecord() []string {
 	if i.HopByHop != nil {
 		hop = i.HopByHop.ToString()
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(i.Timestamp),
 		formatInt32(i.Version),      // int32
 		formatInt32(i.TrafficClass), // int32
@@ -57,7 +60,9 @@ func (i IPv6) CSVRecord() []string {
 		strconv.FormatFloat(i.PayloadEntropy, 'f', 6, 64), // float64
 		formatInt32(i.PayloadSize),                        // int32
 		hop,                                               // *IPv6HopByHop
-	})
+		hex.EncodeToString(i.Payload),                     // []byte
+		i.PayloadHash,                                     // string
+	}, i.Context.identifiers()...))
 }
 
 func (i IPv6) Time() string {
@@ -146,6 +151,7 @@ func (a *IPv6) SetPacketContext(ctx *PacketContext) {
 		SrcPort: ctx.SrcPort,
 		DstPort: ctx.DstPort,
 	}
+	a.Context.identify(ctx)
 }
 
 func (a IPv6) Src() string {
@@ -28,7 +28,7 @@ var fieldsIPv6HopByHop = []string{
 }
 
 func (l IPv6HopByHop) CSVHeader() []string {
-	return filter(fieldsIPv6HopByHop)
+	return filter(append(fieldsIPv6HopByHop, fieldsContext...))
 }
 
 func (l IPv6HopByHop) CSVRecord() []string {
@@ -40,12 +40,12 @@ func (l IPv6HopByHop) CSVRecord() []string {
 	if l.Context == nil {
 		l.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(l.Timestamp),
 		strings.Join(opts, ""),
 		l.Context.SrcIP,
 		l.Context.DstIP,
-	})
+	}, l.Context.identifiers()...))
 }
 
 func (l IPv6HopByHop) Time() string {
@@ -85,7 +85,7 @@ func init() {
 }
 
 func (a IPv6HopByHop) Inc() {
-	ip6hopMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ip6hopMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsIPv6HopByHop)]...).Inc()
 }
 
 func (a *IPv6HopByHop) SetPacketContext(ctx *PacketContext) {
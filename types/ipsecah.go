@@ -31,7 +31,7 @@ var fieldsIPSecAH = []string{
 }
 
 func (a IPSecAH) CSVHeader() []string {
-	return filter(fieldsIPSecAH)
+	return filter(append(fieldsIPSecAH, fieldsContext...))
 }
 
 func (a IPSecAH) CSVRecord() []string {
@@ -39,7 +39,7 @@ func (a IPSecAH) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Reserved),
 		formatInt32(a.SPI),
@@ -47,7 +47,7 @@ func (a IPSecAH) CSVRecord() []string {
 		hex.EncodeToString(a.AuthenticationData),
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a IPSecAH) Time() string {
@@ -71,7 +71,7 @@ func init() {
 }
 
 func (a IPSecAH) Inc() {
-	ipSecAhMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ipSecAhMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsIPSecAH)]...).Inc()
 }
 
 func (a *IPSecAH) SetPacketContext(ctx *PacketContext) {
@@ -29,7 +29,7 @@ var fieldsIPSecESP = []string{
 }
 
 func (a IPSecESP) CSVHeader() []string {
-	return filter(fieldsIPSecESP)
+	return filter(append(fieldsIPSecESP, fieldsContext...))
 }
 
 func (a IPSecESP) CSVRecord() []string {
@@ -37,14 +37,14 @@ func (a IPSecESP) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.SPI),
 		formatInt32(a.Seq),
 		formatInt32(a.LenEncrypted),
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a IPSecESP) Time() string {
@@ -68,7 +68,7 @@ func init() {
 }
 
 func (a IPSecESP) Inc() {
-	ipSecEspMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ipSecEspMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsIPSecESP)]...).Inc()
 }
 
 func (a *IPSecESP) SetPacketContext(ctx *PacketContext) {
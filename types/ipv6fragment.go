@@ -33,7 +33,7 @@ var fieldsIPv6Fragment = []string{
 }
 
 func (a IPv6Fragment) CSVHeader() []string {
-	return filter(fieldsIPv6Fragment)
+	return filter(append(fieldsIPv6Fragment, fieldsContext...))
 }
 
 func (a IPv6Fragment) CSVRecord() []string {
@@ -41,7 +41,7 @@ func (a IPv6Fragment) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.NextHeader),           // int32
 		formatInt32(a.Reserved1),            // int32
@@ -51,7 +51,7 @@ func (a IPv6Fragment) CSVRecord() []string {
 		formatUint32(a.Identification),      // uint32
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a IPv6Fragment) Time() string {
@@ -75,7 +75,7 @@ func init() {
 }
 
 func (a IPv6Fragment) Inc() {
-	ipv6fragMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ipv6fragMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsIPv6Fragment)]...).Inc()
 }
 
 func (a *IPv6Fragment) SetPacketContext(ctx *PacketContext) {
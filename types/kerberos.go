@@ -0,0 +1,110 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsKerberos = []string{
+	"Timestamp",
+	"MsgType",
+	"Transport",
+	"ClientRealm",
+	"ClientName",
+	"Realm",
+	"ServiceName",
+	"KDCOptions",
+	"EncryptionTypes",
+	"PADataTypes",
+	"PreAuthEtype",
+	"TicketEtype",
+	"EncPartEtype",
+	"ErrorCode",
+	"ErrorName",
+	"ErrorText",
+	"WeakEtype",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (k Kerberos) CSVHeader() []string {
+	return filter(fieldsKerberos)
+}
+
+func (k Kerberos) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(k.Timestamp),
+		k.MsgType,
+		k.Transport,
+		k.ClientRealm,
+		k.ClientName,
+		k.Realm,
+		k.ServiceName,
+		formatUint32(k.KDCOptions),
+		joinInts(k.EncryptionTypes),
+		joinInts(k.PADataTypes),
+		formatInt32(k.PreAuthEtype),
+		formatInt32(k.TicketEtype),
+		formatInt32(k.EncPartEtype),
+		formatInt32(k.ErrorCode),
+		k.ErrorName,
+		k.ErrorText,
+		strconv.FormatBool(k.WeakEtype),
+		k.SrcIP,
+		k.DstIP,
+		formatInt32(k.SrcPort),
+		formatInt32(k.DstPort),
+		k.ConnUID,
+	})
+}
+
+func (k Kerberos) Time() string {
+	return k.Timestamp
+}
+
+func (k Kerberos) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&k)
+}
+
+var kerberosMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Kerberos.String()),
+		Help: Type_NC_Kerberos.String() + " audit records",
+	},
+	[]string{"MsgType", "ErrorName", "WeakEtype"},
+)
+
+func init() {
+	prometheus.MustRegister(kerberosMetric)
+}
+
+func (k Kerberos) Inc() {
+	kerberosMetric.WithLabelValues(k.MsgType, k.ErrorName, strconv.FormatBool(k.WeakEtype)).Inc()
+}
+
+func (k *Kerberos) SetPacketContext(ctx *PacketContext) {}
+
+func (k Kerberos) Src() string {
+	return k.SrcIP
+}
+
+func (k Kerberos) Dst() string {
+	return k.DstIP
+}
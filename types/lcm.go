@@ -37,7 +37,7 @@ var fieldsLCM = []string{
 }
 
 func (a LCM) CSVHeader() []string {
-	return filter(fieldsLCM)
+	return filter(append(fieldsLCM, fieldsContext...))
 }
 
 func (a LCM) CSVRecord() []string {
@@ -45,7 +45,7 @@ func (a LCM) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Magic),             // int32
 		formatInt32(a.SequenceNumber),    // int32
@@ -59,7 +59,7 @@ func (a LCM) CSVRecord() []string {
 		a.Context.DstIP,
 		a.Context.SrcPort,
 		a.Context.DstPort,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a LCM) Time() string {
@@ -83,7 +83,7 @@ func init() {
 }
 
 func (a LCM) Inc() {
-	lcmMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	lcmMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsLCM)]...).Inc()
 }
 
 func (a *LCM) SetPacketContext(ctx *PacketContext) {
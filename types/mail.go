@@ -0,0 +1,226 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsMail = []string{
+	"Timestamp",
+	"Protocol",
+	"Command",
+	"EnvelopeFrom",
+	"EnvelopeTo",
+	"From",
+	"To",
+	"Cc",
+	"Subject",
+	"MessageID",
+	"Date",
+	"XMailer",
+	"ContentType",
+	"Length",
+	"SHA256",
+	"Attachments",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (m Mail) CSVHeader() []string {
+	return filter(fieldsMail)
+}
+
+func (m Mail) CSVRecord() []string {
+	var attachments strings.Builder
+	for _, a := range m.Attachments {
+		attachments.WriteString(a.ToString())
+	}
+	return filter([]string{
+		formatTimestamp(m.Timestamp),
+		m.Protocol,
+		m.Command,
+		m.EnvelopeFrom,
+		join(m.EnvelopeTo...),
+		m.From,
+		join(m.To...),
+		join(m.Cc...),
+		m.Subject,
+		m.MessageID,
+		m.Date,
+		m.XMailer,
+		m.ContentType,
+		formatInt64(m.Length),
+		m.SHA256,
+		attachments.String(),
+		m.SrcIP,
+		m.DstIP,
+		formatInt32(m.SrcPort),
+		formatInt32(m.DstPort),
+		m.ConnUID,
+	})
+}
+
+func (a MailAttachment) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(a.Name)
+	b.WriteString(Separator)
+	b.WriteString(a.ContentType)
+	b.WriteString(Separator)
+	b.WriteString(a.MIME)
+	b.WriteString(Separator)
+	b.WriteString(formatInt64(a.Length))
+	b.WriteString(Separator)
+	b.WriteString(a.SHA256)
+	b.WriteString(Separator)
+	b.WriteString(a.Location)
+	b.WriteString(End)
+	return b.String()
+}
+
+func (m Mail) Time() string {
+	return m.Timestamp
+}
+
+func (m Mail) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&m)
+}
+
+var mailMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Mail.String()),
+		Help: Type_NC_Mail.String() + " audit records",
+	},
+	[]string{"Protocol", "Command", "Attachments"},
+)
+
+func init() {
+	prometheus.MustRegister(mailMetric)
+}
+
+func (m Mail) Inc() {
+	mailMetric.WithLabelValues(m.Protocol, m.Command, strconv.Itoa(len(m.Attachments))).Inc()
+}
+
+func (m *Mail) SetPacketContext(ctx *PacketContext) {}
+
+func (m Mail) Src() string {
+	return m.SrcIP
+}
+
+func (m Mail) Dst() string {
+	return m.DstIP
+}
+
+var fieldsMailSession = []string{
+	"Timestamp",
+	"TimestampLast",
+	"Protocol",
+	"Banner",
+	"Hostname",
+	"Commands",
+	"AuthMechanisms",
+	"AuthMechanism",
+	"AuthUser",
+	"StartTLS",
+	"NumMessages",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (s MailSession) CSVHeader() []string {
+	return filter(fieldsMailSession)
+}
+
+func (s MailSession) CSVRecord() []string {
+	var commands strings.Builder
+	for _, c := range s.Commands {
+		commands.WriteString(c.ToString())
+	}
+	return filter([]string{
+		formatTimestamp(s.Timestamp),
+		formatTimestamp(s.TimestampLast),
+		s.Protocol,
+		s.Banner,
+		s.Hostname,
+		commands.String(),
+		join(s.AuthMechanisms...),
+		s.AuthMechanism,
+		s.AuthUser,
+		strconv.FormatBool(s.StartTLS),
+		formatInt32(s.NumMessages),
+		s.SrcIP,
+		s.DstIP,
+		formatInt32(s.SrcPort),
+		formatInt32(s.DstPort),
+		s.ConnUID,
+	})
+}
+
+func (c MailCommand) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(formatTimestamp(c.Timestamp))
+	b.WriteString(Separator)
+	b.WriteString(c.Command)
+	b.WriteString(Separator)
+	b.WriteString(c.Argument)
+	b.WriteString(Separator)
+	b.WriteString(c.Status)
+	b.WriteString(End)
+	return b.String()
+}
+
+func (s MailSession) Time() string {
+	return s.Timestamp
+}
+
+func (s MailSession) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&s)
+}
+
+var mailSessionMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_MailSession.String()),
+		Help: Type_NC_MailSession.String() + " audit records",
+	},
+	[]string{"Protocol", "AuthMechanism", "StartTLS"},
+)
+
+func init() {
+	prometheus.MustRegister(mailSessionMetric)
+}
+
+func (s MailSession) Inc() {
+	mailSessionMetric.WithLabelValues(s.Protocol, s.AuthMechanism, strconv.FormatBool(s.StartTLS)).Inc()
+}
+
+func (s *MailSession) SetPacketContext(ctx *PacketContext) {}
+
+func (s MailSession) Src() string {
+	return s.SrcIP
+}
+
+func (s MailSession) Dst() string {
+	return s.DstIP
+}
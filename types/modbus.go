@@ -28,6 +28,7 @@ var fieldsModbus = []string{
 	"Length",        // int32
 	"UnitID",        // int32
 	"Payload",       // []byte
+	"PayloadHash",   // string
 	"Exception",     // bool
 	"FunctionCode",  // int32
 	"SrcIP",
@@ -36,8 +37,25 @@ var fieldsModbus = []string{
 	"DstPort",
 }
 
+var fieldsModbusDetails = []string{
+	"FunctionName",
+	"Request",
+	"ExceptionCode",
+	"ExceptionName",
+	"ReadAddress",
+	"ReadQuantity",
+	"WriteAddress",
+	"WriteQuantity",
+	"Values",
+	"SubFunction",
+	"SubFunctionName",
+	"Control",
+	"Paired",
+	"Latency",
+}
+
 func (a Modbus) CSVHeader() []string {
-	return filter(fieldsModbus)
+	return filter(append(append(fieldsModbus, fieldsModbusDetails...), fieldsContext...))
 }
 
 func (a Modbus) CSVRecord() []string {
@@ -45,20 +63,35 @@ func (a Modbus) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.TransactionID), // int32
 		formatInt32(a.ProtocolID),    // int32
 		formatInt32(a.Length),        // int32
 		formatInt32(a.UnitID),        // int32
 		hex.EncodeToString(a.Payload),
+		a.PayloadHash,
 		strconv.FormatBool(a.Exception),
 		formatInt32(a.FunctionCode),
 		a.Context.SrcIP,
 		a.Context.DstIP,
 		a.Context.SrcPort,
 		a.Context.DstPort,
-	})
+		a.FunctionName,
+		strconv.FormatBool(a.Request),
+		formatInt32(a.ExceptionCode),
+		a.ExceptionName,
+		formatInt32(a.ReadAddress),
+		formatInt32(a.ReadQuantity),
+		formatInt32(a.WriteAddress),
+		formatInt32(a.WriteQuantity),
+		joinUints(a.Values),
+		formatInt32(a.SubFunction),
+		a.SubFunctionName,
+		strconv.FormatBool(a.Control),
+		strconv.FormatBool(a.Paired),
+		formatInt64(a.Latency),
+	}, a.Context.identifiers()...))
 }
 
 func (a Modbus) Time() string {
@@ -82,7 +115,7 @@ func init() {
 }
 
 func (a Modbus) Inc() {
-	modbusTcpMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	modbusTcpMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsModbus)]...).Inc()
 }
 
 func (a *Modbus) SetPacketContext(ctx *PacketContext) {
@@ -0,0 +1,126 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsModbusDevice = []string{
+	"Timestamp",
+	"TimestampLast",
+	"ServerIP",
+	"UnitID",
+	"Clients",
+	"NumRequests",
+	"NumResponses",
+	"NumExceptions",
+	"NumUnanswered",
+	"Functions",
+	"Registers",
+}
+
+func (d ModbusDevice) CSVHeader() []string {
+	return filter(fieldsModbusDevice)
+}
+
+func (d ModbusDevice) CSVRecord() []string {
+	var functions, registers strings.Builder
+	for _, f := range d.Functions {
+		functions.WriteString(f.ToString())
+	}
+	for _, r := range d.Registers {
+		registers.WriteString(r.ToString())
+	}
+	return filter([]string{
+		formatTimestamp(d.Timestamp),
+		formatTimestamp(d.TimestampLast),
+		d.ServerIP,
+		formatInt32(d.UnitID),
+		join(d.Clients...),
+		formatInt64(d.NumRequests),
+		formatInt64(d.NumResponses),
+		formatInt64(d.NumExceptions),
+		formatInt64(d.NumUnanswered),
+		functions.String(),
+		registers.String(),
+	})
+}
+
+func (f ModbusFunction) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(formatInt32(f.FunctionCode))
+	b.WriteString(Separator)
+	b.WriteString(f.Name)
+	b.WriteString(Separator)
+	b.WriteString(formatInt64(f.Requests))
+	b.WriteString(Separator)
+	b.WriteString(formatInt64(f.Exceptions))
+	b.WriteString(End)
+	return b.String()
+}
+
+func (r ModbusRegister) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(r.Table)
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(r.Address))
+	b.WriteString(Separator)
+	b.WriteString(formatInt64(r.Reads))
+	b.WriteString(Separator)
+	b.WriteString(formatInt64(r.Writes))
+	b.WriteString(Separator)
+	b.WriteString(formatInt64(r.Exceptions))
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(r.LastValue))
+	b.WriteString(End)
+	return b.String()
+}
+
+func (d ModbusDevice) Time() string {
+	return d.Timestamp
+}
+
+func (d ModbusDevice) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&d)
+}
+
+var modbusDeviceMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_ModbusDevice.String()),
+		Help: Type_NC_ModbusDevice.String() + " audit records",
+	},
+	[]string{"ServerIP", "UnitID"},
+)
+
+func init() {
+	prometheus.MustRegister(modbusDeviceMetric)
+}
+
+func (d ModbusDevice) Inc() {
+	modbusDeviceMetric.WithLabelValues(d.ServerIP, formatInt32(d.UnitID)).Inc()
+}
+
+func (d *ModbusDevice) SetPacketContext(ctx *PacketContext) {}
+
+func (d ModbusDevice) Src() string {
+	return ""
+}
+
+func (d ModbusDevice) Dst() string {
+	return d.ServerIP
+}
@@ -31,7 +31,7 @@ var fieldsMPLS = []string{
 }
 
 func (a MPLS) CSVHeader() []string {
-	return filter(fieldsMPLS)
+	return filter(append(fieldsMPLS, fieldsContext...))
 }
 
 func (a MPLS) CSVRecord() []string {
@@ -39,7 +39,7 @@ func (a MPLS) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Label),              // int32
 		formatInt32(a.TrafficClass),       // int32
@@ -47,7 +47,7 @@ func (a MPLS) CSVRecord() []string {
 		formatInt32(a.TTL),                // int32
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a MPLS) Time() string {
@@ -71,7 +71,7 @@ func init() {
 }
 
 func (a MPLS) Inc() {
-	mplsMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	mplsMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsMPLS)]...).Inc()
 }
 
 func (a *MPLS) SetPacketContext(ctx *PacketContext) {
@@ -0,0 +1,91 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsNTLM = []string{
+	"Timestamp",
+	"MessageType",
+	"Flags",
+	"User",
+	"Domain",
+	"Workstation",
+	"TargetName",
+	"Protocol",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (n NTLM) CSVHeader() []string {
+	return filter(fieldsNTLM)
+}
+
+func (n NTLM) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(n.Timestamp),
+		n.MessageType,
+		formatUint32(n.Flags),
+		n.User,
+		n.Domain,
+		n.Workstation,
+		n.TargetName,
+		n.Protocol,
+		n.SrcIP,
+		n.DstIP,
+		formatInt32(n.SrcPort),
+		formatInt32(n.DstPort),
+		n.ConnUID,
+	})
+}
+
+func (n NTLM) Time() string {
+	return n.Timestamp
+}
+
+func (n NTLM) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&n)
+}
+
+var ntlmMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_NTLM.String()),
+		Help: Type_NC_NTLM.String() + " audit records",
+	},
+	[]string{"MessageType", "Protocol"},
+)
+
+func init() {
+	prometheus.MustRegister(ntlmMetric)
+}
+
+func (n NTLM) Inc() {
+	ntlmMetric.WithLabelValues(n.MessageType, n.Protocol).Inc()
+}
+
+func (n *NTLM) SetPacketContext(ctx *PacketContext) {}
+
+func (n NTLM) Src() string {
+	return n.SrcIP
+}
+
+func (n NTLM) Dst() string {
+	return n.DstIP
+}
@@ -44,7 +44,7 @@ var fieldsNTP = []string{
 }
 
 func (n NTP) CSVHeader() []string {
-	return filter(fieldsNTP)
+	return filter(append(fieldsNTP, fieldsContext...))
 }
 
 func (n NTP) CSVRecord() []string {
@@ -52,7 +52,7 @@ func (n NTP) CSVRecord() []string {
 	if n.Context == nil {
 		n.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(n.Timestamp),
 		formatInt32(n.LeapIndicator),                         // int32
 		formatInt32(n.Version),                               // int32
@@ -72,7 +72,7 @@ func (n NTP) CSVRecord() []string {
 		n.Context.DstIP,
 		n.Context.SrcPort,
 		n.Context.DstPort,
-	})
+	}, n.Context.identifiers()...))
 }
 
 func (n NTP) Time() string {
@@ -96,7 +96,7 @@ func init() {
 }
 
 func (a NTP) Inc() {
-	ntpMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ntpMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsNTP)]...).Inc()
 }
 
 func (a *NTP) SetPacketContext(ctx *PacketContext) {
@@ -40,7 +40,7 @@ var fieldsOSPFv2 = []string{
 }
 
 func (a OSPFv2) CSVHeader() []string {
-	return filter(fieldsOSPFv2)
+	return filter(append(fieldsOSPFv2, fieldsContext...))
 }
 
 func (a OSPFv2) CSVRecord() []string {
@@ -58,7 +58,7 @@ func (a OSPFv2) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Version),        // int32
 		formatInt32(a.Type),           // int32
@@ -75,7 +75,7 @@ func (a OSPFv2) CSVRecord() []string {
 		toString(a.HelloV2),           // *HelloPkgV2
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a OSPFv2) Time() string {
@@ -464,7 +464,7 @@ func init() {
 }
 
 func (a OSPFv2) Inc() {
-	ospf2Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ospf2Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsOSPFv2)]...).Inc()
 }
 
 func (a OSPFv2) JSON() (string, error) {
@@ -39,7 +39,7 @@ var fieldsOSPFv3 = []string{
 }
 
 func (a OSPFv3) CSVHeader() []string {
-	return filter(fieldsOSPFv3)
+	return filter(append(fieldsOSPFv3, fieldsContext...))
 }
 
 func (a OSPFv3) CSVRecord() []string {
@@ -57,7 +57,7 @@ func (a OSPFv3) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Version),      // int32
 		formatInt32(a.Type),         // int32
@@ -74,7 +74,7 @@ func (a OSPFv3) CSVRecord() []string {
 		join(lsas...),               // []*LSAheader
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a OSPFv3) Time() string {
@@ -123,7 +123,7 @@ func init() {
 }
 
 func (a OSPFv3) Inc() {
-	ospf3Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	ospf3Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsOSPFv3)]...).Inc()
 }
 
 func (a OSPFv3) JSON() (string, error) {
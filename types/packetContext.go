@@ -0,0 +1,46 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+// fieldsContext are the identifiers from the packet context,
+// that are appended to the CSV output of records with a packet context.
+// they are unique per flow or packet and therefore not used as metric labels.
+var fieldsContext = []string{
+	"CommunityID",
+	"ConnUID",
+	"FlowUID",
+	"PacketNumber",
+}
+
+// identifiers returns the values for the fieldsContext columns
+func (c *PacketContext) identifiers() []string {
+	if c == nil {
+		c = &PacketContext{}
+	}
+	return []string{
+		c.CommunityID,
+		c.ConnUID,
+		c.FlowUID,
+		formatInt64(c.PacketNumber),
+	}
+}
+
+// identify copies the identifiers from ctx,
+// used for contexts that only hold information not present on the record itself
+func (c *PacketContext) identify(ctx *PacketContext) {
+	c.CommunityID = ctx.CommunityID
+	c.ConnUID = ctx.ConnUID
+	c.FlowUID = ctx.FlowUID
+	c.PacketNumber = ctx.PacketNumber
+}
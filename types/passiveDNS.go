@@ -0,0 +1,77 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsPassiveDNS = []string{
+	"Timestamp",
+	"TimestampLast",
+	"Name",
+	"Type",
+	"Answer",
+	"Count",
+}
+
+func (p PassiveDNS) CSVHeader() []string {
+	return filter(fieldsPassiveDNS)
+}
+
+func (p PassiveDNS) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(p.Timestamp),
+		formatTimestamp(p.TimestampLast),
+		p.Name,
+		p.Type,
+		p.Answer,
+		formatInt64(p.Count),
+	})
+}
+
+func (p PassiveDNS) Time() string {
+	return p.Timestamp
+}
+
+func (p PassiveDNS) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&p)
+}
+
+var passiveDNSMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_PassiveDNS.String()),
+		Help: Type_NC_PassiveDNS.String() + " audit records",
+	},
+	[]string{"Type"},
+)
+
+func init() {
+	prometheus.MustRegister(passiveDNSMetric)
+}
+
+func (p PassiveDNS) Inc() {
+	passiveDNSMetric.WithLabelValues(p.Type).Inc()
+}
+
+func (p *PassiveDNS) SetPacketContext(ctx *PacketContext) {}
+
+func (p PassiveDNS) Src() string {
+	return ""
+}
+
+func (p PassiveDNS) Dst() string {
+	return ""
+}
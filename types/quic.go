@@ -0,0 +1,99 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsQUIC = []string{
+	"Timestamp",
+	"Version",
+	"DCID",
+	"SCID",
+	"TokenLength",
+	"SNI",
+	"ALPNs",
+	"Ja3",
+	"CipherSuites",
+	"Extensions",
+	"SupportedGroups",
+	"TransportParameters",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (q QUIC) CSVHeader() []string {
+	return filter(fieldsQUIC)
+}
+
+func (q QUIC) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(q.Timestamp),
+		q.Version,
+		q.DCID,
+		q.SCID,
+		formatInt32(q.TokenLength),
+		q.SNI,
+		join(q.ALPNs...),
+		q.Ja3,
+		joinInts(q.CipherSuites),
+		joinInts(q.Extensions),
+		joinInts(q.SupportedGroups),
+		joinMap(q.TransportParameters),
+		q.SrcIP,
+		q.DstIP,
+		formatInt32(q.SrcPort),
+		formatInt32(q.DstPort),
+		q.ConnUID,
+	})
+}
+
+func (q QUIC) Time() string {
+	return q.Timestamp
+}
+
+func (q QUIC) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&q)
+}
+
+var quicMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_QUIC.String()),
+		Help: Type_NC_QUIC.String() + " audit records",
+	},
+	[]string{"Version", "Ja3"},
+)
+
+func init() {
+	prometheus.MustRegister(quicMetric)
+}
+
+func (q QUIC) Inc() {
+	quicMetric.WithLabelValues(q.Version, q.Ja3).Inc()
+}
+
+func (q *QUIC) SetPacketContext(ctx *PacketContext) {}
+
+func (q QUIC) Src() string {
+	return q.SrcIP
+}
+
+func (q QUIC) Dst() string {
+	return q.DstIP
+}
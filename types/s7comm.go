@@ -0,0 +1,134 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsS7Comm = []string{
+	"Timestamp",
+	"COTPType",
+	"SrcTSAP",
+	"DstTSAP",
+	"ProtocolID",
+	"ROSCTR",
+	"PDUReference",
+	"ErrorClass",
+	"ErrorCode",
+	"FunctionCode",
+	"FunctionName",
+	"Items",
+	"ReturnCodes",
+	"Block",
+	"PIService",
+	"UserdataGroup",
+	"UserdataSubfunction",
+	"PDULength",
+	"Control",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (s S7Comm) CSVHeader() []string {
+	return filter(fieldsS7Comm)
+}
+
+func (s S7Comm) CSVRecord() []string {
+	var items []string
+	for _, i := range s.Items {
+		items = append(items, i.ToString())
+	}
+	return filter([]string{
+		formatTimestamp(s.Timestamp),
+		s.COTPType,
+		s.SrcTSAP,
+		s.DstTSAP,
+		formatInt32(s.ProtocolID),
+		s.ROSCTR,
+		formatInt32(s.PDUReference),
+		formatInt32(s.ErrorClass),
+		formatInt32(s.ErrorCode),
+		formatInt32(s.FunctionCode),
+		s.FunctionName,
+		strings.Join(items, ""),
+		joinInts(s.ReturnCodes),
+		s.Block,
+		s.PIService,
+		s.UserdataGroup,
+		formatInt32(s.UserdataSubfunction),
+		formatInt32(s.PDULength),
+		strconv.FormatBool(s.Control),
+		s.SrcIP,
+		s.DstIP,
+		formatInt32(s.SrcPort),
+		formatInt32(s.DstPort),
+		s.ConnUID,
+	})
+}
+
+func (i S7Item) ToString() string {
+	var b strings.Builder
+	b.WriteString(Begin)
+	b.WriteString(i.Area)
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(i.DBNumber))
+	b.WriteString(Separator)
+	b.WriteString(i.Address)
+	b.WriteString(Separator)
+	b.WriteString(i.TransportSize)
+	b.WriteString(Separator)
+	b.WriteString(formatInt32(i.Length))
+	b.WriteString(End)
+	return b.String()
+}
+
+func (s S7Comm) Time() string {
+	return s.Timestamp
+}
+
+func (s S7Comm) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&s)
+}
+
+var s7CommMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_S7Comm.String()),
+		Help: Type_NC_S7Comm.String() + " audit records",
+	},
+	[]string{"COTPType", "ROSCTR", "FunctionName", "DstIP"},
+)
+
+func init() {
+	prometheus.MustRegister(s7CommMetric)
+}
+
+func (s S7Comm) Inc() {
+	s7CommMetric.WithLabelValues(s.COTPType, s.ROSCTR, s.FunctionName, s.DstIP).Inc()
+}
+
+func (s *S7Comm) SetPacketContext(ctx *PacketContext) {}
+
+func (s S7Comm) Src() string {
+	return s.SrcIP
+}
+
+func (s S7Comm) Dst() string {
+	return s.DstIP
+}
@@ -0,0 +1,98 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsScanEvent = []string{
+	"Timestamp",
+	"TimestampLast",
+	"SrcIP",
+	"ScanType",
+	"Kind",
+	"Targets",
+	"NumTargets",
+	"Ports",
+	"NumPorts",
+	"NumProbes",
+	"NumAnswered",
+	"NumOpen",
+	"Duration",
+	"Rate",
+}
+
+func (s ScanEvent) CSVHeader() []string {
+	return filter(fieldsScanEvent)
+}
+
+func (s ScanEvent) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(s.Timestamp),
+		formatTimestamp(s.TimestampLast),
+		s.SrcIP,
+		s.ScanType,
+		s.Kind,
+		join(s.Targets...),
+		formatInt32(s.NumTargets),
+		strings.Replace(s.Ports, ",", "(comma)", -1),
+		formatInt32(s.NumPorts),
+		formatInt64(s.NumProbes),
+		formatInt64(s.NumAnswered),
+		formatInt64(s.NumOpen),
+		formatInt64(s.Duration),
+		formatFloat64(s.Rate),
+	})
+}
+
+func (s ScanEvent) Time() string {
+	return s.Timestamp
+}
+
+func (s ScanEvent) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&s)
+}
+
+var scanEventMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_ScanEvent.String()),
+		Help: Type_NC_ScanEvent.String() + " audit records",
+	},
+	fieldsScanEvent[1:],
+)
+
+func init() {
+	prometheus.MustRegister(scanEventMetric)
+}
+
+func (s ScanEvent) Inc() {
+	scanEventMetric.WithLabelValues(s.CSVRecord()[1:]...).Inc()
+}
+
+func (s *ScanEvent) SetPacketContext(ctx *PacketContext) {}
+
+func (s ScanEvent) Src() string {
+	return s.SrcIP
+}
+
+// Dst returns the target if a single host has been scanned.
+func (s ScanEvent) Dst() string {
+	if len(s.Targets) == 1 {
+		return s.Targets[0]
+	}
+	return ""
+}
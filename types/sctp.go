@@ -31,7 +31,7 @@ var fieldsSCTP = []string{
 }
 
 func (s SCTP) CSVHeader() []string {
-	return filter(fieldsSCTP)
+	return filter(append(fieldsSCTP, fieldsContext...))
 }
 
 func (s SCTP) CSVRecord() []string {
@@ -39,7 +39,7 @@ func (s SCTP) CSVRecord() []string {
 	if s.Context == nil {
 		s.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(s.Timestamp),
 		strconv.FormatUint(uint64(s.SrcPort), 10),
 		strconv.FormatUint(uint64(s.DstPort), 10),
@@ -47,7 +47,7 @@ func (s SCTP) CSVRecord() []string {
 		strconv.FormatUint(uint64(s.Checksum), 10),
 		s.Context.SrcIP,
 		s.Context.DstIP,
-	})
+	}, s.Context.identifiers()...))
 }
 
 func (s SCTP) Time() string {
@@ -71,7 +71,7 @@ func init() {
 }
 
 func (a SCTP) Inc() {
-	sctpMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	sctpMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsSCTP)]...).Inc()
 }
 
 func (a *SCTP) SetPacketContext(ctx *PacketContext) {
@@ -82,6 +82,7 @@ func (a *SCTP) SetPacketContext(ctx *PacketContext) {
 		SrcPort: ctx.SrcPort,
 		DstPort: ctx.DstPort,
 	}
+	a.Context.identify(ctx)
 }
 
 func (a SCTP) Src() string {
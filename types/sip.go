@@ -35,7 +35,7 @@ var fieldsSIP = []string{
 }
 
 func (s SIP) CSVHeader() []string {
-	return filter(fieldsSIP)
+	return filter(append(fieldsSIP, fieldsContext...))
 }
 
 func (s SIP) CSVRecord() []string {
@@ -43,7 +43,7 @@ func (s SIP) CSVRecord() []string {
 	if s.Context == nil {
 		s.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(s.Timestamp),
 		formatInt32(s.Version),           //  int32 `protobuf:"varint,2,opt,name=Version,proto3" json:"Version,omitempty"`
 		formatInt32(s.Method),            //   int32 `protobuf:"varint,3,opt,name=Method,proto3" json:"Method,omitempty"`
@@ -55,7 +55,7 @@ func (s SIP) CSVRecord() []string {
 		s.Context.DstIP,
 		s.Context.SrcPort,
 		s.Context.DstPort,
-	})
+	}, s.Context.identifiers()...))
 }
 
 func (s SIP) Time() string {
@@ -79,7 +79,7 @@ func init() {
 }
 
 func (a SIP) Inc() {
-	sipMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	sipMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsSIP)]...).Inc()
 }
 
 func (a *SIP) SetPacketContext(ctx *PacketContext) {
@@ -0,0 +1,114 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsSMB = []string{
+	"Timestamp",
+	"Command",
+	"Dialect",
+	"Status",
+	"SessionID",
+	"TreeID",
+	"MessageID",
+	"User",
+	"Domain",
+	"Host",
+	"Share",
+	"ShareType",
+	"FileName",
+	"AccessMask",
+	"Disposition",
+	"Offset",
+	"Length",
+	"IsPipe",
+	"CtlCode",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"ConnUID",
+}
+
+func (s SMB) CSVHeader() []string {
+	return filter(fieldsSMB)
+}
+
+func (s SMB) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(s.Timestamp),
+		s.Command,
+		s.Dialect,
+		s.Status,
+		formatUint64(s.SessionID),
+		formatUint32(s.TreeID),
+		formatUint64(s.MessageID),
+		s.User,
+		s.Domain,
+		s.Host,
+		s.Share,
+		s.ShareType,
+		s.FileName,
+		formatUint32(s.AccessMask),
+		formatUint32(s.Disposition),
+		formatUint64(s.Offset),
+		formatUint64(s.Length),
+		strconv.FormatBool(s.IsPipe),
+		formatUint32(s.CtlCode),
+		s.SrcIP,
+		s.DstIP,
+		formatInt32(s.SrcPort),
+		formatInt32(s.DstPort),
+		s.ConnUID,
+	})
+}
+
+func (s SMB) Time() string {
+	return s.Timestamp
+}
+
+func (s SMB) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&s)
+}
+
+var smbMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_SMB.String()),
+		Help: Type_NC_SMB.String() + " audit records",
+	},
+	[]string{"Command", "Status", "ShareType"},
+)
+
+func init() {
+	prometheus.MustRegister(smbMetric)
+}
+
+func (s SMB) Inc() {
+	smbMetric.WithLabelValues(s.Command, s.Status, s.ShareType).Inc()
+}
+
+func (s *SMB) SetPacketContext(ctx *PacketContext) {}
+
+func (s SMB) Src() string {
+	return s.SrcIP
+}
+
+func (s SMB) Dst() string {
+	return s.DstIP
+}
@@ -45,12 +45,13 @@ var fieldsTCP = []string{
 	"PayloadEntropy",
 	"PayloadSize",
 	"Payload",
+	"PayloadHash",
 	"SrcIP",
 	"DstIP",
 }
 
 func (t TCP) CSVHeader() []string {
-	return filter(fieldsTCP)
+	return filter(append(fieldsTCP, fieldsContext...))
 }
 
 func (t TCP) CSVRecord() []string {
@@ -58,7 +59,7 @@ func (t TCP) CSVRecord() []string {
 	if t.Context == nil {
 		t.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(t.Timestamp),                      // string
 		formatInt32(t.SrcPort),                            // int32
 		formatInt32(t.DstPort),                            // int32
@@ -82,9 +83,10 @@ func (t TCP) CSVRecord() []string {
 		strconv.FormatFloat(t.PayloadEntropy, 'f', 8, 64), // float64
 		formatInt32(t.PayloadSize),                        // int32
 		hex.EncodeToString(t.Payload),
+		t.PayloadHash,
 		t.Context.SrcIP,
 		t.Context.DstIP,
-	})
+	}, t.Context.identifiers()...))
 }
 
 func (t TCP) GetOptionString() string {
@@ -201,6 +203,7 @@ func (a *TCP) SetPacketContext(ctx *PacketContext) {
 		SrcIP: ctx.SrcIP,
 		DstIP: ctx.DstIP,
 	}
+	a.Context.identify(ctx)
 }
 
 func (a TCP) Src() string {
@@ -49,6 +49,8 @@ var fieldsTLSClientHello = []string{
 	"DstMAC",
 	"SrcPort",
 	"DstPort",
+	"CommunityID",
+	"DstHostname",
 }
 
 func (t TLSClientHello) CSVHeader() []string {
@@ -84,6 +86,8 @@ func (t TLSClientHello) CSVRecord() []string {
 		t.DstMAC,
 		formatInt32(t.SrcPort),
 		formatInt32(t.DstPort),
+		t.CommunityID,
+		t.DstHostname,
 	})
 }
 
@@ -30,12 +30,13 @@ var fieldsUDP = []string{
 	"PayloadEntropy",
 	"PayloadSize",
 	"Payload",
+	"PayloadHash",
 	"SrcIP",
 	"DstIP",
 }
 
 func (u UDP) CSVHeader() []string {
-	return filter(fieldsUDP)
+	return filter(append(fieldsUDP, fieldsContext...))
 }
 
 func (u UDP) CSVRecord() []string {
@@ -43,7 +44,7 @@ func (u UDP) CSVRecord() []string {
 	if u.Context == nil {
 		u.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(u.Timestamp),                      // string
 		formatInt32(u.SrcPort),                            // int32
 		formatInt32(u.DstPort),                            // int32
@@ -52,9 +53,10 @@ func (u UDP) CSVRecord() []string {
 		strconv.FormatFloat(u.PayloadEntropy, 'f', 8, 64), // float64
 		formatInt32(u.PayloadSize),                        // int32
 		hex.EncodeToString(u.Payload),
+		u.PayloadHash,
 		u.Context.SrcIP,
 		u.Context.DstIP,
-	})
+	}, u.Context.identifiers()...))
 }
 
 func (u UDP) Time() string {
@@ -125,6 +127,7 @@ func (a *UDP) SetPacketContext(ctx *PacketContext) {
 		SrcIP: ctx.SrcIP,
 		DstIP: ctx.DstIP,
 	}
+	a.Context.identify(ctx)
 }
 
 func (a UDP) Src() string {
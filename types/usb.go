@@ -42,6 +42,7 @@ var fieldsUSB = []string{
 	"UrbCopyOfTransferFlags",
 	"IsoNumDesc",
 	"Payload",
+	"PayloadHash",
 }
 
 func (u USB) CSVHeader() []string {
@@ -70,6 +71,7 @@ func (u USB) CSVRecord() []string {
 		formatUint32(u.UrbCopyOfTransferFlags),
 		formatUint32(u.IsoNumDesc),
 		hex.EncodeToString(u.Payload),
+		u.PayloadHash,
 	})
 }
 
@@ -15,6 +15,7 @@ package types
 
 import (
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -107,6 +108,26 @@ func join(a ...string) string {
 	return b.String()
 }
 
+// joinMap formats the map sorted by key as (key:value)(key:value)
+// commas are replaced, to avoid breaking the CSV
+func joinMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(Begin)
+		b.WriteString(k)
+		b.WriteString(":")
+		b.WriteString(strings.Replace(m[k], ",", "(comma)", -1))
+		b.WriteString(End)
+	}
+	return b.String()
+}
+
 func formatTimestamp(ts string) string {
 	if UTC {
 		return utils.TimeToUTC(ts)
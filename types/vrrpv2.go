@@ -35,7 +35,7 @@ var fieldsVRRPv2 = []string{
 }
 
 func (a VRRPv2) CSVHeader() []string {
-	return filter(fieldsVRRPv2)
+	return filter(append(fieldsVRRPv2, fieldsContext...))
 }
 
 func (a VRRPv2) CSVRecord() []string {
@@ -43,7 +43,7 @@ func (a VRRPv2) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		formatInt32(a.Version),      // int32
 		formatInt32(a.Type),         // int32
@@ -56,7 +56,7 @@ func (a VRRPv2) CSVRecord() []string {
 		join(a.IPAddress...),        // []string
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a VRRPv2) Time() string {
@@ -80,7 +80,7 @@ func init() {
 }
 
 func (a VRRPv2) Inc() {
-	vrrp2Metric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	vrrp2Metric.WithLabelValues(a.CSVRecord()[1:len(fieldsVRRPv2)]...).Inc()
 }
 
 func (a *VRRPv2) SetPacketContext(ctx *PacketContext) {
@@ -33,7 +33,7 @@ var fieldsVXLAN = []string{
 }
 
 func (a VXLAN) CSVHeader() []string {
-	return filter(fieldsVXLAN)
+	return filter(append(fieldsVXLAN, fieldsContext...))
 }
 
 func (a VXLAN) CSVRecord() []string {
@@ -41,7 +41,7 @@ func (a VXLAN) CSVRecord() []string {
 	if a.Context == nil {
 		a.Context = &PacketContext{}
 	}
-	return filter([]string{
+	return filter(append([]string{
 		formatTimestamp(a.Timestamp),
 		strconv.FormatBool(a.ValidIDFlag),  //  bool
 		formatUint32(a.VNI),                //  uint32
@@ -51,7 +51,7 @@ func (a VXLAN) CSVRecord() []string {
 		formatInt32(a.GBPGroupPolicyID),    //  int32
 		a.Context.SrcIP,
 		a.Context.DstIP,
-	})
+	}, a.Context.identifiers()...))
 }
 
 func (a VXLAN) Time() string {
@@ -75,7 +75,7 @@ func init() {
 }
 
 func (a VXLAN) Inc() {
-	vxlanMetric.WithLabelValues(a.CSVRecord()[1:]...).Inc()
+	vxlanMetric.WithLabelValues(a.CSVRecord()[1:len(fieldsVXLAN)]...).Inc()
 }
 
 func (a *VXLAN) SetPacketContext(ctx *PacketContext) {
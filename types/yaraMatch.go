@@ -0,0 +1,94 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsYARAMatch = []string{
+	"Timestamp",
+	"Rule",
+	"Tags",
+	"Strings",
+	"Description",
+	"Source",
+	"Protocol",
+	"SrcIP",
+	"DstIP",
+	"SrcPort",
+	"DstPort",
+	"FlowUID",
+	"Length",
+	"ConnUID",
+}
+
+func (y YARAMatch) CSVHeader() []string {
+	return filter(fieldsYARAMatch)
+}
+
+func (y YARAMatch) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(y.Timestamp),
+		y.Rule,
+		join(y.Tags...),
+		join(y.Strings...),
+		y.Description,
+		y.Source,
+		y.Protocol,
+		y.SrcIP,
+		y.DstIP,
+		formatInt32(y.SrcPort),
+		formatInt32(y.DstPort),
+		y.FlowUID,
+		formatInt32(y.Length),
+		y.ConnUID,
+	})
+}
+
+func (y YARAMatch) Time() string {
+	return y.Timestamp
+}
+
+func (y YARAMatch) JSON() (string, error) {
+	return jsonMarshaler.MarshalToString(&y)
+}
+
+var yaraMatchMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_YARAMatch.String()),
+		Help: Type_NC_YARAMatch.String() + " audit records",
+	},
+	fieldsYARAMatch[1:],
+)
+
+func init() {
+	prometheus.MustRegister(yaraMatchMetric)
+}
+
+func (y YARAMatch) Inc() {
+	yaraMatchMetric.WithLabelValues(y.CSVRecord()[1:]...).Inc()
+}
+
+func (y *YARAMatch) SetPacketContext(ctx *PacketContext) {}
+
+func (y YARAMatch) Src() string {
+	return y.SrcIP
+}
+
+func (y YARAMatch) Dst() string {
+	return y.DstIP
+}
@@ -0,0 +1,180 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+// IP protocol numbers used for the community id
+const (
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+	protoSCTP   = 132
+)
+
+// icmp types and their counterpart, used to make icmp request and response map to the same community id
+var (
+	icmp4Pairs = map[uint8]uint8{
+		0:  8,  // echo reply / echo request
+		8:  0,  // echo request / echo reply
+		13: 14, // timestamp / timestamp reply
+		14: 13, // timestamp reply / timestamp
+		15: 16, // information request / information reply
+		16: 15, // information reply / information request
+		10: 9,  // router solicitation / router advertisement
+		9:  10, // router advertisement / router solicitation
+		17: 18, // address mask request / address mask reply
+		18: 17, // address mask reply / address mask request
+	}
+	icmp6Pairs = map[uint8]uint8{
+		128: 129, // echo request / echo reply
+		129: 128, // echo reply / echo request
+		133: 134, // router solicitation / router advertisement
+		134: 133, // router advertisement / router solicitation
+		135: 136, // neighbor solicitation / neighbor advertisement
+		136: 135, // neighbor advertisement / neighbor solicitation
+		130: 131, // multicast listener query / multicast listener report
+		131: 130, // multicast listener report / multicast listener query
+		139: 140, // node information query / node information response
+		140: 139, // node information response / node information query
+		144: 145, // home agent address discovery request / reply
+		145: 144, // home agent address discovery reply / request
+		146: 147, // mobile prefix solicitation / advertisement
+		147: 146, // mobile prefix advertisement / solicitation
+	}
+)
+
+// CommunityID calculates the version 1 community id flow hash,
+// as used by Zeek and Suricata, see https://github.com/corelight/community-id-spec.
+// For ICMP, the type and code must be passed as source and destination port.
+func CommunityID(seed uint16, proto uint8, srcIP, dstIP net.IP, srcPort, dstPort uint16) string {
+
+	// use the 4 byte representation for IPv4 addresses
+	if ip := srcIP.To4(); ip != nil {
+		srcIP = ip
+	}
+	if ip := dstIP.To4(); ip != nil {
+		dstIP = ip
+	}
+
+	oneWay := false
+	switch proto {
+	case protoICMP:
+		srcPort, dstPort, oneWay = icmpPorts(icmp4Pairs, srcPort, dstPort)
+	case protoICMPv6:
+		srcPort, dstPort, oneWay = icmpPorts(icmp6Pairs, srcPort, dstPort)
+	}
+
+	// order the endpoints, so that both directions of a flow produce the same hash
+	if !oneWay {
+		if c := bytes.Compare(srcIP, dstIP); c > 0 || (c == 0 && srcPort > dstPort) {
+			srcIP, dstIP = dstIP, srcIP
+			srcPort, dstPort = dstPort, srcPort
+		}
+	}
+
+	var (
+		h   = sha1.New()
+		buf = make([]byte, 2)
+	)
+	binary.BigEndian.PutUint16(buf, seed)
+	h.Write(buf)
+	h.Write(srcIP)
+	h.Write(dstIP)
+	h.Write([]byte{proto, 0})
+
+	switch proto {
+	case protoICMP, protoTCP, protoUDP, protoICMPv6, protoSCTP:
+		binary.BigEndian.PutUint16(buf, srcPort)
+		h.Write(buf)
+		binary.BigEndian.PutUint16(buf, dstPort)
+		h.Write(buf)
+	}
+
+	return "1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// icmpPorts maps the icmp type and code to ports
+// and reports whether the message has no counterpart
+func icmpPorts(pairs map[uint8]uint8, typ, code uint16) (uint16, uint16, bool) {
+	if other, ok := pairs[uint8(typ)]; ok {
+		return typ, uint16(other), false
+	}
+	return typ, code, true
+}
+
+// CommunityIDFromPacket calculates the community id for the network and transport layer of the packet.
+// An empty string is returned for packets without a network layer.
+func CommunityIDFromPacket(seed uint16, p gopacket.Packet) string {
+
+	var (
+		srcIP, dstIP     net.IP
+		proto            uint8
+		srcPort, dstPort uint16
+	)
+
+	switch nl := p.NetworkLayer().(type) {
+	case *layers.IPv4:
+		srcIP, dstIP, proto = nl.SrcIP, nl.DstIP, uint8(nl.Protocol)
+	case *layers.IPv6:
+		srcIP, dstIP, proto = nl.SrcIP, nl.DstIP, uint8(nl.NextHeader)
+	default:
+		return ""
+	}
+
+	switch tl := p.TransportLayer().(type) {
+	case *layers.TCP:
+		proto, srcPort, dstPort = protoTCP, uint16(tl.SrcPort), uint16(tl.DstPort)
+	case *layers.UDP:
+		proto, srcPort, dstPort = protoUDP, uint16(tl.SrcPort), uint16(tl.DstPort)
+	case *layers.SCTP:
+		proto, srcPort, dstPort = protoSCTP, uint16(tl.SrcPort), uint16(tl.DstPort)
+	default:
+		if l := p.Layer(layers.LayerTypeICMPv4); l != nil {
+			tc := l.(*layers.ICMPv4).TypeCode
+			proto, srcPort, dstPort = protoICMP, uint16(tc.Type()), uint16(tc.Code())
+		} else if l := p.Layer(layers.LayerTypeICMPv6); l != nil {
+			tc := l.(*layers.ICMPv6).TypeCode
+			proto, srcPort, dstPort = protoICMPv6, uint16(tc.Type()), uint16(tc.Code())
+		}
+	}
+
+	return CommunityID(seed, proto, srcIP, dstIP, srcPort, dstPort)
+}
+
+// CommunityIDFromFlows calculates the community id for a network and transport flow
+// of the given IP protocol, e.g. for reassembled TCP streams.
+func CommunityIDFromFlows(seed uint16, proto uint8, netFlow, transportFlow gopacket.Flow) string {
+
+	var (
+		src, dst         = transportFlow.Endpoints()
+		srcPort, dstPort uint16
+	)
+	if len(src.Raw()) == 2 && len(dst.Raw()) == 2 {
+		srcPort = binary.BigEndian.Uint16(src.Raw())
+		dstPort = binary.BigEndian.Uint16(dst.Raw())
+	}
+
+	return CommunityID(seed, proto, net.IP(netFlow.Src().Raw()), net.IP(netFlow.Dst().Raw()), srcPort, dstPort)
+}
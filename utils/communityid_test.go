@@ -0,0 +1,60 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+// test vectors from the community id specification baseline
+var communityIDTests = []struct {
+	proto            uint8
+	srcIP, dstIP     string
+	srcPort, dstPort uint16
+	expected         string
+}{
+	{6, "128.232.110.120", "66.35.250.204", 34855, 80, "1:LQU9qZlK+B5F3KDmev6m5PMibrg="},
+	{17, "192.168.1.52", "8.8.8.8", 54585, 53, "1:d/FP5EW3wiY1vCndhwleRRKHowQ="},
+	{1, "192.168.0.89", "192.168.0.1", 8, 0, "1:X0snYXpgwiv9TZtqg64sgzUn6Dk="},
+}
+
+func TestCommunityID(t *testing.T) {
+	for _, c := range communityIDTests {
+		var (
+			src = net.ParseIP(c.srcIP)
+			dst = net.ParseIP(c.dstIP)
+		)
+		if id := CommunityID(0, c.proto, src, dst, c.srcPort, c.dstPort); id != c.expected {
+			t.Fatal("expected", c.expected, "got", id)
+		}
+	}
+}
+
+func TestCommunityIDBidirectional(t *testing.T) {
+	var (
+		a = net.ParseIP("10.0.0.1")
+		b = net.ParseIP("10.0.0.2")
+	)
+	if CommunityID(0, 6, a, b, 4000, 443) != CommunityID(0, 6, b, a, 443, 4000) {
+		t.Fatal("expected the same id for both directions of a tcp flow")
+	}
+	// echo request and reply
+	if CommunityID(0, 1, a, b, 8, 0) != CommunityID(0, 1, b, a, 0, 0) {
+		t.Fatal("expected the same id for icmp echo request and reply")
+	}
+	if CommunityID(0, 6, a, b, 4000, 443) == CommunityID(1, 6, a, b, 4000, 443) {
+		t.Fatal("expected a different id for a different seed")
+	}
+}
@@ -0,0 +1,424 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package yara
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// node is a compiled part of a condition.
+// booleans are represented as 1 and 0.
+type node func(s *scanState) int64
+
+func boolean(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// condParser is a recursive descent parser for rule conditions.
+type condParser struct {
+	toks []string
+	pos  int
+	rule *Rule
+}
+
+func compileCondition(src string, r *Rule) (node, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, errors.New("empty condition")
+	}
+	c := &condParser{toks: toks, rule: r}
+	n, err := c.or()
+	if err != nil {
+		return nil, err
+	}
+	if c.pos != len(c.toks) {
+		return nil, errors.Errorf("unexpected %q in condition", c.toks[c.pos])
+	}
+	return n, nil
+}
+
+// tokenize splits a condition into identifiers, numbers, string references and operators.
+func tokenize(src string) (toks []string, err error) {
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return toks, nil
+			}
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment in condition")
+			}
+			i += end + 2
+		case isAlnum(c) || c == '_' || c == '$' || c == '#' || c == '@':
+			start := i
+			i++
+			for i < len(src) && (isAlnum(src[i]) || src[i] == '_') {
+				i++
+			}
+			// wildcard for string sets
+			if c == '$' && i < len(src) && src[i] == '*' {
+				i++
+			}
+			toks = append(toks, src[start:i])
+		case strings.HasPrefix(src[i:], ".."), strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="),
+			strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="):
+			toks = append(toks, src[i:i+2])
+			i += 2
+		case strings.IndexByte("()[],<>", c) >= 0:
+			toks = append(toks, src[i:i+1])
+			i++
+		default:
+			return nil, errors.Errorf("unsupported character %q in condition", c)
+		}
+	}
+	return toks, nil
+}
+
+func (c *condParser) peek() string {
+	if c.pos < len(c.toks) {
+		return c.toks[c.pos]
+	}
+	return ""
+}
+
+func (c *condParser) next() string {
+	t := c.peek()
+	c.pos++
+	return t
+}
+
+func (c *condParser) expect(tok string) error {
+	if t := c.next(); t != tok {
+		return errors.Errorf("expected %q, got %q", tok, t)
+	}
+	return nil
+}
+
+func (c *condParser) or() (node, error) {
+	left, err := c.and()
+	if err != nil {
+		return nil, err
+	}
+	for c.peek() == "or" {
+		c.pos++
+		right, err := c.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s *scanState) int64 {
+			return boolean(l(s) != 0 || right(s) != 0)
+		}
+	}
+	return left, nil
+}
+
+func (c *condParser) and() (node, error) {
+	left, err := c.not()
+	if err != nil {
+		return nil, err
+	}
+	for c.peek() == "and" {
+		c.pos++
+		right, err := c.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s *scanState) int64 {
+			return boolean(l(s) != 0 && right(s) != 0)
+		}
+	}
+	return left, nil
+}
+
+func (c *condParser) not() (node, error) {
+	if c.peek() == "not" {
+		c.pos++
+		n, err := c.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(s *scanState) int64 {
+			return boolean(n(s) == 0)
+		}, nil
+	}
+	return c.compare()
+}
+
+func (c *condParser) compare() (node, error) {
+	left, err := c.term()
+	if err != nil {
+		return nil, err
+	}
+	op := c.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		c.pos++
+	default:
+		return left, nil
+	}
+	right, err := c.term()
+	if err != nil {
+		return nil, err
+	}
+	return func(s *scanState) int64 {
+		a, b := left(s), right(s)
+		switch op {
+		case "==":
+			return boolean(a == b)
+		case "!=":
+			return boolean(a != b)
+		case "<":
+			return boolean(a < b)
+		case "<=":
+			return boolean(a <= b)
+		case ">":
+			return boolean(a > b)
+		default:
+			return boolean(a >= b)
+		}
+	}, nil
+}
+
+func (c *condParser) term() (node, error) {
+	tok := c.next()
+	switch {
+	case tok == "":
+		return nil, errors.New("unexpected end of condition")
+	case tok == "(":
+		n, err := c.or()
+		if err != nil {
+			return nil, err
+		}
+		return n, c.expect(")")
+	case tok == "true":
+		return func(*scanState) int64 { return 1 }, nil
+	case tok == "false":
+		return func(*scanState) int64 { return 0 }, nil
+	case tok == "filesize":
+		return func(s *scanState) int64 { return int64(len(s.data)) }, nil
+	case tok == "any" || tok == "all" || tok == "none":
+		return c.of(tok)
+	case tok[0] >= '0' && tok[0] <= '9':
+		n, err := parseNumber(tok)
+		if err != nil {
+			return nil, err
+		}
+		if c.peek() == "of" {
+			return c.of(tok)
+		}
+		return func(*scanState) int64 { return n }, nil
+	case tok[0] == '$':
+		return c.stringRef(tok)
+	case tok[0] == '#':
+		i, err := c.index("$" + tok[1:])
+		if err != nil {
+			return nil, err
+		}
+		return func(s *scanState) int64 { return int64(len(s.find(i))) }, nil
+	case tok[0] == '@':
+		i, err := c.index("$" + tok[1:])
+		if err != nil {
+			return nil, err
+		}
+		// @a is the offset of the first match, @a[n] of the nth match
+		n := int64(1)
+		if c.peek() == "[" {
+			c.pos++
+			if n, err = parseNumber(c.next()); err != nil {
+				return nil, err
+			}
+			if err := c.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+		return func(s *scanState) int64 {
+			offsets := s.find(i)
+			if n < 1 || int(n) > len(offsets) {
+				return -1
+			}
+			return int64(offsets[n-1])
+		}, nil
+	}
+	return nil, errors.Errorf("unsupported expression %q in condition", tok)
+}
+
+// stringRef compiles $a, $a at N and $a in (N..M).
+func (c *condParser) stringRef(id string) (node, error) {
+	i, err := c.index(id)
+	if err != nil {
+		return nil, err
+	}
+	switch c.peek() {
+	case "at":
+		c.pos++
+		at, err := c.term()
+		if err != nil {
+			return nil, err
+		}
+		return func(s *scanState) int64 {
+			off := at(s)
+			for _, o := range s.find(i) {
+				if int64(o) == off {
+					return 1
+				}
+			}
+			return 0
+		}, nil
+	case "in":
+		c.pos++
+		if err := c.expect("("); err != nil {
+			return nil, err
+		}
+		from, err := c.term()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.expect(".."); err != nil {
+			return nil, err
+		}
+		to, err := c.term()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(s *scanState) int64 {
+			lower, upper := from(s), to(s)
+			for _, o := range s.find(i) {
+				if int64(o) >= lower && int64(o) <= upper {
+					return 1
+				}
+			}
+			return 0
+		}, nil
+	}
+	return func(s *scanState) int64 {
+		return boolean(len(s.find(i)) > 0)
+	}, nil
+}
+
+// of compiles 'any of', 'all of', 'none of' and 'N of' expressions over a string set.
+func (c *condParser) of(quantifier string) (node, error) {
+	if err := c.expect("of"); err != nil {
+		return nil, err
+	}
+
+	var set []int
+	if c.peek() == "them" {
+		c.pos++
+		for i := range c.rule.strings {
+			set = append(set, i)
+		}
+	} else {
+		if err := c.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			id := c.next()
+			if !strings.HasPrefix(id, "$") {
+				return nil, errors.Errorf("expected string identifier in set, got %q", id)
+			}
+			indices := c.rule.lookup(id)
+			if len(indices) == 0 {
+				return nil, errors.Errorf("undefined string %s", id)
+			}
+			set = append(set, indices...)
+			if c.peek() != "," {
+				break
+			}
+			c.pos++
+		}
+		if err := c.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(set) == 0 {
+		return nil, errors.New("empty string set")
+	}
+
+	var min int64
+	switch quantifier {
+	case "any":
+		min = 1
+	case "all":
+		min = int64(len(set))
+	case "none":
+		min = 0
+	default:
+		n, err := parseNumber(quantifier)
+		if err != nil {
+			return nil, err
+		}
+		min = n
+	}
+
+	return func(s *scanState) int64 {
+		var count int64
+		for _, i := range set {
+			if len(s.find(i)) > 0 {
+				count++
+			}
+		}
+		if quantifier == "none" {
+			return boolean(count == 0)
+		}
+		return boolean(count >= min)
+	}, nil
+}
+
+// index returns the index of the named string in the current rule.
+func (c *condParser) index(id string) (int, error) {
+	indices := c.rule.lookup(id)
+	if len(indices) != 1 {
+		return 0, errors.Errorf("undefined string %s", id)
+	}
+	return indices[0], nil
+}
+
+// parseNumber parses decimal and hexadecimal numbers with an optional KB or MB suffix.
+func parseNumber(tok string) (int64, error) {
+	mul := int64(1)
+	switch {
+	case strings.HasSuffix(tok, "KB"):
+		mul = 1024
+		tok = strings.TrimSuffix(tok, "KB")
+	case strings.HasSuffix(tok, "MB"):
+		mul = 1024 * 1024
+		tok = strings.TrimSuffix(tok, "MB")
+	}
+	n, err := strconv.ParseInt(tok, 0, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid number %q", tok)
+	}
+	return n * mul, nil
+}
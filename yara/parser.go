@@ -0,0 +1,417 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package yara
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parser reads rules from the source of a single file.
+type parser struct {
+	src  string
+	pos  int
+	file string
+	dir  string
+}
+
+func parse(src, file, dir string) (rules []*Rule, err error) {
+	p := &parser{src: src, file: file, dir: dir}
+
+	for {
+		p.skip()
+		if p.eof() {
+			return rules, nil
+		}
+
+		var (
+			word    = p.ident()
+			private bool
+			global  bool
+		)
+		switch word {
+		case "import":
+			mod, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			return nil, p.errorf("module %s is not supported", mod)
+		case "include":
+			path, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			if !filepath.IsAbs(string(path)) {
+				path = []byte(filepath.Join(p.dir, string(path)))
+			}
+			included, err := parseFile(string(path))
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, included...)
+			continue
+		}
+
+		// rule modifiers
+		for word == "private" || word == "global" {
+			if word == "private" {
+				private = true
+			} else {
+				global = true
+			}
+			p.skip()
+			word = p.ident()
+		}
+		if word != "rule" {
+			return nil, p.errorf("expected rule, got %q", word)
+		}
+
+		r, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		r.Private = private
+		r.Global = global
+		rules = append(rules, r)
+	}
+}
+
+func (p *parser) rule() (*Rule, error) {
+	p.skip()
+	r := &Rule{
+		Name: p.ident(),
+		Meta: make(map[string]string),
+	}
+	if r.Name == "" {
+		return nil, p.errorf("missing rule name")
+	}
+
+	// tags
+	p.skip()
+	if p.peek() == ':' {
+		p.pos++
+		for {
+			p.skip()
+			tag := p.ident()
+			if tag == "" {
+				break
+			}
+			r.Tags = append(r.Tags, tag)
+		}
+	}
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skip()
+		section := p.ident()
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		switch section {
+		case "meta":
+			if err := p.meta(r); err != nil {
+				return nil, err
+			}
+		case "strings":
+			if err := p.strings(r); err != nil {
+				return nil, err
+			}
+		case "condition":
+			// the condition ends with the closing brace of the rule
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if end < 0 {
+				return nil, p.errorf("missing closing brace for rule %s", r.Name)
+			}
+			cond, err := compileCondition(p.src[p.pos:p.pos+end], r)
+			if err != nil {
+				return nil, p.errorf("rule %s: %s", r.Name, err)
+			}
+			r.cond = cond
+			p.pos += end + 1
+			return r, nil
+		default:
+			return nil, p.errorf("unknown section %q in rule %s", section, r.Name)
+		}
+	}
+}
+
+func (p *parser) meta(r *Rule) error {
+	for {
+		p.skip()
+		if p.sectionFollows() {
+			return nil
+		}
+		key := p.ident()
+		if key == "" {
+			return p.errorf("invalid meta key in rule %s", r.Name)
+		}
+		if err := p.expect('='); err != nil {
+			return err
+		}
+		p.skip()
+		if p.peek() == '"' {
+			val, err := p.str()
+			if err != nil {
+				return err
+			}
+			r.Meta[key] = string(val)
+			continue
+		}
+		// numbers and booleans
+		start := p.pos
+		for !p.eof() && (isAlnum(p.peek()) || p.peek() == '-') {
+			p.pos++
+		}
+		if start == p.pos {
+			return p.errorf("invalid meta value for %s in rule %s", key, r.Name)
+		}
+		r.Meta[key] = p.src[start:p.pos]
+	}
+}
+
+func (p *parser) strings(r *Rule) error {
+	for {
+		p.skip()
+		if p.peek() != '$' {
+			return nil
+		}
+		p.pos++
+		id := "$" + p.ident()
+		if err := p.expect('='); err != nil {
+			return err
+		}
+		p.skip()
+
+		var (
+			kind  = p.peek()
+			text  []byte
+			expr  string
+			flags string
+			err   error
+		)
+		switch kind {
+		case '"':
+			text, err = p.str()
+		case '{':
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if end < 0 {
+				return p.errorf("unterminated hex string %s", id)
+			}
+			expr = p.src[p.pos+1 : p.pos+end]
+			p.pos += end + 1
+		case '/':
+			expr, flags, err = p.regex()
+		default:
+			return p.errorf("invalid value for string %s", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		// modifiers
+		var modifiers []string
+		for {
+			p.skip()
+			if p.peek() == '$' || p.sectionFollows() {
+				break
+			}
+			m := p.ident()
+			if m == "" {
+				return p.errorf("invalid modifier for string %s", id)
+			}
+			modifiers = append(modifiers, m)
+		}
+
+		var pat *pattern
+		switch kind {
+		case '"':
+			pat, err = newTextPattern(id, text, modifiers)
+		case '{':
+			pat = &pattern{id: id, kind: kindHex}
+			if len(modifiers) > 0 && !(len(modifiers) == 1 && modifiers[0] == "private") {
+				err = errors.Errorf("modifiers are not allowed for hex string %s", id)
+			} else {
+				pat.hex, err = parseHex(expr)
+			}
+		case '/':
+			pat, err = newRegexPattern(id, expr, flags, modifiers)
+		}
+		if err != nil {
+			return p.errorf("rule %s: %s", r.Name, err)
+		}
+		if id == "$" {
+			pat.anonymous = true
+		} else if r.lookup(id) != nil {
+			return p.errorf("duplicate string %s in rule %s", id, r.Name)
+		}
+		r.strings = append(r.strings, pat)
+	}
+}
+
+// sectionFollows checks if the next token is a section label or the end of the rule.
+func (p *parser) sectionFollows() bool {
+	if p.peek() == '}' {
+		return true
+	}
+	start := p.pos
+	defer func() {
+		p.pos = start
+	}()
+	word := p.ident()
+	p.skip()
+	return (word == "meta" || word == "strings" || word == "condition") && p.peek() == ':'
+}
+
+// str reads a double quoted string and resolves escape sequences.
+func (p *parser) str() ([]byte, error) {
+	p.skip()
+	if p.peek() != '"' {
+		return nil, p.errorf("expected string")
+	}
+	p.pos++
+	var out []byte
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return out, nil
+		case '\\':
+			if p.eof() {
+				break
+			}
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 't':
+				out = append(out, '\t')
+			case 'r':
+				out = append(out, '\r')
+			case 'x':
+				if p.pos+2 > len(p.src) {
+					return nil, p.errorf("invalid escape sequence")
+				}
+				v, err := strconv.ParseUint(p.src[p.pos:p.pos+2], 16, 8)
+				if err != nil {
+					return nil, p.errorf("invalid escape sequence \\x%s", p.src[p.pos:p.pos+2])
+				}
+				out = append(out, byte(v))
+				p.pos += 2
+			default:
+				out = append(out, e)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// regex reads a regular expression between slashes, followed by optional flags.
+func (p *parser) regex() (expr, flags string, err error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() {
+		switch p.src[p.pos] {
+		case '\\':
+			// escaped slashes do not end the expression
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] == '/' {
+				expr += p.src[start:p.pos] + "/"
+				p.pos += 2
+				start = p.pos
+				continue
+			}
+			p.pos += 2
+			continue
+		case '/':
+			expr += p.src[start:p.pos]
+			p.pos++
+			for !p.eof() && (p.peek() == 'i' || p.peek() == 's') {
+				flags += string(p.peek())
+				p.pos++
+			}
+			return expr, flags, nil
+		case '\n':
+			return "", "", p.errorf("unterminated regular expression")
+		}
+		p.pos++
+	}
+	return "", "", p.errorf("unterminated regular expression")
+}
+
+// skip advances over whitespace and comments.
+func (p *parser) skip() {
+	for !p.eof() {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 1
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos:], "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 2
+		case p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\r' || p.src[p.pos] == '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for !p.eof() && (isAlnum(p.peek()) || p.peek() == '_') {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) expect(c byte) error {
+	p.skip()
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return errors.Errorf("%s:%d: %s", p.file, line, errors.Errorf(format, args...))
+}
@@ -0,0 +1,344 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package yara
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type patternKind int
+
+const (
+	kindText patternKind = iota
+	kindHex
+	kindRegex
+)
+
+// pattern is a compiled string from the strings section of a rule.
+type pattern struct {
+	id        string
+	anonymous bool
+	kind      patternKind
+
+	// text
+	variants [][]byte
+	nocase   bool
+	fullword bool
+
+	// hex
+	hex []hexToken
+
+	// regex
+	re *regexp.Regexp
+}
+
+// find returns the offsets of all matches in the scanned data.
+func (p *pattern) find(s *scanState) (offsets []int) {
+	switch p.kind {
+	case kindText:
+		data := s.data
+		if p.nocase {
+			data = s.lowered()
+		}
+		for _, v := range p.variants {
+			for pos := 0; pos < len(data) && len(offsets) < maxMatches; {
+				i := bytes.Index(data[pos:], v)
+				if i < 0 {
+					break
+				}
+				off := pos + i
+				if !p.fullword || isFullword(data, off, len(v)) {
+					offsets = append(offsets, off)
+				}
+				pos = off + 1
+			}
+		}
+	case kindHex:
+		for i := 0; i < len(s.data) && len(offsets) < maxMatches; i++ {
+			// fast path: skip ahead to the first fixed byte
+			if first := p.hex[0]; first.kind == hexByte && first.mask == 0xff {
+				n := bytes.IndexByte(s.data[i:], first.value)
+				if n < 0 {
+					break
+				}
+				i += n
+			}
+			if matchHex(p.hex, s.data, i) >= 0 {
+				offsets = append(offsets, i)
+			}
+		}
+	case kindRegex:
+		for _, loc := range p.re.FindAllIndex(s.data, maxMatches) {
+			offsets = append(offsets, loc[0])
+		}
+	}
+	return offsets
+}
+
+func isAlnum(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// isFullword checks if the match at off is delimited by non alphanumeric characters.
+func isFullword(data []byte, off, length int) bool {
+	if off > 0 && isAlnum(data[off-1]) {
+		return false
+	}
+	if end := off + length; end < len(data) && isAlnum(data[end]) {
+		return false
+	}
+	return true
+}
+
+// newTextPattern creates a text pattern and applies the modifiers.
+func newTextPattern(id string, text []byte, modifiers []string) (*pattern, error) {
+	var (
+		p          = &pattern{id: id, kind: kindText}
+		wide       bool
+		ascii      bool
+		lowered    = text
+		isNoCase   bool
+		isFullword bool
+	)
+	for _, m := range modifiers {
+		switch m {
+		case "nocase":
+			isNoCase = true
+		case "wide":
+			wide = true
+		case "ascii":
+			ascii = true
+		case "fullword":
+			isFullword = true
+		case "private":
+		default:
+			return nil, errors.Errorf("unsupported modifier %s for string %s", m, id)
+		}
+	}
+	if isNoCase {
+		lowered = make([]byte, len(text))
+		for i, b := range text {
+			lowered[i] = toLower(b)
+		}
+	}
+	if ascii || !wide {
+		p.variants = append(p.variants, lowered)
+	}
+	if wide {
+		w := make([]byte, 0, len(lowered)*2)
+		for _, b := range lowered {
+			w = append(w, b, 0x00)
+		}
+		p.variants = append(p.variants, w)
+	}
+	p.nocase = isNoCase
+	p.fullword = isFullword
+	return p, nil
+}
+
+// newRegexPattern compiles a regular expression pattern.
+// Go regular expressions operate on UTF-8,
+// escapes for bytes above 0x7f therefore match the encoded code point.
+func newRegexPattern(id, expr, flags string, modifiers []string) (*pattern, error) {
+	for _, m := range modifiers {
+		switch m {
+		case "nocase":
+			flags += "i"
+		case "ascii", "fullword", "private":
+		default:
+			return nil, errors.Errorf("unsupported modifier %s for regex %s", m, id)
+		}
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid regex "+id)
+	}
+	return &pattern{id: id, kind: kindRegex, re: re}, nil
+}
+
+/*
+ * Hex strings
+ */
+
+type hexKind int
+
+const (
+	hexByte hexKind = iota
+	hexJump
+	hexAlt
+)
+
+type hexToken struct {
+	kind hexKind
+
+	// byte with nibble mask for wildcards
+	value byte
+	mask  byte
+
+	// jump range, max is -1 for unbounded jumps
+	min int
+	max int
+
+	// alternatives
+	alts [][]hexToken
+}
+
+// matchHex tries to match toks at pos and returns the end offset or -1.
+func matchHex(toks []hexToken, data []byte, pos int) int {
+	if len(toks) == 0 {
+		return pos
+	}
+	t := toks[0]
+	switch t.kind {
+	case hexByte:
+		if pos >= len(data) || data[pos]&t.mask != t.value {
+			return -1
+		}
+		return matchHex(toks[1:], data, pos+1)
+	case hexJump:
+		max := t.max
+		if max < 0 || pos+max > len(data) {
+			max = len(data) - pos
+		}
+		for n := t.min; n <= max; n++ {
+			if end := matchHex(toks[1:], data, pos+n); end >= 0 {
+				return end
+			}
+		}
+	case hexAlt:
+		for _, alt := range t.alts {
+			seq := make([]hexToken, 0, len(alt)+len(toks)-1)
+			seq = append(seq, alt...)
+			seq = append(seq, toks[1:]...)
+			if end := matchHex(seq, data, pos); end >= 0 {
+				return end
+			}
+		}
+	}
+	return -1
+}
+
+// parseHex parses the contents of a hex string, without the curly braces.
+func parseHex(s string) ([]hexToken, error) {
+	toks, rest, err := parseHexSeq(strings.TrimSpace(s), false)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, errors.Errorf("unexpected %q in hex string", rest)
+	}
+	if len(toks) == 0 || toks[0].kind == hexJump || toks[len(toks)-1].kind == hexJump {
+		return nil, errors.New("hex string must not be empty or start or end with a jump")
+	}
+	return toks, nil
+}
+
+// parseHexSeq parses tokens until the end of input or, inside alternatives, until | or ).
+func parseHexSeq(s string, inAlt bool) (toks []hexToken, rest string, err error) {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return toks, s, nil
+		}
+		switch s[0] {
+		case '|', ')':
+			if !inAlt {
+				return nil, s, errors.Errorf("unexpected %c in hex string", s[0])
+			}
+			return toks, s, nil
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, s, errors.New("unterminated jump in hex string")
+			}
+			t, err := parseJump(s[1:end])
+			if err != nil {
+				return nil, s, err
+			}
+			toks = append(toks, t)
+			s = s[end+1:]
+		case '(':
+			t := hexToken{kind: hexAlt}
+			s = s[1:]
+			for {
+				alt, r, err := parseHexSeq(s, true)
+				if err != nil {
+					return nil, r, err
+				}
+				if r == "" {
+					return nil, r, errors.New("unterminated alternative in hex string")
+				}
+				t.alts = append(t.alts, alt)
+				s = r[1:]
+				if r[0] == ')' {
+					break
+				}
+			}
+			toks = append(toks, t)
+		default:
+			if len(s) < 2 {
+				return nil, s, errors.Errorf("invalid byte %q in hex string", s)
+			}
+			t := hexToken{kind: hexByte}
+			for i := 0; i < 2; i++ {
+				shift := uint(4 - 4*i)
+				if s[i] == '?' {
+					continue
+				}
+				v, err := strconv.ParseUint(s[i:i+1], 16, 8)
+				if err != nil {
+					return nil, s, errors.Errorf("invalid byte %q in hex string", s[:2])
+				}
+				t.value |= byte(v) << shift
+				t.mask |= 0xf << shift
+			}
+			toks = append(toks, t)
+			s = s[2:]
+		}
+	}
+}
+
+// parseJump parses the contents of a jump: [n], [n-m], [n-] or [-].
+func parseJump(s string) (t hexToken, err error) {
+	t.kind = hexJump
+	s = strings.Replace(s, " ", "", -1)
+	parts := strings.Split(s, "-")
+	if len(parts) > 2 {
+		return t, errors.Errorf("invalid jump [%s]", s)
+	}
+	if parts[0] != "" {
+		if t.min, err = strconv.Atoi(parts[0]); err != nil {
+			return t, errors.Errorf("invalid jump [%s]", s)
+		}
+	}
+	switch {
+	case len(parts) == 1:
+		t.max = t.min
+	case parts[1] == "":
+		t.max = -1
+	default:
+		if t.max, err = strconv.Atoi(parts[1]); err != nil || t.max < t.min {
+			return t, errors.Errorf("invalid jump [%s]", s)
+		}
+	}
+	return t, nil
+}
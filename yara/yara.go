@@ -0,0 +1,242 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package yara implements a pure Go matcher for a subset of the YARA rule language.
+// It is used to scan reassembled streams and payloads without depending on libyara.
+//
+// Supported are text strings (nocase, wide, ascii, fullword), hex strings
+// (wildcards, jumps and alternatives) and regular expressions (i, s),
+// as well as conditions made of boolean operators, string references,
+// match counts (#a), offsets (@a[i]), 'at', 'in', 'filesize' and 'N of' expressions.
+// Modules and external variables are not supported.
+package yara
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxMatches limits the number of offsets collected per string and scan.
+const maxMatches = 1000
+
+// Rule is a compiled YARA rule.
+type Rule struct {
+	Name    string
+	Tags    []string
+	Meta    map[string]string
+	Private bool
+	Global  bool
+
+	strings []*pattern
+	cond    node
+}
+
+// Rules is a set of compiled YARA rules.
+type Rules struct {
+	rules []*Rule
+}
+
+// Match describes a rule that matched the scanned data.
+type Match struct {
+	Rule    string
+	Tags    []string
+	Meta    map[string]string
+	Strings []string // identifiers of the strings that matched
+}
+
+// Compile parses the rules contained in src.
+// Includes are resolved relative to the current working directory.
+func Compile(src string) (*Rules, error) {
+	rules, err := parse(src, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{rules: rules}, nil
+}
+
+// LoadFile parses the rules contained in the file at path.
+func LoadFile(path string) (*Rules, error) {
+	rules, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{rules: rules}, nil
+}
+
+// LoadDir parses all files with a .yar or .yara extension in the directory at path.
+// If path points to a file, only this file will be loaded.
+func LoadDir(path string) (*Rules, error) {
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return LoadFile(path)
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		r     = &Rules{}
+		names = make(map[string]string)
+	)
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yar" && ext != ".yara") {
+			continue
+		}
+		file := filepath.Join(path, f.Name())
+		rules, err := parseFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if prev, ok := names[rule.Name]; ok {
+				return nil, errors.Errorf("duplicate rule %s in %s, first defined in %s", rule.Name, file, prev)
+			}
+			names[rule.Name] = file
+		}
+		r.rules = append(r.rules, rules...)
+	}
+	return r, nil
+}
+
+func parseFile(path string) ([]*Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(string(data), path, filepath.Dir(path))
+}
+
+// Len returns the number of rules.
+func (r *Rules) Len() int {
+	return len(r.rules)
+}
+
+// Names returns the sorted names of all rules.
+func (r *Rules) Names() []string {
+	var names []string
+	for _, rule := range r.rules {
+		names = append(names, rule.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scan evaluates all rules against data and returns the matches.
+// Private rules are evaluated but never reported.
+// If a global rule does not match, no matches will be returned.
+func (r *Rules) Scan(data []byte) (matches []Match) {
+
+	s := &scanState{
+		data: data,
+	}
+
+	for _, rule := range r.rules {
+		s.rule = rule
+		s.offsets = make([][]int, len(rule.strings))
+		s.done = make([]bool, len(rule.strings))
+
+		if rule.cond(s) == 0 {
+			if rule.Global {
+				return nil
+			}
+			continue
+		}
+		if rule.Private {
+			continue
+		}
+
+		m := Match{
+			Rule: rule.Name,
+			Tags: rule.Tags,
+			Meta: rule.Meta,
+		}
+		for i, p := range rule.strings {
+			if len(s.find(i)) > 0 && !p.anonymous {
+				m.Strings = append(m.Strings, p.id)
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	return matches
+}
+
+// scanState holds the lazily computed string matches for a single rule evaluation.
+type scanState struct {
+	data  []byte
+	lower []byte
+	rule  *Rule
+
+	offsets [][]int
+	done    []bool
+}
+
+// find returns the match offsets for the string at index i of the current rule.
+func (s *scanState) find(i int) []int {
+	if !s.done[i] {
+		s.offsets[i] = s.rule.strings[i].find(s)
+		s.done[i] = true
+	}
+	return s.offsets[i]
+}
+
+// lowered returns an ASCII lower case copy of the scanned data.
+// bytes.ToLower is not used, since it would change the length for invalid UTF-8.
+func (s *scanState) lowered() []byte {
+	if s.lower == nil {
+		s.lower = make([]byte, len(s.data))
+		for i, b := range s.data {
+			s.lower[i] = toLower(b)
+		}
+	}
+	return s.lower
+}
+
+func toLower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 32
+	}
+	return b
+}
+
+// lookup returns the indices of the strings matching the identifier.
+// a trailing * matches all identifiers with the given prefix.
+func (r *Rule) lookup(id string) (indices []int) {
+	if strings.HasSuffix(id, "*") {
+		prefix := strings.TrimSuffix(id, "*")
+		for i, p := range r.strings {
+			if strings.HasPrefix(p.id, prefix) {
+				indices = append(indices, i)
+			}
+		}
+		return indices
+	}
+	for i, p := range r.strings {
+		if p.id == id {
+			return []int{i}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package yara
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/dreadl0ck/gopacket/pcapgo"
+)
+
+const testRules = `
+rule Text : a b {
+    meta:
+        description = "text strings"
+        score = 10
+    strings:
+        $a = "Hello" nocase
+        $b = "wide" wide
+        $c = "word" fullword
+    condition:
+        $a and ($b or $c)
+}
+
+rule Hex {
+    strings:
+        $h = { 4D 5A ?? [1-3] (AA | BB CC) 0? }
+    condition:
+        $h at 0
+}
+
+rule Count {
+    strings:
+        $x = "x"
+        $re = /ab+c/i
+    condition:
+        #x >= 3 and @re[1] > 2 and 2 of them
+}
+
+private rule Hidden {
+    condition:
+        filesize < 1KB
+}
+`
+
+func scan(t *testing.T, r *Rules, data string) map[string]Match {
+	res := make(map[string]Match)
+	for _, m := range r.Scan([]byte(data)) {
+		res[m.Rule] = m
+	}
+	return res
+}
+
+func TestRules(t *testing.T) {
+	r, err := Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 4 {
+		t.Fatal("expected 4 rules, got", r.Len())
+	}
+
+	tests := []struct {
+		data  string
+		rules []string
+	}{
+		{"hELLo a word.", []string{"Text"}},
+		{"hello w\x00i\x00d\x00e\x00", []string{"Text"}},
+		{"hello words", nil},
+		{"MZ\x01\x02\xbb\xcc\x0f", []string{"Hex"}},
+		{"MZ\x01\x02\x03\x04\x05\xaa\x01", nil},
+		{" MZ\x01\x02\xaa\x01", nil},
+		{"xx ABBBC x", []string{"Count"}},
+		{"ABC xxx", nil},
+	}
+
+	for _, test := range tests {
+		res := scan(t, r, test.data)
+		if len(res) != len(test.rules) {
+			t.Fatalf("%q: expected %v, got %v", test.data, test.rules, res)
+		}
+		for _, name := range test.rules {
+			if _, ok := res[name]; !ok {
+				t.Fatalf("%q: expected match for %s, got %v", test.data, name, res)
+			}
+		}
+	}
+
+	m := scan(t, r, "hello word")["Text"]
+	if len(m.Tags) != 2 || m.Meta["description"] != "text strings" || m.Meta["score"] != "10" {
+		t.Fatal("unexpected tags or meta:", m)
+	}
+	if len(m.Strings) != 2 || m.Strings[0] != "$a" || m.Strings[1] != "$c" {
+		t.Fatal("unexpected strings:", m.Strings)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, src := range []string{
+		`rule a { condition: $a }`,
+		`rule a { strings: $a = "a" condition: $b }`,
+		`rule a { strings: $a = { 4D [2-1] 5A } condition: $a }`,
+		`rule a { strings: $a = "a" xor condition: $a }`,
+		`import "pe" rule a { condition: pe.is_dll() }`,
+		`rule a { condition: true`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Fatal("expected error for", src)
+		}
+	}
+}
+
+// TestPcap scans the TCP payloads of the test pcap with the bundled rules.
+func TestPcap(t *testing.T) {
+	r, err := LoadDir("rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open("testdata/eicar.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	reader, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := make(map[string]int)
+	for {
+		data, _, err := reader.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+		if tl := p.TransportLayer(); tl != nil {
+			for _, m := range r.Scan(tl.LayerPayload()) {
+				matches[m.Rule]++
+			}
+		}
+	}
+
+	if matches["EICAR_Test_File"] != 1 || matches["PowerShell_Download_Cradle"] != 1 || len(matches) != 2 {
+		t.Fatal("unexpected matches:", matches)
+	}
+}